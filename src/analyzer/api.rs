@@ -0,0 +1,961 @@
+//! Public API surface of the analyzed files and API diffs between revisions.
+//!
+//! Public items are recognized per language, best effort: `pub` items in
+//! Rust, exported declarations in JavaScript/TypeScript, names without a
+//! leading underscore in Python, `public`/`protected` members in Java and
+//! C++, capitalized names in Go and non-`static` functions in C. Members
+//! count only when their enclosing type is public as well. Each item keeps
+//! its declaration header as the signature, so any change to its parameters,
+//! type annotations or modifiers shows up in `code-analyzer api-diff`.
+
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, BTreeSet, HashMap};
+use std::path::PathBuf;
+use tree_sitter::Node;
+
+use super::language::SupportedLanguage;
+use super::parser::FileAnalysis;
+
+/// A public item and its signature
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct ApiItem {
+    /// Name qualified by the enclosing types (`Parser::parse`, `Parser.parse`)
+    pub name: String,
+    /// Kind of item: function, method, constructor, class, struct, ...
+    pub kind: String,
+    /// Declaration header without the body or initializer, whitespace collapsed
+    pub signature: String,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub parameters: Vec<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub return_type: Option<String>,
+    /// Line of the declaration (1-based)
+    pub line: usize,
+}
+
+/// Visibility of a declaration to code outside its module, file or type
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum Visibility {
+    /// Part of the public API
+    Public,
+    /// Visible to subclasses only (Java, C++ and TypeScript `protected`)
+    Protected,
+    Private,
+}
+
+impl Visibility {
+    /// Get the lowercase name of the visibility
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Visibility::Public => "public",
+            Visibility::Protected => "protected",
+            Visibility::Private => "private",
+        }
+    }
+}
+
+/// Public items of a set of analyzed files
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct ApiManifest {
+    /// Public items by file, in document order
+    pub files: BTreeMap<PathBuf, Vec<ApiItem>>,
+}
+
+/// A public item of a file, as listed in an API diff
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct ApiDiffEntry {
+    pub path: PathBuf,
+    #[serde(flatten)]
+    pub item: ApiItem,
+}
+
+/// A public item whose kind or signature differs between two revisions
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct ApiChange {
+    pub path: PathBuf,
+    pub before: ApiItem,
+    pub after: ApiItem,
+}
+
+/// Differences between the public items of two revisions
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct ApiDiff {
+    pub removed: Vec<ApiDiffEntry>,
+    pub added: Vec<ApiDiffEntry>,
+    pub changed: Vec<ApiChange>,
+}
+
+impl ApiDiff {
+    /// Check if consumers of the old API may break (items removed or changed)
+    pub fn is_breaking(&self) -> bool {
+        !self.removed.is_empty() || !self.changed.is_empty()
+    }
+
+    /// Check if the public API is the same in both revisions
+    pub fn is_empty(&self) -> bool {
+        self.removed.is_empty() && self.added.is_empty() && self.changed.is_empty()
+    }
+}
+
+impl ApiManifest {
+    /// Collect the public items of `files`
+    pub fn from_files(files: &[FileAnalysis]) -> Self {
+        let files = files
+            .iter()
+            .filter(|file| !file.api.is_empty())
+            .map(|file| (file.path.clone(), file.api.clone()))
+            .collect();
+        Self { files }
+    }
+
+    /// Total number of public items
+    pub fn item_count(&self) -> usize {
+        self.files.values().map(Vec::len).sum()
+    }
+
+    /// Compare against the manifest of a newer revision
+    ///
+    /// Items are matched by file and qualified name. Overloads sharing a name
+    /// are paired by identical signature first, then in document order.
+    pub fn diff(&self, newer: &ApiManifest) -> ApiDiff {
+        let mut diff = ApiDiff::default();
+        let empty = Vec::new();
+
+        let paths: BTreeSet<&PathBuf> = self.files.keys().chain(newer.files.keys()).collect();
+        for path in paths {
+            // (old items, new items) per name, in order of first appearance
+            let mut groups: Vec<(Vec<&ApiItem>, Vec<&ApiItem>)> = Vec::new();
+            let mut group_of: HashMap<&str, usize> = HashMap::new();
+            let old_items = self.files.get(path).unwrap_or(&empty);
+            let new_items = newer.files.get(path).unwrap_or(&empty);
+            for (item, is_new) in old_items
+                .iter()
+                .map(|item| (item, false))
+                .chain(new_items.iter().map(|item| (item, true)))
+            {
+                let index = *group_of.entry(item.name.as_str()).or_insert_with(|| {
+                    groups.push((Vec::new(), Vec::new()));
+                    groups.len() - 1
+                });
+                if is_new {
+                    groups[index].1.push(item);
+                } else {
+                    groups[index].0.push(item);
+                }
+            }
+
+            for (mut old, mut new) in groups {
+                old.retain(|item| match new.iter().position(|n| same_item(item, n)) {
+                    Some(index) => {
+                        new.remove(index);
+                        false
+                    }
+                    None => true,
+                });
+
+                let paired = old.len().min(new.len());
+                for (before, after) in old.iter().zip(&new) {
+                    diff.changed.push(ApiChange {
+                        path: path.clone(),
+                        before: (*before).clone(),
+                        after: (*after).clone(),
+                    });
+                }
+                let entry = |item: &&ApiItem| ApiDiffEntry {
+                    path: path.clone(),
+                    item: (*item).clone(),
+                };
+                diff.removed.extend(old[paired..].iter().map(entry));
+                diff.added.extend(new[paired..].iter().map(entry));
+            }
+        }
+
+        diff
+    }
+}
+
+/// Check if two items declare the same API (their position doesn't matter)
+fn same_item(a: &ApiItem, b: &ApiItem) -> bool {
+    a.kind == b.kind && a.signature == b.signature
+}
+
+/// Public items declared by an API node (see `NodeKindMapper::is_api_node`)
+///
+/// Most declarations yield one item; JavaScript variable declarations yield
+/// one per declarator and C/C++ declarations only count if they declare a
+/// function.
+pub fn public_items(language: SupportedLanguage, node: &Node, source: &[u8]) -> Vec<ApiItem> {
+    let declarations = match node.kind() {
+        "lexical_declaration" | "variable_declaration" => {
+            let mut cursor = node.walk();
+            let declarators: Vec<Node> = node
+                .named_children(&mut cursor)
+                .filter(|child| child.kind() == "variable_declarator")
+                .collect();
+            declarators
+        }
+        _ => vec![*node],
+    };
+
+    declarations
+        .into_iter()
+        .filter_map(|declaration| {
+            let name = qualified_name(language, &declaration, source)?;
+            Some(api_item(&declaration, name, source))
+        })
+        .collect()
+}
+
+/// Visibility of a declaration, by the same rules as the API manifest except
+/// that Rust items keep their declared visibility (`None` for declarations
+/// without one, such as Rust `impl` blocks and namespaces)
+pub fn visibility(language: SupportedLanguage, node: &Node, source: &[u8]) -> Option<Visibility> {
+    if matches!(node.kind(), "impl_item" | "namespace_definition") {
+        return None;
+    }
+    if language == SupportedLanguage::Rust {
+        // Declared rather than effective visibility: trait members and trait
+        // implementations are as visible as the trait
+        let in_trait = node
+            .parent()
+            .and_then(|list| list.parent())
+            .is_some_and(|p| {
+                p.kind() == "trait_item"
+                    || (p.kind() == "impl_item" && p.child_by_field_name("trait").is_some())
+            });
+        return Some(if in_trait || rust_is_pub(node, source) {
+            Visibility::Public
+        } else {
+            Visibility::Private
+        });
+    }
+    if qualified_name(language, node, source).is_none() {
+        return Some(Visibility::Private);
+    }
+
+    let protected = match language {
+        SupportedLanguage::Java => java_modifiers(node, source).contains(&"protected"),
+        SupportedLanguage::TypeScript | SupportedLanguage::Tsx => {
+            ts_accessibility(node, source) == Some("protected")
+        }
+        SupportedLanguage::Cpp => node
+            .parent()
+            .filter(|list| list.kind() == "field_declaration_list")
+            .is_some_and(|list| cpp_access(node, &list, source) == "protected"),
+        _ => false,
+    };
+    Some(if protected {
+        Visibility::Protected
+    } else {
+        Visibility::Public
+    })
+}
+
+fn api_item(node: &Node, name: String, source: &[u8]) -> ApiItem {
+    let function = function_node(node);
+    let parameters = function
+        .and_then(|f| parameter_list(&f))
+        .map(|list| {
+            if list.kind() == "identifier" {
+                // A single arrow function parameter without parentheses
+                return vec![collapse(text(&list, source))];
+            }
+            let mut cursor = list.walk();
+            let parameters: Vec<String> = list
+                .named_children(&mut cursor)
+                .filter(|param| !param.kind().contains("comment"))
+                .map(|param| collapse(text(&param, source)))
+                .collect();
+            parameters
+        })
+        .unwrap_or_default();
+    let return_type = function.and_then(|f| {
+        ["return_type", "result", "type"]
+            .iter()
+            .find_map(|field| f.child_by_field_name(field))
+            .map(|t| collapse(text(&t, source).trim_start_matches(':')))
+    });
+
+    ApiItem {
+        kind: symbol_kind(node).to_string(),
+        name,
+        signature: signature(node, source),
+        parameters,
+        return_type,
+        line: node.start_position().row + 1,
+    }
+}
+
+/// The function a declaration declares, if any (itself, or the function
+/// assigned to a JavaScript variable)
+fn function_node<'t>(node: &Node<'t>) -> Option<Node<'t>> {
+    match node.kind() {
+        "variable_declarator" => node
+            .child_by_field_name("value")
+            .filter(|value| is_function_value(value)),
+        "const_item" | "static_item" => None,
+        kind if is_type_kind(kind) => None,
+        _ => Some(*node),
+    }
+}
+
+fn is_function_value(node: &Node) -> bool {
+    matches!(
+        node.kind(),
+        "arrow_function" | "function_expression" | "function" | "generator_function"
+    )
+}
+
+fn is_type_kind(kind: &str) -> bool {
+    matches!(
+        kind,
+        "struct_item"
+            | "enum_item"
+            | "union_item"
+            | "trait_item"
+            | "type_item"
+            | "class_declaration"
+            | "abstract_class_declaration"
+            | "interface_declaration"
+            | "type_alias_declaration"
+            | "enum_declaration"
+            | "record_declaration"
+            | "class_definition"
+            | "class_specifier"
+            | "struct_specifier"
+            | "type_spec"
+    )
+}
+
+/// Parameter list of a function, found directly or through a C declarator
+fn parameter_list<'t>(function: &Node<'t>) -> Option<Node<'t>> {
+    function
+        .child_by_field_name("parameters")
+        .or_else(|| function.child_by_field_name("parameter"))
+        .or_else(|| function_declarator(function)?.child_by_field_name("parameters"))
+}
+
+/// The `function_declarator` of a C/C++ declaration (below pointer and reference declarators)
+fn function_declarator<'t>(node: &Node<'t>) -> Option<Node<'t>> {
+    let mut declarator = node.child_by_field_name("declarator")?;
+    loop {
+        if declarator.kind() == "function_declarator" {
+            return Some(declarator);
+        }
+        declarator = declarator.child_by_field_name("declarator")?;
+    }
+}
+
+/// Declared name of a function or type (C and C++ functions are named by
+/// their declarator, `Parser::parse` for out-of-line member definitions)
+pub fn declared_name(node: &Node, source: &[u8]) -> Option<String> {
+    if let Some(name) = field_text(node, "name", source) {
+        return Some(name.to_string());
+    }
+    let declarator = function_declarator(node)?.child_by_field_name("declarator")?;
+    Some(collapse(text(&declarator, source)))
+}
+
+/// Check if a type declaration is abstract: interfaces, traits, abstract classes,
+/// Python classes deriving from `ABC` or `Protocol` and C++ classes with pure
+/// virtual methods
+pub fn is_abstract_type(language: SupportedLanguage, node: &Node, source: &[u8]) -> bool {
+    match node.kind() {
+        "trait_item" | "interface_declaration" | "abstract_class_declaration" => true,
+        "type_spec" => node
+            .child_by_field_name("type")
+            .is_some_and(|t| t.kind() == "interface_type"),
+        "class_declaration" if language == SupportedLanguage::Java => {
+            java_modifiers(node, source).contains(&"abstract")
+        }
+        "class_definition" => field_text(node, "superclasses", source).is_some_and(|bases| {
+            bases
+                .split(|c: char| !c.is_alphanumeric() && c != '_')
+                .any(|word| matches!(word, "ABC" | "ABCMeta" | "Protocol"))
+        }),
+        "class_specifier" | "struct_specifier" => {
+            let Some(body) = node.child_by_field_name("body") else {
+                return false;
+            };
+            let mut cursor = body.walk();
+            let pure_virtual = body.named_children(&mut cursor).any(|member| {
+                let member = collapse(text(&member, source));
+                member.starts_with("virtual ") && member.replace(' ', "").ends_with("=0;")
+            });
+            pure_virtual
+        }
+        _ => false,
+    }
+}
+
+/// Normalized kind of a declaration (function, method, class, struct, impl, ...);
+/// functions declared inside a type are methods
+pub fn symbol_kind(node: &Node) -> &'static str {
+    match node.kind() {
+        "constructor_declaration" => "constructor",
+        "struct_item" | "struct_specifier" => "struct",
+        "enum_item" | "enum_declaration" | "enum_specifier" => "enum",
+        "union_item" | "union_specifier" => "union",
+        "impl_item" => "impl",
+        "mod_item" => "module",
+        "namespace_definition" => "namespace",
+        "trait_item" => "trait",
+        "interface_declaration" => "interface",
+        "record_declaration" => "record",
+        "type_item" | "type_alias_declaration" => "type",
+        "class_declaration"
+        | "class"
+        | "abstract_class_declaration"
+        | "class_definition"
+        | "class_specifier" => "class",
+        "type_spec" => match node.child_by_field_name("type").map(|t| t.kind()) {
+            Some("struct_type") => "struct",
+            Some("interface_type") => "interface",
+            _ => "type",
+        },
+        "const_item" => "constant",
+        "static_item" => "static",
+        "variable_declarator" => {
+            if function_node(node).is_some() {
+                "function"
+            } else {
+                "variable"
+            }
+        }
+        "method_declaration"
+        | "method_definition"
+        | "method_signature"
+        | "abstract_method_signature" => "method",
+        _ if is_member(node) => "method",
+        _ => "function",
+    }
+}
+
+/// Check if a declaration is directly inside a type (impl, trait, class or struct body)
+fn is_member(node: &Node) -> bool {
+    let mut parent = node.parent();
+    if parent.is_some_and(|p| p.kind() == "decorated_definition") {
+        parent = parent.and_then(|p| p.parent());
+    }
+    let Some(parent) = parent else {
+        return false;
+    };
+
+    let container = parent.parent().map(|p| p.kind()).unwrap_or_default();
+    match parent.kind() {
+        "field_declaration_list" | "class_body" | "interface_body" => true,
+        "declaration_list" => matches!(container, "impl_item" | "trait_item"),
+        "block" => container == "class_definition",
+        _ => false,
+    }
+}
+
+/// Declaration header: the node's text up to its body or initializer
+fn signature(node: &Node, source: &[u8]) -> String {
+    let cut = match node.kind() {
+        // Only the keyword of struct and interface types (`Point struct`)
+        "type_spec" => node
+            .child_by_field_name("type")
+            .filter(|t| matches!(t.kind(), "struct_type" | "interface_type"))
+            .map(|t| t.start_byte() + t.kind().trim_end_matches("_type").len()),
+        // The aliased type is part of a type alias' API
+        "type_alias_declaration" => None,
+        // Keep the parameters of functions assigned to variables
+        "variable_declarator" => node.child_by_field_name("value").map(|value| {
+            ["return_type", "parameters", "parameter"]
+                .iter()
+                .find_map(|field| value.child_by_field_name(field))
+                .filter(|_| is_function_value(&value))
+                .map_or(value.start_byte(), |header| header.end_byte())
+        }),
+        _ => ["body", "value"]
+            .iter()
+            .filter_map(|field| node.child_by_field_name(field))
+            .map(|child| child.start_byte())
+            .min(),
+    };
+    let end = cut.unwrap_or(node.end_byte()).min(source.len());
+    let header = String::from_utf8_lossy(&source[node.start_byte()..end]);
+
+    collapse(&header)
+        .trim_end_matches(|c: char| matches!(c, '{' | ':' | '=' | ';' | ' '))
+        .to_string()
+}
+
+/// Public name of a declaration qualified by its enclosing types, or `None`
+/// if it is not part of the public API
+fn qualified_name(language: SupportedLanguage, node: &Node, source: &[u8]) -> Option<String> {
+    match language {
+        SupportedLanguage::Rust => rust_name(node, source),
+        SupportedLanguage::JavaScript | SupportedLanguage::TypeScript | SupportedLanguage::Tsx => {
+            js_name(node, source)
+        }
+        SupportedLanguage::Python => python_name(node, source),
+        SupportedLanguage::Java => java_name(node, source),
+        SupportedLanguage::C | SupportedLanguage::Cpp => c_name(node, source),
+        SupportedLanguage::Go => go_name(node, source),
+    }
+}
+
+fn rust_name(node: &Node, source: &[u8]) -> Option<String> {
+    let name = field_text(node, "name", source)?;
+    let mut container = None;
+    let mut needs_pub = true;
+
+    let mut parent = node.parent();
+    while let Some(p) = parent {
+        match p.kind() {
+            "source_file" => break,
+            "declaration_list" => {}
+            "mod_item" if rust_is_pub(&p, source) => {}
+            "impl_item" => {
+                // Trait implementations are covered by the trait itself
+                if p.child_by_field_name("trait").is_some() {
+                    return None;
+                }
+                let implemented = field_text(&p, "type", source)?;
+                container.get_or_insert_with(|| {
+                    implemented
+                        .split('<')
+                        .next()
+                        .unwrap_or(implemented)
+                        .to_string()
+                });
+            }
+            "trait_item" if rust_is_pub(&p, source) => {
+                container.get_or_insert_with(|| {
+                    field_text(&p, "name", source)
+                        .unwrap_or_default()
+                        .to_string()
+                });
+                // Trait members are as public as the trait
+                needs_pub = false;
+            }
+            _ => return None,
+        }
+        parent = p.parent();
+    }
+
+    if needs_pub && !rust_is_pub(node, source) {
+        return None;
+    }
+    Some(match container {
+        Some(container) => format!("{container}::{name}"),
+        None => name.to_string(),
+    })
+}
+
+/// Check if a Rust item is `pub` (restricted visibility such as `pub(crate)` doesn't count)
+fn rust_is_pub(node: &Node, source: &[u8]) -> bool {
+    let mut cursor = node.walk();
+    let is_pub = node
+        .named_children(&mut cursor)
+        .any(|child| child.kind() == "visibility_modifier" && text(&child, source) == "pub");
+    is_pub
+}
+
+fn js_name(node: &Node, source: &[u8]) -> Option<String> {
+    match node.kind() {
+        "method_definition" | "method_signature" | "abstract_method_signature" => {
+            let name = node.child_by_field_name("name")?;
+            if name.kind() == "private_property_identifier" {
+                return None;
+            }
+            if ts_accessibility(node, source) == Some("private") {
+                return None;
+            }
+            let class = node.parent()?.parent()?;
+            Some(format!(
+                "{}.{}",
+                js_name(&class, source)?,
+                text(&name, source)
+            ))
+        }
+        // Variables are exported by their declaration (`export const a = 1, b = 2`)
+        "variable_declarator" => node
+            .parent()
+            .filter(js_is_exported)
+            .and_then(|_| field_text(node, "name", source))
+            .map(str::to_string),
+        _ if js_is_exported(node) => field_text(node, "name", source).map(str::to_string),
+        _ => None,
+    }
+}
+
+/// TypeScript accessibility modifier of a class member (`public`, `protected`, `private`)
+fn ts_accessibility<'s>(node: &Node, source: &'s [u8]) -> Option<&'s str> {
+    let mut cursor = node.walk();
+    let modifier = node
+        .children(&mut cursor)
+        .find(|child| child.kind() == "accessibility_modifier")
+        .map(|modifier| text(&modifier, source));
+    modifier
+}
+
+fn js_is_exported(node: &Node) -> bool {
+    node.parent()
+        .is_some_and(|parent| parent.kind() == "export_statement")
+}
+
+fn python_name(node: &Node, source: &[u8]) -> Option<String> {
+    let name = field_text(node, "name", source)?;
+    let dunder = name.starts_with("__") && name.ends_with("__");
+    if name.starts_with('_') && !dunder {
+        return None;
+    }
+
+    let mut parent = node.parent()?;
+    if parent.kind() == "decorated_definition" {
+        parent = parent.parent()?;
+    }
+    match parent.kind() {
+        "module" => Some(name.to_string()),
+        "block" => {
+            let class = parent.parent()?;
+            if class.kind() != "class_definition" {
+                return None;
+            }
+            Some(format!("{}.{name}", python_name(&class, source)?))
+        }
+        _ => None,
+    }
+}
+
+fn java_name(node: &Node, source: &[u8]) -> Option<String> {
+    let name = field_text(node, "name", source)?;
+    let modifiers = java_modifiers(node, source);
+    let public = modifiers.contains(&"public");
+
+    let parent = node.parent()?;
+    match parent.kind() {
+        "program" => public.then(|| name.to_string()),
+        "class_body" | "interface_body" | "enum_body_declarations" => {
+            let visible = if parent.kind() == "interface_body" {
+                !modifiers.contains(&"private")
+            } else {
+                public || modifiers.contains(&"protected")
+            };
+            if !visible {
+                return None;
+            }
+            let mut container = parent.parent()?;
+            if container.kind() == "enum_body" {
+                container = container.parent()?;
+            }
+            Some(format!("{}.{name}", java_name(&container, source)?))
+        }
+        _ => None,
+    }
+}
+
+/// Words of a Java declaration's modifiers (`public`, `static`, annotations, ...)
+fn java_modifiers<'s>(node: &Node, source: &'s [u8]) -> Vec<&'s str> {
+    let mut cursor = node.walk();
+    let modifiers = node
+        .children(&mut cursor)
+        .filter(|child| child.kind() == "modifiers")
+        .flat_map(|modifiers| text(&modifiers, source).split_whitespace())
+        .collect();
+    modifiers
+}
+
+fn go_name(node: &Node, source: &[u8]) -> Option<String> {
+    let name = field_text(node, "name", source)?;
+    if !is_capitalized(name) {
+        return None;
+    }
+
+    match node.kind() {
+        "method_declaration" => {
+            let receiver = node.child_by_field_name("receiver")?;
+            let mut cursor = receiver.walk();
+            let parameter = receiver.named_children(&mut cursor).next()?;
+            let receiver_type = field_text(&parameter, "type", source)?;
+            let receiver_type = receiver_type
+                .trim_start_matches('*')
+                .split('[')
+                .next()
+                .unwrap_or_default()
+                .trim();
+            is_capitalized(receiver_type).then(|| format!("{receiver_type}.{name}"))
+        }
+        "type_spec" => {
+            let top_level = node
+                .parent()
+                .and_then(|declaration| declaration.parent())
+                .is_some_and(|parent| parent.kind() == "source_file");
+            top_level.then(|| name.to_string())
+        }
+        _ => Some(name.to_string()),
+    }
+}
+
+fn is_capitalized(name: &str) -> bool {
+    name.chars().next().is_some_and(char::is_uppercase)
+}
+
+fn c_name(node: &Node, source: &[u8]) -> Option<String> {
+    let is_type = matches!(
+        node.kind(),
+        "class_specifier" | "struct_specifier" | "union_specifier" | "enum_specifier"
+    );
+    let name = if is_type {
+        // Only type definitions, not uses such as `struct point p;`
+        node.child_by_field_name("body")?;
+        field_text(node, "name", source)?.to_string()
+    } else {
+        // Out-of-line definitions of members (`void Parser::parse() {}`) are
+        // listed with the class declaring them, function pointers aren't functions
+        let declarator = function_declarator(node)?.child_by_field_name("declarator")?;
+        if !matches!(
+            declarator.kind(),
+            "identifier" | "field_identifier" | "destructor_name" | "operator_name"
+        ) {
+            return None;
+        }
+        text(&declarator, source).to_string()
+    };
+
+    let parent = node.parent()?;
+    if parent.kind() == "field_declaration_list" {
+        if !cpp_member_is_public(node, &parent, source) {
+            return None;
+        }
+        let class = parent.parent()?;
+        return Some(format!("{}::{name}", c_name(&class, source)?));
+    }
+    if is_type && parent.kind() == "field_declaration" {
+        // A nested type declared as `struct Inner { ... } inner;`
+        let list = parent.parent()?;
+        if list.kind() != "field_declaration_list" || !cpp_member_is_public(&parent, &list, source)
+        {
+            return None;
+        }
+        return Some(format!("{}::{name}", c_name(&list.parent()?, source)?));
+    }
+
+    let mut cursor = node.walk();
+    let is_static = node
+        .children(&mut cursor)
+        .any(|child| child.kind() == "storage_class_specifier" && text(&child, source) == "static");
+    if is_static {
+        return None;
+    }
+
+    // Namespaces qualify the name; anything else (function bodies) hides it
+    let mut namespaces = Vec::new();
+    let mut ancestor = Some(parent);
+    while let Some(a) = ancestor {
+        match a.kind() {
+            "translation_unit" => break,
+            "namespace_definition" => namespaces.push(field_text(&a, "name", source)?),
+            "declaration_list"
+            | "linkage_specification"
+            | "template_declaration"
+            | "declaration"
+            | "type_definition" => {}
+            kind if kind.starts_with("preproc_") => {}
+            _ => return None,
+        }
+        ancestor = a.parent();
+    }
+    namespaces.reverse();
+    namespaces.push(&name);
+    Some(namespaces.join("::"))
+}
+
+/// Check if a class member is in a `public` or `protected` section
+fn cpp_member_is_public(member: &Node, list: &Node, source: &[u8]) -> bool {
+    cpp_access(member, list, source) != "private"
+}
+
+/// Access of a class member: that of the closest access specifier before it
+/// (members before any are private in classes, public in structs and unions)
+fn cpp_access<'s>(member: &Node, list: &Node, source: &'s [u8]) -> &'s str {
+    let mut sibling = member.prev_named_sibling();
+    while let Some(s) = sibling {
+        if s.kind() == "access_specifier" {
+            return text(&s, source).trim_end_matches(':').trim();
+        }
+        sibling = s.prev_named_sibling();
+    }
+    let is_class = list
+        .parent()
+        .is_some_and(|class| class.kind() == "class_specifier");
+    if is_class {
+        "private"
+    } else {
+        "public"
+    }
+}
+
+fn field_text<'s>(node: &Node, field: &str, source: &'s [u8]) -> Option<&'s str> {
+    node.child_by_field_name(field)
+        .and_then(|child| child.utf8_text(source).ok())
+}
+
+fn text<'s>(node: &Node, source: &'s [u8]) -> &'s str {
+    node.utf8_text(source).unwrap_or_default()
+}
+
+fn collapse(text: &str) -> String {
+    text.split_whitespace().collect::<Vec<_>>().join(" ")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::analyzer::language::NodeKindMapper;
+
+    fn items(language: SupportedLanguage, source: &str) -> Vec<ApiItem> {
+        let mut parser = tree_sitter::Parser::new();
+        parser.set_language(&language.get_grammar()).unwrap();
+        let tree = parser.parse(source, None).unwrap();
+
+        let mut items = Vec::new();
+        let mut stack = vec![tree.root_node()];
+        while let Some(node) = stack.pop() {
+            if language.is_api_node(node.kind()) {
+                items.extend(public_items(language, &node, source.as_bytes()));
+            }
+            let mut cursor = node.walk();
+            let children: Vec<_> = node.children(&mut cursor).collect();
+            stack.extend(children.into_iter().rev());
+        }
+        items
+    }
+
+    fn names(items: &[ApiItem]) -> Vec<&str> {
+        items.iter().map(|item| item.name.as_str()).collect()
+    }
+
+    #[test]
+    fn test_rust_public_items() {
+        let source = "pub fn parse(input: &str, strict: bool) -> Result<Ast> {}\n\
+                      fn helper() {}\n\
+                      pub(crate) fn internal() {}\n\
+                      pub struct Parser<T> { inner: T }\n\
+                      impl<T> Parser<T> {\n    pub fn new(inner: T) -> Self {}\n    fn step(&self) {}\n}\n\
+                      impl Default for Parser<()> {\n    fn default() -> Self {}\n}\n\
+                      pub trait Visit {\n    fn visit(&mut self, node: Node);\n}\n\
+                      mod private {\n    pub fn hidden() {}\n}\n";
+        let items = items(SupportedLanguage::Rust, source);
+
+        assert_eq!(
+            names(&items),
+            vec!["parse", "Parser", "Parser::new", "Visit", "Visit::visit"]
+        );
+        assert_eq!(
+            items[0].signature,
+            "pub fn parse(input: &str, strict: bool) -> Result<Ast>"
+        );
+        assert_eq!(items[0].parameters, vec!["input: &str", "strict: bool"]);
+        assert_eq!(items[0].return_type.as_deref(), Some("Result<Ast>"));
+        assert_eq!(items[1].kind, "struct");
+        assert_eq!(items[1].signature, "pub struct Parser<T>");
+        assert_eq!(items[2].kind, "method");
+    }
+
+    #[test]
+    fn test_typescript_exports() {
+        let source = "export function load(path: string): Config {}\n\
+                      function local() {}\n\
+                      export const parse = (text: string) => text;\n\
+                      export class Loader {\n  read(n: number): void {}\n  private cache() {}\n}\n";
+        let items = items(SupportedLanguage::TypeScript, source);
+
+        assert_eq!(
+            names(&items),
+            vec!["load", "parse", "Loader", "Loader.read"]
+        );
+        assert_eq!(items[0].parameters, vec!["path: string"]);
+        assert_eq!(items[0].return_type.as_deref(), Some("Config"));
+        assert_eq!(items[1].kind, "function");
+        assert_eq!(items[1].signature, "parse = (text: string)");
+    }
+
+    #[test]
+    fn test_python_go_and_java_visibility() {
+        let python = "def run(a, b: int = 1) -> str:\n    pass\n\
+                      def _helper():\n    pass\n\
+                      class Client:\n    def __init__(self, url):\n        pass\n    def _retry(self):\n        pass\n";
+        assert_eq!(
+            names(&items(SupportedLanguage::Python, python)),
+            vec!["run", "Client", "Client.__init__"]
+        );
+
+        let go = "package p\n\nfunc Run(a int) error { return nil }\n\
+                  func helper() {}\n\
+                  type Server struct { addr string }\n\
+                  func (s *Server) Start() {}\n";
+        let go_items = items(SupportedLanguage::Go, go);
+        assert_eq!(names(&go_items), vec!["Run", "Server", "Server.Start"]);
+        assert_eq!(go_items[1].signature, "Server struct");
+
+        let java =
+            "public class Api {\n  public void open(String path) {}\n  void internal() {}\n}\n";
+        assert_eq!(
+            names(&items(SupportedLanguage::Java, java)),
+            vec!["Api", "Api.open"]
+        );
+    }
+
+    fn item(name: &str, signature: &str) -> ApiItem {
+        ApiItem {
+            name: name.to_string(),
+            kind: "function".to_string(),
+            signature: signature.to_string(),
+            parameters: Vec::new(),
+            return_type: None,
+            line: 1,
+        }
+    }
+
+    fn manifest(items: Vec<ApiItem>) -> ApiManifest {
+        ApiManifest {
+            files: BTreeMap::from([(PathBuf::from("src/lib.rs"), items)]),
+        }
+    }
+
+    #[test]
+    fn test_diff_reports_removed_added_and_changed() {
+        let before = manifest(vec![
+            item("keep", "pub fn keep()"),
+            item("gone", "pub fn gone()"),
+            item("resize", "pub fn resize(w: u32)"),
+        ]);
+        let mut moved = item("keep", "pub fn keep()");
+        moved.line = 40;
+        let after = manifest(vec![
+            moved,
+            item("resize", "pub fn resize(w: u32, h: u32)"),
+            item("fresh", "pub fn fresh()"),
+        ]);
+
+        let diff = before.diff(&after);
+        assert_eq!(diff.removed.len(), 1);
+        assert_eq!(diff.removed[0].item.name, "gone");
+        assert_eq!(diff.added.len(), 1);
+        assert_eq!(diff.added[0].item.name, "fresh");
+        assert_eq!(diff.changed.len(), 1);
+        assert_eq!(
+            diff.changed[0].after.signature,
+            "pub fn resize(w: u32, h: u32)"
+        );
+        assert!(diff.is_breaking());
+
+        assert!(before.diff(&before).is_empty());
+    }
+
+    #[test]
+    fn test_diff_pairs_overloads_by_signature() {
+        let before = manifest(vec![item("f", "void f(int a)"), item("f", "void f()")]);
+        let after = manifest(vec![item("f", "void f()")]);
+
+        let diff = before.diff(&after);
+        assert!(diff.changed.is_empty());
+        assert_eq!(diff.removed.len(), 1);
+        assert_eq!(diff.removed[0].item.signature, "void f(int a)");
+    }
+}
@@ -0,0 +1,310 @@
+//! Reading source archives (`.tar`, `.tar.gz`/`.tgz`, `.zip`) as analysis targets.
+//!
+//! Entries are streamed straight into memory, one at a time, and never
+//! extracted to disk. Entry paths are relative to the archive root.
+
+use flate2::read::GzDecoder;
+use std::fs::File;
+use std::io::{BufReader, Read};
+use std::path::{Component, Path, PathBuf};
+
+use crate::error::{AnalyzerError, Result};
+
+/// Supported archive formats
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ArchiveFormat {
+    Tar,
+    TarGz,
+    Zip,
+}
+
+impl ArchiveFormat {
+    /// Detect the archive format from the file name
+    pub fn detect(path: &Path) -> Option<Self> {
+        let name = path.file_name()?.to_str()?.to_ascii_lowercase();
+        if name.ends_with(".tar.gz") || name.ends_with(".tgz") {
+            Some(ArchiveFormat::TarGz)
+        } else if name.ends_with(".tar") {
+            Some(ArchiveFormat::Tar)
+        } else if name.ends_with(".zip") {
+            Some(ArchiveFormat::Zip)
+        } else {
+            None
+        }
+    }
+}
+
+/// Check if `path` is an archive file the analyzer can read
+pub fn is_archive(path: &Path) -> bool {
+    path.is_file() && ArchiveFormat::detect(path).is_some()
+}
+
+/// Kind of an archive entry
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum EntryKind {
+    File,
+    Directory,
+    /// Links and special files, which are never analyzed
+    Other,
+}
+
+/// What to do with an archive entry
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum EntryAction {
+    /// Read the contents of the (regular file) entry
+    Read,
+    Skip,
+    /// Stop reading the archive
+    Stop,
+}
+
+/// Visit the entries of an archive in archive order
+///
+/// `select(path, kind, size)` is asked for every entry before its contents
+/// are read; only regular files it selects are read into memory and passed
+/// to `on_file`. Entries with absolute paths or `..` components are skipped.
+pub fn for_each_entry<S, F>(archive: &Path, mut select: S, mut on_file: F) -> Result<()>
+where
+    S: FnMut(&Path, EntryKind, u64) -> EntryAction,
+    F: FnMut(PathBuf, Vec<u8>),
+{
+    let format = ArchiveFormat::detect(archive).ok_or_else(|| {
+        AnalyzerError::validation_error(format!(
+            "Unsupported archive format: {} (expected .tar, .tar.gz, .tgz or .zip)",
+            archive.display()
+        ))
+    })?;
+    let file = BufReader::new(File::open(archive)?);
+
+    match format {
+        ArchiveFormat::Tar => read_tar(archive, file, &mut select, &mut on_file),
+        ArchiveFormat::TarGz => read_tar(archive, GzDecoder::new(file), &mut select, &mut on_file),
+        ArchiveFormat::Zip => read_zip(archive, file, &mut select, &mut on_file),
+    }
+}
+
+fn read_tar<R, S, F>(archive: &Path, reader: R, select: &mut S, on_file: &mut F) -> Result<()>
+where
+    R: Read,
+    S: FnMut(&Path, EntryKind, u64) -> EntryAction,
+    F: FnMut(PathBuf, Vec<u8>),
+{
+    let mut tar = tar::Archive::new(reader);
+    let entries = tar.entries().map_err(|e| archive_error(archive, e))?;
+
+    for entry in entries {
+        let mut entry = entry.map_err(|e| archive_error(archive, e))?;
+        let path = entry.path().map_err(|e| archive_error(archive, e))?;
+        let Some(path) = enclosed_path(&path) else {
+            continue;
+        };
+
+        let entry_type = entry.header().entry_type();
+        let kind = if entry_type.is_file() {
+            EntryKind::File
+        } else if entry_type.is_dir() {
+            EntryKind::Directory
+        } else {
+            EntryKind::Other
+        };
+        match select(&path, kind, entry.size()) {
+            EntryAction::Read if kind == EntryKind::File => {}
+            EntryAction::Read | EntryAction::Skip => continue,
+            EntryAction::Stop => break,
+        }
+
+        let mut contents = Vec::with_capacity(entry.size() as usize);
+        entry
+            .read_to_end(&mut contents)
+            .map_err(|e| archive_error(archive, e))?;
+        on_file(path, contents);
+    }
+
+    Ok(())
+}
+
+fn read_zip<S, F>(
+    archive: &Path,
+    file: BufReader<File>,
+    select: &mut S,
+    on_file: &mut F,
+) -> Result<()>
+where
+    S: FnMut(&Path, EntryKind, u64) -> EntryAction,
+    F: FnMut(PathBuf, Vec<u8>),
+{
+    let mut zip = zip::ZipArchive::new(file).map_err(|e| archive_error(archive, e))?;
+
+    for index in 0..zip.len() {
+        let mut entry = zip.by_index(index).map_err(|e| archive_error(archive, e))?;
+        let Some(path) = entry.enclosed_name() else {
+            continue;
+        };
+
+        let kind = if entry.is_dir() {
+            EntryKind::Directory
+        } else if entry.is_file() && !entry.is_symlink() {
+            EntryKind::File
+        } else {
+            EntryKind::Other
+        };
+        match select(&path, kind, entry.size()) {
+            EntryAction::Read if kind == EntryKind::File => {}
+            EntryAction::Read | EntryAction::Skip => continue,
+            EntryAction::Stop => break,
+        }
+
+        let mut contents = Vec::with_capacity(entry.size() as usize);
+        entry
+            .read_to_end(&mut contents)
+            .map_err(|e| archive_error(archive, e))?;
+        on_file(path, contents);
+    }
+
+    Ok(())
+}
+
+/// Normalize an entry path, rejecting paths that would escape the archive root
+fn enclosed_path(path: &Path) -> Option<PathBuf> {
+    let mut enclosed = PathBuf::new();
+    for component in path.components() {
+        match component {
+            Component::Normal(part) => enclosed.push(part),
+            Component::CurDir => {}
+            Component::ParentDir | Component::RootDir | Component::Prefix(_) => return None,
+        }
+    }
+    (!enclosed.as_os_str().is_empty()).then_some(enclosed)
+}
+
+fn archive_error(archive: &Path, error: impl std::fmt::Display) -> AnalyzerError {
+    AnalyzerError::validation_error(format!(
+        "Cannot read archive {}: {error}",
+        archive.display()
+    ))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use flate2::write::GzEncoder;
+    use flate2::Compression;
+    use tempfile::TempDir;
+
+    fn write_tar_gz(path: &Path, files: &[(&str, &str)]) {
+        let encoder = GzEncoder::new(File::create(path).unwrap(), Compression::default());
+        let mut builder = tar::Builder::new(encoder);
+        for (name, contents) in files {
+            let mut header = tar::Header::new_gnu();
+            header.set_size(contents.len() as u64);
+            header.set_mode(0o644);
+            header.set_cksum();
+            builder
+                .append_data(&mut header, name, contents.as_bytes())
+                .unwrap();
+        }
+        builder.into_inner().unwrap().finish().unwrap();
+    }
+
+    #[test]
+    fn test_detect_format() {
+        assert_eq!(
+            ArchiveFormat::detect(Path::new("release-1.0.tar.gz")),
+            Some(ArchiveFormat::TarGz)
+        );
+        assert_eq!(
+            ArchiveFormat::detect(Path::new("drop.TGZ")),
+            Some(ArchiveFormat::TarGz)
+        );
+        assert_eq!(
+            ArchiveFormat::detect(Path::new("src.tar")),
+            Some(ArchiveFormat::Tar)
+        );
+        assert_eq!(
+            ArchiveFormat::detect(Path::new("code.zip")),
+            Some(ArchiveFormat::Zip)
+        );
+        assert_eq!(ArchiveFormat::detect(Path::new("main.rs")), None);
+    }
+
+    #[test]
+    fn test_enclosed_path() {
+        assert_eq!(
+            enclosed_path(Path::new("./pkg/src/lib.rs")),
+            Some(PathBuf::from("pkg/src/lib.rs"))
+        );
+        assert_eq!(enclosed_path(Path::new("../escape.rs")), None);
+        assert_eq!(enclosed_path(Path::new("/etc/passwd")), None);
+        assert_eq!(enclosed_path(Path::new("./")), None);
+    }
+
+    #[test]
+    fn test_tar_gz_entries_are_filtered_before_reading() {
+        let dir = TempDir::new().unwrap();
+        let archive = dir.path().join("drop.tgz");
+        write_tar_gz(
+            &archive,
+            &[
+                ("pkg/src/main.rs", "fn main() {}"),
+                ("pkg/README.md", "# Drop"),
+                ("pkg/tiny.rs", "fn t() {}"),
+            ],
+        );
+
+        let mut asked = Vec::new();
+        let mut read = Vec::new();
+        for_each_entry(
+            &archive,
+            |path, _, size| {
+                asked.push(path.to_path_buf());
+                if size < 12 && path.extension().is_some_and(|e| e == "rs") {
+                    EntryAction::Read
+                } else {
+                    EntryAction::Skip
+                }
+            },
+            |path, contents| read.push((path, String::from_utf8(contents).unwrap())),
+        )
+        .unwrap();
+
+        assert_eq!(asked.len(), 3);
+        assert_eq!(
+            read,
+            vec![(PathBuf::from("pkg/tiny.rs"), "fn t() {}".to_string())]
+        );
+    }
+
+    #[test]
+    fn test_stop_early() {
+        let dir = TempDir::new().unwrap();
+        let archive = dir.path().join("drop.tar.gz");
+        write_tar_gz(&archive, &[("a.rs", "fn a() {}"), ("b.rs", "fn b() {}")]);
+
+        let mut selected = 0;
+        let mut read = 0;
+        for_each_entry(
+            &archive,
+            |_, _, _| {
+                selected += 1;
+                if selected == 1 {
+                    EntryAction::Read
+                } else {
+                    EntryAction::Stop
+                }
+            },
+            |_, _| read += 1,
+        )
+        .unwrap();
+        assert_eq!((selected, read), (2, 1));
+    }
+
+    #[test]
+    fn test_corrupt_archive() {
+        let dir = TempDir::new().unwrap();
+        let archive = dir.path().join("broken.tgz");
+        std::fs::write(&archive, b"not a gzip stream").unwrap();
+
+        let err = for_each_entry(&archive, |_, _, _| EntryAction::Read, |_, _| {}).unwrap_err();
+        assert!(err.to_string().contains("Cannot read archive"));
+    }
+}
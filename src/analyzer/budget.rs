@@ -0,0 +1,193 @@
+//! Global resource caps for a single analysis run.
+//!
+//! `RunLimits` describes the caps; `RunBudget` tracks consumption across the
+//! walker and parser threads and records the first cap that was reached, so the
+//! run can stop early and still produce a (partial) report.
+
+use serde::{Deserialize, Serialize};
+use std::fmt;
+use std::sync::atomic::{AtomicU64, AtomicUsize, Ordering};
+use std::sync::OnceLock;
+use std::time::{Duration, Instant};
+
+/// Caps applied to a whole analysis run (None = unlimited)
+#[derive(Debug, Clone, Copy, Default, PartialEq)]
+pub struct RunLimits {
+    /// Maximum number of files to analyze
+    pub max_files: Option<usize>,
+    /// Maximum total size of analyzed files in bytes
+    pub max_total_bytes: Option<u64>,
+    /// Wall-clock budget for discovery and analysis
+    pub time_budget: Option<Duration>,
+}
+
+impl RunLimits {
+    /// Create run limits from CLI arguments
+    pub fn from_cli(args: &crate::cli::CliArgs) -> Self {
+        Self {
+            max_files: args.max_files,
+            max_total_bytes: args.max_total_mb.map(|mb| mb * 1024 * 1024),
+            time_budget: args.time_budget_secs.map(Duration::from_secs),
+        }
+    }
+}
+
+/// A run limit that can end an analysis early
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum RunLimit {
+    MaxFiles,
+    MaxTotalBytes,
+    TimeBudget,
+}
+
+/// Record of the limit that stopped a run, with its configured value
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+pub struct LimitReached {
+    pub limit: RunLimit,
+    /// Configured cap (files, bytes or seconds)
+    pub value: u64,
+}
+
+impl fmt::Display for LimitReached {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        match self.limit {
+            RunLimit::MaxFiles => write!(f, "file limit of {} files", self.value),
+            RunLimit::MaxTotalBytes => write!(f, "size limit of {} bytes", self.value),
+            RunLimit::TimeBudget => write!(f, "time budget of {} seconds", self.value),
+        }
+    }
+}
+
+/// Shared consumption tracker for `RunLimits`
+#[derive(Debug)]
+pub struct RunBudget {
+    limits: RunLimits,
+    started: Instant,
+    files: AtomicUsize,
+    bytes: AtomicU64,
+    reached: OnceLock<LimitReached>,
+}
+
+impl RunBudget {
+    /// Start tracking a run now
+    pub fn new(limits: RunLimits) -> Self {
+        Self {
+            limits,
+            started: Instant::now(),
+            files: AtomicUsize::new(0),
+            bytes: AtomicU64::new(0),
+            reached: OnceLock::new(),
+        }
+    }
+
+    /// Budget without any caps
+    pub fn unlimited() -> Self {
+        Self::new(RunLimits::default())
+    }
+
+    /// Account for a file of `size` bytes; false if it would exceed a cap
+    pub fn admit_file(&self, size: u64) -> bool {
+        if self.reached.get().is_some() || !self.within_time() {
+            return false;
+        }
+
+        if let Some(max_files) = self.limits.max_files {
+            if self.files.fetch_add(1, Ordering::Relaxed) >= max_files {
+                return self.stop(RunLimit::MaxFiles, max_files as u64);
+            }
+        }
+
+        if let Some(max_bytes) = self.limits.max_total_bytes {
+            let total = self.bytes.fetch_add(size, Ordering::Relaxed) + size;
+            if total > max_bytes {
+                return self.stop(RunLimit::MaxTotalBytes, max_bytes);
+            }
+        }
+
+        true
+    }
+
+    /// Check the wall-clock budget only; file and size caps stop discovery,
+    /// not the analysis of files already admitted
+    pub fn within_time(&self) -> bool {
+        match self.limits.time_budget {
+            Some(budget) if self.started.elapsed() >= budget => {
+                self.stop(RunLimit::TimeBudget, budget.as_secs())
+            }
+            _ => true,
+        }
+    }
+
+    /// The first limit that was reached, if any
+    pub fn reached(&self) -> Option<LimitReached> {
+        self.reached.get().copied()
+    }
+
+    fn stop(&self, limit: RunLimit, value: u64) -> bool {
+        // Only the first limit is recorded; later ones are a consequence
+        let _ = self.reached.set(LimitReached { limit, value });
+        false
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_unlimited_budget_admits_everything() {
+        let budget = RunBudget::unlimited();
+        assert!((0..1000).all(|_| budget.admit_file(u64::MAX / 2000)));
+        assert!(budget.reached().is_none());
+    }
+
+    #[test]
+    fn test_max_files() {
+        let budget = RunBudget::new(RunLimits {
+            max_files: Some(2),
+            ..Default::default()
+        });
+
+        assert!(budget.admit_file(10));
+        assert!(budget.admit_file(10));
+        assert!(!budget.admit_file(10));
+        assert_eq!(
+            budget.reached(),
+            Some(LimitReached {
+                limit: RunLimit::MaxFiles,
+                value: 2
+            })
+        );
+        // Once stopped, discovery stays stopped, but admitted files are still analyzed
+        assert!(!budget.admit_file(1));
+        assert!(budget.within_time());
+    }
+
+    #[test]
+    fn test_max_total_bytes() {
+        let budget = RunBudget::new(RunLimits {
+            max_total_bytes: Some(100),
+            ..Default::default()
+        });
+
+        assert!(budget.admit_file(60));
+        assert!(budget.admit_file(40));
+        assert!(!budget.admit_file(1));
+        assert_eq!(budget.reached().unwrap().limit, RunLimit::MaxTotalBytes);
+    }
+
+    #[test]
+    fn test_time_budget() {
+        let budget = RunBudget::new(RunLimits {
+            time_budget: Some(Duration::ZERO),
+            ..Default::default()
+        });
+
+        assert!(!budget.within_time());
+        assert!(!budget.admit_file(1));
+        let reached = budget.reached().unwrap();
+        assert_eq!(reached.limit, RunLimit::TimeBudget);
+        assert_eq!(reached.to_string(), "time budget of 0 seconds");
+    }
+}
@@ -0,0 +1,465 @@
+//! Function-level call graph of the analyzed files (`--call-graph`).
+//!
+//! Calls are resolved by name, best effort: functions of that name in the
+//! calling file win, then those in files the caller imports, then the only
+//! function of that name in the project. A call with a receiver or path
+//! qualifier (`self.0.fmt(f)`, `Self::new()`) never resolves to the calling
+//! function itself. Calls that stay ambiguous or leave the project (library and
+//! standard library calls) are not in the graph.
+
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeSet, HashMap, HashSet};
+use std::fmt::Write as _;
+use std::path::{Path, PathBuf};
+
+use super::imports::ImportResolver;
+use super::parser::FileAnalysis;
+use super::visitor::FunctionScope;
+
+/// A named function or method in the call graph
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct CallGraphNode {
+    /// Unique id: `path:line:name`
+    pub id: String,
+    pub path: PathBuf,
+    pub name: String,
+    pub start_line: usize,
+    pub cyclomatic_complexity: usize,
+    /// Number of distinct project functions calling this one
+    pub fan_in: usize,
+    /// Number of distinct project functions this one calls
+    pub fan_out: usize,
+    /// Part of a call cycle, or calls itself
+    pub recursive: bool,
+    /// Refactoring risk: cyclomatic complexity × fan-in
+    pub risk: usize,
+}
+
+/// A resolved call from one function to another
+#[derive(Debug, Clone, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+pub struct CallEdge {
+    pub caller: String,
+    pub callee: String,
+}
+
+/// Call graph of the named functions in a set of analyzed files
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct CallGraph {
+    pub functions: Vec<CallGraphNode>,
+    pub edges: Vec<CallEdge>,
+    /// Groups of functions that call each other (or themselves), by id
+    pub cycles: Vec<Vec<String>>,
+    /// Functions no other project function calls (entry points or dead code)
+    pub uncalled: Vec<String>,
+    /// Calls that matched no project function, or several without a tie-breaker
+    pub unresolved_calls: usize,
+}
+
+impl CallGraph {
+    /// Build the call graph of the named functions in `files`, analyzed under `root`
+    pub fn build(files: &[FileAnalysis], root: &Path) -> Self {
+        let mut nodes = Vec::new();
+        // (file index, scope) per node
+        let mut node_calls = Vec::new();
+        for (file_index, file) in files.iter().enumerate() {
+            for scope in &file.scopes {
+                let Some(ref name) = scope.name else {
+                    continue;
+                };
+                nodes.push(CallGraphNode {
+                    id: format!("{}:{}:{}", file.path.display(), scope.start_line, name),
+                    path: file.path.clone(),
+                    name: name.clone(),
+                    start_line: scope.start_line,
+                    cyclomatic_complexity: scope.cyclomatic_complexity,
+                    fan_in: 0,
+                    fan_out: 0,
+                    recursive: false,
+                    risk: 0,
+                });
+                node_calls.push((file_index, scope));
+            }
+        }
+
+        let mut by_name: HashMap<&str, Vec<usize>> = HashMap::new();
+        for (index, node) in nodes.iter().enumerate() {
+            by_name.entry(node.name.as_str()).or_default().push(index);
+        }
+        let imported = imported_files(files, root);
+
+        let mut unresolved_calls = 0;
+        let mut adjacency: Vec<BTreeSet<usize>> = vec![BTreeSet::new(); nodes.len()];
+        for (caller, (file_index, scope)) in node_calls.iter().enumerate() {
+            for callee_name in &scope.calls {
+                let candidates: Vec<usize> = by_name
+                    .get(callee_name.as_str())
+                    .into_iter()
+                    .flatten()
+                    .copied()
+                    .filter(|&c| c != caller || !is_qualified(scope, callee_name))
+                    .collect();
+                let callees = resolve(&candidates, &nodes, files, *file_index, &imported);
+                if callees.is_empty() {
+                    unresolved_calls += 1;
+                }
+                adjacency[caller].extend(callees);
+            }
+        }
+
+        for (caller, callees) in adjacency.iter().enumerate() {
+            nodes[caller].fan_out = callees.len();
+            for &callee in callees {
+                if callee != caller {
+                    nodes[callee].fan_in += 1;
+                }
+            }
+        }
+        // Recursive calls don't count towards fan-out either
+        for (caller, callees) in adjacency.iter().enumerate() {
+            if callees.contains(&caller) {
+                nodes[caller].fan_out -= 1;
+            }
+        }
+
+        let mut cycles: Vec<Vec<String>> = strongly_connected(&adjacency)
+            .into_iter()
+            .filter(|component| {
+                component.len() > 1 || adjacency[component[0]].contains(&component[0])
+            })
+            .map(|component| {
+                let mut ids: Vec<String> = component
+                    .into_iter()
+                    .map(|index| {
+                        nodes[index].recursive = true;
+                        nodes[index].id.clone()
+                    })
+                    .collect();
+                ids.sort();
+                ids
+            })
+            .collect();
+        cycles.sort();
+
+        for node in &mut nodes {
+            node.risk = node.cyclomatic_complexity * node.fan_in;
+        }
+
+        let edges = adjacency
+            .iter()
+            .enumerate()
+            .flat_map(|(caller, callees)| callees.iter().map(move |&callee| (caller, callee)))
+            .map(|(caller, callee)| CallEdge {
+                caller: nodes[caller].id.clone(),
+                callee: nodes[callee].id.clone(),
+            })
+            .collect();
+        let uncalled = nodes
+            .iter()
+            .filter(|node| node.fan_in == 0)
+            .map(|node| node.id.clone())
+            .collect();
+
+        Self {
+            functions: nodes,
+            edges,
+            cycles,
+            uncalled,
+            unresolved_calls,
+        }
+    }
+
+    /// Called functions with the highest risk (complexity × fan-in), riskiest first
+    pub fn hotspots(&self, limit: usize) -> Vec<&CallGraphNode> {
+        let mut hotspots: Vec<_> = self.functions.iter().filter(|f| f.risk > 0).collect();
+        hotspots.sort_by(|a, b| b.risk.cmp(&a.risk).then_with(|| a.id.cmp(&b.id)));
+        hotspots.truncate(limit);
+        hotspots
+    }
+
+    /// Render the graph in Graphviz DOT format (recursive functions in red)
+    pub fn to_dot(&self) -> String {
+        let mut dot = String::from("digraph calls {\n    rankdir=LR;\n    node [shape=box];\n");
+        for node in &self.functions {
+            let _ = writeln!(
+                dot,
+                "    \"{}\" [label=\"{}\\n{}:{}\\ncc={} fan-in={} fan-out={}\"{}];",
+                escape_dot(&node.id),
+                escape_dot(&node.name),
+                escape_dot(&node.path.display().to_string()),
+                node.start_line,
+                node.cyclomatic_complexity,
+                node.fan_in,
+                node.fan_out,
+                if node.recursive { ", color=red" } else { "" }
+            );
+        }
+        for edge in &self.edges {
+            let _ = writeln!(
+                dot,
+                "    \"{}\" -> \"{}\";",
+                escape_dot(&edge.caller),
+                escape_dot(&edge.callee)
+            );
+        }
+        dot.push_str("}\n");
+        dot
+    }
+}
+
+/// Pick the functions a call resolves to among the functions of that name
+fn resolve(
+    candidates: &[usize],
+    nodes: &[CallGraphNode],
+    files: &[FileAnalysis],
+    caller_file: usize,
+    imported: &[HashSet<usize>],
+) -> Vec<usize> {
+    let caller_path = &files[caller_file].path;
+    let same_file: Vec<usize> = candidates
+        .iter()
+        .copied()
+        .filter(|&c| nodes[c].path == *caller_path)
+        .collect();
+    if !same_file.is_empty() {
+        return same_file;
+    }
+
+    let from_imports: Vec<usize> = candidates
+        .iter()
+        .copied()
+        .filter(|&c| {
+            imported[caller_file]
+                .iter()
+                .any(|&file| files[file].path == nodes[c].path)
+        })
+        .collect();
+    if !from_imports.is_empty() {
+        return from_imports;
+    }
+
+    match candidates {
+        [only] => vec![*only],
+        _ => Vec::new(),
+    }
+}
+
+/// Whether `scope` only calls `name` through a receiver or path qualifier
+fn is_qualified(scope: &FunctionScope, name: &str) -> bool {
+    scope
+        .qualified_calls
+        .binary_search_by(|called| called.as_str().cmp(name))
+        .is_ok()
+}
+
+/// For every file, the other files its imports resolve to
+fn imported_files(files: &[FileAnalysis], root: &Path) -> Vec<HashSet<usize>> {
+    let resolver = ImportResolver::new(files, root);
+    files
+        .iter()
+        .enumerate()
+        .map(|(index, file)| {
+            file.imports
+                .iter()
+                .flat_map(|import| resolver.resolve(index, &import.statement))
+                .filter(|&other| other != index)
+                .collect()
+        })
+        .collect()
+}
+
+/// Strongly connected components (Tarjan), each in discovery order
+fn strongly_connected(adjacency: &[BTreeSet<usize>]) -> Vec<Vec<usize>> {
+    struct Tarjan<'a> {
+        adjacency: &'a [BTreeSet<usize>],
+        index: Vec<Option<usize>>,
+        low_link: Vec<usize>,
+        on_stack: Vec<bool>,
+        stack: Vec<usize>,
+        next_index: usize,
+        components: Vec<Vec<usize>>,
+    }
+
+    impl Tarjan<'_> {
+        fn visit(&mut self, root: usize) {
+            // Iterative DFS: (node, successors left to visit)
+            let mut work = vec![(root, self.adjacency[root].iter())];
+            self.open(root);
+            while let Some((node, successors)) = work.last_mut() {
+                let node = *node;
+                match successors.next() {
+                    Some(&next) if self.index[next].is_none() => {
+                        self.open(next);
+                        work.push((next, self.adjacency[next].iter()));
+                    }
+                    Some(&next) => {
+                        if self.on_stack[next] {
+                            self.low_link[node] =
+                                self.low_link[node].min(self.index[next].unwrap_or(usize::MAX));
+                        }
+                    }
+                    None => {
+                        work.pop();
+                        if let Some((parent, _)) = work.last() {
+                            self.low_link[*parent] =
+                                self.low_link[*parent].min(self.low_link[node]);
+                        }
+                        if Some(self.low_link[node]) == self.index[node] {
+                            self.close(node);
+                        }
+                    }
+                }
+            }
+        }
+
+        fn open(&mut self, node: usize) {
+            self.index[node] = Some(self.next_index);
+            self.low_link[node] = self.next_index;
+            self.next_index += 1;
+            self.stack.push(node);
+            self.on_stack[node] = true;
+        }
+
+        fn close(&mut self, root: usize) {
+            let mut component = Vec::new();
+            while let Some(node) = self.stack.pop() {
+                self.on_stack[node] = false;
+                component.push(node);
+                if node == root {
+                    break;
+                }
+            }
+            component.reverse();
+            self.components.push(component);
+        }
+    }
+
+    let mut tarjan = Tarjan {
+        adjacency,
+        index: vec![None; adjacency.len()],
+        low_link: vec![0; adjacency.len()],
+        on_stack: vec![false; adjacency.len()],
+        stack: Vec::new(),
+        next_index: 0,
+        components: Vec::new(),
+    };
+    for node in 0..adjacency.len() {
+        if tarjan.index[node].is_none() {
+            tarjan.visit(node);
+        }
+    }
+    tarjan.components
+}
+
+fn escape_dot(text: &str) -> String {
+    text.replace('\\', "\\\\").replace('"', "\\\"")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::analyzer::parser::fixtures::{file, scope};
+    use crate::analyzer::FunctionScope;
+
+    fn project() -> Vec<FileAnalysis> {
+        vec![
+            file("src/main.rs")
+                .with_imports(&["use crate::parser::parse;"])
+                .with_scopes(vec![scope("main", (1, 2), &["parse", "println", "new"])]),
+            file("src/parser.rs").with_scopes(vec![
+                FunctionScope {
+                    cyclomatic_complexity: 8,
+                    ..scope("parse", (1, 2), &["parse_expr", "new"])
+                },
+                FunctionScope {
+                    cyclomatic_complexity: 5,
+                    ..scope("parse_expr", (10, 11), &["parse_term"])
+                },
+                FunctionScope {
+                    cyclomatic_complexity: 3,
+                    ..scope("parse_term", (20, 21), &["parse_expr", "parse_term"])
+                },
+                scope("new", (30, 31), &[]),
+            ]),
+            file("src/util.rs").with_scopes(vec![scope("new", (1, 2), &[])]),
+        ]
+    }
+
+    fn node<'a>(graph: &'a CallGraph, id: &str) -> &'a CallGraphNode {
+        graph.functions.iter().find(|f| f.id == id).unwrap()
+    }
+
+    #[test]
+    fn test_resolution_prefers_same_file_then_imports() {
+        let graph = CallGraph::build(&project(), Path::new(""));
+
+        // `new` is ambiguous from main.rs (parser.rs is imported though)
+        assert!(graph.edges.contains(&CallEdge {
+            caller: "src/main.rs:1:main".to_string(),
+            callee: "src/parser.rs:30:new".to_string(),
+        }));
+        assert!(graph.edges.contains(&CallEdge {
+            caller: "src/parser.rs:1:parse".to_string(),
+            callee: "src/parser.rs:30:new".to_string(),
+        }));
+        // `println` is not a project function
+        assert_eq!(graph.unresolved_calls, 1);
+
+        let parse = node(&graph, "src/parser.rs:1:parse");
+        assert_eq!((parse.fan_in, parse.fan_out), (1, 2));
+        assert_eq!(parse.risk, 8);
+    }
+
+    #[test]
+    fn test_recursion_and_uncalled() {
+        let graph = CallGraph::build(&project(), Path::new(""));
+
+        assert_eq!(
+            graph.cycles,
+            vec![vec![
+                "src/parser.rs:10:parse_expr".to_string(),
+                "src/parser.rs:20:parse_term".to_string(),
+            ]]
+        );
+        assert!(node(&graph, "src/parser.rs:20:parse_term").recursive);
+        assert!(!node(&graph, "src/parser.rs:1:parse").recursive);
+        // Self-calls count towards neither fan-in nor fan-out
+        let term = node(&graph, "src/parser.rs:20:parse_term");
+        assert_eq!((term.fan_in, term.fan_out), (1, 1));
+
+        assert_eq!(
+            graph.uncalled,
+            vec![
+                "src/main.rs:1:main".to_string(),
+                "src/util.rs:1:new".to_string()
+            ]
+        );
+    }
+
+    #[test]
+    fn test_hotspots_and_dot() {
+        let graph = CallGraph::build(&project(), Path::new(""));
+
+        let hotspots: Vec<_> = graph.hotspots(2).iter().map(|f| f.name.as_str()).collect();
+        assert_eq!(hotspots, vec!["parse_expr", "parse"]);
+
+        let dot = graph.to_dot();
+        assert!(dot.starts_with("digraph calls {"));
+        assert!(dot.contains("\"src/main.rs:1:main\" -> \"src/parser.rs:1:parse\";"));
+        assert!(dot.contains("color=red"));
+    }
+
+    #[test]
+    fn test_qualified_call_is_not_a_self_call() {
+        // `fn fmt(&self, f) { self.0.fmt(f) }` calls another type's `fmt`
+        let mut fmt = scope("fmt", (1, 2), &["fmt"]);
+        fmt.qualified_calls = vec!["fmt".to_string()];
+        let files =
+            vec![file("src/id.rs").with_scopes(vec![fmt, scope("walk", (5, 6), &["walk"])])];
+        let graph = CallGraph::build(&files, Path::new(""));
+
+        assert!(!node(&graph, "src/id.rs:1:fmt").recursive);
+        assert!(node(&graph, "src/id.rs:5:walk").recursive);
+        assert_eq!(graph.unresolved_calls, 1);
+        assert_eq!(graph.cycles, vec![vec!["src/id.rs:5:walk".to_string()]]);
+    }
+}
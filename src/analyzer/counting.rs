@@ -0,0 +1,367 @@
+//! Cyclomatic complexity counting rules of reference tools.
+//!
+//! By default a decision point is any control flow node of the language
+//! (see `NodeKindMapper::is_control_flow_node`) plus each `&&`/`||`. Teams
+//! coming from other tools expect those tools' numbers, so a
+//! `ComplexityProfile` swaps in the ruleset of one of them. Every function
+//! starts at 1 and adds one per decision point:
+//!
+//! - `radon` (Python): `if`, `elif`, `for`, `while`, the `else` of a loop or
+//!   `try`, `except`, `with`, `assert`, `case`, conditional expressions, each
+//!   `for` and `if` clause of a comprehension and each `and`/`or`.
+//! - `gocyclo` (Go): `if`, `for`, each non-default `case` of a `switch` or
+//!   `select`, `&&` and `||`.
+//! - `lizard` (all languages): the condition keywords `if`, `elif`, `for`,
+//!   `while`, `case` (not `default`), `catch`/`except`, the ternary `?` and
+//!   `&&`/`||` (`and`/`or`, comprehension clauses in Python). Rust counts
+//!   `match` arms instead of `case`.
+//! - `pmd` (Java): `if`, `for`, `while`, `do`, non-default `case` labels,
+//!   `catch`, `assert`, `?:`, `&&` and `||`.
+//! - `eslint` (JavaScript/TypeScript, the `complexity` rule): `if`, `for`,
+//!   `for-in`/`for-of`, `while`, `do`, non-default `case`, `catch`, `?:`,
+//!   default parameter values, `&&`, `||`, `??` and their assignments
+//!   (`&&=`, `||=`, `??=`).
+//!
+//! Languages a profile does not cover keep the default rules. Nesting depth
+//! is the same under every profile.
+
+use tree_sitter::Node;
+
+use super::language::SupportedLanguage;
+use crate::cli::ComplexityProfile;
+
+/// Decision points of a reference tool for one language
+pub struct Ruleset {
+    /// Every node of these kinds is a decision point
+    decisions: &'static [&'static str],
+    /// Case labels, decision points unless they are the `default` label
+    case_labels: &'static [&'static str],
+    /// `else` clauses are decision points inside these statements
+    else_of: &'static [&'static str],
+    /// Parameters, decision points when they declare a default value
+    defaulted_parameters: &'static [&'static str],
+    /// Nodes counted once when their operator is one of `logical_operators`
+    logical_nodes: &'static [&'static str],
+    logical_operators: &'static [&'static str],
+}
+
+impl Ruleset {
+    /// Decision points and logical operators contributed by `node`
+    pub fn count(&self, node: &Node) -> (usize, usize) {
+        let kind = node.kind();
+        let decision = self.decisions.contains(&kind)
+            || (self.case_labels.contains(&kind) && !is_default_label(node))
+            || (kind == "else_clause"
+                && node
+                    .parent()
+                    .is_some_and(|parent| self.else_of.contains(&parent.kind())))
+            || (self.defaulted_parameters.contains(&kind)
+                && node.child_by_field_name("value").is_some());
+        let logical = self.logical_nodes.contains(&kind)
+            && (0..node.child_count())
+                .filter_map(|i| node.child(i as u32))
+                .any(|child| !child.is_named() && self.logical_operators.contains(&child.kind()));
+        (usize::from(decision), usize::from(logical))
+    }
+}
+
+/// Ruleset of `profile` for `language` (None = the default rules)
+pub fn ruleset(
+    profile: ComplexityProfile,
+    language: SupportedLanguage,
+) -> Option<&'static Ruleset> {
+    use SupportedLanguage::*;
+
+    match (profile, language) {
+        (ComplexityProfile::Radon, Python) => Some(&RADON_PYTHON),
+        (ComplexityProfile::Gocyclo, Go) => Some(&GOCYCLO_GO),
+        (ComplexityProfile::Pmd, Java) => Some(&PMD_JAVA),
+        (ComplexityProfile::Eslint, JavaScript | TypeScript | Tsx) => Some(&ESLINT_JS),
+        (ComplexityProfile::Lizard, Rust) => Some(&LIZARD_RUST),
+        (ComplexityProfile::Lizard, JavaScript | TypeScript | Tsx) => Some(&LIZARD_JS),
+        (ComplexityProfile::Lizard, Python) => Some(&LIZARD_PYTHON),
+        (ComplexityProfile::Lizard, Java) => Some(&LIZARD_JAVA),
+        (ComplexityProfile::Lizard, C) => Some(&LIZARD_C),
+        (ComplexityProfile::Lizard, Cpp) => Some(&LIZARD_CPP),
+        (ComplexityProfile::Lizard, Go) => Some(&LIZARD_GO),
+        _ => None,
+    }
+}
+
+/// Check if a case label is `default` (Java `switch_label`, C/C++ `case_statement`)
+fn is_default_label(node: &Node) -> bool {
+    node.child(0).is_some_and(|first| first.kind() == "default")
+}
+
+const C_LOGICAL: &[&str] = &["&&", "||"];
+const PYTHON_LOGICAL: &[&str] = &["and", "or"];
+
+static RADON_PYTHON: Ruleset = Ruleset {
+    decisions: &[
+        "if_statement",
+        "elif_clause",
+        "for_statement",
+        "while_statement",
+        "except_clause",
+        "with_statement",
+        "assert_statement",
+        "case_clause",
+        "conditional_expression",
+        "for_in_clause",
+        "if_clause",
+    ],
+    case_labels: &[],
+    else_of: &["for_statement", "while_statement", "try_statement"],
+    defaulted_parameters: &[],
+    logical_nodes: &["boolean_operator"],
+    logical_operators: PYTHON_LOGICAL,
+};
+
+static GOCYCLO_GO: Ruleset = Ruleset {
+    decisions: &[
+        "if_statement",
+        "for_statement",
+        "expression_case",
+        "type_case",
+        "communication_case",
+    ],
+    case_labels: &[],
+    else_of: &[],
+    defaulted_parameters: &[],
+    logical_nodes: &["binary_expression"],
+    logical_operators: C_LOGICAL,
+};
+
+static PMD_JAVA: Ruleset = Ruleset {
+    decisions: &[
+        "if_statement",
+        "for_statement",
+        "enhanced_for_statement",
+        "while_statement",
+        "do_statement",
+        "catch_clause",
+        "assert_statement",
+        "ternary_expression",
+    ],
+    case_labels: &["switch_label"],
+    else_of: &[],
+    defaulted_parameters: &[],
+    logical_nodes: &["binary_expression"],
+    logical_operators: C_LOGICAL,
+};
+
+static ESLINT_JS: Ruleset = Ruleset {
+    decisions: &[
+        "if_statement",
+        "for_statement",
+        "for_in_statement",
+        "while_statement",
+        "do_statement",
+        "switch_case",
+        "catch_clause",
+        "ternary_expression",
+        "assignment_pattern",
+    ],
+    case_labels: &[],
+    else_of: &[],
+    // TypeScript parameters hold their default in a `value` field
+    defaulted_parameters: &["required_parameter", "optional_parameter"],
+    logical_nodes: &["binary_expression", "augmented_assignment_expression"],
+    logical_operators: &["&&", "||", "??", "&&=", "||=", "??="],
+};
+
+static LIZARD_RUST: Ruleset = Ruleset {
+    decisions: &[
+        "if_expression",
+        "for_expression",
+        "while_expression",
+        "match_arm",
+    ],
+    case_labels: &[],
+    else_of: &[],
+    defaulted_parameters: &[],
+    logical_nodes: &["binary_expression"],
+    logical_operators: C_LOGICAL,
+};
+
+static LIZARD_JS: Ruleset = Ruleset {
+    decisions: &[
+        "if_statement",
+        "for_statement",
+        "for_in_statement",
+        "while_statement",
+        "do_statement",
+        "switch_case",
+        "catch_clause",
+        "ternary_expression",
+    ],
+    case_labels: &[],
+    else_of: &[],
+    defaulted_parameters: &[],
+    logical_nodes: &["binary_expression"],
+    logical_operators: C_LOGICAL,
+};
+
+static LIZARD_PYTHON: Ruleset = Ruleset {
+    decisions: &[
+        "if_statement",
+        "elif_clause",
+        "for_statement",
+        "while_statement",
+        "except_clause",
+        "conditional_expression",
+        "for_in_clause",
+        "if_clause",
+    ],
+    case_labels: &[],
+    else_of: &[],
+    defaulted_parameters: &[],
+    logical_nodes: &["boolean_operator"],
+    logical_operators: PYTHON_LOGICAL,
+};
+
+static LIZARD_JAVA: Ruleset = Ruleset {
+    decisions: &[
+        "if_statement",
+        "for_statement",
+        "enhanced_for_statement",
+        "while_statement",
+        "do_statement",
+        "catch_clause",
+        "ternary_expression",
+    ],
+    case_labels: &["switch_label"],
+    else_of: &[],
+    defaulted_parameters: &[],
+    logical_nodes: &["binary_expression"],
+    logical_operators: C_LOGICAL,
+};
+
+static LIZARD_C: Ruleset = Ruleset {
+    decisions: &[
+        "if_statement",
+        "for_statement",
+        "while_statement",
+        "do_statement",
+        "conditional_expression",
+    ],
+    case_labels: &["case_statement"],
+    else_of: &[],
+    defaulted_parameters: &[],
+    logical_nodes: &["binary_expression"],
+    logical_operators: C_LOGICAL,
+};
+
+static LIZARD_CPP: Ruleset = Ruleset {
+    decisions: &[
+        "if_statement",
+        "for_statement",
+        "for_range_loop",
+        "while_statement",
+        "do_statement",
+        "catch_clause",
+        "conditional_expression",
+    ],
+    case_labels: &["case_statement"],
+    else_of: &[],
+    defaulted_parameters: &[],
+    logical_nodes: &["binary_expression"],
+    logical_operators: C_LOGICAL,
+};
+
+static LIZARD_GO: Ruleset = Ruleset {
+    decisions: &[
+        "if_statement",
+        "for_statement",
+        "expression_case",
+        "type_case",
+        "communication_case",
+    ],
+    case_labels: &[],
+    else_of: &[],
+    defaulted_parameters: &[],
+    logical_nodes: &["binary_expression"],
+    logical_operators: C_LOGICAL,
+};
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::analyzer::visitor::{TreeMetrics, TreeVisitor};
+
+    fn visit(language: SupportedLanguage, profile: ComplexityProfile, source: &str) -> TreeMetrics {
+        let mut parser = tree_sitter::Parser::new();
+        parser.set_language(&language.get_grammar()).unwrap();
+        let tree = parser.parse(source, None).unwrap();
+        TreeVisitor::new(language, source.as_bytes())
+            .with_complexity_profile(profile)
+            .run(&tree.root_node())
+    }
+
+    #[test]
+    fn test_radon_python() {
+        let source = "def f(xs):\n    if a:\n        pass\n    elif b and c:\n        pass\n    try:\n        pass\n    except E:\n        pass\n    return [x for x in xs if x]\n";
+
+        // Default: if, try, except, comprehension, and
+        let default = visit(
+            SupportedLanguage::Python,
+            ComplexityProfile::Default,
+            source,
+        );
+        assert_eq!(default.cyclomatic_complexity(), 6);
+        assert_eq!(default.scopes[0].cyclomatic_complexity, 6);
+
+        // radon: if, elif, and, except, for clause, if clause
+        let radon = visit(SupportedLanguage::Python, ComplexityProfile::Radon, source);
+        assert_eq!(radon.cyclomatic_complexity(), 7);
+        assert_eq!(radon.scopes[0].cyclomatic_complexity, 7);
+    }
+
+    #[test]
+    fn test_gocyclo_skips_default_case() {
+        let source = "package p\n\nfunc f(x int) {\n\tswitch x {\n\tcase 1:\n\tcase 2:\n\tdefault:\n\t}\n}\n";
+
+        let default = visit(SupportedLanguage::Go, ComplexityProfile::Default, source);
+        assert_eq!(default.scopes[0].cyclomatic_complexity, 4);
+        let gocyclo = visit(SupportedLanguage::Go, ComplexityProfile::Gocyclo, source);
+        assert_eq!(gocyclo.scopes[0].cyclomatic_complexity, 3);
+    }
+
+    #[test]
+    fn test_pmd_and_lizard_java_case_labels() {
+        let source = "class A {\n  int f(int x) {\n    switch (x) {\n      case 1: return 1;\n      case 2: return 2;\n      default: return 0;\n    }\n  }\n}\n";
+
+        let pmd = visit(SupportedLanguage::Java, ComplexityProfile::Pmd, source);
+        assert_eq!(pmd.scopes[0].cyclomatic_complexity, 3);
+        let lizard = visit(SupportedLanguage::Java, ComplexityProfile::Lizard, source);
+        assert_eq!(lizard.scopes[0].cyclomatic_complexity, 3);
+    }
+
+    #[test]
+    fn test_eslint_javascript() {
+        let source = "function f(a = 1) {\n  try { g(); } catch (e) {}\n  x ??= a ?? b;\n  switch (a) { case 1: break; default: break; }\n}\n";
+
+        // Default: try, catch, case, default
+        let default = visit(
+            SupportedLanguage::JavaScript,
+            ComplexityProfile::Default,
+            source,
+        );
+        assert_eq!(default.scopes[0].cyclomatic_complexity, 5);
+
+        // ESLint: default parameter, catch, ??=, ??, case
+        let eslint = visit(
+            SupportedLanguage::JavaScript,
+            ComplexityProfile::Eslint,
+            source,
+        );
+        assert_eq!(eslint.scopes[0].cyclomatic_complexity, 6);
+    }
+
+    #[test]
+    fn test_uncovered_language_keeps_default_rules() {
+        assert!(ruleset(ComplexityProfile::Radon, SupportedLanguage::Rust).is_none());
+        assert!(ruleset(ComplexityProfile::Default, SupportedLanguage::Python).is_none());
+        assert!(ruleset(ComplexityProfile::Lizard, SupportedLanguage::Rust).is_some());
+    }
+}
@@ -0,0 +1,710 @@
+//! Extract-method suggestions for long or complex functions.
+//!
+//! Candidates are runs of consecutive statements of a block in the function:
+//! a single loop or `if`, a whole branch or loop body, or a plain sequence.
+//! A use/def analysis of the function's local variables (names bound by a
+//! declaration, assignment, parameter or pattern) gives each candidate its
+//! inputs, locals bound before it and read in it, and its outputs, locals
+//! it assigns that are read after it. Candidates that `return`, `yield` or
+//! `break`/`continue` out of the block cannot be moved as they are and are
+//! skipped; the rest are ranked by the decision points they take out of the
+//! function (counted with the default rules), their length and how few
+//! variables cross their boundary.
+//!
+//! The analysis is syntactic: it ignores block scoping and shadowing, and
+//! a variable written in one loop iteration and read in the next is not an
+//! output. Suggestions are a starting point for a refactoring, not a proof
+//! that it is behavior-preserving.
+
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, HashMap};
+use std::fmt;
+use tree_sitter::Node;
+
+use super::language::{NodeKindMapper, SupportedLanguage};
+use super::visitor::FunctionScope;
+
+/// Suggestions kept per function
+const MAX_SUGGESTIONS: usize = 3;
+/// Longest run of statements considered, besides whole blocks
+const MAX_RUN: usize = 12;
+/// Shortest candidate, in lines
+const MIN_LINES: usize = 3;
+const MAX_INPUTS: usize = 4;
+const MAX_OUTPUTS: usize = 1;
+
+/// Nodes holding a sequence of statements
+const BLOCK_KINDS: &[&str] = &[
+    "block",
+    "statement_block",
+    "compound_statement",
+    "statement_list",
+];
+
+const LOOP_KINDS: &[&str] = &[
+    "for_statement",
+    "for_in_statement",
+    "enhanced_for_statement",
+    "for_range_loop",
+    "while_statement",
+    "do_statement",
+    "for_expression",
+    "while_expression",
+    "loop_expression",
+];
+
+const SWITCH_KINDS: &[&str] = &[
+    "switch_statement",
+    "switch_expression",
+    "expression_switch_statement",
+    "type_switch_statement",
+    "select_statement",
+];
+
+/// Jumps out of the function, wherever they are
+const RETURN_KINDS: &[&str] = &[
+    "return_statement",
+    "return_expression",
+    "yield",
+    "yield_statement",
+    "yield_expression",
+    "goto_statement",
+];
+
+/// Nodes binding the names below them without being a binding themselves
+const PATTERN_KINDS: &[&str] = &[
+    "tuple_pattern",
+    "list_pattern",
+    "pattern_list",
+    "tuple",
+    "expression_list",
+    "match_pattern",
+    "tuple_struct_pattern",
+    "struct_pattern",
+    "field_pattern",
+    "ref_pattern",
+    "reference_pattern",
+    "mut_pattern",
+    "array_pattern",
+    "object_pattern",
+    "pair_pattern",
+    "rest_pattern",
+    "list_splat_pattern",
+    "typed_parameter",
+    "pointer_declarator",
+    "reference_declarator",
+    "array_declarator",
+];
+
+/// Parameter lists, whose identifiers are all bindings
+const PARAMETER_LISTS: &[&str] = &[
+    "parameters",
+    "formal_parameters",
+    "closure_parameters",
+    "lambda_parameters",
+    "inferred_parameters",
+];
+
+/// Where a binding is introduced: parent kind, field holding the bound
+/// names and whether the binding takes effect at the end of the parent
+/// (`x = f(x)` reads the old `x`) rather than right after the names
+/// (loop variables are bound before the body runs)
+const BINDINGS: &[(&str, &str, bool)] = &[
+    ("let_declaration", "pattern", true),
+    ("let_condition", "pattern", true),
+    ("parameter", "pattern", true),
+    ("for_expression", "pattern", false),
+    ("match_arm", "pattern", false),
+    ("assignment_expression", "left", true),
+    ("compound_assignment_expr", "left", true),
+    ("augmented_assignment_expression", "left", true),
+    ("variable_declarator", "name", true),
+    ("for_in_statement", "left", false),
+    ("required_parameter", "pattern", true),
+    ("optional_parameter", "pattern", true),
+    ("assignment_pattern", "left", true),
+    ("update_expression", "argument", true),
+    ("catch_clause", "parameter", false),
+    ("assignment", "left", true),
+    ("augmented_assignment", "left", true),
+    ("for_statement", "left", false),
+    ("for_in_clause", "left", false),
+    ("default_parameter", "name", true),
+    ("typed_default_parameter", "name", true),
+    ("named_expression", "name", true),
+    ("as_pattern", "alias", false),
+    ("formal_parameter", "name", true),
+    ("catch_formal_parameter", "name", true),
+    ("enhanced_for_statement", "name", false),
+    ("init_declarator", "declarator", true),
+    ("declaration", "declarator", true),
+    ("parameter_declaration", "declarator", true),
+    ("for_range_loop", "declarator", false),
+    ("short_var_declaration", "left", true),
+    ("assignment_statement", "left", true),
+    ("var_spec", "name", true),
+    ("parameter_declaration", "name", true),
+    ("range_clause", "left", false),
+];
+
+/// Bindings that also read the previous value (`x += 1`, `x++`)
+const READ_WRITE_KINDS: &[&str] = &[
+    "compound_assignment_expr",
+    "augmented_assignment_expression",
+    "augmented_assignment",
+    "update_expression",
+    "assignment_statement",
+];
+
+/// Fields whose identifiers name members or types, not local variables
+const NON_VARIABLE_FIELDS: &[&str] = &["attribute", "field", "type"];
+
+/// Functions reaching either threshold get extract-method suggestions
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct ExtractThresholds {
+    pub max_lines: usize,
+    pub max_cyclomatic_complexity: usize,
+}
+
+impl Default for ExtractThresholds {
+    fn default() -> Self {
+        Self {
+            max_lines: 50,
+            max_cyclomatic_complexity: 10,
+        }
+    }
+}
+
+impl ExtractThresholds {
+    /// Create thresholds from CLI arguments (None unless suggestions are requested)
+    pub fn from_cli(args: &crate::cli::CliArgs) -> Option<Self> {
+        let defaults = Self::default();
+        args.suggest_extractions.then(|| Self {
+            max_lines: args.max_function_lines.unwrap_or(defaults.max_lines),
+            max_cyclomatic_complexity: args
+                .max_function_cc
+                .unwrap_or(defaults.max_cyclomatic_complexity),
+        })
+    }
+
+    /// Check if a function is long or complex enough for suggestions
+    pub fn exceeded_by(&self, scope: &FunctionScope) -> bool {
+        scope.line_count() >= self.max_lines
+            || scope.cyclomatic_complexity >= self.max_cyclomatic_complexity
+    }
+}
+
+/// A block of a function that could become a function of its own
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ExtractSuggestion {
+    /// First line of the block (1-based)
+    pub start_line: usize,
+    /// Last line of the block (1-based)
+    pub end_line: usize,
+    /// Locals bound before the block and read in it: the new function's parameters
+    pub inputs: Vec<String>,
+    /// Locals the block assigns that are read after it: the new function's result
+    pub outputs: Vec<String>,
+    /// Decision points moving out of the function
+    pub cc_reduction: usize,
+}
+
+impl fmt::Display for ExtractSuggestion {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        let list = |names: &[String]| match names {
+            [] => "none".to_string(),
+            names => names.join(", "),
+        };
+        write!(
+            f,
+            "extract lines {}–{} (inputs: {}; outputs: {}), estimated CC reduction {}",
+            self.start_line,
+            self.end_line,
+            list(&self.inputs),
+            list(&self.outputs),
+            self.cc_reduction
+        )
+    }
+}
+
+/// Fill in the extract-method suggestions of the `scopes` reaching `thresholds`
+pub fn suggest_extractions(
+    language: SupportedLanguage,
+    root: &Node,
+    source: &[u8],
+    scopes: &mut [FunctionScope],
+    thresholds: &ExtractThresholds,
+) {
+    let wanted: HashMap<(usize, usize, &str), usize> = scopes
+        .iter()
+        .enumerate()
+        .filter(|(_, scope)| thresholds.exceeded_by(scope))
+        .map(|(index, scope)| {
+            (
+                (scope.start_line, scope.end_line, scope.kind.as_str()),
+                index,
+            )
+        })
+        .collect();
+    if wanted.is_empty() {
+        return;
+    }
+
+    let mut found = Vec::new();
+    let mut stack = vec![*root];
+    while let Some(node) = stack.pop() {
+        let key = (
+            node.start_position().row + 1,
+            node.end_position().row + 1,
+            node.kind(),
+        );
+        if let Some(&index) = wanted.get(&key) {
+            found.push((index, node));
+        }
+        let mut cursor = node.walk();
+        stack.extend(node.children(&mut cursor));
+    }
+
+    for (index, node) in found {
+        scopes[index].extractions = FunctionBody::new(language, node, source).suggestions();
+    }
+}
+
+/// Reads and writes of the locals of one function
+struct FunctionBody<'a> {
+    language: SupportedLanguage,
+    function: Node<'a>,
+    /// (byte offset, variable), sorted
+    uses: Vec<(usize, usize)>,
+    defs: Vec<(usize, usize)>,
+    names: Vec<String>,
+}
+
+/// A run of statements that could be extracted
+struct Candidate {
+    start_byte: usize,
+    end_byte: usize,
+    suggestion: ExtractSuggestion,
+}
+
+impl<'a> FunctionBody<'a> {
+    fn new(language: SupportedLanguage, function: Node<'a>, source: &[u8]) -> Self {
+        let mut body = Self {
+            language,
+            function,
+            uses: Vec::new(),
+            defs: Vec::new(),
+            names: Vec::new(),
+        };
+
+        let mut ids: HashMap<String, usize> = HashMap::new();
+        let mut occurrences = Vec::new();
+        for node in body.descendants(function) {
+            if !matches!(
+                node.kind(),
+                "identifier"
+                    | "shorthand_property_identifier"
+                    | "shorthand_property_identifier_pattern"
+            ) {
+                continue;
+            }
+            let Ok(name) = node.utf8_text(source) else {
+                continue;
+            };
+            if name == "self" || !is_variable(&node) {
+                continue;
+            }
+            let next = ids.len();
+            let id = *ids.entry(name.to_string()).or_insert(next);
+            occurrences.push((node, id));
+        }
+        body.names = vec![String::new(); ids.len()];
+        for (name, id) in ids {
+            body.names[id] = name;
+        }
+
+        for (node, id) in occurrences {
+            match binding(&node) {
+                Some((at, read_write)) => {
+                    body.defs.push((at, id));
+                    if read_write {
+                        body.uses.push((node.start_byte(), id));
+                    }
+                }
+                None => body.uses.push((node.start_byte(), id)),
+            }
+        }
+        body.uses.sort_unstable();
+        body.defs.sort_unstable();
+        body
+    }
+
+    /// Nodes of the function, without descending into nested named functions
+    fn descendants(&self, root: Node<'a>) -> Vec<Node<'a>> {
+        let mut nodes = Vec::new();
+        let mut stack = vec![root];
+        while let Some(node) = stack.pop() {
+            nodes.push(node);
+            if node.id() != self.function.id()
+                && self.is_named_function(&node)
+                && !self.is_own_declarator(&node)
+            {
+                continue;
+            }
+            let mut cursor = node.walk();
+            let children: Vec<Node<'a>> = node.children(&mut cursor).collect();
+            stack.extend(children.into_iter().rev());
+        }
+        nodes
+    }
+
+    fn is_named_function(&self, node: &Node) -> bool {
+        self.language.is_function_node(node.kind()) || self.language.is_method_node(node.kind())
+    }
+
+    /// Whether `node` declares the function itself, like the `function_declarator`
+    /// holding a C/C++ function's parameters (possibly under `*` or `&` declarators)
+    fn is_own_declarator(&self, node: &Node) -> bool {
+        let mut parent = node.parent();
+        while let Some(ancestor) = parent {
+            if ancestor.id() == self.function.id() {
+                return true;
+            }
+            if !ancestor.kind().ends_with("declarator") {
+                return false;
+            }
+            parent = ancestor.parent();
+        }
+        false
+    }
+
+    /// Best non-overlapping candidates, in document order
+    fn suggestions(&self) -> Vec<ExtractSuggestion> {
+        let Some(body) = self.function.child_by_field_name("body") else {
+            return Vec::new();
+        };
+        let function_lines =
+            self.function.end_position().row + 1 - self.function.start_position().row;
+
+        let mut candidates = Vec::new();
+        for block in self.descendants(body) {
+            if !BLOCK_KINDS.contains(&block.kind()) {
+                continue;
+            }
+            let mut cursor = block.walk();
+            let statements: Vec<Node<'a>> = block
+                .named_children(&mut cursor)
+                .filter(|s| !self.language.is_comment_node(s.kind()))
+                .collect();
+            let movable: Vec<bool> = statements.iter().map(|s| !self.escapes(s)).collect();
+            let decisions: Vec<usize> = statements.iter().map(|s| self.decisions(s)).collect();
+
+            for first in 0..statements.len() {
+                for last in first..statements.len() {
+                    if !movable[last] {
+                        break;
+                    }
+                    let whole_block = first == 0 && last + 1 == statements.len();
+                    if last - first >= MAX_RUN && !whole_block {
+                        continue;
+                    }
+                    let (start, end) = (statements[first], statements[last]);
+                    let lines = end.end_position().row + 1 - start.start_position().row;
+                    // Moving (nearly) the whole function out is not a refactoring
+                    if lines < MIN_LINES || lines * 10 > function_lines * 8 {
+                        continue;
+                    }
+                    let cc_reduction = decisions[first..=last].iter().sum();
+                    if let Some(candidate) = self.candidate(&start, &end, cc_reduction) {
+                        candidates.push(candidate);
+                    }
+                }
+            }
+        }
+
+        let score = |c: &Candidate| {
+            let s = &c.suggestion;
+            let lines = s.end_line + 1 - s.start_line;
+            (s.cc_reduction * 4 + lines) as i64 - 3 * (s.inputs.len() + s.outputs.len()) as i64
+        };
+        candidates.sort_by(|a, b| {
+            score(b)
+                .cmp(&score(a))
+                .then_with(|| a.start_byte.cmp(&b.start_byte))
+        });
+
+        let mut chosen: Vec<Candidate> = Vec::new();
+        for candidate in candidates {
+            if chosen.len() == MAX_SUGGESTIONS {
+                break;
+            }
+            let overlaps = chosen
+                .iter()
+                .any(|c| candidate.start_byte < c.end_byte && c.start_byte < candidate.end_byte);
+            if !overlaps && score(&candidate) > 0 {
+                chosen.push(candidate);
+            }
+        }
+        chosen.sort_by_key(|c| c.start_byte);
+        chosen.into_iter().map(|c| c.suggestion).collect()
+    }
+
+    /// Inputs and outputs of the statements from `start` to `end`, if few enough
+    fn candidate(&self, start: &Node, end: &Node, cc_reduction: usize) -> Option<Candidate> {
+        let (from, to) = (start.start_byte(), end.end_byte());
+        let in_range = |list: &[(usize, usize)]| {
+            let first = list.partition_point(|&(at, _)| at < from);
+            let last = list.partition_point(|&(at, _)| at < to);
+            list[first..last].to_vec()
+        };
+
+        // First read and first write of each variable inside the range
+        let mut touched: BTreeMap<usize, (Option<usize>, Option<usize>)> = BTreeMap::new();
+        for (at, id) in in_range(&self.uses) {
+            touched.entry(id).or_default().0.get_or_insert(at);
+        }
+        for (at, id) in in_range(&self.defs) {
+            touched.entry(id).or_default().1.get_or_insert(at);
+        }
+
+        let mut inputs = Vec::new();
+        let mut outputs = Vec::new();
+        for (id, (read, write)) in touched {
+            let bound_before = self.defs.iter().any(|&(at, v)| v == id && at < from);
+            let read_first = match (read, write) {
+                (Some(read), Some(write)) => read < write,
+                (Some(_), None) => true,
+                _ => false,
+            };
+            if read_first && bound_before {
+                inputs.push(self.names[id].clone());
+            }
+            let read_after = self.uses.iter().any(|&(at, v)| v == id && at >= to);
+            if write.is_some() && read_after {
+                outputs.push(self.names[id].clone());
+            }
+        }
+        if inputs.len() > MAX_INPUTS || outputs.len() > MAX_OUTPUTS {
+            return None;
+        }
+        inputs.sort();
+        outputs.sort();
+
+        Some(Candidate {
+            start_byte: from,
+            end_byte: to,
+            suggestion: ExtractSuggestion {
+                start_line: start.start_position().row + 1,
+                end_line: end.end_position().row + 1,
+                inputs,
+                outputs,
+                cc_reduction,
+            },
+        })
+    }
+
+    /// Check if control can leave `statement` other than by falling through
+    /// (a `return`, or a `break`/`continue` for a loop outside of it)
+    fn escapes(&self, statement: &Node<'a>) -> bool {
+        let mut stack = vec![(*statement, false, false)];
+        while let Some((node, in_loop, in_switch)) = stack.pop() {
+            let kind = node.kind();
+            if RETURN_KINDS.contains(&kind) {
+                return true;
+            }
+            if kind.starts_with("continue") && !in_loop {
+                return true;
+            }
+            if kind.starts_with("break") && !in_loop && !in_switch {
+                return true;
+            }
+            if self.is_named_function(&node) || self.language.is_anonymous_function_node(kind) {
+                continue;
+            }
+            let in_loop = in_loop || LOOP_KINDS.contains(&kind);
+            let in_switch = in_switch || SWITCH_KINDS.contains(&kind);
+            let mut cursor = node.walk();
+            stack.extend(
+                node.children(&mut cursor)
+                    .map(|child| (child, in_loop, in_switch)),
+            );
+        }
+        false
+    }
+
+    /// Decision points in `statement` (default rules, nested functions excluded)
+    fn decisions(&self, statement: &Node<'a>) -> usize {
+        let logical_operators = self.language.logical_operators();
+        self.descendants(*statement)
+            .into_iter()
+            .filter(|node| {
+                let kind = node.kind();
+                self.language.is_control_flow_node(kind)
+                    || (self.language.is_binary_expression_node(kind)
+                        && (0..node.child_count())
+                            .filter_map(|i| node.child(i as u32))
+                            .any(|op| !op.is_named() && logical_operators.contains(&op.kind())))
+            })
+            .count()
+    }
+}
+
+/// Check if an identifier may name a local variable (not a member, type or keyword argument)
+fn is_variable(node: &Node) -> bool {
+    let Some(parent) = node.parent() else {
+        return true;
+    };
+    if parent.kind() == "keyword_argument" {
+        return false;
+    }
+    field_of(&parent, node).is_none_or(|field| !NON_VARIABLE_FIELDS.contains(&field))
+}
+
+/// Where the identifier `node` is bound, as (byte offset the binding takes
+/// effect, whether the binding also reads the old value); None for reads
+fn binding(node: &Node) -> Option<(usize, bool)> {
+    let mut child = *node;
+    let mut parent = node.parent()?;
+    while PATTERN_KINDS.contains(&parent.kind()) {
+        if field_of(&parent, &child) == Some("type") {
+            return None;
+        }
+        child = parent;
+        parent = parent.parent()?;
+    }
+
+    if PARAMETER_LISTS.contains(&parent.kind()) {
+        return Some((parent.end_byte(), false));
+    }
+    let field = field_of(&parent, &child)?;
+    let (_, _, at_end) = BINDINGS
+        .iter()
+        .find(|(kind, binding_field, _)| *kind == parent.kind() && *binding_field == field)?;
+    let at = if *at_end {
+        parent.end_byte()
+    } else {
+        child.end_byte()
+    };
+    Some((at, READ_WRITE_KINDS.contains(&parent.kind())))
+}
+
+/// Name of the field of `parent` holding `child`
+fn field_of(parent: &Node, child: &Node) -> Option<&'static str> {
+    (0..parent.child_count())
+        .find(|&i| parent.child(i as u32).is_some_and(|c| c.id() == child.id()))
+        .and_then(|i| parent.field_name_for_child(i as u32))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::analyzer::visitor::TreeVisitor;
+
+    fn suggestions(language: SupportedLanguage, source: &str) -> Vec<FunctionScope> {
+        let mut parser = tree_sitter::Parser::new();
+        parser.set_language(&language.get_grammar()).unwrap();
+        let tree = parser.parse(source, None).unwrap();
+        let mut scopes = TreeVisitor::new(language, source.as_bytes())
+            .run(&tree.root_node())
+            .scopes;
+        let thresholds = ExtractThresholds {
+            max_lines: 10,
+            max_cyclomatic_complexity: 100,
+        };
+        suggest_extractions(
+            language,
+            &tree.root_node(),
+            source.as_bytes(),
+            &mut scopes,
+            &thresholds,
+        );
+        scopes
+    }
+
+    #[test]
+    fn test_suggests_loop_with_inputs_and_outputs() {
+        let source = r#"fn report(items: &[u32], limit: u32) -> u32 {
+    let mut total = 0;
+    for item in items {
+        if *item > limit {
+            total += item;
+        } else if *item == 0 {
+            total += 1;
+        }
+    }
+    let label = format!("{}", total);
+    println!("{}", label);
+    println!("{}", label);
+    total
+}
+
+fn short() {}
+"#;
+        let scopes = suggestions(SupportedLanguage::Rust, source);
+
+        assert!(scopes[1].extractions.is_empty());
+        let extractions = &scopes[0].extractions;
+        assert_eq!(extractions.len(), 2);
+        // The loop together with the initialization of its accumulator
+        let first = &extractions[0];
+        assert_eq!((first.start_line, first.end_line), (2, 9));
+        assert_eq!(first.inputs, vec!["items", "limit"]);
+        assert_eq!(first.outputs, vec!["total"]);
+        assert_eq!(first.cc_reduction, 3);
+        assert_eq!(
+            first.to_string(),
+            "extract lines 2–9 (inputs: items, limit; outputs: total), estimated CC reduction 3"
+        );
+        assert_eq!(extractions[1].start_line, 10);
+        assert_eq!(extractions[1].inputs, vec!["total"]);
+    }
+
+    #[test]
+    fn test_skips_blocks_that_return() {
+        let source = r#"def check(values):
+    for value in values:
+        if value < 0:
+            return False
+        if value > 100:
+            return False
+        if value == 42:
+            return True
+    seen = set()
+    print(seen)
+    print(seen)
+    print(seen)
+    return True
+"#;
+        let scopes = suggestions(SupportedLanguage::Python, source);
+
+        let extractions = &scopes[0].extractions;
+        assert!(extractions.iter().all(|e| e.start_line > 8));
+        assert_eq!(extractions[0].start_line, 9);
+        assert_eq!(extractions[0].outputs, Vec::<String>::new());
+    }
+
+    #[test]
+    fn test_cpp_parameters_are_bindings() {
+        let source = r#"int total(const std::vector<int>& items, int limit) {
+    int sum = 0;
+    for (int item : items) {
+        if (item > limit) {
+            sum += item;
+        } else if (item == 0) {
+            sum += 1;
+        }
+    }
+    std::cout << sum;
+    std::cout << sum;
+    std::cout << sum;
+    return sum;
+}
+"#;
+        let scopes = suggestions(SupportedLanguage::Cpp, source);
+
+        let extractions: Vec<_> = scopes.iter().flat_map(|s| &s.extractions).collect();
+        assert!(extractions
+            .iter()
+            .any(|e| e.inputs == vec!["items", "limit"] && e.outputs == vec!["sum"]));
+    }
+}
@@ -2,21 +2,62 @@
 //!
 //! This module provides functionality to integrate with git repositories,
 //! allowing the analyzer to focus only on files that have changed since
-//! a specific commit reference.
+//! a specific commit reference, and to recognize submodules and nested
+//! repositories inside the analyzed tree.
 
+use serde::{Deserialize, Serialize};
+use std::collections::HashSet;
 use std::path::{Path, PathBuf};
-use std::process::Command;
+use std::process::{Command, Output};
 
 use crate::error::{AnalyzerError, Result};
+use crate::{log_debug, log_warn};
+
+/// File mode git records for a submodule (a "gitlink")
+const GITLINK_MODE: &str = "160000";
+
+/// Kind of git repository nested inside the analyzed tree
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum NestedRepoKind {
+    /// Submodule (its `.git` is a file pointing into the parent's git directory)
+    Submodule,
+    /// Independent repository with its own `.git` directory
+    Repository,
+}
+
+impl NestedRepoKind {
+    /// Get the lowercase name of the kind
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            NestedRepoKind::Submodule => "submodule",
+            NestedRepoKind::Repository => "repository",
+        }
+    }
+}
+
+/// HEAD commit, branch and origin of a repository
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct GitMetadata {
+    pub commit: String,
+    /// Checked-out branch (`None` for a detached HEAD, as usual for submodules)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub branch: Option<String>,
+    /// URL of the `origin` remote
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub remote: Option<String>,
+}
 
 /// Get list of files changed since a specific commit
 ///
-/// Executes `git diff --name-only <commit_ref>` and returns the list of
+/// Executes `git diff --raw <commit_ref>` and returns the list of
 /// changed file paths as absolute paths.
 ///
 /// # Arguments
 /// * `repo_path` - Path to the git repository root (or any subdirectory)
 /// * `commit_ref` - Git commit reference (e.g., "HEAD~1", "main", "abc123")
+/// * `submodules` - Resolve changed submodule pointers to the files that
+///   changed inside the submodule (otherwise they are left out)
 ///
 /// # Returns
 /// * `Ok(Vec<PathBuf>)` - List of changed file paths (absolute)
@@ -24,26 +65,32 @@ use crate::error::{AnalyzerError, Result};
 ///
 /// # Examples
 /// ```ignore
-/// let changed = get_changed_files("./", "HEAD~1")?;
+/// let changed = get_changed_files("./", "HEAD~1", true)?;
 /// println!("Changed files: {:?}", changed);
 /// ```
-pub fn get_changed_files<P: AsRef<Path>>(repo_path: P, commit_ref: &str) -> Result<Vec<PathBuf>> {
+pub fn get_changed_files<P: AsRef<Path>>(
+    repo_path: P,
+    commit_ref: &str,
+    submodules: bool,
+) -> Result<Vec<PathBuf>> {
     let repo_path = repo_path.as_ref();
 
     // Get the repository root first
     let repo_root = get_repo_root(repo_path)?;
 
-    // Execute git diff --name-only to get changed files (unstaged + staged vs commit_ref)
-    let output = Command::new("git")
-        .args(["diff", "--name-only", commit_ref])
-        .current_dir(&repo_root)
-        .output()
-        .map_err(|e| {
-            AnalyzerError::validation_error(format!(
-                "Failed to execute git command: {}. Is git installed and in PATH?",
-                e
-            ))
-        })?;
+    let mut changed_files = changed_files_in(&repo_root, commit_ref, submodules)?;
+
+    // Deduplicate (a file could be in both diff and cached)
+    changed_files.sort();
+    changed_files.dedup();
+
+    Ok(changed_files)
+}
+
+/// Changed files of the repository at `repo_root`
+fn changed_files_in(repo_root: &Path, commit_ref: &str, submodules: bool) -> Result<Vec<PathBuf>> {
+    // Changed files (unstaged + staged vs commit_ref), with modes and object ids
+    let output = run_git(repo_root, &["diff", "--raw", "--no-abbrev", commit_ref])?;
 
     if !output.status.success() {
         let stderr = String::from_utf8_lossy(&output.stderr);
@@ -55,31 +102,194 @@ pub fn get_changed_files<P: AsRef<Path>>(repo_path: P, commit_ref: &str) -> Resu
     }
 
     // Also get staged files (git diff --cached)
-    let staged_output = Command::new("git")
-        .args(["diff", "--name-only", "--cached"])
-        .current_dir(&repo_root)
-        .output()
-        .map_err(|e| {
-            AnalyzerError::validation_error(format!("Failed to get staged files: {}", e))
-        })?;
+    let staged_output = run_git(repo_root, &["diff", "--raw", "--no-abbrev", "--cached"])?;
 
-    // Parse output into file paths
     let stdout = String::from_utf8_lossy(&output.stdout);
     let staged_stdout = String::from_utf8_lossy(&staged_output.stdout);
 
-    let mut changed_files: Vec<PathBuf> = stdout
+    let mut seen = HashSet::new();
+    let mut changed_files = Vec::new();
+    for entry in parse_raw_diff(&stdout)
+        .into_iter()
+        .chain(parse_raw_diff(&staged_stdout))
+    {
+        // The diff against commit_ref comes first and already covers staged changes
+        if !seen.insert(entry.path.clone()) {
+            continue;
+        }
+
+        let path = repo_root.join(&entry.path);
+        if entry.new_mode == GITLINK_MODE {
+            if submodules {
+                changed_files.extend(submodule_changed_files(&path, &entry));
+            }
+        } else if path.exists() {
+            // Only include files that still exist
+            changed_files.push(path);
+        }
+    }
+
+    Ok(changed_files)
+}
+
+/// Files changed inside a submodule whose recorded commit moved
+///
+/// Changes are taken relative to the commit the parent recorded at the base
+/// ref, so they include the new commits as well as uncommitted edits.
+fn submodule_changed_files(submodule: &Path, entry: &RawDiffEntry) -> Vec<PathBuf> {
+    if nested_repo_kind(submodule).is_none() {
+        log_debug!(
+            "Skipping uninitialized submodule",
+            path = submodule.display()
+        );
+        return Vec::new();
+    }
+
+    let files = if entry.old_mode == GITLINK_MODE && !is_null_id(&entry.old_id) {
+        changed_files_in(submodule, &entry.old_id, true)
+    } else {
+        // A newly added submodule: all of its files are new
+        tracked_files(submodule)
+    };
+
+    files.unwrap_or_else(|e| {
+        log_warn!(
+            "Cannot resolve submodule changes",
+            path = submodule.display(),
+            error = e
+        );
+        Vec::new()
+    })
+}
+
+/// All files tracked by the repository at `repo_root`
+fn tracked_files(repo_root: &Path) -> Result<Vec<PathBuf>> {
+    let output = run_git(repo_root, &["ls-files"])?;
+    if !output.status.success() {
+        let stderr = String::from_utf8_lossy(&output.stderr);
+        return Err(AnalyzerError::validation_error(format!(
+            "Git ls-files failed in {}: {}",
+            repo_root.display(),
+            stderr.trim()
+        )));
+    }
+
+    Ok(String::from_utf8_lossy(&output.stdout)
         .lines()
-        .chain(staged_stdout.lines())
-        .filter(|line| !line.is_empty())
         .map(|line| repo_root.join(line))
-        .filter(|path| path.exists()) // Only include files that still exist
-        .collect();
+        .filter(|path| path.is_file())
+        .collect())
+}
 
-    // Deduplicate (a file could be in both diff and cached)
-    changed_files.sort();
-    changed_files.dedup();
+/// One entry of `git diff --raw` output
+#[derive(Debug, PartialEq)]
+struct RawDiffEntry {
+    old_mode: String,
+    new_mode: String,
+    old_id: String,
+    /// Path after the change (the new name of a rename)
+    path: String,
+}
 
-    Ok(changed_files)
+/// Parse `git diff --raw` output (`:<old mode> <new mode> <old id> <new id> <status>\t<path>`)
+fn parse_raw_diff(output: &str) -> Vec<RawDiffEntry> {
+    output
+        .lines()
+        .filter_map(|line| {
+            let (meta, paths) = line.strip_prefix(':')?.split_once('\t')?;
+            let mut fields = meta.split_whitespace();
+            Some(RawDiffEntry {
+                old_mode: fields.next()?.to_string(),
+                new_mode: fields.next()?.to_string(),
+                old_id: fields.next()?.to_string(),
+                path: paths.rsplit('\t').next()?.to_string(),
+            })
+        })
+        .collect()
+}
+
+/// Check if an object id is git's all-zero placeholder
+fn is_null_id(id: &str) -> bool {
+    id.bytes().all(|b| b == b'0')
+}
+
+/// Detect a git repository rooted at `dir`
+pub fn nested_repo_kind(dir: &Path) -> Option<NestedRepoKind> {
+    let metadata = std::fs::symlink_metadata(dir.join(".git")).ok()?;
+    if metadata.is_file() {
+        Some(NestedRepoKind::Submodule)
+    } else if metadata.is_dir() {
+        Some(NestedRepoKind::Repository)
+    } else {
+        None
+    }
+}
+
+/// Read the HEAD commit, branch and origin URL of a repository
+pub fn repo_metadata<P: AsRef<Path>>(path: P) -> Result<GitMetadata> {
+    let path = path.as_ref();
+
+    let commit = git_stdout(path, &["rev-parse", "HEAD"])?.ok_or_else(|| {
+        AnalyzerError::validation_error(format!(
+            "Cannot read the HEAD commit of {}",
+            path.display()
+        ))
+    })?;
+
+    Ok(GitMetadata {
+        commit,
+        branch: git_stdout(path, &["symbolic-ref", "--short", "-q", "HEAD"])?,
+        remote: git_stdout(path, &["config", "--get", "remote.origin.url"])?,
+    })
+}
+
+/// Write the tree of `revision` to a tar archive at `archive`
+///
+/// Run from a subdirectory of the repository, only that subdirectory is
+/// exported, with paths relative to it.
+pub fn export_revision<P: AsRef<Path>>(dir: P, revision: &str, archive: &Path) -> Result<()> {
+    let dir = dir.as_ref();
+    let archive_arg = archive.display().to_string();
+    let output = run_git(
+        dir,
+        &["archive", "--format=tar", "-o", &archive_arg, revision],
+    )?;
+
+    if !output.status.success() {
+        let stderr = String::from_utf8_lossy(&output.stderr);
+        return Err(AnalyzerError::validation_error(format!(
+            "Cannot export revision '{}' of {}: {}",
+            revision,
+            dir.display(),
+            stderr.trim()
+        )));
+    }
+    Ok(())
+}
+
+/// Run git in `dir`
+fn run_git(dir: &Path, args: &[&str]) -> Result<Output> {
+    Command::new("git")
+        .args(args)
+        .current_dir(dir)
+        .output()
+        .map_err(|e| {
+            AnalyzerError::validation_error(format!(
+                "Failed to execute git command: {}. Is git installed and in PATH?",
+                e
+            ))
+        })
+}
+
+/// Run git in `dir` and return its trimmed output, or `None` if it fails or prints nothing
+fn git_stdout(dir: &Path, args: &[&str]) -> Result<Option<String>> {
+    let output = run_git(dir, args)?;
+    if !output.status.success() {
+        return Ok(None);
+    }
+
+    let stdout = String::from_utf8_lossy(&output.stdout).trim().to_string();
+    Ok((!stdout.is_empty()).then_some(stdout))
 }
 
 /// Get the git repository root path
@@ -214,7 +424,7 @@ mod tests {
             .expect("Failed to stage file");
 
         // Get changed files since HEAD
-        let changed = get_changed_files(root, "HEAD").unwrap();
+        let changed = get_changed_files(root, "HEAD", true).unwrap();
 
         // new_file.rs should be in the list
         assert!(
@@ -228,7 +438,7 @@ mod tests {
     #[test]
     fn test_get_changed_files_invalid_ref() {
         let repo = create_git_repo();
-        let result = get_changed_files(repo.path(), "invalid_ref_that_does_not_exist");
+        let result = get_changed_files(repo.path(), "invalid_ref_that_does_not_exist", true);
         assert!(result.is_err());
     }
 
@@ -237,7 +447,7 @@ mod tests {
         let repo = create_git_repo();
 
         // No changes since HEAD - should return empty
-        let changed = get_changed_files(repo.path(), "HEAD").unwrap();
+        let changed = get_changed_files(repo.path(), "HEAD", true).unwrap();
         assert!(
             changed.is_empty(),
             "No changes expected immediately after commit"
@@ -257,7 +467,7 @@ mod tests {
         .unwrap();
 
         // Get changed files since HEAD
-        let changed = get_changed_files(root, "HEAD").unwrap();
+        let changed = get_changed_files(root, "HEAD", true).unwrap();
 
         // initial.rs should be in the list
         assert!(
@@ -267,4 +477,124 @@ mod tests {
             "Modified file should be in changed list"
         );
     }
+
+    fn git(dir: &Path, args: &[&str]) {
+        let output = Command::new("git")
+            .args(args)
+            .current_dir(dir)
+            .output()
+            .expect("Failed to run git");
+        assert!(
+            output.status.success(),
+            "git {:?} failed: {}",
+            args,
+            String::from_utf8_lossy(&output.stderr)
+        );
+    }
+
+    #[test]
+    fn test_parse_raw_diff() {
+        let output = ":100644 100644 1111 2222 M\tsrc/lib.rs\n\
+                      :160000 160000 3333 4444 M\tvendor/lib\n\
+                      :100644 100644 5555 6666 R090\told.rs\tnew.rs\n";
+        let entries = parse_raw_diff(output);
+
+        assert_eq!(entries.len(), 3);
+        assert_eq!(entries[0].path, "src/lib.rs");
+        assert_eq!(entries[1].new_mode, GITLINK_MODE);
+        assert_eq!(entries[1].old_id, "3333");
+        assert_eq!(entries[2].path, "new.rs");
+        assert!(is_null_id("0000000000"));
+        assert!(!is_null_id("3333"));
+    }
+
+    #[test]
+    fn test_nested_repo_kind() {
+        let dir = TempDir::new().unwrap();
+        let repo = dir.path().join("repo");
+        let submodule = dir.path().join("submodule");
+        fs::create_dir_all(repo.join(".git")).unwrap();
+        fs::create_dir_all(&submodule).unwrap();
+        fs::write(submodule.join(".git"), "gitdir: ../.git/modules/submodule").unwrap();
+
+        assert_eq!(nested_repo_kind(&repo), Some(NestedRepoKind::Repository));
+        assert_eq!(
+            nested_repo_kind(&submodule),
+            Some(NestedRepoKind::Submodule)
+        );
+        assert_eq!(nested_repo_kind(dir.path()), None);
+    }
+
+    #[test]
+    fn test_repo_metadata() {
+        let repo = create_git_repo();
+        let metadata = repo_metadata(repo.path()).unwrap();
+
+        assert_eq!(metadata.commit.len(), 40);
+        assert!(metadata.branch.is_some());
+        assert!(metadata.remote.is_none());
+    }
+
+    #[test]
+    fn test_export_revision() {
+        let repo = create_git_repo();
+        let root = repo.path();
+        fs::write(root.join("initial.rs"), "fn changed() {}").unwrap();
+        let archive = root.join("head.tar");
+
+        export_revision(root, "HEAD", &archive).unwrap();
+        let mut tar = tar::Archive::new(fs::File::open(&archive).unwrap());
+        // git also writes a global header holding the commit id
+        let names: Vec<String> = tar
+            .entries()
+            .unwrap()
+            .map(|entry| entry.unwrap())
+            .filter(|entry| entry.header().entry_type().is_file())
+            .map(|entry| entry.path().unwrap().display().to_string())
+            .collect();
+        assert_eq!(names, vec!["initial.rs"]);
+
+        assert!(export_revision(root, "no_such_ref", &archive).is_err());
+    }
+
+    #[test]
+    fn test_submodule_pointer_change_resolves_to_files() {
+        let upstream = create_git_repo();
+        let parent = create_git_repo();
+        let root = parent.path();
+
+        git(
+            root,
+            &[
+                "-c",
+                "protocol.file.allow=always",
+                "submodule",
+                "add",
+                &upstream.path().display().to_string(),
+                "vendor",
+            ],
+        );
+        git(root, &["commit", "-m", "Add submodule"]);
+
+        // Commit inside the submodule and record the new pointer
+        let submodule = root.join("vendor");
+        git(&submodule, &["config", "user.email", "test@test.com"]);
+        git(&submodule, &["config", "user.name", "Test User"]);
+        fs::write(submodule.join("changed.rs"), "fn changed() {}").unwrap();
+        git(&submodule, &["add", "changed.rs"]);
+        git(&submodule, &["commit", "-m", "Change"]);
+        git(root, &["add", "vendor"]);
+
+        let changed = get_changed_files(root, "HEAD", true).unwrap();
+        assert!(
+            changed.iter().any(|p| p.ends_with("vendor/changed.rs")),
+            "Files changed inside the submodule should be listed: {:?}",
+            changed
+        );
+        assert!(!changed.iter().any(|p| p.ends_with("vendor")));
+        assert!(!changed.iter().any(|p| p.ends_with("vendor/initial.rs")));
+
+        let without_submodules = get_changed_files(root, "HEAD", false).unwrap();
+        assert!(!without_submodules.iter().any(|p| p.starts_with(&submodule)));
+    }
 }
@@ -0,0 +1,510 @@
+//! Resolution of import statements to the analyzed files they refer to.
+//!
+//! Every import is parsed per language into a module path and resolved, best
+//! effort: relative imports (`./x`, `from . import x`, `super::x`) from the
+//! importing file, absolute ones by the longest path suffix any analyzed file
+//! has (`crate::domain::Order` names `src/domain.rs`). Imports of code outside
+//! the analyzed files (libraries, the standard library) resolve to nothing.
+
+use std::collections::{BTreeSet, HashMap};
+use std::path::{Component, Path, PathBuf};
+
+use super::language::SupportedLanguage;
+use super::parser::FileAnalysis;
+
+/// Where the module path of an import starts
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum Anchor {
+    /// Anywhere in the project: resolved by the longest matching suffix
+    Project,
+    /// The importing file's directory, `n` levels up (`./x`, `../x`, `.x`)
+    Directory(usize),
+    /// The importing file's own module, `n` levels up (Rust `self::`, `super::`)
+    Module(usize),
+}
+
+/// Module path named by an import
+#[derive(Debug, Clone, PartialEq, Eq)]
+struct ImportPath {
+    anchor: Anchor,
+    /// Path segments, possibly ending with items rather than modules
+    segments: Vec<String>,
+}
+
+impl ImportPath {
+    fn new<'s>(anchor: Anchor, segments: impl IntoIterator<Item = &'s str>) -> Option<Self> {
+        let segments: Vec<String> = segments
+            .into_iter()
+            .map(str::trim)
+            .filter(|s| !s.is_empty() && *s != "*")
+            .map(str::to_string)
+            .collect();
+        // A relative path may name its base itself (`use super::*`, `from . import *`)
+        (!segments.is_empty() || anchor != Anchor::Project).then_some(Self { anchor, segments })
+    }
+}
+
+/// Module paths named by an import statement (several for Rust use lists
+/// and Python imports of several names)
+fn import_paths(language: SupportedLanguage, statement: &str) -> Vec<ImportPath> {
+    match language {
+        SupportedLanguage::Rust => rust_import_paths(statement),
+        SupportedLanguage::JavaScript | SupportedLanguage::TypeScript | SupportedLanguage::Tsx => {
+            quoted(statement)
+                .and_then(js_import_path)
+                .into_iter()
+                .collect()
+        }
+        SupportedLanguage::Python => python_import_paths(statement),
+        SupportedLanguage::Java => {
+            let path = statement
+                .trim_start_matches("import")
+                .trim_end_matches(';')
+                .trim();
+            let path = path.strip_prefix("static ").unwrap_or(path);
+            ImportPath::new(Anchor::Project, path.split('.'))
+                .into_iter()
+                .collect()
+        }
+        // Only `#include`s name files; C++ `using` declarations name namespaces
+        SupportedLanguage::C | SupportedLanguage::Cpp => {
+            let Some(rest) = statement.strip_prefix("#include") else {
+                return Vec::new();
+            };
+            let header = quoted(rest).or_else(|| {
+                let start = rest.find('<')? + 1;
+                let end = rest[start..].find('>')? + start;
+                Some(&rest[start..end])
+            });
+            header
+                .and_then(|header| {
+                    let segments = header.split('/').filter(|s| !matches!(*s, "." | ".."));
+                    ImportPath::new(Anchor::Project, segments)
+                })
+                .into_iter()
+                .collect()
+        }
+        SupportedLanguage::Go => quoted(statement)
+            .and_then(|path| ImportPath::new(Anchor::Project, path.split('/')))
+            .into_iter()
+            .collect(),
+    }
+}
+
+/// `use a::b::{c, d::e};` names `a::b::c` and `a::b::d::e`
+fn rust_import_paths(statement: &str) -> Vec<ImportPath> {
+    let Some(start) = statement.find("use ") else {
+        return Vec::new();
+    };
+    let tree = statement[start + 4..].trim().trim_end_matches(';');
+
+    let mut paths = Vec::new();
+    for path in expand_use_tree("", tree) {
+        let path = path.split(" as ").next().unwrap_or(&path).replace(' ', "");
+        let mut segments: Vec<&str> = path.split("::").collect();
+        let anchor = match segments.first().copied() {
+            Some("crate") => {
+                segments.remove(0);
+                Anchor::Project
+            }
+            Some("self") => {
+                segments.remove(0);
+                Anchor::Module(0)
+            }
+            Some("super") => {
+                let up = segments.iter().take_while(|s| **s == "super").count();
+                segments.drain(..up);
+                Anchor::Module(up)
+            }
+            _ => Anchor::Project,
+        };
+        // `use super::{self, x}` names the module itself
+        segments.retain(|s| *s != "self");
+        paths.extend(ImportPath::new(anchor, segments));
+    }
+    paths
+}
+
+/// Expand the braces of a Rust use tree into full paths
+fn expand_use_tree(prefix: &str, tree: &str) -> Vec<String> {
+    let Some(open) = tree.find('{') else {
+        return vec![format!("{prefix}{tree}")];
+    };
+    let close = tree.rfind('}').unwrap_or(tree.len());
+    let head = format!("{prefix}{}", &tree[..open]);
+
+    let inner = &tree[open + 1..close];
+    let mut items = Vec::new();
+    let (mut depth, mut item_start) = (0, 0);
+    for (i, c) in inner.char_indices() {
+        match c {
+            '{' => depth += 1,
+            '}' => depth -= 1,
+            ',' if depth == 0 => {
+                items.push(&inner[item_start..i]);
+                item_start = i + 1;
+            }
+            _ => {}
+        }
+    }
+    items.push(&inner[item_start..]);
+
+    items
+        .into_iter()
+        .map(str::trim)
+        .filter(|item| !item.is_empty())
+        .flat_map(|item| expand_use_tree(&head, item))
+        .collect()
+}
+
+/// `./a/b`, `../a` and `@/a/b.js` (path aliases) name modules, bare package names too
+fn js_import_path(specifier: &str) -> Option<ImportPath> {
+    let mut segments: Vec<&str> = specifier.split('/').collect();
+    let anchor = if specifier.starts_with('.') {
+        let up = segments.iter().take_while(|s| **s == "..").count();
+        segments.retain(|s| !matches!(*s, "." | ".."));
+        Anchor::Directory(up)
+    } else {
+        if segments
+            .first()
+            .is_some_and(|s| s.starts_with('@') || *s == "~")
+        {
+            segments.remove(0);
+        }
+        Anchor::Project
+    };
+    // `./model.js` names `model.ts` in TypeScript projects
+    if let Some(last) = segments.last_mut() {
+        if let Some((stem, _)) = last.rsplit_once('.') {
+            *last = stem;
+        }
+    }
+    ImportPath::new(anchor, segments)
+}
+
+/// `import a.b, c as d` and `from ..a import b, c`
+fn python_import_paths(statement: &str) -> Vec<ImportPath> {
+    let without_alias = |name: &str| name.split(" as ").next().unwrap_or(name).trim().to_string();
+
+    if let Some(rest) = statement.strip_prefix("from ") {
+        let Some((module, names)) = rest.split_once(" import ") else {
+            return Vec::new();
+        };
+        let dots = module.chars().take_while(|c| *c == '.').count();
+        let anchor = match dots {
+            0 => Anchor::Project,
+            dots => Anchor::Directory(dots - 1),
+        };
+        let module: Vec<&str> = module[dots..].split('.').collect();
+        let names = names.trim().trim_start_matches('(').trim_end_matches(')');
+
+        // Imported names may be submodules or items of the module
+        let paths: Vec<ImportPath> = names
+            .split(',')
+            .map(without_alias)
+            .filter_map(|name| {
+                let segments = module.iter().copied().chain(std::iter::once(name.as_str()));
+                ImportPath::new(anchor, segments)
+            })
+            .collect();
+        if paths.is_empty() {
+            return ImportPath::new(anchor, module).into_iter().collect();
+        }
+        return paths;
+    }
+
+    let Some(rest) = statement.strip_prefix("import ") else {
+        return Vec::new();
+    };
+    rest.split(',')
+        .map(without_alias)
+        .filter_map(|module| ImportPath::new(Anchor::Project, module.split('.')))
+        .collect()
+}
+
+/// Contents of the first quoted string in `text`
+fn quoted(text: &str) -> Option<&str> {
+    let start = text.find(['"', '\'', '`'])?;
+    let quote = text[start..].chars().next()?;
+    let end = text[start + 1..].find(quote)? + start + 1;
+    Some(&text[start + 1..end])
+}
+
+/// Resolves the imports of a set of analyzed files to the files they refer to
+pub struct ImportResolver {
+    /// Paths relative to the analysis root, per file
+    relative: Vec<PathBuf>,
+    languages: Vec<Option<SupportedLanguage>>,
+    /// Module path segments per file
+    modules: Vec<Vec<String>>,
+    /// Directory segments per file
+    directories: Vec<Vec<String>>,
+    /// Files by the last segment of their module path
+    by_name: HashMap<String, Vec<usize>>,
+}
+
+impl ImportResolver {
+    /// Index `files`, analyzed under `root` (a directory or a single file)
+    pub fn new(files: &[FileAnalysis], root: &Path) -> Self {
+        let base = match root.parent() {
+            Some(parent) if root.is_file() => parent,
+            _ => root,
+        };
+        let relative: Vec<PathBuf> = files
+            .iter()
+            .map(|file| {
+                file.path
+                    .strip_prefix(base)
+                    .unwrap_or(&file.path)
+                    .components()
+                    .filter(|c| matches!(c, Component::Normal(_)))
+                    .collect()
+            })
+            .collect();
+
+        let segments = |path: &Path| -> Vec<String> {
+            path.iter()
+                .map(|s| s.to_string_lossy().into_owned())
+                .collect()
+        };
+        let directories: Vec<Vec<String>> = relative
+            .iter()
+            .map(|path| path.parent().map(segments).unwrap_or_default())
+            .collect();
+        let modules: Vec<Vec<String>> = files
+            .iter()
+            .zip(&relative)
+            .zip(&directories)
+            .map(|((file, path), directory)| module_path(&file.language, path, directory))
+            .collect();
+        let mut by_name: HashMap<String, Vec<usize>> = HashMap::new();
+        for (index, module) in modules.iter().enumerate() {
+            if let Some(name) = module.last() {
+                by_name.entry(name.clone()).or_default().push(index);
+            }
+        }
+        let languages = files
+            .iter()
+            .map(|file| file.language.parse::<SupportedLanguage>().ok())
+            .collect();
+
+        Self {
+            relative,
+            languages,
+            modules,
+            directories,
+            by_name,
+        }
+    }
+
+    /// Path of file `file` relative to the analysis root
+    pub fn relative_path(&self, file: usize) -> &Path {
+        &self.relative[file]
+    }
+
+    /// Other files an import statement of file `from` refers to
+    pub fn resolve(&self, from: usize, statement: &str) -> BTreeSet<usize> {
+        let Some(language) = self.languages[from] else {
+            return BTreeSet::new();
+        };
+        import_paths(language, statement)
+            .iter()
+            .flat_map(|path| self.resolve_path(from, path))
+            .collect()
+    }
+
+    /// Files an import path from file `from` can refer to
+    fn resolve_path(&self, from: usize, path: &ImportPath) -> Vec<usize> {
+        let (base, up) = match path.anchor {
+            Anchor::Project => (None, 0),
+            Anchor::Directory(up) => (Some(&self.directories[from]), up),
+            Anchor::Module(up) => (Some(&self.modules[from]), up),
+        };
+        let base = match base {
+            // Relative paths can't leave the analysis root
+            Some(base) => match base.len().checked_sub(up) {
+                Some(length) => Some(&base[..length]),
+                None => return Vec::new(),
+            },
+            None => None,
+        };
+
+        // The longest prefix of the path naming a module wins (`a::b::Item` names `a::b`)
+        let shortest = usize::from(base.is_none());
+        for length in (shortest..=path.segments.len()).rev() {
+            let wanted = &path.segments[..length];
+            // Only files whose module is named like the path's last segment can match
+            let name = wanted.last().or_else(|| base.and_then(|base| base.last()));
+            let Some(candidates) = name.and_then(|name| self.by_name.get(name)) else {
+                continue;
+            };
+            let found: Vec<usize> = candidates
+                .iter()
+                .copied()
+                .filter(|&index| {
+                    let module = &self.modules[index];
+                    index != from
+                        && module.ends_with(wanted)
+                        && base.is_none_or(|base| {
+                            module.len() == base.len() + length && module.starts_with(base)
+                        })
+                })
+                .collect();
+            if !found.is_empty() {
+                return found;
+            }
+        }
+        Vec::new()
+    }
+}
+
+/// Module path of a file: its path without extension, named after the
+/// directory for `mod.rs`, `lib.rs`, `main.rs`, `index.js` and `__init__.py`;
+/// Go packages are directories and C headers keep their file name
+fn module_path(language: &str, path: &Path, directory: &[String]) -> Vec<String> {
+    let mut module = directory.to_vec();
+    let file_name = path
+        .file_name()
+        .map(|name| name.to_string_lossy().into_owned())
+        .unwrap_or_default();
+    let stem = path
+        .file_stem()
+        .map(|stem| stem.to_string_lossy().into_owned())
+        .unwrap_or_default();
+
+    let names_directory = match language {
+        "rust" => matches!(stem.as_str(), "mod" | "lib" | "main"),
+        "javascript" | "typescript" => stem == "index",
+        "python" => stem == "__init__",
+        "go" => true,
+        _ => false,
+    };
+    if names_directory {
+        return module;
+    }
+    if matches!(language, "c" | "cpp") {
+        module.push(file_name);
+    } else {
+        module.push(stem);
+    }
+    module
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::analyzer::parser::fixtures::file;
+
+    fn segments(paths: Vec<ImportPath>) -> Vec<(Anchor, String)> {
+        paths
+            .into_iter()
+            .map(|path| (path.anchor, path.segments.join("/")))
+            .collect()
+    }
+
+    #[test]
+    fn test_rust_import_paths() {
+        assert_eq!(
+            segments(rust_import_paths(
+                "use crate::domain::{model::Order, self, repo as r};"
+            )),
+            vec![
+                (Anchor::Project, "domain/model/Order".to_string()),
+                (Anchor::Project, "domain".to_string()),
+                (Anchor::Project, "domain/repo".to_string()),
+            ]
+        );
+        assert_eq!(
+            segments(rust_import_paths("pub use super::super::infra::Db;")),
+            vec![(Anchor::Module(2), "infra/Db".to_string())]
+        );
+        assert_eq!(
+            segments(rust_import_paths("use super::*;")),
+            vec![(Anchor::Module(1), String::new())]
+        );
+    }
+
+    #[test]
+    fn test_import_paths_per_language() {
+        let paths = |language, statement| segments(import_paths(language, statement));
+
+        assert_eq!(
+            paths(
+                SupportedLanguage::TypeScript,
+                "import { Db } from '../infra/db.js';"
+            ),
+            vec![(Anchor::Directory(1), "infra/db".to_string())]
+        );
+        assert_eq!(
+            paths(
+                SupportedLanguage::JavaScript,
+                "import x from '@/api/client'"
+            ),
+            vec![(Anchor::Project, "api/client".to_string())]
+        );
+        assert_eq!(
+            paths(
+                SupportedLanguage::Python,
+                "from ..infra import db as d, cache"
+            ),
+            vec![
+                (Anchor::Directory(1), "infra/db".to_string()),
+                (Anchor::Directory(1), "infra/cache".to_string()),
+            ]
+        );
+        assert_eq!(
+            paths(SupportedLanguage::Python, "import app.infra.db, os"),
+            vec![
+                (Anchor::Project, "app/infra/db".to_string()),
+                (Anchor::Project, "os".to_string()),
+            ]
+        );
+        assert_eq!(
+            paths(
+                SupportedLanguage::Java,
+                "import static com.shop.infra.Db.connect;"
+            ),
+            vec![(Anchor::Project, "com/shop/infra/Db/connect".to_string())]
+        );
+        assert_eq!(
+            paths(SupportedLanguage::Cpp, "#include \"../infra/db.h\""),
+            vec![(Anchor::Project, "infra/db.h".to_string())]
+        );
+        assert!(paths(SupportedLanguage::Cpp, "using namespace std;").is_empty());
+        assert_eq!(
+            paths(
+                SupportedLanguage::Go,
+                "import db \"example.com/shop/infra\""
+            ),
+            vec![(Anchor::Project, "example.com/shop/infra".to_string())]
+        );
+    }
+
+    #[test]
+    fn test_resolver() {
+        let files = vec![
+            file("app/src/lib.rs"),
+            file("app/src/shop/mod.rs"),
+            file("app/src/shop/cart.rs"),
+            file("app/pkg/orders/__init__.py").with_language("python"),
+            file("app/pkg/orders/models.py").with_language("python"),
+            file("app/pkg/views.py").with_language("python"),
+        ];
+        let resolver = ImportResolver::new(&files, Path::new("app"));
+        assert_eq!(resolver.relative_path(2), Path::new("src/shop/cart.rs"));
+
+        let resolve = |from, statement| {
+            resolver
+                .resolve(from, statement)
+                .into_iter()
+                .collect::<Vec<_>>()
+        };
+        assert_eq!(resolve(2, "use super::Shop;"), vec![1]);
+        assert_eq!(resolve(0, "use crate::shop::cart::Cart;"), vec![2]);
+        assert_eq!(resolve(1, "use self::cart::{Cart, Item};"), vec![2]);
+        assert_eq!(resolve(0, "use serde::Serialize;"), Vec::<usize>::new());
+        assert_eq!(resolve(5, "from .orders import models"), vec![4]);
+        assert_eq!(resolve(5, "from .orders import Order"), vec![3]);
+        assert_eq!(resolve(4, "import os"), Vec::<usize>::new());
+    }
+}
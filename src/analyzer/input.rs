@@ -0,0 +1,175 @@
+//! Explicit inputs that bypass the directory walker (`--files-from`, `--stdin`).
+//!
+//! File lists are newline-separated, or NUL-separated if they contain a NUL
+//! byte (`git ls-files -z`, `fd -0`, `find -print0`). `-` reads from stdin.
+
+use std::io::Read;
+use std::path::{Path, PathBuf};
+
+use super::language::{LanguageManager, SupportedLanguage};
+use crate::cli::CliArgs;
+use crate::error::{AnalyzerError, Result};
+
+/// Path argument that stands for stdin
+pub const STDIN_ARG: &str = "-";
+
+/// Path a `--stdin` buffer is reported under without `--stdin-filename`
+pub const STDIN_PATH: &str = "<stdin>";
+
+/// Read the list of files to analyze from `source` (`-` for stdin)
+pub fn read_file_list(source: &Path) -> Result<Vec<PathBuf>> {
+    let bytes = if source == Path::new(STDIN_ARG) {
+        read_stdin()?
+    } else {
+        std::fs::read(source).map_err(|e| {
+            AnalyzerError::config_error(format!("Cannot read file list {}: {e}", source.display()))
+        })?
+    };
+    Ok(split_file_list(&bytes))
+}
+
+/// Split a file list on NUL bytes if it has any, otherwise on newlines
+fn split_file_list(bytes: &[u8]) -> Vec<PathBuf> {
+    let separator = if bytes.contains(&0) { 0 } else { b'\n' };
+    bytes
+        .split(|&b| b == separator)
+        .map(|entry| {
+            if separator == b'\n' {
+                entry.strip_suffix(b"\r").unwrap_or(entry)
+            } else {
+                entry
+            }
+        })
+        .filter(|entry| !entry.is_empty())
+        .map(path_from_bytes)
+        .collect()
+}
+
+#[cfg(unix)]
+fn path_from_bytes(bytes: &[u8]) -> PathBuf {
+    use std::os::unix::ffi::OsStrExt;
+    PathBuf::from(std::ffi::OsStr::from_bytes(bytes))
+}
+
+#[cfg(not(unix))]
+fn path_from_bytes(bytes: &[u8]) -> PathBuf {
+    PathBuf::from(String::from_utf8_lossy(bytes).into_owned())
+}
+
+/// Read all of stdin
+pub fn read_stdin() -> Result<Vec<u8>> {
+    let mut bytes = Vec::new();
+    std::io::stdin()
+        .lock()
+        .read_to_end(&mut bytes)
+        .map_err(|e| AnalyzerError::config_error(format!("Cannot read stdin: {e}")))?;
+    Ok(bytes)
+}
+
+/// A single source buffer read from stdin (`--stdin`)
+#[derive(Debug, Clone, PartialEq)]
+pub struct StdinSource {
+    /// Path the buffer is reported under
+    pub path: PathBuf,
+    pub language: SupportedLanguage,
+}
+
+impl StdinSource {
+    /// Resolve the reported path and language of `--stdin` (`None` without `--stdin`)
+    ///
+    /// `--stdin-language` wins over the extension of `--stdin-filename`.
+    pub fn from_cli(args: &CliArgs) -> Result<Option<Self>> {
+        if !args.stdin {
+            return Ok(None);
+        }
+
+        let path = args
+            .stdin_filename
+            .clone()
+            .unwrap_or_else(|| PathBuf::from(STDIN_PATH));
+        let language = match (&args.stdin_language, &args.stdin_filename) {
+            (Some(language), _) => language.parse()?,
+            (None, Some(filename)) => LanguageManager::new()
+                .detect_language(filename)
+                .ok_or_else(|| {
+                    AnalyzerError::validation_error(format!(
+                        "Cannot detect the language of --stdin-filename {}; pass --stdin-language",
+                        filename.display()
+                    ))
+                })?,
+            (None, None) => {
+                return Err(AnalyzerError::validation_error(
+                    "--stdin needs --stdin-language or --stdin-filename",
+                ))
+            }
+        };
+
+        Ok(Some(Self { path, language }))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_split_newline_list() {
+        assert_eq!(
+            split_file_list(b"src/main.rs\r\nsrc/lib.rs\n\nsrc/a b.py\n"),
+            vec![
+                PathBuf::from("src/main.rs"),
+                PathBuf::from("src/lib.rs"),
+                PathBuf::from("src/a b.py"),
+            ]
+        );
+        assert!(split_file_list(b"").is_empty());
+    }
+
+    #[test]
+    fn test_split_nul_list() {
+        // Newlines are part of the name once the list is NUL-separated
+        assert_eq!(
+            split_file_list(b"src/main.rs\0odd\nname.rs\0"),
+            vec![PathBuf::from("src/main.rs"), PathBuf::from("odd\nname.rs")]
+        );
+    }
+
+    #[test]
+    fn test_read_file_list_from_file() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let list = dir.path().join("files.txt");
+        std::fs::write(&list, "a.rs\nb.go\n").unwrap();
+
+        assert_eq!(
+            read_file_list(&list).unwrap(),
+            vec![PathBuf::from("a.rs"), PathBuf::from("b.go")]
+        );
+        let err = read_file_list(&dir.path().join("missing.txt")).unwrap_err();
+        assert!(err.to_string().contains("Cannot read file list"));
+    }
+
+    #[test]
+    fn test_stdin_source_from_cli() {
+        let mut args = CliArgs {
+            stdin: true,
+            ..Default::default()
+        };
+        assert!(StdinSource::from_cli(&args).is_err());
+
+        args.stdin_filename = Some(PathBuf::from("src/foo.rs"));
+        let source = StdinSource::from_cli(&args).unwrap().unwrap();
+        assert_eq!(source.path, PathBuf::from("src/foo.rs"));
+        assert_eq!(source.language, SupportedLanguage::Rust);
+
+        args.stdin_language = Some("python".to_string());
+        let source = StdinSource::from_cli(&args).unwrap().unwrap();
+        assert_eq!(source.language, SupportedLanguage::Python);
+
+        args.stdin_filename = None;
+        let source = StdinSource::from_cli(&args).unwrap().unwrap();
+        assert_eq!(source.path, PathBuf::from(STDIN_PATH));
+
+        args.stdin = false;
+        assert!(StdinSource::from_cli(&args).unwrap().is_none());
+    }
+}
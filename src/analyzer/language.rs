@@ -5,6 +5,11 @@ use tree_sitter::{Language, Parser};
 
 struct LanguageSpec {
     function_nodes: &'static [&'static str],
+    /// Closures, lambdas and function expressions, which have no declared name
+    anonymous_function_nodes: &'static [&'static str],
+    /// Nodes binding an anonymous function to a name, with the field holding the
+    /// name: `const f = () => {}` declares a function `f`
+    function_bindings: &'static [(&'static str, &'static str)],
     class_nodes: &'static [&'static str],
     control_flow_nodes: &'static [&'static str],
     comment_nodes: &'static [&'static str],
@@ -12,10 +17,20 @@ struct LanguageSpec {
     nesting_nodes: &'static [&'static str],
     binary_expr_node: Option<&'static str>,
     logical_operators: &'static [&'static str],
+    call_nodes: &'static [&'static str],
+    /// Field of a call node holding the called expression
+    call_target_field: &'static str,
+    import_nodes: &'static [&'static str],
+    /// Declarations that can be part of the public API
+    api_nodes: &'static [&'static str],
+    /// Types and other containers listed with their functions in the symbol outline
+    outline_nodes: &'static [&'static str],
 }
 
 static RUST_SPEC: LanguageSpec = LanguageSpec {
     function_nodes: &["function_item"],
+    anonymous_function_nodes: &["closure_expression"],
+    function_bindings: &[],
     class_nodes: &["struct_item", "enum_item", "impl_item"],
     control_flow_nodes: &[
         "if_expression",
@@ -37,14 +52,39 @@ static RUST_SPEC: LanguageSpec = LanguageSpec {
     ],
     binary_expr_node: Some("binary_expression"),
     logical_operators: &["&&", "||"],
+    call_nodes: &["call_expression"],
+    call_target_field: "function",
+    import_nodes: &["use_declaration"],
+    api_nodes: &[
+        "function_item",
+        "function_signature_item",
+        "struct_item",
+        "enum_item",
+        "union_item",
+        "trait_item",
+        "type_item",
+        "const_item",
+        "static_item",
+    ],
+    outline_nodes: &[
+        "struct_item",
+        "enum_item",
+        "union_item",
+        "trait_item",
+        "impl_item",
+        "mod_item",
+    ],
 };
 
 static JS_SPEC: LanguageSpec = LanguageSpec {
-    function_nodes: &[
-        "function_declaration",
-        "function_expression",
-        "arrow_function",
-        "method_definition",
+    function_nodes: &["function_declaration", "method_definition"],
+    anonymous_function_nodes: &["function_expression", "arrow_function"],
+    function_bindings: &[
+        ("variable_declarator", "name"),
+        ("pair", "key"),
+        ("assignment_expression", "left"),
+        ("field_definition", "property"),
+        ("public_field_definition", "name"),
     ],
     class_nodes: &["class_declaration"],
     control_flow_nodes: &[
@@ -71,16 +111,34 @@ static JS_SPEC: LanguageSpec = LanguageSpec {
     ],
     binary_expr_node: Some("binary_expression"),
     logical_operators: &["&&", "||"],
+    call_nodes: &["call_expression"],
+    call_target_field: "function",
+    import_nodes: &["import_statement"],
+    api_nodes: &[
+        "function_declaration",
+        "generator_function_declaration",
+        "class_declaration",
+        "lexical_declaration",
+        "variable_declaration",
+        "method_definition",
+    ],
+    outline_nodes: &["class_declaration", "class"],
 };
 
 static TS_SPEC: LanguageSpec = LanguageSpec {
     function_nodes: &[
         "function_declaration",
-        "function_expression",
-        "arrow_function",
         "method_definition",
         "method_signature",
     ],
+    anonymous_function_nodes: &["function_expression", "arrow_function"],
+    function_bindings: &[
+        ("variable_declarator", "name"),
+        ("pair", "key"),
+        ("assignment_expression", "left"),
+        ("field_definition", "property"),
+        ("public_field_definition", "name"),
+    ],
     class_nodes: &["class_declaration", "interface_declaration"],
     control_flow_nodes: &[
         "if_statement",
@@ -106,10 +164,37 @@ static TS_SPEC: LanguageSpec = LanguageSpec {
     ],
     binary_expr_node: Some("binary_expression"),
     logical_operators: &["&&", "||"],
+    call_nodes: &["call_expression"],
+    call_target_field: "function",
+    import_nodes: &["import_statement"],
+    api_nodes: &[
+        "function_declaration",
+        "generator_function_declaration",
+        "function_signature",
+        "class_declaration",
+        "abstract_class_declaration",
+        "interface_declaration",
+        "type_alias_declaration",
+        "enum_declaration",
+        "lexical_declaration",
+        "variable_declaration",
+        "method_definition",
+        "method_signature",
+        "abstract_method_signature",
+    ],
+    outline_nodes: &[
+        "class_declaration",
+        "class",
+        "abstract_class_declaration",
+        "interface_declaration",
+        "enum_declaration",
+    ],
 };
 
 static PYTHON_SPEC: LanguageSpec = LanguageSpec {
     function_nodes: &["function_definition"],
+    anonymous_function_nodes: &["lambda"],
+    function_bindings: &[],
     class_nodes: &["class_definition"],
     control_flow_nodes: &[
         "if_statement",
@@ -136,10 +221,17 @@ static PYTHON_SPEC: LanguageSpec = LanguageSpec {
     ],
     binary_expr_node: None,
     logical_operators: &[],
+    call_nodes: &["call"],
+    call_target_field: "function",
+    import_nodes: &["import_statement", "import_from_statement"],
+    api_nodes: &["function_definition", "class_definition"],
+    outline_nodes: &["class_definition"],
 };
 
 static JAVA_SPEC: LanguageSpec = LanguageSpec {
     function_nodes: &["method_declaration", "constructor_declaration"],
+    anonymous_function_nodes: &["lambda_expression"],
+    function_bindings: &[],
     class_nodes: &[
         "class_declaration",
         "interface_declaration",
@@ -168,10 +260,29 @@ static JAVA_SPEC: LanguageSpec = LanguageSpec {
     ],
     binary_expr_node: Some("binary_expression"),
     logical_operators: &["&&", "||"],
+    call_nodes: &["method_invocation"],
+    call_target_field: "name",
+    import_nodes: &["import_declaration"],
+    api_nodes: &[
+        "class_declaration",
+        "interface_declaration",
+        "enum_declaration",
+        "record_declaration",
+        "method_declaration",
+        "constructor_declaration",
+    ],
+    outline_nodes: &[
+        "class_declaration",
+        "interface_declaration",
+        "enum_declaration",
+        "record_declaration",
+    ],
 };
 
 static C_SPEC: LanguageSpec = LanguageSpec {
     function_nodes: &["function_definition"],
+    anonymous_function_nodes: &[],
+    function_bindings: &[],
     class_nodes: &["struct_specifier"],
     control_flow_nodes: &[
         "if_statement",
@@ -192,10 +303,17 @@ static C_SPEC: LanguageSpec = LanguageSpec {
     ],
     binary_expr_node: Some("binary_expression"),
     logical_operators: &["&&", "||"],
+    call_nodes: &["call_expression"],
+    call_target_field: "function",
+    import_nodes: &["preproc_include"],
+    api_nodes: &["function_definition", "declaration"],
+    outline_nodes: &["struct_specifier", "union_specifier", "enum_specifier"],
 };
 
 static CPP_SPEC: LanguageSpec = LanguageSpec {
     function_nodes: &["function_definition", "function_declarator"],
+    anonymous_function_nodes: &["lambda_expression"],
+    function_bindings: &[],
     class_nodes: &["class_specifier", "struct_specifier"],
     control_flow_nodes: &[
         "if_statement",
@@ -219,10 +337,29 @@ static CPP_SPEC: LanguageSpec = LanguageSpec {
     ],
     binary_expr_node: Some("binary_expression"),
     logical_operators: &["&&", "||"],
+    call_nodes: &["call_expression"],
+    call_target_field: "function",
+    import_nodes: &["preproc_include", "using_declaration"],
+    api_nodes: &[
+        "function_definition",
+        "declaration",
+        "field_declaration",
+        "class_specifier",
+        "struct_specifier",
+    ],
+    outline_nodes: &[
+        "class_specifier",
+        "struct_specifier",
+        "union_specifier",
+        "enum_specifier",
+        "namespace_definition",
+    ],
 };
 
 static GO_SPEC: LanguageSpec = LanguageSpec {
     function_nodes: &["function_declaration", "method_declaration"],
+    anonymous_function_nodes: &["func_literal"],
+    function_bindings: &[],
     class_nodes: &["type_declaration"],
     control_flow_nodes: &[
         "if_statement",
@@ -242,6 +379,11 @@ static GO_SPEC: LanguageSpec = LanguageSpec {
     ],
     binary_expr_node: Some("binary_expression"),
     logical_operators: &["&&", "||"],
+    call_nodes: &["call_expression"],
+    call_target_field: "function",
+    import_nodes: &["import_declaration"],
+    api_nodes: &["function_declaration", "method_declaration", "type_spec"],
+    outline_nodes: &["type_spec"],
 };
 
 /// Supported programming languages with their tree-sitter grammars
@@ -353,6 +495,13 @@ pub trait NodeKindMapper {
     /// Check if a node kind represents a function declaration
     fn is_function_node(&self, kind: &str) -> bool;
 
+    /// Check if a node kind represents an anonymous function (closure, lambda, arrow function)
+    fn is_anonymous_function_node(&self, kind: &str) -> bool;
+
+    /// Field naming the function bound by a node of this kind, if it binds
+    /// anonymous functions to names (variable declarators, object pairs, ...)
+    fn function_binding_field(&self, kind: &str) -> Option<&'static str>;
+
     /// Check if a node kind represents a class or struct declaration
     fn is_class_node(&self, kind: &str) -> bool;
 
@@ -391,6 +540,21 @@ pub trait NodeKindMapper {
 
     /// Get node kinds that contribute to nesting depth (for max nesting metric)
     fn nesting_node_kinds(&self) -> &'static [&'static str];
+
+    /// Check if a node kind represents a function or method call
+    fn is_call_node(&self, kind: &str) -> bool;
+
+    /// Get the field of a call node that holds the called expression
+    fn call_target_field(&self) -> &'static str;
+
+    /// Check if a node kind represents an import (use, import, #include)
+    fn is_import_node(&self, kind: &str) -> bool;
+
+    /// Check if a node kind is a declaration that can be part of the public API
+    fn is_api_node(&self, kind: &str) -> bool;
+
+    /// Check if a node kind is a type or other container listed in the symbol outline
+    fn is_outline_node(&self, kind: &str) -> bool;
 }
 
 impl NodeKindMapper for SupportedLanguage {
@@ -398,6 +562,18 @@ impl NodeKindMapper for SupportedLanguage {
         self.function_node_kinds().contains(&kind)
     }
 
+    fn is_anonymous_function_node(&self, kind: &str) -> bool {
+        self.spec().anonymous_function_nodes.contains(&kind)
+    }
+
+    fn function_binding_field(&self, kind: &str) -> Option<&'static str> {
+        self.spec()
+            .function_bindings
+            .iter()
+            .find(|(binding, _)| *binding == kind)
+            .map(|(_, field)| *field)
+    }
+
     fn is_class_node(&self, kind: &str) -> bool {
         self.class_node_kinds().contains(&kind)
     }
@@ -450,6 +626,26 @@ impl NodeKindMapper for SupportedLanguage {
     fn nesting_node_kinds(&self) -> &'static [&'static str] {
         self.spec().nesting_nodes
     }
+
+    fn is_call_node(&self, kind: &str) -> bool {
+        self.spec().call_nodes.contains(&kind)
+    }
+
+    fn call_target_field(&self) -> &'static str {
+        self.spec().call_target_field
+    }
+
+    fn is_import_node(&self, kind: &str) -> bool {
+        self.spec().import_nodes.contains(&kind)
+    }
+
+    fn is_api_node(&self, kind: &str) -> bool {
+        self.spec().api_nodes.contains(&kind)
+    }
+
+    fn is_outline_node(&self, kind: &str) -> bool {
+        self.spec().outline_nodes.contains(&kind)
+    }
 }
 
 /// Language detection and parser management
@@ -646,6 +842,14 @@ mod tests {
         let js = SupportedLanguage::JavaScript;
         assert!(js.is_function_node("function_declaration"));
         assert!(js.is_class_node("class_declaration"));
+
+        // Anonymous functions are kept apart from named ones in every language
+        assert!(js.is_anonymous_function_node("arrow_function"));
+        assert!(!js.is_function_node("arrow_function"));
+        assert!(rust.is_anonymous_function_node("closure_expression"));
+        assert!(SupportedLanguage::Python.is_anonymous_function_node("lambda"));
+        assert!(SupportedLanguage::Go.is_anonymous_function_node("func_literal"));
+        assert!(!SupportedLanguage::C.is_anonymous_function_node("function_definition"));
     }
 
     #[test]
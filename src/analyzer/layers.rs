@@ -0,0 +1,351 @@
+//! Architecture layering rules on imports (`--layer-rules`).
+//!
+//! Layers are declared as path globs (gitignore syntax, relative to the
+//! analysis root) and rules say which layers a layer must not, or may only,
+//! depend on. Imports are resolved to the analyzed files they refer to (see
+//! `imports`); imports of code outside the analyzed files (libraries, the
+//! standard library) and of files outside every layer are never violations.
+
+use ignore::gitignore::{Gitignore, GitignoreBuilder};
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeSet;
+use std::path::{Path, PathBuf};
+
+use super::imports::ImportResolver;
+use super::parser::FileAnalysis;
+use crate::error::{AnalyzerError, Result};
+
+/// An import that breaks a layering rule
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct LayerViolation {
+    /// File containing the import
+    pub path: PathBuf,
+    /// Line of the import (1-based)
+    pub line: usize,
+    /// The import statement, whitespace collapsed
+    pub import: String,
+    pub from_layer: String,
+    pub to_layer: String,
+    /// The rule broken, e.g. "domain must not depend on infra"
+    pub rule: String,
+}
+
+/// A named group of files
+#[derive(Debug, Clone)]
+struct Layer {
+    name: String,
+    matcher: Gitignore,
+}
+
+/// Allowed dependency directions of a layer
+#[derive(Debug, Clone, PartialEq)]
+enum LayerRule {
+    MustNotDependOn { layer: String, layers: Vec<String> },
+    MayOnlyDependOn { layer: String, layers: Vec<String> },
+}
+
+impl LayerRule {
+    fn layer(&self) -> &str {
+        match self {
+            LayerRule::MustNotDependOn { layer, .. } | LayerRule::MayOnlyDependOn { layer, .. } => {
+                layer
+            }
+        }
+    }
+
+    /// Check if a dependency of the rule's layer on `target` breaks the rule
+    fn forbids(&self, target: &str) -> bool {
+        match self {
+            LayerRule::MustNotDependOn { layers, .. } => layers.iter().any(|l| l == target),
+            LayerRule::MayOnlyDependOn { layers, .. } => !layers.iter().any(|l| l == target),
+        }
+    }
+
+    fn describe(&self) -> String {
+        match self {
+            LayerRule::MustNotDependOn { layer, layers } => {
+                format!("{layer} must not depend on {}", layers.join(", "))
+            }
+            LayerRule::MayOnlyDependOn { layer, layers } => {
+                format!("{layer} may only depend on {}", layers.join(", "))
+            }
+        }
+    }
+}
+
+/// On-disk form of a layer
+#[derive(Debug, Deserialize)]
+#[serde(deny_unknown_fields)]
+struct LayerSpec {
+    name: String,
+    paths: Vec<String>,
+}
+
+/// On-disk form of a layering rule
+#[derive(Debug, Deserialize)]
+#[serde(deny_unknown_fields)]
+struct LayerRuleSpec {
+    layer: String,
+    must_not_depend_on: Option<Vec<String>>,
+    may_only_depend_on: Option<Vec<String>>,
+}
+
+/// On-disk form of a layer rules file
+#[derive(Debug, Deserialize)]
+#[serde(deny_unknown_fields)]
+struct LayerRulesFile {
+    layers: Vec<LayerSpec>,
+    #[serde(default)]
+    rules: Vec<LayerRuleSpec>,
+}
+
+/// Declared layers and the dependency rules between them
+#[derive(Debug, Clone, Default)]
+pub struct LayerRules {
+    layers: Vec<Layer>,
+    rules: Vec<LayerRule>,
+}
+
+impl LayerRules {
+    /// Load layers and rules from a JSON file
+    ///
+    /// ```json
+    /// {"layers": [{"name": "domain", "paths": ["src/domain/**"]},
+    ///             {"name": "infra", "paths": ["src/infra/**"]}],
+    ///  "rules": [{"layer": "domain", "must_not_depend_on": ["infra"]}]}
+    /// ```
+    pub fn load<P: AsRef<Path>>(path: P) -> Result<Self> {
+        let path = path.as_ref();
+        let content = std::fs::read_to_string(path).map_err(|e| {
+            AnalyzerError::config_error(format!("Cannot read layer rules {}: {e}", path.display()))
+        })?;
+        Self::from_json(&content)
+    }
+
+    /// Parse layers and rules from their JSON representation
+    pub fn from_json(json: &str) -> Result<Self> {
+        let file: LayerRulesFile = serde_json::from_str(json)
+            .map_err(|e| AnalyzerError::config_error(format!("Invalid layer rules: {e}")))?;
+
+        let mut layers: Vec<Layer> = Vec::new();
+        for spec in file.layers {
+            if layers.iter().any(|layer| layer.name == spec.name) {
+                return Err(AnalyzerError::config_error(format!(
+                    "layer '{}' is declared twice",
+                    spec.name
+                )));
+            }
+            let mut builder = GitignoreBuilder::new("");
+            for pattern in &spec.paths {
+                builder.add_line(None, pattern).map_err(|e| {
+                    AnalyzerError::config_error(format!(
+                        "layer '{}': invalid pattern '{pattern}': {e}",
+                        spec.name
+                    ))
+                })?;
+            }
+            let matcher = builder
+                .build()
+                .map_err(|e| AnalyzerError::config_error(format!("layer '{}': {e}", spec.name)))?;
+            layers.push(Layer {
+                name: spec.name,
+                matcher,
+            });
+        }
+
+        let rules = file
+            .rules
+            .into_iter()
+            .map(|spec| match (spec.must_not_depend_on, spec.may_only_depend_on) {
+                (Some(layers), None) => Ok(LayerRule::MustNotDependOn {
+                    layer: spec.layer,
+                    layers,
+                }),
+                (None, Some(layers)) => Ok(LayerRule::MayOnlyDependOn {
+                    layer: spec.layer,
+                    layers,
+                }),
+                _ => Err(AnalyzerError::config_error(format!(
+                    "rule for layer '{}': set exactly one of 'must_not_depend_on' or 'may_only_depend_on'",
+                    spec.layer
+                ))),
+            })
+            .collect::<Result<Vec<_>>>()?;
+
+        for rule in &rules {
+            let (LayerRule::MustNotDependOn {
+                layer,
+                layers: names,
+            }
+            | LayerRule::MayOnlyDependOn {
+                layer,
+                layers: names,
+            }) = rule;
+            let unknown = std::iter::once(layer)
+                .chain(names)
+                .find(|name| !layers.iter().any(|l| &l.name == *name));
+            if let Some(unknown) = unknown {
+                return Err(AnalyzerError::config_error(format!(
+                    "rule for layer '{layer}': unknown layer '{unknown}'"
+                )));
+            }
+        }
+
+        Ok(Self { layers, rules })
+    }
+
+    /// Check if no layers are declared
+    pub fn is_empty(&self) -> bool {
+        self.layers.is_empty()
+    }
+
+    /// Name of the first declared layer containing `path` (relative to the analysis root)
+    pub fn layer_of(&self, path: &Path) -> Option<&str> {
+        self.layers
+            .iter()
+            .find(|layer| {
+                layer
+                    .matcher
+                    .matched_path_or_any_parents(path, false)
+                    .is_ignore()
+            })
+            .map(|layer| layer.name.as_str())
+    }
+
+    /// Check the imports of `files`, analyzed under `root`, against the rules
+    pub fn check(&self, files: &[FileAnalysis], root: &Path) -> Vec<LayerViolation> {
+        let resolver = ImportResolver::new(files, root);
+        let file_layers: Vec<Option<&str>> = (0..files.len())
+            .map(|file| self.layer_of(resolver.relative_path(file)))
+            .collect();
+
+        let mut violations = Vec::new();
+        for (from, file) in files.iter().enumerate() {
+            let Some(from_layer) = file_layers[from] else {
+                continue;
+            };
+            let rules: Vec<&LayerRule> = self
+                .rules
+                .iter()
+                .filter(|rule| rule.layer() == from_layer)
+                .collect();
+            if rules.is_empty() {
+                continue;
+            }
+
+            for import in &file.imports {
+                let to_layers: BTreeSet<&str> = resolver
+                    .resolve(from, &import.statement)
+                    .into_iter()
+                    .filter_map(|target| file_layers[target])
+                    .filter(|&layer| layer != from_layer)
+                    .collect();
+                for to_layer in to_layers {
+                    if let Some(rule) = rules.iter().find(|rule| rule.forbids(to_layer)) {
+                        violations.push(LayerViolation {
+                            path: file.path.clone(),
+                            line: import.line,
+                            import: import.statement.clone(),
+                            from_layer: from_layer.to_string(),
+                            to_layer: to_layer.to_string(),
+                            rule: rule.describe(),
+                        });
+                    }
+                }
+            }
+        }
+
+        violations.sort_by(|a, b| (&a.path, a.line).cmp(&(&b.path, b.line)));
+        violations
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::analyzer::parser::fixtures::file;
+
+    const RULES: &str = r#"{
+        "layers": [
+            {"name": "domain", "paths": ["src/domain/**"]},
+            {"name": "infra", "paths": ["src/infra/**"]},
+            {"name": "api", "paths": ["src/api/**"]},
+            {"name": "ui", "paths": ["src/ui/**"]}
+        ],
+        "rules": [
+            {"layer": "domain", "must_not_depend_on": ["infra", "ui"]},
+            {"layer": "ui", "may_only_depend_on": ["api"]}
+        ]
+    }"#;
+
+    #[test]
+    fn test_invalid_rules() {
+        let unknown = r#"{"layers": [{"name": "domain", "paths": ["src/domain/**"]}],
+                          "rules": [{"layer": "domain", "must_not_depend_on": ["infra"]}]}"#;
+        let error = LayerRules::from_json(unknown).unwrap_err().to_string();
+        assert!(error.contains("unknown layer 'infra'"), "{error}");
+
+        let both = r#"{"layers": [{"name": "a", "paths": ["a/**"]}],
+                       "rules": [{"layer": "a", "must_not_depend_on": [], "may_only_depend_on": []}]}"#;
+        assert!(LayerRules::from_json(both).is_err());
+
+        let twice =
+            r#"{"layers": [{"name": "a", "paths": ["a/**"]}, {"name": "a", "paths": ["b/**"]}]}"#;
+        assert!(LayerRules::from_json(twice).is_err());
+    }
+
+    #[test]
+    fn test_layer_of() {
+        let rules = LayerRules::from_json(RULES).unwrap();
+        assert_eq!(
+            rules.layer_of(Path::new("src/domain/order.rs")),
+            Some("domain")
+        );
+        assert_eq!(
+            rules.layer_of(Path::new("src/infra/db/mod.rs")),
+            Some("infra")
+        );
+        assert_eq!(rules.layer_of(Path::new("src/main.rs")), None);
+    }
+
+    #[test]
+    fn test_check_reports_violations() {
+        let rules = LayerRules::from_json(RULES).unwrap();
+        let files = vec![
+            file("/project/src/domain/order.rs").with_imports(&[
+                "use std::collections::HashMap;",
+                "use crate::domain::money::Money;",
+                "use crate::infra::db::{Pool, connect};",
+            ]),
+            file("/project/src/domain/money.rs"),
+            file("/project/src/infra/db.rs").with_imports(&["use crate::domain::order::Order;"]),
+            file("/project/src/ui/app.ts")
+                .with_language("typescript")
+                .with_imports(&[
+                    "import { client } from '../api/client';",
+                    "import { Order } from '../domain/order';",
+                    "import React from 'react';",
+                ]),
+            file("/project/src/api/client.ts").with_language("typescript"),
+            file("/project/src/domain/order.ts").with_language("typescript"),
+        ];
+
+        let violations = rules.check(&files, Path::new("/project"));
+        assert_eq!(violations.len(), 2, "{violations:#?}");
+
+        let domain = &violations[0];
+        assert_eq!(domain.path, PathBuf::from("/project/src/domain/order.rs"));
+        assert_eq!(domain.line, 3);
+        assert_eq!(domain.import, "use crate::infra::db::{Pool, connect};");
+        assert_eq!(
+            (domain.from_layer.as_str(), domain.to_layer.as_str()),
+            ("domain", "infra")
+        );
+        assert_eq!(domain.rule, "domain must not depend on infra, ui");
+
+        let ui = &violations[1];
+        assert_eq!(ui.line, 2);
+        assert_eq!(ui.to_layer, "domain");
+        assert_eq!(ui.rule, "ui may only depend on api");
+    }
+}
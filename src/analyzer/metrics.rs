@@ -0,0 +1,287 @@
+//! Extensible per-file metrics.
+//!
+//! Built-in metrics live as fixed fields on `FileAnalysis`. Additional metrics are
+//! contributed by `MetricProvider` implementations registered in a `MetricRegistry`;
+//! their values end up in `FileAnalysis::metrics` keyed by metric name, and outputs,
+//! sorting, filters and thresholds address them generically by that name.
+
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
+use std::fmt;
+use std::sync::Arc;
+use tree_sitter::Node;
+
+use super::language::SupportedLanguage;
+use super::visitor::TreeVisitor;
+use crate::error::{AnalyzerError, Result};
+
+/// Names of the metrics stored as fixed fields on `FileAnalysis`
+pub const BUILTIN_METRICS: &[&str] = &[
+    "lines_of_code",
+    "blank_lines",
+    "comment_lines",
+    "functions",
+    "methods",
+    "anonymous_functions",
+    "classes",
+    "cyclomatic_complexity",
+    "max_nesting_depth",
+    "complexity_score",
+];
+
+/// Value of a single metric
+#[derive(Debug, Clone, Copy, PartialEq, Serialize, Deserialize)]
+#[serde(untagged)]
+pub enum MetricValue {
+    /// Whole-number metric (counts, depths)
+    Count(u64),
+    /// Fractional metric (ratios, scores)
+    Float(f64),
+}
+
+impl MetricValue {
+    /// Get the value as a float for comparisons and sorting
+    pub fn as_f64(&self) -> f64 {
+        match self {
+            MetricValue::Count(n) => *n as f64,
+            MetricValue::Float(x) => *x,
+        }
+    }
+}
+
+impl fmt::Display for MetricValue {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        match self {
+            MetricValue::Count(n) => write!(f, "{n}"),
+            MetricValue::Float(x) => write!(f, "{x:.2}"),
+        }
+    }
+}
+
+impl From<usize> for MetricValue {
+    fn from(value: usize) -> Self {
+        MetricValue::Count(value as u64)
+    }
+}
+
+impl From<f64> for MetricValue {
+    fn from(value: f64) -> Self {
+        MetricValue::Float(value)
+    }
+}
+
+/// Source of a custom per-file metric
+///
+/// Providers are shared across worker threads; per-file state lives in the
+/// `MetricCollector` returned by `collector`.
+pub trait MetricProvider: Send + Sync {
+    /// Metric name, used as the key in `FileAnalysis::metrics`
+    fn name(&self) -> &str;
+
+    /// Check if this metric applies to files of the given language
+    fn supports(&self, language: SupportedLanguage) -> bool;
+
+    /// Create a collector for a single file
+    fn collector(&self, language: SupportedLanguage) -> Box<dyn MetricCollector>;
+}
+
+/// Per-file state of a metric, fed every AST node in document order
+pub trait MetricCollector {
+    /// Visit a single AST node
+    fn visit(&mut self, node: &Node, source: &[u8]);
+
+    /// Produce the final value (None = metric not applicable to this file)
+    fn finish(self: Box<Self>) -> Option<MetricValue>;
+}
+
+/// Set of registered metric providers
+#[derive(Clone, Default)]
+pub struct MetricRegistry {
+    providers: Vec<Arc<dyn MetricProvider>>,
+}
+
+impl MetricRegistry {
+    /// Create an empty registry
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Register a provider, rejecting names that clash with built-in or registered metrics
+    pub fn register(&mut self, provider: Arc<dyn MetricProvider>) -> Result<()> {
+        let name = provider.name();
+        if BUILTIN_METRICS.contains(&name) || self.providers.iter().any(|p| p.name() == name) {
+            return Err(AnalyzerError::config_error(format!(
+                "Metric '{name}' is already defined"
+            )));
+        }
+        self.providers.push(provider);
+        Ok(())
+    }
+
+    /// Check if no providers are registered
+    pub fn is_empty(&self) -> bool {
+        self.providers.is_empty()
+    }
+
+    /// Get the names of all registered metrics
+    pub fn names(&self) -> Vec<&str> {
+        self.providers.iter().map(|p| p.name()).collect()
+    }
+
+    /// Check if `name` is a built-in or registered metric
+    pub fn contains(&self, name: &str) -> bool {
+        BUILTIN_METRICS.contains(&name) || self.providers.iter().any(|p| p.name() == name)
+    }
+
+    /// Create a named collector for every provider that supports `language`
+    pub fn collectors(&self, language: SupportedLanguage) -> Vec<(&str, Box<dyn MetricCollector>)> {
+        self.providers
+            .iter()
+            .filter(|p| p.supports(language))
+            .map(|p| (p.name(), p.collector(language)))
+            .collect()
+    }
+
+    /// Run every provider that supports `language` over the tree rooted at `root`
+    pub fn compute(
+        &self,
+        root: &Node,
+        source: &[u8],
+        language: SupportedLanguage,
+    ) -> BTreeMap<String, MetricValue> {
+        let collectors = self.collectors(language);
+        if collectors.is_empty() {
+            return BTreeMap::new();
+        }
+
+        TreeVisitor::new(language, source)
+            .with_collectors(collectors)
+            .run(root)
+            .metrics
+    }
+}
+
+impl fmt::Debug for MetricRegistry {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        f.debug_struct("MetricRegistry")
+            .field("providers", &self.names())
+            .finish()
+    }
+}
+
+/// Parse a `name=value` metric bound as used by `--min-metric`, `--max-metric`
+/// and `--metric-threshold`
+pub fn parse_metric_bound(spec: &str) -> Result<(String, f64)> {
+    let (name, value) = spec.split_once('=').ok_or_else(|| {
+        AnalyzerError::validation_error(format!(
+            "Invalid metric bound '{spec}', expected NAME=VALUE"
+        ))
+    })?;
+
+    let name = name.trim();
+    if name.is_empty() {
+        return Err(AnalyzerError::validation_error(format!(
+            "Invalid metric bound '{spec}': metric name is empty"
+        )));
+    }
+
+    let value = value.trim().parse::<f64>().map_err(|_| {
+        AnalyzerError::validation_error(format!(
+            "Invalid metric bound '{spec}': '{}' is not a number",
+            value.trim()
+        ))
+    })?;
+
+    Ok((name.to_string(), value))
+}
+
+/// Parse a list of `name=value` bounds into a map
+pub fn parse_metric_bounds(specs: &[String]) -> Result<BTreeMap<String, f64>> {
+    specs.iter().map(|s| parse_metric_bound(s)).collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    struct NodeCount;
+
+    struct NodeCountCollector(usize);
+
+    impl MetricProvider for NodeCount {
+        fn name(&self) -> &str {
+            "node_count"
+        }
+
+        fn supports(&self, language: SupportedLanguage) -> bool {
+            language == SupportedLanguage::Rust
+        }
+
+        fn collector(&self, _language: SupportedLanguage) -> Box<dyn MetricCollector> {
+            Box::new(NodeCountCollector(0))
+        }
+    }
+
+    impl MetricCollector for NodeCountCollector {
+        fn visit(&mut self, _node: &Node, _source: &[u8]) {
+            self.0 += 1;
+        }
+
+        fn finish(self: Box<Self>) -> Option<MetricValue> {
+            Some(self.0.into())
+        }
+    }
+
+    #[test]
+    fn test_metric_value_display_and_conversion() {
+        assert_eq!(MetricValue::Count(7).to_string(), "7");
+        assert_eq!(MetricValue::Float(1.234).to_string(), "1.23");
+        assert_eq!(MetricValue::Count(3).as_f64(), 3.0);
+    }
+
+    #[test]
+    fn test_metric_value_serde_roundtrip() {
+        let json = serde_json::to_string(&MetricValue::Count(4)).unwrap();
+        assert_eq!(json, "4");
+        let value: MetricValue = serde_json::from_str("2.5").unwrap();
+        assert_eq!(value, MetricValue::Float(2.5));
+    }
+
+    #[test]
+    fn test_registry_rejects_duplicate_names() {
+        let mut registry = MetricRegistry::new();
+        assert!(registry.register(Arc::new(NodeCount)).is_ok());
+        assert!(registry.register(Arc::new(NodeCount)).is_err());
+        assert_eq!(registry.names(), vec!["node_count"]);
+    }
+
+    #[test]
+    fn test_registry_compute_respects_language_support() {
+        let mut registry = MetricRegistry::new();
+        registry.register(Arc::new(NodeCount)).unwrap();
+
+        let source = b"fn main() {}";
+        let mut parser = tree_sitter::Parser::new();
+        parser
+            .set_language(&tree_sitter_rust::LANGUAGE.into())
+            .unwrap();
+        let tree = parser.parse(&source[..], None).unwrap();
+
+        let metrics = registry.compute(&tree.root_node(), source, SupportedLanguage::Rust);
+        assert!(metrics["node_count"].as_f64() > 0.0);
+
+        let metrics = registry.compute(&tree.root_node(), source, SupportedLanguage::Python);
+        assert!(metrics.is_empty());
+    }
+
+    #[test]
+    fn test_parse_metric_bound() {
+        assert_eq!(
+            parse_metric_bound("fan_out=12").unwrap(),
+            ("fan_out".to_string(), 12.0)
+        );
+        assert!(parse_metric_bound("fan_out").is_err());
+        assert!(parse_metric_bound("=3").is_err());
+        assert!(parse_metric_bound("fan_out=high").is_err());
+    }
+}
@@ -1,31 +1,98 @@
 use chrono::Utc;
 use indicatif::{ProgressBar, ProgressStyle};
 use rayon::prelude::*;
-use std::path::Path;
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::path::{Path, PathBuf};
+use std::sync::{mpsc, Arc, Mutex};
+use std::time::Instant;
 
-use crate::cli::CliArgs;
+use crate::cli::{CliArgs, LogLevel, LowConfidenceAction, NestedRepoMode};
 use crate::error::{AnalyzerError, ParseWarning, Result};
-
+use crate::{log_debug, log_info, log_span, log_warn};
+
+pub mod api;
+pub mod archive;
+pub mod budget;
+pub mod callgraph;
+pub mod counting;
+pub mod extract;
 pub mod git;
+pub mod imports;
+pub mod input;
 pub mod language;
+pub mod layers;
+pub mod metrics;
+pub mod packages;
 pub mod parser;
+pub mod profile;
 pub mod sanitizer;
+pub mod split;
+pub mod visitor;
 pub mod walker;
 
-pub use git::{get_changed_files, get_repo_root, is_git_repository};
+pub use api::{ApiChange, ApiDiff, ApiDiffEntry, ApiItem, ApiManifest, Visibility};
+pub use budget::{LimitReached, RunBudget, RunLimit, RunLimits};
+pub use callgraph::{CallEdge, CallGraph, CallGraphNode};
+pub use extract::{ExtractSuggestion, ExtractThresholds};
+pub use git::{get_changed_files, get_repo_root, is_git_repository, GitMetadata, NestedRepoKind};
+pub use imports::ImportResolver;
+pub use input::StdinSource;
 pub use language::{LanguageManager, SupportedLanguage};
+pub use layers::{LayerRules, LayerViolation};
+pub use metrics::{MetricCollector, MetricProvider, MetricRegistry, MetricValue};
+pub use packages::{package_metrics, PackageMetrics};
 pub use parser::{
-    create_project_summary, identify_refactoring_candidates, AnalysisConfig, AnalysisReport,
-    FileAnalysis, FileAnalysisResult, FileParser, ProjectSummary, RefactoringCandidate,
-    RefactoringReason, RefactoringThresholds,
+    create_project_summary, identify_refactoring_candidates, reproducible_timestamp,
+    AnalysisConfig, AnalysisReport, ComponentReport, Confidence, CountingRules, FailedFile,
+    FileAnalysis, FileAnalysisResult, FileParser, ParseLimits, ParserOptions, ProjectSummary,
+    QualityPolicy, RefactoringCandidate, RefactoringReason, RefactoringThresholds, RootReport,
+};
+pub use profile::{FileTiming, Phase, ProfileReport, Profiler};
+pub use sanitizer::{RewriteRule, RewriteRules};
+pub use split::{suggest_splits, SplitGroup, SplitSuggestion};
+pub use visitor::{FunctionScope, Import, OutlineSymbol, TreeMetrics, TreeVisitor};
+pub use walker::{
+    create_walker_from_cli, FileWalker, FilterConfig, PathCheck, PathExplanation, SkipReason,
+    SkippedFile, WalkStats, IGNORE_FILE_NAME,
 };
-pub use walker::{create_walker_from_cli, FileWalker, FilterConfig, WalkStats};
+
+/// Number of discovered files that may queue up ahead of the parse stage
+const DISCOVERY_CHANNEL_CAPACITY: usize = 1024;
+
+/// A file handed from discovery to the parse stage
+struct SourceFile {
+    path: PathBuf,
+    /// Contents already read from an archive or stdin (`None` for files on disk)
+    contents: Option<Vec<u8>>,
+    /// Language to parse as, regardless of the extension (`--stdin-language`)
+    language: Option<SupportedLanguage>,
+}
+
+/// Where the files to analyze come from
+enum Discovery {
+    /// Walk the target directory, or read the target archive
+    Walk,
+    /// Files resolved up front (`--only-changed-since`)
+    Resolved(Vec<PathBuf>, WalkStats),
+    /// An explicit file list, still subject to the file filters (`--files-from`)
+    Listed(Vec<PathBuf>),
+    /// A single source buffer (`--stdin`)
+    Stdin(StdinSource, Vec<u8>),
+}
+
+/// Raw output of the parse stage, in completion order
+struct ParsedFiles {
+    results: Vec<FileAnalysisResult>,
+    errors: Vec<(PathBuf, AnalyzerError)>,
+}
 
 /// Core analyzer engine that orchestrates the analysis process
 pub struct AnalyzerEngine {
     language_manager: LanguageManager,
     file_parser: FileParser,
     file_walker: FileWalker,
+    metric_registry: MetricRegistry,
+    layer_rules: Option<LayerRules>,
     show_progress: bool,
 }
 
@@ -40,6 +107,8 @@ impl AnalyzerEngine {
             language_manager,
             file_parser,
             file_walker,
+            metric_registry: MetricRegistry::new(),
+            layer_rules: None,
             show_progress: false,
         }
     }
@@ -58,57 +127,328 @@ impl AnalyzerEngine {
         let base_language_manager = LanguageManager::with_languages(target_languages);
 
         // Create file parser with size limits (needs own LanguageManager for thread-safety)
-        let file_parser = FileParser::new(base_language_manager.clone(), args.max_file_size_mb);
+        let file_parser = FileParser::new(base_language_manager.clone(), args.max_file_size_mb)
+            .with_options(ParserOptions::from_cli(args)?);
 
         // Create file walker from CLI args (needs own LanguageManager for language detection)
         let file_walker = create_walker_from_cli(args, base_language_manager.clone());
 
+        // Architecture layers whose import rules are checked after parsing
+        let layer_rules = args
+            .layer_rules
+            .as_ref()
+            .map(LayerRules::load)
+            .transpose()?;
+
         Ok(Self {
             language_manager: base_language_manager,
             file_parser,
             file_walker,
+            metric_registry: MetricRegistry::new(),
+            layer_rules,
             show_progress: args.verbose,
         })
     }
 
+    /// Register a custom metric provider
+    ///
+    /// The metric is computed for every file whose language the provider supports
+    /// and stored in `FileAnalysis::metrics` under the provider's name.
+    pub fn register_metric(&mut self, provider: Arc<dyn MetricProvider>) -> Result<()> {
+        self.metric_registry.register(provider)?;
+        self.file_parser = self
+            .file_parser
+            .clone()
+            .with_metric_registry(self.metric_registry.clone());
+        Ok(())
+    }
+
+    /// Record each file's public items, whatever the CLI arguments ask for
+    pub fn record_public_api(&mut self) {
+        self.file_parser = self.file_parser.clone().with_api(true);
+    }
+
+    /// Get the registry of custom metric providers
+    pub fn metric_registry(&self) -> &MetricRegistry {
+        &self.metric_registry
+    }
+
+    /// Check that every metric named on the command line is built in or registered
+    ///
+    /// A misspelled name would otherwise filter out every file (`--min-metric`,
+    /// `--max-metric`) or never flag one (`--metric-threshold`).
+    fn validate_metric_names(&self, cli_args: &CliArgs) -> Result<()> {
+        let mut named = Vec::new();
+        for (option, specs) in [
+            ("--min-metric", &cli_args.min_metric),
+            ("--max-metric", &cli_args.max_metric),
+            ("--metric-threshold", &cli_args.metric_threshold),
+        ] {
+            for (name, _) in metrics::parse_metric_bounds(specs)? {
+                named.push((option, name));
+            }
+        }
+        if let Some(ref name) = cli_args.sort_metric {
+            named.push(("--sort-metric", name.clone()));
+        }
+
+        match named
+            .into_iter()
+            .find(|(_, name)| !self.metric_registry.contains(name))
+        {
+            Some((option, name)) => {
+                let known: Vec<&str> = metrics::BUILTIN_METRICS
+                    .iter()
+                    .copied()
+                    .chain(self.metric_registry.names())
+                    .collect();
+                Err(AnalyzerError::validation_error(format!(
+                    "Unknown metric '{name}' for {option} (known metrics: {})",
+                    known.join(", ")
+                )))
+            }
+            None => Ok(()),
+        }
+    }
+
     /// Analyze a project directory and return comprehensive results
     pub fn analyze_project<P: AsRef<Path>>(
         &mut self,
         target_path: P,
         cli_args: &CliArgs,
     ) -> Result<AnalysisReport> {
-        let target_path = target_path.as_ref();
+        self.analyze_roots(&[target_path.as_ref().to_path_buf()], cli_args)
+    }
 
-        if self.show_progress {
-            println!("Starting analysis of: {}", target_path.display());
-        }
+    /// Analyze several root paths together
+    ///
+    /// Roots inside another root are folded into it, and files reachable from
+    /// more than one root (e.g. through symlinks) are analyzed once and belong
+    /// to the innermost root containing them. With more than one root, every
+    /// file is tagged with its root and the report has a summary per root.
+    pub fn analyze_roots(
+        &mut self,
+        roots: &[PathBuf],
+        cli_args: &CliArgs,
+    ) -> Result<AnalysisReport> {
+        self.validate_metric_names(cli_args)?;
+
+        let roots = normalize_roots(roots);
+        let roots = roots.as_slice();
+        let target_path = common_root(roots);
+        let target_path = target_path.as_path();
+        let archive_root = match roots {
+            [root] => archive::is_archive(root),
+            _ => {
+                if let Some(archive) = roots.iter().find(|root| archive::is_archive(root)) {
+                    return Err(AnalyzerError::validation_error(format!(
+                        "Archives can only be analyzed on their own: {}",
+                        archive.display()
+                    )));
+                }
+                false
+            }
+        };
 
-        // Step 1: Discover files (with optional git filtering)
-        let (files, walk_stats) = if let Some(ref commit_ref) = cli_args.only_changed_since {
-            self.discover_git_changed_files(target_path, commit_ref)?
+        let mut profiler = cli_args
+            .profile
+            .then(|| Profiler::new(cli_args.profile_slowest));
+        let reproducible_at = if cli_args.reproducible {
+            let source_date_epoch = std::env::var("SOURCE_DATE_EPOCH").ok();
+            Some(reproducible_timestamp(source_date_epoch.as_deref())?)
         } else {
-            self.file_walker.discover_files(target_path)?
+            None
         };
 
-        if self.show_progress {
-            println!("Discovered {} files to analyze", files.len());
-            println!("Walk statistics: {}", walk_stats.summary());
+        let _span = log_span!(LogLevel::Debug, "analyze", path = target_path.display());
+
+        // Git mode and explicit inputs resolve the file list up front; otherwise
+        // files are discovered by the parallel walker while parsing is already underway
+        let discovery = if let Some(ref commit_ref) = cli_args.only_changed_since {
+            let (files, stats) =
+                self.discover_git_changed_files(target_path, commit_ref, cli_args.nested_repos)?;
+            Discovery::Resolved(files, stats)
+        } else if let Some(ref list) = cli_args.files_from {
+            Discovery::Listed(input::read_file_list(list)?)
+        } else if let Some(source) = StdinSource::from_cli(cli_args)? {
+            Discovery::Stdin(source, input::read_stdin()?)
+        } else {
+            Discovery::Walk
+        };
+
+        // Global caps shared by discovery and analysis
+        let budget = RunBudget::new(RunLimits::from_cli(cli_args));
+
+        // Steps 1 + 2: Discover files and analyze them in parallel, connected by a channel
+        let progress_bar = self.analysis_progress_bar();
+        let (sender, receiver) = mpsc::sync_channel(DISCOVERY_CHANNEL_CAPACITY);
+        let file_walker = &self.file_walker;
+        let budget = &budget;
+
+        let (walk_result, parsed) = std::thread::scope(|scope| {
+            let discovery_progress = progress_bar.clone();
+            let discovery = scope.spawn(move || {
+                let _span = log_span!(LogLevel::Debug, "walk");
+                let send_source = |file: SourceFile| {
+                    if let Some(ref pb) = discovery_progress {
+                        pb.inc_length(1);
+                    }
+                    // The receiver only hangs up if the parse stage panicked
+                    let _ = sender.send(file);
+                };
+                let send = |path: PathBuf| {
+                    send_source(SourceFile {
+                        path,
+                        contents: None,
+                        language: None,
+                    })
+                };
+
+                let walk_started = Instant::now();
+                let result = match discovery {
+                    Discovery::Resolved(files, mut stats) => {
+                        stats.files_found = 0;
+                        for file in files {
+                            let size = std::fs::metadata(&file).map(|m| m.len()).unwrap_or(0);
+                            if !budget.admit_file(size) {
+                                break;
+                            }
+                            stats.files_found += 1;
+                            send(file);
+                        }
+                        Ok(stats)
+                    }
+                    Discovery::Listed(files) => {
+                        file_walker.walk_list_with_budget(files, budget, send)
+                    }
+                    Discovery::Stdin(source, contents) => {
+                        let mut stats = WalkStats {
+                            total_entries_scanned: 1,
+                            ..Default::default()
+                        };
+                        if budget.admit_file(contents.len() as u64) {
+                            stats.files_found = 1;
+                            send_source(SourceFile {
+                                path: source.path,
+                                contents: Some(contents),
+                                language: Some(source.language),
+                            });
+                        }
+                        Ok(stats)
+                    }
+                    // Archive entries are read by the walker and parsed from memory
+                    Discovery::Walk if archive_root => file_walker.walk_archive_with_budget(
+                        target_path,
+                        budget,
+                        |path, contents| {
+                            send_source(SourceFile {
+                                path,
+                                contents: Some(contents),
+                                language: None,
+                            })
+                        },
+                    ),
+                    Discovery::Walk => walk_roots(file_walker, roots, budget, send),
+                };
+                (result, walk_started.elapsed())
+            });
+
+            let parsed = self.analyze_file_stream(receiver, budget, progress_bar.as_ref());
+            let walk_result = discovery.join().expect("file discovery thread panicked");
+            (walk_result, parsed)
+        });
+        let (walk_result, walk_time) = walk_result;
+
+        if let Some(ref mut profiler) = profiler {
+            profiler.record_phase(Phase::Walk, walk_time);
+            for result in &parsed.results {
+                profiler.record_file(
+                    &result.analysis.path,
+                    &result.analysis.language,
+                    &result.timing,
+                );
+            }
+        }
+
+        if let Some(pb) = progress_bar {
+            pb.finish_with_message("File analysis completed");
+        }
+
+        let mut walk_stats = walk_result?;
+        let mut skipped_files = std::mem::take(&mut walk_stats.skipped_files);
+        skipped_files.sort_by(|a, b| a.path.cmp(&b.path));
+        let limit_reached = budget.reached();
+
+        if let Some(limit) = limit_reached {
+            log_warn!(
+                "Analysis stopped early; the report is partial",
+                limit = limit
+            );
         }
 
-        if files.is_empty() {
+        log_info!(
+            "Discovered files to analyze",
+            files = walk_stats.files_found
+        );
+        log_debug!("Walk statistics", stats = walk_stats.summary());
+
+        if walk_stats.files_found == 0 && limit_reached.is_none() {
             return Err(AnalyzerError::validation_error(
                 "No supported files found in the specified directory",
             ));
         }
 
-        // Step 2: Analyze files in parallel (now returns warnings too)
-        let (analysis_results, warnings) = self.analyze_files_parallel(&files)?;
+        let aggregation_started = Instant::now();
+        let aggregation_span = log_span!(LogLevel::Debug, "aggregate");
+        let (analysis_results, warnings, failed_files) =
+            self.collect_parsed_files(parsed, limit_reached.is_some())?;
+
+        for (name, count) in self.file_parser.rewrite_rules().fire_counts() {
+            log_info!("Rewrite rule fired", rule = name, count = count);
+        }
+
+        // Layering is checked on every analyzed file, before filters drop any import targets
+        let layer_violations = match self.layer_rules {
+            Some(ref rules) => {
+                let violations = rules.check(&analysis_results, target_path);
+                log_info!("Checked architecture layers", violations = violations.len());
+                violations
+            }
+            None => Vec::new(),
+        };
+
+        // Package coupling also counts imports of files the filters drop
+        let packages = if cli_args.package_metrics.is_some() {
+            package_metrics(&analysis_results, target_path)
+        } else {
+            Vec::new()
+        };
 
         // Step 3: Apply CLI filters
-        let filtered_results = self.apply_cli_filters(analysis_results, cli_args);
+        let mut filtered_results = self.apply_cli_filters(analysis_results, cli_args);
+        let root_reports = if roots.len() > 1 {
+            summarize_roots(&mut filtered_results, roots)
+        } else {
+            Vec::new()
+        };
 
-        // Step 4: Create project summary
-        let summary = create_project_summary(&filtered_results);
+        // Step 4: Create project summary (and one per nested repository if requested)
+        let (mut summary, components) = match cli_args.nested_repos {
+            NestedRepoMode::Separate => summarize_components(&filtered_results, target_path),
+            NestedRepoMode::Skip | NestedRepoMode::Include => {
+                (create_project_summary(&filtered_results), Vec::new())
+            }
+        };
+        summary.packages = packages;
+
+        let split_suggestions = if cli_args.suggest_splits {
+            suggest_splits(
+                &filtered_results,
+                &RefactoringThresholds::from_cli(cli_args),
+            )
+        } else {
+            Vec::new()
+        };
 
         // Step 5: Create analysis configuration record
         let config = AnalysisConfig {
@@ -121,132 +461,188 @@ impl AnalyzerEngine {
         };
 
         // Step 6: Create final report with warnings
-        let report = AnalysisReport {
+        let mut report = AnalysisReport {
             files: filtered_results,
             summary,
             config,
             generated_at: Utc::now(),
             warnings,
+            limit_reached,
+            failed_files,
+            skipped_files,
+            walk_stats,
+            profile: profiler.map(|mut profiler| {
+                profiler.record_phase(Phase::Aggregation, aggregation_started.elapsed());
+                profiler.finish()
+            }),
+            components,
+            roots: root_reports,
+            layer_violations,
+            split_suggestions,
         };
 
-        if self.show_progress {
-            println!("Analysis completed successfully");
-            println!("Total files analyzed: {}", report.files.len());
-            println!("Total lines of code: {}", report.summary.total_lines);
+        if let Some(timestamp) = reproducible_at {
+            report.make_reproducible(target_path, timestamp);
         }
+        drop(aggregation_span);
+
+        log_info!(
+            "Analysis finished",
+            files = report.files.len(),
+            total_lines = report.summary.total_lines
+        );
 
         Ok(report)
     }
 
-    /// Result from parallel file analysis
-    fn analyze_files_parallel(
-        &mut self,
-        files: &[std::path::PathBuf],
-    ) -> Result<(Vec<FileAnalysis>, Vec<ParseWarning>)> {
-        let progress_bar = if self.show_progress {
-            let pb = ProgressBar::new(files.len() as u64);
-            pb.set_style(
-                ProgressStyle::default_bar()
-                    .template("{spinner:.green} [{elapsed_precise}] [{bar:40.cyan/blue}] {pos}/{len} ({eta}) {msg}")
-                    .unwrap_or_else(|_| ProgressStyle::default_bar())
-                    .progress_chars("#>-"),
-            );
-            pb.set_message("Analyzing files...");
-            Some(pb)
-        } else {
-            None
-        };
+    /// Progress bar for the analysis stage (length grows as files are discovered)
+    fn analysis_progress_bar(&self) -> Option<ProgressBar> {
+        if !self.show_progress {
+            return None;
+        }
 
-        let show_progress = self.show_progress;
+        let pb = ProgressBar::new(0);
+        pb.set_style(
+            ProgressStyle::default_bar()
+                .template("{spinner:.green} [{elapsed_precise}] [{bar:40.cyan/blue}] {pos}/{len} ({eta}) {msg}")
+                .unwrap_or_else(|_| ProgressStyle::default_bar())
+                .progress_chars("#>-"),
+        );
+        pb.set_message("Analyzing files...");
+        Some(pb)
+    }
+
+    /// Parse files in parallel as they arrive on `files`, until the sender hangs up
+    fn analyze_file_stream(
+        &self,
+        files: mpsc::Receiver<SourceFile>,
+        budget: &RunBudget,
+        progress_bar: Option<&ProgressBar>,
+    ) -> ParsedFiles {
         let max_file_size_bytes = self.file_parser.max_file_size_bytes();
         let max_file_size_mb = (max_file_size_bytes / (1024 * 1024)) as usize;
         let enabled_languages = self.language_manager.enabled_languages();
+        let options = self.file_parser.options();
 
         // Parallel analysis with thread-local parser reuse
         let (results_with_warnings, errors): (Vec<_>, Vec<_>) = files
-            .par_iter()
+            .into_iter()
+            .par_bridge()
+            // Files still queued when the time budget runs out are dropped unparsed;
+            // file and size caps only stop discovery
+            .filter(|_| budget.within_time())
             .map_init(
                 || {
                     // Create a thread-local parser for this thread
                     let language_manager =
                         LanguageManager::with_languages(enabled_languages.clone());
                     FileParser::new(language_manager, max_file_size_mb)
+                        .with_options(options.clone())
                 },
                 |file_parser, file| {
                     // Update progress
-                    if let Some(ref pb) = progress_bar {
+                    if let Some(pb) = progress_bar {
                         pb.set_message(format!(
                             "Analyzing: {}",
-                            file.file_name().unwrap_or_default().to_string_lossy()
+                            file.path.file_name().unwrap_or_default().to_string_lossy()
                         ));
                         pb.inc(1);
                     }
 
                     // Analyze single file with warnings
-                    match file_parser.parse_file_with_warnings(file) {
+                    let SourceFile {
+                        path: file,
+                        contents,
+                        language,
+                    } = file;
+                    let _span = log_span!(LogLevel::Trace, "file", path = file.display());
+                    let result = match (contents, language) {
+                        (Some(contents), Some(language)) => {
+                            file_parser.parse_source_as(&file, language, &contents)
+                        }
+                        (Some(contents), None) => {
+                            file_parser.parse_source_with_warnings(&file, &contents)
+                        }
+                        (None, _) => file_parser.parse_file_with_warnings(&file),
+                    };
+                    match result {
                         Ok(result) => {
-                            if !result.warnings.is_empty() && show_progress {
-                                for warning in &result.warnings {
-                                    eprintln!("Warning: {}", warning);
-                                    for loc in warning.locations.iter().take(3) {
-                                        if let Some(snippet) = &loc.snippet {
-                                            eprintln!(
-                                                "  at {}:{} ({})  {}",
-                                                loc.line, loc.column, loc.kind, snippet
-                                            );
-                                        } else {
-                                            eprintln!(
-                                                "  at {}:{} ({})",
-                                                loc.line, loc.column, loc.kind
-                                            );
-                                        }
-                                    }
+                            for warning in &result.warnings {
+                                log_debug!("Parse warning", warning = warning);
+                                for loc in warning.locations.iter().take(3) {
+                                    log_debug!(
+                                        "Parse error location",
+                                        line = loc.line,
+                                        column = loc.column,
+                                        kind = loc.kind,
+                                        snippet = loc.snippet.as_deref().unwrap_or_default(),
+                                    );
                                 }
                             }
                             Ok(result)
                         }
                         Err(e) => {
-                            if show_progress {
-                                eprintln!("Warning: Failed to analyze {}: {}", file.display(), e);
-                            }
-                            Err((file.clone(), e))
+                            log_info!("Failed to analyze file", path = file.display(), error = e);
+                            Err((file, e))
                         }
                     }
                 },
             )
             .partition(|r| r.is_ok());
 
-        if let Some(pb) = progress_bar {
-            pb.finish_with_message("File analysis completed");
+        ParsedFiles {
+            results: results_with_warnings
+                .into_iter()
+                .map(|r| r.expect("partition guarantees Ok"))
+                .collect(),
+            errors: errors.into_iter().map(|r| r.unwrap_err()).collect(),
         }
+    }
+
+    /// Order parse results deterministically and split out analyses, warnings and failures
+    fn collect_parsed_files(
+        &self,
+        parsed: ParsedFiles,
+        partial: bool,
+    ) -> Result<(Vec<FileAnalysis>, Vec<ParseWarning>, Vec<FailedFile>)> {
+        let ParsedFiles {
+            mut results,
+            mut errors,
+        } = parsed;
+
+        // Completion order depends on thread scheduling; sort by path
+        results.sort_by(|a, b| a.analysis.path.cmp(&b.analysis.path));
+        errors.sort_by(|a, b| a.0.cmp(&b.0));
 
         // Unzip results
-        let mut analyses = Vec::with_capacity(results_with_warnings.len());
+        let mut analyses = Vec::with_capacity(results.len());
         let mut warnings = Vec::new();
 
-        for res in results_with_warnings {
-            let res = res.expect("partition guarantees Ok");
+        for res in results {
             analyses.push(res.analysis);
             warnings.extend(res.warnings);
         }
 
-        let errors: Vec<_> = errors.into_iter().map(|r| r.unwrap_err()).collect();
-
-        // Report errors if in verbose mode
-        if self.show_progress && !errors.is_empty() {
-            println!("Encountered {} errors during analysis:", errors.len());
-            for (file, error) in &errors {
-                eprintln!("  {}: {}", file.display(), error);
-            }
+        if !errors.is_empty() {
+            log_info!("Some files could not be analyzed", count = errors.len());
+        }
+        for (file, error) in &errors {
+            log_debug!("Analysis error", path = file.display(), error = error);
         }
 
-        if analyses.is_empty() {
+        // A run stopped by a limit reports what it got, even if that is nothing
+        if analyses.is_empty() && !partial {
             return Err(AnalyzerError::validation_error(
                 "Failed to analyze any files successfully",
             ));
         }
 
-        Ok((analyses, warnings))
+        let failed = errors
+            .iter()
+            .map(|(file, error)| FailedFile::new(file, error))
+            .collect();
+
+        Ok((analyses, warnings, failed))
     }
 
     /// Apply CLI-based filters to analysis results
@@ -273,6 +669,20 @@ impl AnalyzerEngine {
             results.retain(|analysis| analysis.classes >= min_classes);
         }
 
+        // Filter by generic metric bounds (files lacking the metric are excluded).
+        // Malformed bounds are rejected earlier by CliArgs::validate.
+        for (name, min) in metrics::parse_metric_bounds(&cli_args.min_metric).unwrap_or_default() {
+            results.retain(|analysis| analysis.metric(&name).is_some_and(|v| v >= min));
+        }
+        for (name, max) in metrics::parse_metric_bounds(&cli_args.max_metric).unwrap_or_default() {
+            results.retain(|analysis| analysis.metric(&name).is_some_and(|v| v <= max));
+        }
+
+        // Drop files whose metrics come from misparsed code if requested
+        if cli_args.low_confidence == LowConfidenceAction::Exclude {
+            results.retain(|analysis| analysis.confidence == Confidence::High);
+        }
+
         results
     }
 
@@ -285,6 +695,11 @@ impl AnalyzerEngine {
         }
     }
 
+    /// Explain why `path` is or isn't analyzed when analyzing `target_path`
+    pub fn explain_path(&self, target_path: &Path, path: &Path) -> Result<PathExplanation> {
+        self.file_walker.explain_path(target_path, path)
+    }
+
     /// Enable or disable progress reporting
     pub fn set_show_progress(&mut self, show: bool) {
         self.show_progress = show;
@@ -295,7 +710,8 @@ impl AnalyzerEngine {
         self.file_parser = FileParser::new(
             LanguageManager::with_languages(self.language_manager.enabled_languages()),
             size_mb,
-        );
+        )
+        .with_options(self.file_parser.options().clone());
     }
 
     /// Discover only files changed since a git commit
@@ -303,19 +719,19 @@ impl AnalyzerEngine {
         &self,
         target_path: P,
         commit_ref: &str,
+        nested_repos: NestedRepoMode,
     ) -> Result<(Vec<std::path::PathBuf>, WalkStats)> {
         let target_path = target_path.as_ref();
 
-        if self.show_progress {
-            println!("Git mode: analyzing files changed since '{}'", commit_ref);
-        }
-
-        // Get changed files from git
-        let changed_files = git::get_changed_files(target_path, commit_ref)?;
+        log_info!("Git mode: analyzing changed files", since = commit_ref);
 
-        if self.show_progress {
-            println!("Git reports {} changed files", changed_files.len());
-        }
+        // Get changed files from git (following submodule pointer changes unless skipped)
+        let changed_files = git::get_changed_files(
+            target_path,
+            commit_ref,
+            nested_repos != NestedRepoMode::Skip,
+        )?;
+        log_debug!("Git reports changed files", files = changed_files.len());
 
         // Filter through the normal language/size filters
         let filtered_files: Vec<std::path::PathBuf> = changed_files
@@ -330,20 +746,220 @@ impl AnalyzerEngine {
             files_skipped_size: 0,
             files_skipped_language: 0,
             files_skipped_hidden: 0,
+            files_skipped_ignored: 0,
+            files_skipped_generated: 0,
+            files_skipped_pattern: 0,
+            symlinks_skipped: 0,
+            nested_repos_skipped: 0,
             errors_encountered: 0,
+            skipped_files: Vec::new(),
         };
 
-        if self.show_progress {
-            println!(
-                "After filtering: {} supported files to analyze",
-                filtered_files.len()
-            );
-        }
+        log_debug!(
+            "Supported changed files after filtering",
+            files = filtered_files.len()
+        );
 
         Ok((filtered_files, stats))
     }
 }
 
+/// Closest directory containing all of `roots` (the root itself if there is only one)
+pub fn common_root(roots: &[PathBuf]) -> PathBuf {
+    let Some((first, rest)) = roots.split_first() else {
+        return PathBuf::from(".");
+    };
+    if rest.is_empty() {
+        return first.clone();
+    }
+
+    let mut common: Vec<_> = first.components().collect();
+    for root in rest {
+        let shared = common
+            .iter()
+            .zip(root.components())
+            .take_while(|(a, b)| **a == *b)
+            .count();
+        common.truncate(shared);
+    }
+    if common.is_empty() {
+        PathBuf::from(".")
+    } else {
+        common.into_iter().collect()
+    }
+}
+
+/// Drop `.` components, repeated roots and roots inside another root, keeping the order of the rest
+fn normalize_roots(roots: &[PathBuf]) -> Vec<PathBuf> {
+    let cwd = std::env::current_dir().unwrap_or_default();
+    // Containment is decided on resolved paths, so `a/../b` or a symlink is no way around it
+    let resolved: Vec<PathBuf> = roots
+        .iter()
+        .map(|root| std::fs::canonicalize(root).unwrap_or_else(|_| cwd.join(without_cur_dir(root))))
+        .collect();
+
+    let mut normalized: Vec<PathBuf> = Vec::with_capacity(roots.len());
+    for (index, root) in roots.iter().enumerate() {
+        let inside_other = resolved.iter().enumerate().any(|(other, outer)| {
+            other != index
+                && resolved[index].starts_with(outer)
+                && (resolved[index] != *outer || other < index)
+        });
+        if inside_other {
+            continue;
+        }
+
+        let mut root = without_cur_dir(root);
+        if root.as_os_str().is_empty() {
+            root = PathBuf::from(".");
+        }
+        normalized.push(root);
+    }
+    normalized
+}
+
+fn without_cur_dir(path: &Path) -> PathBuf {
+    path.components()
+        .filter(|c| *c != std::path::Component::CurDir)
+        .collect()
+}
+
+/// Walk each root in turn, passing on files reachable from several roots only once
+///
+/// Files already found under an earlier root are left out before the budget
+/// counts them, so overlapping roots don't use up `--max-files` twice.
+fn walk_roots<F>(
+    file_walker: &FileWalker,
+    roots: &[PathBuf],
+    budget: &RunBudget,
+    on_file: F,
+) -> Result<WalkStats>
+where
+    F: Fn(PathBuf) + Sync,
+{
+    if let [root] = roots {
+        return file_walker.walk_files_with_budget(root, budget, on_file);
+    }
+
+    let seen = Mutex::new(HashSet::new());
+    let mut total = WalkStats::default();
+    for root in roots {
+        let stats = file_walker.walk_unseen_files_with_budget(root, budget, &seen, &on_file)?;
+        total.merge(&stats);
+
+        if budget.reached().is_some() {
+            break;
+        }
+    }
+    Ok(total)
+}
+
+/// Tag every file with the innermost root containing it and summarize each root
+fn summarize_roots(files: &mut [FileAnalysis], roots: &[PathBuf]) -> Vec<RootReport> {
+    let mut root_files: Vec<Vec<FileAnalysis>> = vec![Vec::new(); roots.len()];
+    // Compared without `.` components, so the root `.` is a prefix of every relative path
+    let bare_roots: Vec<PathBuf> = roots.iter().map(|root| without_cur_dir(root)).collect();
+    for file in files.iter_mut() {
+        let path = without_cur_dir(&file.path);
+        let innermost = bare_roots
+            .iter()
+            .enumerate()
+            .filter(|(_, root)| path.starts_with(root))
+            .max_by_key(|(_, root)| root.components().count());
+        if let Some((index, _)) = innermost {
+            file.root = Some(roots[index].clone());
+            root_files[index].push(file.clone());
+        }
+    }
+
+    roots
+        .iter()
+        .zip(root_files)
+        .map(|(root, files)| {
+            log_info!("Root analyzed", path = root.display(), files = files.len());
+            RootReport {
+                path: root.clone(),
+                summary: create_project_summary(&files),
+            }
+        })
+        .collect()
+}
+
+/// Summarize each nested repository separately from the rest of the project
+///
+/// Files belong to the innermost submodule or nested repository below
+/// `target_path` that contains them; the project summary covers the rest.
+fn summarize_components(
+    files: &[FileAnalysis],
+    target_path: &Path,
+) -> (ProjectSummary, Vec<ComponentReport>) {
+    // Git mode reports absolute paths, so compare paths in absolute form
+    let cwd = std::env::current_dir().unwrap_or_default();
+    let root = cwd.join(target_path);
+
+    // Files share most of their ancestors, so each directory is checked once
+    let mut repo_kinds: HashMap<PathBuf, Option<git::NestedRepoKind>> = HashMap::new();
+    let mut project_files = Vec::new();
+    let mut component_files: BTreeMap<PathBuf, (git::NestedRepoKind, Vec<&FileAnalysis>)> =
+        BTreeMap::new();
+    for file in files {
+        let repo = file
+            .path
+            .ancestors()
+            .skip(1)
+            .take_while(|dir| {
+                let dir = cwd.join(dir);
+                dir.starts_with(&root) && dir != root
+            })
+            .find_map(|dir| {
+                let kind = *repo_kinds
+                    .entry(dir.to_path_buf())
+                    .or_insert_with(|| git::nested_repo_kind(dir));
+                kind.map(|kind| (dir.to_path_buf(), kind))
+            });
+
+        match repo {
+            Some((dir, kind)) => component_files
+                .entry(dir)
+                .or_insert_with(|| (kind, Vec::new()))
+                .1
+                .push(file),
+            None => project_files.push(file),
+        }
+    }
+
+    let components = component_files
+        .into_iter()
+        .map(|(path, (kind, files))| {
+            let git = match git::repo_metadata(&path) {
+                Ok(metadata) => Some(metadata),
+                Err(e) => {
+                    log_debug!(
+                        "No git metadata for component",
+                        path = path.display(),
+                        error = e
+                    );
+                    None
+                }
+            };
+            log_info!(
+                "Nested repository analyzed as a component",
+                path = path.display(),
+                kind = kind.as_str(),
+                files = files.len(),
+            );
+            ComponentReport {
+                summary: create_project_summary(files),
+                path,
+                kind,
+                git,
+            }
+        })
+        .collect();
+
+    (create_project_summary(project_files), components)
+}
+
 impl Default for AnalyzerEngine {
     fn default() -> Self {
         Self::new()
@@ -377,7 +993,7 @@ pub fn analyze_project_simple<P: AsRef<Path>>(
     max_file_size_mb: Option<usize>,
 ) -> Result<AnalysisReport> {
     let cli_args = CliArgs {
-        path: Some(target_path.as_ref().to_path_buf()),
+        paths: vec![target_path.as_ref().to_path_buf()],
         languages: languages.unwrap_or_default(), // Empty vec triggers all languages in from_cli_args
         max_file_size_mb: max_file_size_mb.unwrap_or(10),
         verbose: false,
@@ -391,6 +1007,7 @@ pub fn analyze_project_simple<P: AsRef<Path>>(
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::analyzer::parser::fixtures;
     use std::fs;
     use tempfile::TempDir;
 
@@ -476,7 +1093,7 @@ mod tests {
     fn test_analyze_project() {
         let test_dir = create_test_project();
         let cli_args = CliArgs {
-            path: Some(test_dir.path().to_path_buf()),
+            paths: vec![test_dir.path().to_path_buf()],
             verbose: false,
             ..Default::default()
         };
@@ -521,7 +1138,7 @@ mod tests {
     fn test_cli_filters() {
         let test_dir = create_test_project();
         let cli_args = CliArgs {
-            path: Some(test_dir.path().to_path_buf()),
+            paths: vec![test_dir.path().to_path_buf()],
             min_lines: 5,         // Filter out very small files
             max_lines: Some(100), // Filter out very large files
             verbose: false,
@@ -547,6 +1164,120 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_report_records_failed_and_skipped_files() {
+        let test_dir = create_test_project();
+        fs::write(test_dir.path().join("broken.rs"), [0xFF, 0xFE, 0xFD]).unwrap();
+        fs::write(test_dir.path().join("notes.txt"), "not code").unwrap();
+
+        let cli_args = CliArgs {
+            paths: vec![test_dir.path().to_path_buf()],
+            report_skipped: true,
+            ..Default::default()
+        };
+        let mut engine = AnalyzerEngine::from_cli_args(&cli_args).unwrap();
+        let report = engine.analyze_project(test_dir.path(), &cli_args).unwrap();
+
+        assert_eq!(report.failed_files.len(), 1);
+        assert!(report.failed_files[0].path.ends_with("broken.rs"));
+        assert_eq!(report.failed_files[0].kind, "parse");
+
+        assert!(report
+            .skipped_files
+            .iter()
+            .any(|f| f.path.ends_with("notes.txt") && f.reason == SkipReason::Language));
+        assert_eq!(
+            report.walk_stats.files_found,
+            report.files.len() + report.failed_files.len()
+        );
+    }
+
+    #[test]
+    fn test_register_metric_populates_metric_map() {
+        use tree_sitter::Node;
+
+        struct IdentifierCount;
+        struct IdentifierCollector(usize);
+
+        impl MetricProvider for IdentifierCount {
+            fn name(&self) -> &str {
+                "identifiers"
+            }
+
+            fn supports(&self, language: SupportedLanguage) -> bool {
+                language == SupportedLanguage::Rust
+            }
+
+            fn collector(&self, _language: SupportedLanguage) -> Box<dyn MetricCollector> {
+                Box::new(IdentifierCollector(0))
+            }
+        }
+
+        impl MetricCollector for IdentifierCollector {
+            fn visit(&mut self, node: &Node, _source: &[u8]) {
+                if node.kind() == "identifier" {
+                    self.0 += 1;
+                }
+            }
+
+            fn finish(self: Box<Self>) -> Option<MetricValue> {
+                Some(self.0.into())
+            }
+        }
+
+        let test_dir = create_test_project();
+        let cli_args = CliArgs {
+            paths: vec![test_dir.path().to_path_buf()],
+            min_metric: vec!["identifiers=1".to_string()],
+            sort_metric: Some("identifiers".to_string()),
+            ..Default::default()
+        };
+
+        let mut engine = AnalyzerEngine::from_cli_args(&cli_args).unwrap();
+        engine.register_metric(Arc::new(IdentifierCount)).unwrap();
+        let report = engine.analyze_project(test_dir.path(), &cli_args).unwrap();
+
+        // Only Rust files report the metric, so the filter keeps only them
+        assert!(!report.files.is_empty());
+        for file in &report.files {
+            assert_eq!(file.language, "rust");
+            assert!(file.metric("identifiers").unwrap() >= 1.0);
+        }
+    }
+
+    #[test]
+    fn test_unknown_metric_names_are_rejected() {
+        let test_dir = create_test_project();
+        for cli_args in [
+            CliArgs {
+                min_metric: vec!["identifers=1".to_string()],
+                ..Default::default()
+            },
+            CliArgs {
+                metric_threshold: vec!["identifers=10".to_string()],
+                ..Default::default()
+            },
+            CliArgs {
+                sort_metric: Some("identifers".to_string()),
+                ..Default::default()
+            },
+        ] {
+            let mut engine = AnalyzerEngine::from_cli_args(&cli_args).unwrap();
+            let error = engine
+                .analyze_project(test_dir.path(), &cli_args)
+                .unwrap_err();
+            assert!(error.to_string().contains("Unknown metric 'identifers'"));
+        }
+
+        // Built-in names need no registration
+        let cli_args = CliArgs {
+            max_metric: vec!["max_nesting_depth=50".to_string()],
+            ..Default::default()
+        };
+        let mut engine = AnalyzerEngine::from_cli_args(&cli_args).unwrap();
+        assert!(engine.analyze_project(test_dir.path(), &cli_args).is_ok());
+    }
+
     #[test]
     fn test_get_analysis_stats() {
         let engine = AnalyzerEngine::new();
@@ -557,4 +1288,125 @@ mod tests {
         assert!(summary.contains("Languages:"));
         assert!(summary.contains("MB"));
     }
+
+    #[test]
+    fn test_summarize_components() {
+        let dir = TempDir::new().unwrap();
+        let root = dir.path();
+        fs::create_dir_all(root.join("vendor/lib/.git")).unwrap();
+        fs::create_dir_all(root.join("src")).unwrap();
+        let file = |path: &str, lines: usize| FileAnalysis {
+            path: root.join(path),
+            lines_of_code: lines,
+            ..fixtures::file(path)
+        };
+        let files = vec![
+            file("src/main.rs", 10),
+            file("vendor/lib/a.rs", 20),
+            file("vendor/lib/src/b.rs", 30),
+        ];
+
+        let (summary, components) = summarize_components(&files, root);
+
+        assert_eq!(summary.total_files, 1);
+        assert_eq!(summary.total_lines, 10);
+        assert_eq!(components.len(), 1);
+        assert_eq!(components[0].path, root.join("vendor/lib"));
+        assert_eq!(components[0].kind, NestedRepoKind::Repository);
+        assert_eq!(components[0].summary.total_files, 2);
+        assert_eq!(components[0].summary.total_lines, 50);
+    }
+
+    #[test]
+    fn test_common_root() {
+        let paths = |list: &[&str]| list.iter().map(PathBuf::from).collect::<Vec<_>>();
+
+        assert_eq!(
+            common_root(&paths(&["src/main.rs"])),
+            PathBuf::from("src/main.rs")
+        );
+        assert_eq!(
+            common_root(&paths(&["services/a", "services/b/api", "services/c"])),
+            PathBuf::from("services")
+        );
+        assert_eq!(
+            common_root(&paths(&["services", "libs"])),
+            PathBuf::from(".")
+        );
+        assert_eq!(
+            normalize_roots(&paths(&["./services/a", "services/a", "."])),
+            paths(&["."])
+        );
+        assert_eq!(
+            normalize_roots(&paths(&["./services/a", "libs", "services/a", "services"])),
+            paths(&["libs", "services"])
+        );
+    }
+
+    #[test]
+    fn test_walk_roots_skips_overlap() {
+        let dir = TempDir::new().unwrap();
+        let root = dir.path();
+        fs::create_dir_all(root.join("services/a")).unwrap();
+        fs::write(root.join("services/a/main.rs"), "fn main() {}").unwrap();
+        fs::write(root.join("services/lib.rs"), "fn lib() {}").unwrap();
+
+        let walker = FileWalker::new(LanguageManager::new());
+        let roots = vec![root.join("services"), root.join("services/a")];
+        let files = Mutex::new(Vec::new());
+        let stats = walk_roots(&walker, &roots, &RunBudget::unlimited(), |path| {
+            files.lock().unwrap().push(path)
+        })
+        .unwrap();
+
+        assert_eq!(files.into_inner().unwrap().len(), 2);
+        assert_eq!(stats.files_found, 2);
+
+        // Files found again under the nested root don't count against the budget
+        let budget = RunBudget::new(RunLimits {
+            max_files: Some(2),
+            ..Default::default()
+        });
+        let roots = vec![root.join("services/a"), root.join("services")];
+        let stats = walk_roots(&walker, &roots, &budget, |_| {}).unwrap();
+        assert_eq!(stats.files_found, 2);
+        assert!(budget.reached().is_none());
+    }
+
+    #[test]
+    fn test_summarize_roots() {
+        let file = |path: &str, lines: usize| FileAnalysis {
+            lines_of_code: lines,
+            ..fixtures::file(path)
+        };
+        let mut files = vec![
+            file("services/a/main.rs", 10),
+            file("services/shared.rs", 20),
+            file("libs/common/lib.rs", 30),
+        ];
+        let roots = vec![
+            PathBuf::from("services"),
+            PathBuf::from("services/a"),
+            PathBuf::from("libs/common"),
+        ];
+
+        let reports = summarize_roots(&mut files, &roots);
+
+        // Files belong to the innermost root containing them
+        assert_eq!(files[0].root, Some(PathBuf::from("services/a")));
+        assert_eq!(files[1].root, Some(PathBuf::from("services")));
+        assert_eq!(files[2].root, Some(PathBuf::from("libs/common")));
+        let lines: Vec<_> = reports
+            .iter()
+            .map(|r| (r.path.clone(), r.summary.total_lines))
+            .collect();
+        assert_eq!(
+            lines,
+            vec![
+                (PathBuf::from("services"), 20),
+                (PathBuf::from("services/a"), 10),
+                (PathBuf::from("libs/common"), 30),
+            ]
+        );
+    }
 }
@@ -0,0 +1,181 @@
+//! Package coupling and abstractness metrics (Robert C. Martin).
+//!
+//! Packages are the directories of the analyzed files. Afferent coupling (Ca)
+//! counts the other packages importing a package and efferent coupling (Ce)
+//! the other packages it imports, through imports resolved to analyzed files.
+//! Instability is I = Ce / (Ca + Ce), abstractness A is the share of abstract
+//! types (interfaces, traits, abstract classes) among the package's types and
+//! D = |A + I - 1| is the distance from the main sequence: 0 for packages that
+//! balance both, 1 for concrete packages everything depends on (the zone of
+//! pain) and for abstract packages nothing uses (the zone of uselessness).
+
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, BTreeSet};
+use std::path::{Path, PathBuf};
+
+use super::imports::ImportResolver;
+use super::parser::FileAnalysis;
+use super::visitor::OutlineSymbol;
+
+/// Coupling and abstractness of a package
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct PackageMetrics {
+    /// Directory of the package, relative to the analysis root
+    pub package: PathBuf,
+    pub files: usize,
+    /// Ca: number of other packages depending on this one
+    pub afferent_coupling: usize,
+    /// Ce: number of other packages this one depends on
+    pub efferent_coupling: usize,
+    /// I = Ce / (Ca + Ce), 0 for packages without dependencies either way
+    pub instability: f64,
+    pub types: usize,
+    pub abstract_types: usize,
+    /// A = abstract types / types, 0 for packages without types
+    pub abstractness: f64,
+    /// D = |A + I - 1|
+    pub distance: f64,
+}
+
+/// Metrics of every package of `files`, analyzed under `root`, by package path
+pub fn package_metrics(files: &[FileAnalysis], root: &Path) -> Vec<PackageMetrics> {
+    let resolver = ImportResolver::new(files, root);
+    let package_of = |file: usize| -> PathBuf {
+        match resolver.relative_path(file).parent() {
+            Some(parent) if !parent.as_os_str().is_empty() => parent.to_path_buf(),
+            _ => PathBuf::from("."),
+        }
+    };
+
+    let mut packages: BTreeMap<PathBuf, PackageMetrics> = BTreeMap::new();
+    let mut dependencies: BTreeSet<(PathBuf, PathBuf)> = BTreeSet::new();
+    for (index, file) in files.iter().enumerate() {
+        let package = package_of(index);
+        for import in &file.imports {
+            for target in resolver.resolve(index, &import.statement) {
+                let target = package_of(target);
+                if target != package {
+                    dependencies.insert((package.clone(), target));
+                }
+            }
+        }
+
+        let metrics = packages
+            .entry(package.clone())
+            .or_insert_with(|| PackageMetrics {
+                package,
+                files: 0,
+                afferent_coupling: 0,
+                efferent_coupling: 0,
+                instability: 0.0,
+                types: 0,
+                abstract_types: 0,
+                abstractness: 0.0,
+                distance: 0.0,
+            });
+        metrics.files += 1;
+        let (types, abstract_types) = count_types(&file.outline);
+        metrics.types += types;
+        metrics.abstract_types += abstract_types;
+    }
+
+    for (from, to) in &dependencies {
+        if let Some(metrics) = packages.get_mut(from) {
+            metrics.efferent_coupling += 1;
+        }
+        if let Some(metrics) = packages.get_mut(to) {
+            metrics.afferent_coupling += 1;
+        }
+    }
+
+    packages
+        .into_values()
+        .map(|mut metrics| {
+            let coupling = metrics.afferent_coupling + metrics.efferent_coupling;
+            if coupling > 0 {
+                metrics.instability = metrics.efferent_coupling as f64 / coupling as f64;
+            }
+            if metrics.types > 0 {
+                metrics.abstractness = metrics.abstract_types as f64 / metrics.types as f64;
+            }
+            metrics.distance = (metrics.abstractness + metrics.instability - 1.0).abs();
+            metrics
+        })
+        .collect()
+}
+
+/// Number of types and abstract types declared in an outline, nested ones included
+fn count_types(symbols: &[OutlineSymbol]) -> (usize, usize) {
+    symbols
+        .iter()
+        .fold((0, 0), |(types, abstract_types), symbol| {
+            let (nested, nested_abstract) = count_types(&symbol.children);
+            (
+                types + usize::from(symbol.is_type()) + nested,
+                abstract_types
+                    + usize::from(symbol.is_type() && symbol.is_abstract)
+                    + nested_abstract,
+            )
+        })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::analyzer::parser::fixtures::{file, symbol};
+
+    #[test]
+    fn test_package_metrics() {
+        let files = vec![
+            file("src/domain/order.rs").with_outline(vec![
+                OutlineSymbol {
+                    is_abstract: true,
+                    ..symbol("trait", "T", (1, 1))
+                },
+                symbol("struct", "T", (1, 1)),
+            ]),
+            file("src/infra/db.rs")
+                .with_imports(&["use crate::domain::order::Order;"])
+                .with_outline(vec![
+                    symbol("struct", "T", (1, 1)),
+                    symbol("impl", "T", (1, 1)),
+                ]),
+            file("src/main.rs")
+                .with_imports(&[
+                    "use crate::infra::db::Db;",
+                    "use crate::domain::order::Order;",
+                ])
+                .with_outline(vec![symbol("function", "T", (1, 1))]),
+        ];
+
+        let packages = package_metrics(&files, Path::new("."));
+        let paths: Vec<_> = packages.iter().map(|p| p.package.clone()).collect();
+        assert_eq!(
+            paths,
+            vec![
+                PathBuf::from("src"),
+                PathBuf::from("src/domain"),
+                PathBuf::from("src/infra"),
+            ]
+        );
+
+        let src = &packages[0];
+        assert_eq!((src.afferent_coupling, src.efferent_coupling), (0, 2));
+        assert_eq!(src.instability, 1.0);
+        assert_eq!(src.types, 0);
+        assert_eq!(src.distance, 0.0);
+
+        let domain = &packages[1];
+        assert_eq!((domain.afferent_coupling, domain.efferent_coupling), (2, 0));
+        assert_eq!(domain.instability, 0.0);
+        assert_eq!((domain.types, domain.abstract_types), (2, 1));
+        assert_eq!(domain.abstractness, 0.5);
+        assert_eq!(domain.distance, 0.5);
+
+        let infra = &packages[2];
+        assert_eq!((infra.afferent_coupling, infra.efferent_coupling), (1, 1));
+        assert_eq!(infra.instability, 0.5);
+        assert_eq!(infra.types, 1);
+        assert_eq!(infra.distance, 0.5);
+    }
+}
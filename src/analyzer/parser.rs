@@ -1,13 +1,26 @@
 use chrono::{DateTime, Utc};
 use serde::{Deserialize, Serialize};
 use std::borrow::Cow;
-use std::collections::HashMap;
+use std::collections::{BTreeMap, HashMap};
 use std::fs;
-use std::path::{Path, PathBuf};
-use tree_sitter::{Node, Tree};
-
-use super::language::{LanguageManager, NodeKindMapper, SupportedLanguage};
-use super::sanitizer::sanitize_for_tree_sitter;
+use std::path::{Component, Path, PathBuf};
+use std::time::{Duration, Instant};
+use tree_sitter::{Node, ParseOptions, ParseState, Tree};
+
+use super::api::ApiItem;
+use super::budget::LimitReached;
+use super::extract::{suggest_extractions, ExtractThresholds};
+use super::git::{GitMetadata, NestedRepoKind};
+use super::language::{LanguageManager, SupportedLanguage};
+use super::layers::LayerViolation;
+use super::metrics::{MetricRegistry, MetricValue};
+use super::packages::PackageMetrics;
+use super::profile::{FileTiming, ProfileReport};
+use super::sanitizer::{sanitize_for_tree_sitter, RewriteRules};
+use super::split::SplitSuggestion;
+use super::visitor::{FunctionScope, Import, OutlineSymbol, TreeMetrics, TreeVisitor};
+use super::walker::{SkippedFile, WalkStats};
+use crate::cli::{AnonymousFunctionMode, ComplexityProfile, LowConfidenceAction, OutputFormat};
 use crate::error::{AnalyzerError, ParseWarning, ParseWarningLocation, Result};
 
 /// Complete result from file analysis including warnings
@@ -15,10 +28,12 @@ use crate::error::{AnalyzerError, ParseWarning, ParseWarningLocation, Result};
 pub struct FileAnalysisResult {
     pub analysis: FileAnalysis,
     pub warnings: Vec<ParseWarning>,
+    /// Time spent on the file, by phase
+    pub timing: FileTiming,
 }
 
 /// Analysis result for a single file
-#[derive(Debug, Clone, Serialize, Deserialize)]
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
 pub struct FileAnalysis {
     pub path: PathBuf,
     pub language: String,
@@ -27,10 +42,59 @@ pub struct FileAnalysis {
     pub comment_lines: usize,
     pub functions: usize,
     pub methods: usize,
+    /// Closures, lambdas and other anonymous functions (not part of `functions`)
+    #[serde(default)]
+    pub anonymous_functions: usize,
     pub classes: usize,
     pub cyclomatic_complexity: usize,
     pub max_nesting_depth: usize,
     pub complexity_score: f64,
+    /// Additional metrics from registered providers, keyed by metric name
+    #[serde(default, skip_serializing_if = "BTreeMap::is_empty")]
+    pub metrics: BTreeMap<String, MetricValue>,
+    /// Function and method scopes in document order
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub scopes: Vec<FunctionScope>,
+    /// Share of source bytes covered by ERROR/MISSING nodes (0.0 = clean parse)
+    #[serde(default)]
+    pub parse_error_ratio: f64,
+    /// How far the metrics can be trusted given the parse quality
+    #[serde(default)]
+    pub confidence: Confidence,
+    /// Root path the file was found under (only when analyzing several roots)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub root: Option<PathBuf>,
+    /// Import statements, used to resolve calls and layer dependencies across files
+    /// (not serialized)
+    #[serde(skip)]
+    pub imports: Vec<Import>,
+    /// Public items (only exported by `--api-manifest` and `api-diff`)
+    #[serde(skip)]
+    pub api: Vec<ApiItem>,
+    /// Types and functions in a hierarchy (only exported by `--output outline`)
+    #[serde(skip)]
+    pub outline: Vec<OutlineSymbol>,
+}
+
+/// Reliability of a file's metrics
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum Confidence {
+    /// Parsed cleanly or with few errors
+    #[default]
+    High,
+    /// Parse errors above the threshold, or no AST at all
+    Low,
+}
+
+impl Confidence {
+    /// Get the lowercase name of the confidence level
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Confidence::High => "high",
+            Confidence::Low => "low",
+        }
+    }
 }
 
 impl FileAnalysis {
@@ -39,6 +103,24 @@ impl FileAnalysis {
         self.lines_of_code + self.blank_lines + self.comment_lines
     }
 
+    /// Look up a metric by name, covering both built-in fields and the extension map
+    pub fn metric(&self, name: &str) -> Option<f64> {
+        let value = match name {
+            "lines_of_code" => self.lines_of_code as f64,
+            "blank_lines" => self.blank_lines as f64,
+            "comment_lines" => self.comment_lines as f64,
+            "functions" => self.functions as f64,
+            "methods" => self.methods as f64,
+            "anonymous_functions" => self.anonymous_functions as f64,
+            "classes" => self.classes as f64,
+            "cyclomatic_complexity" => self.cyclomatic_complexity as f64,
+            "max_nesting_depth" => self.max_nesting_depth as f64,
+            "complexity_score" => self.complexity_score,
+            _ => return self.metrics.get(name).map(MetricValue::as_f64),
+        };
+        Some(value)
+    }
+
     /// Calculate complexity score using cyclomatic complexity as primary factor
     ///
     /// Formula: loc_factor + cyclomatic_factor + structure_factor
@@ -68,6 +150,88 @@ impl FileAnalysis {
     }
 }
 
+/// Builders for the analysis results unit tests work on
+#[cfg(test)]
+pub(crate) mod fixtures {
+    use super::*;
+
+    /// A 10-line Rust file at `path` with complexity 1 and nothing else recorded
+    pub(crate) fn file(path: &str) -> FileAnalysis {
+        FileAnalysis {
+            path: PathBuf::from(path),
+            language: "rust".to_string(),
+            lines_of_code: 10,
+            cyclomatic_complexity: 1,
+            complexity_score: 1.0,
+            ..Default::default()
+        }
+    }
+
+    /// A function spanning `lines` with complexity 1 that calls `calls`
+    pub(crate) fn scope(name: &str, lines: (usize, usize), calls: &[&str]) -> FunctionScope {
+        FunctionScope {
+            name: Some(name.to_string()),
+            kind: "function_item".to_string(),
+            start_line: lines.0,
+            end_line: lines.1,
+            cyclomatic_complexity: 1,
+            max_nesting_depth: 0,
+            calls: calls.iter().map(|c| c.to_string()).collect(),
+            qualified_calls: Vec::new(),
+            references: Vec::new(),
+            extractions: Vec::new(),
+        }
+    }
+
+    /// A concrete outline symbol spanning `lines` with complexity 1
+    pub(crate) fn symbol(kind: &str, name: &str, lines: (usize, usize)) -> OutlineSymbol {
+        OutlineSymbol {
+            kind: kind.to_string(),
+            name: name.to_string(),
+            start_line: lines.0,
+            end_line: lines.1,
+            visibility: None,
+            is_abstract: false,
+            lines: lines.1 + 1 - lines.0,
+            cyclomatic_complexity: 1,
+            max_nesting_depth: 0,
+            children: Vec::new(),
+        }
+    }
+
+    impl FileAnalysis {
+        pub(crate) fn with_language(mut self, language: &str) -> Self {
+            self.language = language.to_string();
+            self
+        }
+
+        /// One import per statement, on consecutive lines from line 1
+        pub(crate) fn with_imports(mut self, statements: &[&str]) -> Self {
+            self.imports = statements
+                .iter()
+                .enumerate()
+                .map(|(line, statement)| Import {
+                    line: line + 1,
+                    statement: statement.to_string(),
+                })
+                .collect();
+            self
+        }
+
+        /// Function scopes, counted as the file's functions
+        pub(crate) fn with_scopes(mut self, scopes: Vec<FunctionScope>) -> Self {
+            self.functions = scopes.len();
+            self.scopes = scopes;
+            self
+        }
+
+        pub(crate) fn with_outline(mut self, outline: Vec<OutlineSymbol>) -> Self {
+            self.outline = outline;
+            self
+        }
+    }
+}
+
 /// Complete analysis report structure
 #[derive(Debug, Serialize, Deserialize, Clone)]
 pub struct AnalysisReport {
@@ -78,6 +242,188 @@ pub struct AnalysisReport {
     /// Non-fatal warnings encountered during parsing
     #[serde(default, skip_serializing_if = "Vec::is_empty")]
     pub warnings: Vec<crate::error::ParseWarning>,
+    /// Run limit that ended the analysis early (the report is partial if set)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub limit_reached: Option<LimitReached>,
+    /// Files that were discovered but could not be analyzed
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub failed_files: Vec<FailedFile>,
+    /// Files left out during discovery (only recorded with `--report-skipped`)
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub skipped_files: Vec<SkippedFile>,
+    /// File discovery statistics
+    #[serde(default)]
+    pub walk_stats: WalkStats,
+    /// Performance profile of the run (only with `--profile`)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub profile: Option<ProfileReport>,
+    /// Nested repositories summarized on their own (`--nested-repos separate`);
+    /// `summary` then covers only the files outside of them
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub components: Vec<ComponentReport>,
+    /// One summary per root path, in the order given (only with several roots)
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub roots: Vec<RootReport>,
+    /// Imports breaking the architecture layering rules (only with `--layer-rules`)
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub layer_violations: Vec<LayerViolation>,
+    /// Proposed partitions of large files (only with `--suggest-splits`)
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub split_suggestions: Vec<SplitSuggestion>,
+}
+
+/// A git submodule or nested repository analyzed as a separate component
+#[derive(Debug, Serialize, Deserialize, Clone)]
+pub struct ComponentReport {
+    /// Root directory of the repository
+    pub path: PathBuf,
+    pub kind: NestedRepoKind,
+    /// HEAD of the repository, if git could read it
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub git: Option<GitMetadata>,
+    pub summary: ProjectSummary,
+}
+
+/// Summary of the files found under one of several root paths
+#[derive(Debug, Serialize, Deserialize, Clone)]
+pub struct RootReport {
+    pub path: PathBuf,
+    pub summary: ProjectSummary,
+}
+
+impl AnalysisReport {
+    /// Normalize the report so identical inputs produce byte-identical output
+    ///
+    /// Paths become relative to `root` with `/` separators, `generated_at` is
+    /// set to `timestamp`, lists get a stable order and the (timing-dependent)
+    /// profile is dropped.
+    pub fn make_reproducible(&mut self, root: &Path, timestamp: DateTime<Utc>) {
+        let base = match root.parent() {
+            Some(parent) if root.is_file() => parent,
+            _ => root,
+        };
+        // Git mode reports absolute paths even for a relative target, so both
+        // sides are compared as absolute paths
+        let cwd = std::env::current_dir().unwrap_or_default();
+        let base = cwd.join(base);
+        let relative = |path: &mut PathBuf| {
+            let absolute = cwd.join(&*path);
+            if absolute.starts_with(&base) {
+                *path = reproducible_path(&absolute, &base);
+            }
+        };
+
+        self.generated_at = timestamp;
+        relative(&mut self.config.target_path);
+        if self.config.target_path.as_os_str().is_empty() {
+            self.config.target_path = PathBuf::from(".");
+        }
+
+        let summary = &mut self.summary;
+        for file in self
+            .files
+            .iter_mut()
+            .chain(summary.largest_files.iter_mut())
+            .chain(summary.most_complex_files.iter_mut())
+        {
+            relative(&mut file.path);
+            if let Some(ref mut root) = file.root {
+                relative(root);
+            }
+        }
+        self.warnings
+            .iter_mut()
+            .for_each(|w| relative(&mut w.file_path));
+        self.failed_files
+            .iter_mut()
+            .for_each(|f| relative(&mut f.path));
+        self.skipped_files
+            .iter_mut()
+            .for_each(|f| relative(&mut f.path));
+        self.layer_violations
+            .iter_mut()
+            .for_each(|v| relative(&mut v.path));
+        self.split_suggestions
+            .iter_mut()
+            .for_each(|s| relative(&mut s.path));
+        let nested_summaries = self
+            .components
+            .iter_mut()
+            .map(|c| (&mut c.path, &mut c.summary))
+            .chain(self.roots.iter_mut().map(|r| (&mut r.path, &mut r.summary)));
+        for (path, summary) in nested_summaries {
+            relative(path);
+            for file in summary
+                .largest_files
+                .iter_mut()
+                .chain(summary.most_complex_files.iter_mut())
+            {
+                relative(&mut file.path);
+                if let Some(ref mut root) = file.root {
+                    relative(root);
+                }
+            }
+        }
+
+        // Stable sorts keep the per-file order of warnings intact
+        self.files.sort_by(|a, b| a.path.cmp(&b.path));
+        self.warnings.sort_by(|a, b| a.file_path.cmp(&b.file_path));
+        self.failed_files.sort_by(|a, b| a.path.cmp(&b.path));
+        self.skipped_files.sort_by(|a, b| a.path.cmp(&b.path));
+        self.components.sort_by(|a, b| a.path.cmp(&b.path));
+        self.profile = None;
+    }
+}
+
+/// Express `path` relative to `base` with `/` separators (unchanged if outside `base`)
+pub fn reproducible_path(path: &Path, base: &Path) -> PathBuf {
+    let Ok(relative) = path.strip_prefix(base) else {
+        return path.to_path_buf();
+    };
+
+    let parts: Vec<_> = relative
+        .components()
+        .filter_map(|component| match component {
+            Component::Normal(part) => Some(part.to_string_lossy()),
+            Component::ParentDir => Some("..".into()),
+            _ => None,
+        })
+        .collect();
+    PathBuf::from(parts.join("/"))
+}
+
+/// Timestamp for reproducible reports: `SOURCE_DATE_EPOCH` if given, else the Unix epoch
+pub fn reproducible_timestamp(source_date_epoch: Option<&str>) -> Result<DateTime<Utc>> {
+    let Some(value) = source_date_epoch else {
+        return Ok(DateTime::UNIX_EPOCH);
+    };
+
+    value
+        .trim()
+        .parse::<i64>()
+        .ok()
+        .and_then(|secs| DateTime::from_timestamp(secs, 0))
+        .ok_or_else(|| AnalyzerError::config_error(format!("Invalid SOURCE_DATE_EPOCH: {value}")))
+}
+
+/// A file that could not be analyzed
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct FailedFile {
+    pub path: PathBuf,
+    /// Error kind (see `AnalyzerError::kind`)
+    pub kind: String,
+    pub message: String,
+}
+
+impl FailedFile {
+    /// Record a failure of `path` with `error`
+    pub fn new<P: Into<PathBuf>>(path: P, error: &AnalyzerError) -> Self {
+        Self {
+            path: path.into(),
+            kind: error.kind().to_string(),
+            message: error.to_string(),
+        }
+    }
 }
 
 /// Project-wide summary statistics
@@ -87,10 +433,15 @@ pub struct ProjectSummary {
     pub total_lines: usize,
     pub total_functions: usize,
     pub total_methods: usize,
+    #[serde(default)]
+    pub total_anonymous_functions: usize,
     pub total_classes: usize,
-    pub language_breakdown: HashMap<String, LanguageStats>,
+    pub language_breakdown: BTreeMap<String, LanguageStats>,
     pub largest_files: Vec<FileAnalysis>,
     pub most_complex_files: Vec<FileAnalysis>,
+    /// Coupling and abstractness per package (directory), by path (`--package-metrics`)
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub packages: Vec<PackageMetrics>,
 }
 
 /// Statistics for a specific language
@@ -125,6 +476,8 @@ pub enum RefactoringReason {
     LargeFile(usize),
     /// File has too many functions (functions >= 20)
     TooManyFunctions(usize),
+    /// A named metric reached its configured threshold
+    MetricThreshold(String, f64),
 }
 
 impl RefactoringReason {
@@ -135,6 +488,15 @@ impl RefactoringReason {
             RefactoringReason::HighCyclomaticComplexity(_) => "High CC",
             RefactoringReason::LargeFile(_) => "Large file",
             RefactoringReason::TooManyFunctions(_) => "Many funcs",
+            RefactoringReason::MetricThreshold(..) => "Metric",
+        }
+    }
+
+    /// Get the display label, naming the metric for generic thresholds
+    pub fn label(&self) -> String {
+        match self {
+            RefactoringReason::MetricThreshold(name, _) => format!("High {name}"),
+            other => other.short_description().to_string(),
         }
     }
 }
@@ -151,7 +513,7 @@ impl RefactoringCandidate {
     pub fn reasons_string(&self) -> String {
         self.reasons
             .iter()
-            .map(|r| r.short_description())
+            .map(|r| r.label())
             .collect::<Vec<_>>()
             .join(", ")
     }
@@ -179,6 +541,10 @@ pub struct RefactoringThresholds {
     pub max_lines_of_code: usize,
     /// Functions per file threshold (default: 25)
     pub max_functions: usize,
+    /// Thresholds for any metric by name (built-in or registered)
+    pub metric_thresholds: BTreeMap<String, f64>,
+    /// Handling of low-confidence files; only `downweight` lets their AST metrics gate
+    pub low_confidence: LowConfidenceAction,
 }
 
 impl Default for RefactoringThresholds {
@@ -188,18 +554,26 @@ impl Default for RefactoringThresholds {
             max_cyclomatic_complexity: 15,
             max_lines_of_code: 500,
             max_functions: 25,
+            metric_thresholds: BTreeMap::new(),
+            low_confidence: LowConfidenceAction::Keep,
         }
     }
 }
 
 impl RefactoringThresholds {
     /// Create thresholds from CLI arguments, using defaults for unspecified values
+    ///
+    /// Malformed `--metric-threshold` entries are rejected by `CliArgs::validate`
+    /// and ignored here.
     pub fn from_cli(args: &crate::cli::CliArgs) -> Self {
         Self {
             max_complexity_score: args.max_complexity_score.unwrap_or(10.0),
             max_cyclomatic_complexity: args.max_cc.unwrap_or(15),
             max_lines_of_code: args.max_loc.unwrap_or(500),
             max_functions: args.max_functions_per_file.unwrap_or(25),
+            metric_thresholds: super::metrics::parse_metric_bounds(&args.metric_threshold)
+                .unwrap_or_default(),
+            low_confidence: args.low_confidence,
         }
     }
 }
@@ -214,6 +588,25 @@ pub fn identify_refactoring_candidates(
     for file in files {
         let mut reasons = Vec::new();
 
+        // Line counts do not depend on the AST and always gate
+        if file.lines_of_code >= thresholds.max_lines_of_code {
+            reasons.push(RefactoringReason::LargeFile(file.lines_of_code));
+        }
+
+        // AST metrics of misparsed code are not trusted for gating, unless their
+        // score was downweighted to account for the parse errors
+        let ast_trusted = file.confidence == Confidence::High
+            || thresholds.low_confidence == LowConfidenceAction::Downweight;
+        if !ast_trusted {
+            if !reasons.is_empty() {
+                candidates.push(RefactoringCandidate {
+                    file: file.clone(),
+                    reasons,
+                });
+            }
+            continue;
+        }
+
         // Check complexity score against threshold
         if file.complexity_score >= thresholds.max_complexity_score {
             reasons.push(RefactoringReason::HighComplexityScore(
@@ -228,16 +621,20 @@ pub fn identify_refactoring_candidates(
             ));
         }
 
-        // Check file size against threshold
-        if file.lines_of_code >= thresholds.max_lines_of_code {
-            reasons.push(RefactoringReason::LargeFile(file.lines_of_code));
-        }
-
         // Check function count against threshold
         if file.functions >= thresholds.max_functions {
             reasons.push(RefactoringReason::TooManyFunctions(file.functions));
         }
 
+        // Check generic metric thresholds (files without the metric are not flagged)
+        for (name, threshold) in &thresholds.metric_thresholds {
+            if let Some(value) = file.metric(name) {
+                if value >= *threshold {
+                    reasons.push(RefactoringReason::MetricThreshold(name.clone(), value));
+                }
+            }
+        }
+
         // Only include if at least one reason
         if !reasons.is_empty() {
             candidates.push(RefactoringCandidate {
@@ -247,22 +644,164 @@ pub fn identify_refactoring_candidates(
         }
     }
 
-    // Sort by complexity score (highest first)
+    // Sort by complexity score (highest first, ties by path)
     candidates.sort_by(|a, b| {
         b.file
             .complexity_score
             .partial_cmp(&a.file.complexity_score)
             .unwrap_or(std::cmp::Ordering::Equal)
+            .then_with(|| a.file.path.cmp(&b.file.path))
     });
 
     candidates
 }
 
+/// Per-file resource limits guarding against pathological inputs
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct ParseLimits {
+    /// Cancel tree-sitter parsing after this long (None = no limit)
+    pub parse_timeout: Option<Duration>,
+    /// Skip AST metrics for trees with more nodes than this (None = no limit)
+    pub max_ast_nodes: Option<usize>,
+    /// Do not descend deeper than this in metric traversals (None = no limit)
+    pub max_traversal_depth: Option<usize>,
+}
+
+impl Default for ParseLimits {
+    fn default() -> Self {
+        Self {
+            parse_timeout: Some(Duration::from_secs(30)),
+            max_ast_nodes: Some(5_000_000),
+            max_traversal_depth: Some(2_000),
+        }
+    }
+}
+
+impl ParseLimits {
+    /// No limits at all
+    pub fn unlimited() -> Self {
+        Self {
+            parse_timeout: None,
+            max_ast_nodes: None,
+            max_traversal_depth: None,
+        }
+    }
+
+    /// Create limits from CLI arguments (0 disables a limit)
+    pub fn from_cli(args: &crate::cli::CliArgs) -> Self {
+        Self {
+            parse_timeout: (args.parse_timeout_ms > 0)
+                .then(|| Duration::from_millis(args.parse_timeout_ms)),
+            max_ast_nodes: (args.max_ast_nodes > 0).then_some(args.max_ast_nodes),
+            max_traversal_depth: (args.max_ast_depth > 0).then_some(args.max_ast_depth),
+        }
+    }
+}
+
+/// How parse errors affect a file's metrics
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct QualityPolicy {
+    /// Files with a higher parse error ratio get low confidence
+    pub error_threshold: f64,
+    /// Handling of low-confidence files
+    pub low_confidence: LowConfidenceAction,
+    /// Compute metrics only from subtrees without parse errors
+    pub error_free_metrics: bool,
+}
+
+impl Default for QualityPolicy {
+    fn default() -> Self {
+        Self {
+            error_threshold: 0.1,
+            low_confidence: LowConfidenceAction::Keep,
+            error_free_metrics: false,
+        }
+    }
+}
+
+impl QualityPolicy {
+    /// Create a quality policy from CLI arguments
+    pub fn from_cli(args: &crate::cli::CliArgs) -> Self {
+        Self {
+            error_threshold: args.parse_error_threshold,
+            low_confidence: args.low_confidence,
+            error_free_metrics: args.error_free_metrics,
+        }
+    }
+}
+
+/// Rules deciding which syntax counts towards functions and their complexity
+#[derive(Debug, Clone, Copy, Default, PartialEq)]
+pub struct CountingRules {
+    /// Whether closures and lambdas are part of their enclosing function
+    pub anonymous_functions: AnonymousFunctionMode,
+    /// Reference tool whose decision points are counted
+    pub complexity_profile: ComplexityProfile,
+}
+
+impl CountingRules {
+    /// Create counting rules from CLI arguments
+    pub fn from_cli(args: &crate::cli::CliArgs) -> Self {
+        Self {
+            anonymous_functions: args.anonymous_functions,
+            complexity_profile: args.complexity_profile,
+        }
+    }
+}
+
+/// Everything a `FileParser` is configured with besides its languages and
+/// size limit, so parsers built for other threads get the same settings
+#[derive(Clone, Default)]
+pub struct ParserOptions {
+    pub metric_registry: MetricRegistry,
+    pub limits: ParseLimits,
+    pub quality: QualityPolicy,
+    pub counting: CountingRules,
+    /// Extract-method suggestion thresholds (None = off)
+    pub extract_thresholds: Option<ExtractThresholds>,
+    /// Record the identifiers each function names, for split suggestions
+    pub references: bool,
+    /// Record import statements, for the call graph, layer rules and package metrics
+    pub imports: bool,
+    /// Record public items, for the API manifest and api-diff
+    pub api: bool,
+    /// Build symbol outlines, for `--output outline`, package metrics and split suggestions
+    pub outline: bool,
+    pub rewrite_rules: RewriteRules,
+}
+
+impl ParserOptions {
+    /// Create parser options from CLI arguments, loading `--rewrite-rules` if given
+    pub fn from_cli(args: &crate::cli::CliArgs) -> Result<Self> {
+        let rewrite_rules = match args.rewrite_rules {
+            Some(ref path) => RewriteRules::load(path)?,
+            None => RewriteRules::default(),
+        };
+        Ok(Self {
+            metric_registry: MetricRegistry::new(),
+            limits: ParseLimits::from_cli(args),
+            quality: QualityPolicy::from_cli(args),
+            counting: CountingRules::from_cli(args),
+            extract_thresholds: ExtractThresholds::from_cli(args),
+            references: args.suggest_splits,
+            imports: args.call_graph.is_some()
+                || args.layer_rules.is_some()
+                || args.package_metrics.is_some(),
+            api: args.api_manifest.is_some() || args.command.is_some(),
+            outline: args.output == OutputFormat::Outline
+                || args.package_metrics.is_some()
+                || args.suggest_splits,
+            rewrite_rules,
+        })
+    }
+}
+
 /// Core file parser using tree-sitter
 #[derive(Clone)]
 pub struct FileParser {
     language_manager: LanguageManager,
     max_file_size_bytes: u64,
+    options: ParserOptions,
 }
 
 impl FileParser {
@@ -271,9 +810,99 @@ impl FileParser {
         Self {
             language_manager,
             max_file_size_bytes: max_file_size_mb as u64 * 1024 * 1024,
+            options: ParserOptions::default(),
         }
     }
 
+    /// Apply all parser options at once
+    pub fn with_options(mut self, options: ParserOptions) -> Self {
+        self.options = options;
+        self
+    }
+
+    /// Get the parser options
+    pub fn options(&self) -> &ParserOptions {
+        &self.options
+    }
+
+    /// Use the given registry to compute additional metrics for each file
+    pub fn with_metric_registry(mut self, registry: MetricRegistry) -> Self {
+        self.options.metric_registry = registry;
+        self
+    }
+
+    /// Apply per-file resource limits
+    pub fn with_limits(mut self, limits: ParseLimits) -> Self {
+        self.options.limits = limits;
+        self
+    }
+
+    /// Get the per-file resource limits
+    pub fn limits(&self) -> ParseLimits {
+        self.options.limits
+    }
+
+    /// Apply a parse quality policy
+    pub fn with_quality_policy(mut self, quality: QualityPolicy) -> Self {
+        self.options.quality = quality;
+        self
+    }
+
+    /// Get the parse quality policy
+    pub fn quality_policy(&self) -> QualityPolicy {
+        self.options.quality
+    }
+
+    /// Apply function counting rules
+    pub fn with_counting_rules(mut self, counting: CountingRules) -> Self {
+        self.options.counting = counting;
+        self
+    }
+
+    /// Get the function counting rules
+    pub fn counting_rules(&self) -> CountingRules {
+        self.options.counting
+    }
+
+    /// Suggest blocks to extract from functions reaching these thresholds (None = off)
+    pub fn with_extract_thresholds(mut self, thresholds: Option<ExtractThresholds>) -> Self {
+        self.options.extract_thresholds = thresholds;
+        self
+    }
+
+    /// Get the extract-method suggestion thresholds
+    pub fn extract_thresholds(&self) -> Option<ExtractThresholds> {
+        self.options.extract_thresholds
+    }
+
+    /// Record the identifiers each function names, for split suggestions
+    pub fn with_references(mut self, references: bool) -> Self {
+        self.options.references = references;
+        self
+    }
+
+    /// Check if function scopes record the identifiers they name
+    pub fn records_references(&self) -> bool {
+        self.options.references
+    }
+
+    /// Record each file's public items and their signatures
+    pub fn with_api(mut self, api: bool) -> Self {
+        self.options.api = api;
+        self
+    }
+
+    /// Apply user-defined source rewrite rules to files that fail to parse
+    pub fn with_rewrite_rules(mut self, rules: RewriteRules) -> Self {
+        self.options.rewrite_rules = rules;
+        self
+    }
+
+    /// Get the source rewrite rules (clones share firing counts)
+    pub fn rewrite_rules(&self) -> &RewriteRules {
+        &self.options.rewrite_rules
+    }
+
     /// Parse a single file and extract metrics
     pub fn parse_file_metrics<P: AsRef<Path>>(&mut self, path: P) -> Result<FileAnalysis> {
         let result = self.parse_file_with_warnings(path)?;
@@ -286,31 +915,73 @@ impl FileParser {
         path: P,
     ) -> Result<FileAnalysisResult> {
         let path = path.as_ref();
-        let mut warnings = Vec::new();
+        let language = self.detect_language(path)?;
 
-        // Detect language
-        let language = self.language_manager.detect_language(path).ok_or_else(|| {
+        // Check file size before reading
+        let read_started = Instant::now();
+        let metadata = fs::metadata(path)?;
+        self.check_size(metadata.len())?;
+
+        // Read file contents
+        let source_code = fs::read(path)?;
+        let read_time = read_started.elapsed();
+
+        self.analyze_source(path, language, &source_code, read_time)
+    }
+
+    /// Parse source code that is already in memory (e.g. an archive entry),
+    /// reporting it under `path`
+    pub fn parse_source_with_warnings(
+        &mut self,
+        path: &Path,
+        source_code: &[u8],
+    ) -> Result<FileAnalysisResult> {
+        let language = self.detect_language(path)?;
+        self.parse_source_as(path, language, source_code)
+    }
+
+    /// Parse in-memory source code as `language`, whatever the extension of `path`
+    pub fn parse_source_as(
+        &mut self,
+        path: &Path,
+        language: SupportedLanguage,
+        source_code: &[u8],
+    ) -> Result<FileAnalysisResult> {
+        self.check_size(source_code.len() as u64)?;
+        self.analyze_source(path, language, source_code, Duration::ZERO)
+    }
+
+    fn detect_language(&self, path: &Path) -> Result<SupportedLanguage> {
+        self.language_manager.detect_language(path).ok_or_else(|| {
             AnalyzerError::unsupported_language(
                 path.extension()
                     .and_then(|ext| ext.to_str())
                     .unwrap_or("unknown"),
             )
-        })?;
+        })
+    }
 
-        // Check file size before reading
-        let metadata = fs::metadata(path)?;
-        if metadata.len() > self.max_file_size_bytes {
+    fn check_size(&self, size: u64) -> Result<()> {
+        if size > self.max_file_size_bytes {
             return Err(AnalyzerError::validation_error(format!(
-                "File too large: {} bytes",
-                metadata.len()
+                "File too large: {size} bytes"
             )));
         }
+        Ok(())
+    }
 
-        // Read file contents
-        let source_code = fs::read(path)?;
+    /// Compute the metrics of one file's source
+    fn analyze_source(
+        &mut self,
+        path: &Path,
+        language: SupportedLanguage,
+        source_code: &[u8],
+        read_time: Duration,
+    ) -> Result<FileAnalysisResult> {
+        let mut warnings = Vec::new();
 
         // Validate UTF-8 encoding
-        let source_text = match std::str::from_utf8(&source_code) {
+        let source_text = match std::str::from_utf8(source_code) {
             Ok(text) => text,
             Err(e) => {
                 warnings.push(ParseWarning::encoding_error(
@@ -325,19 +996,97 @@ impl FileParser {
 
         // Parse with tree-sitter
         let parser = self.language_manager.get_parser(language)?;
-        let parse_result = parse_file_safely(parser, &source_code, source_text, language, path)?;
+        let parse_result = parse_file_safely(
+            parser,
+            source_code,
+            source_text,
+            language,
+            path,
+            self.options.limits.parse_timeout,
+            &self.options.rewrite_rules,
+        )?;
 
         // Collect any parsing warnings
         warnings.extend(parse_result.warnings);
 
-        let tree = parse_result.tree;
+        let mut timing = FileTiming {
+            bytes: source_code.len() as u64,
+            read: read_time,
+            ..parse_result.timing
+        };
+        let metrics_started = Instant::now();
+
+        // Skip AST metrics for oversized trees rather than stalling a worker on them
+        let mut tree = parse_result.tree;
+        if let (Some(max_nodes), Some(ref t)) = (self.options.limits.max_ast_nodes, &tree) {
+            let node_count = t.root_node().descendant_count();
+            if node_count > max_nodes {
+                warnings.push(ParseWarning::resource_limit(
+                    path,
+                    format!("AST has {node_count} nodes (limit {max_nodes}); AST metrics skipped"),
+                ));
+                tree = None;
+            }
+        }
+
+        // Node byte offsets refer to the source the tree was parsed from
+        let parsed_source = parse_result
+            .source
+            .as_ref()
+            .map_or(source_code, |s| s.as_bytes());
+
+        let parse_error_ratio = tree.as_ref().map_or(0.0, |t| {
+            parse_error_ratio(&t.root_node(), parsed_source.len())
+        });
+        let confidence =
+            if tree.is_none() || parse_error_ratio > self.options.quality.error_threshold {
+                Confidence::Low
+            } else {
+                Confidence::High
+            };
 
         // Count lines (basic count for blank lines, AST for comments)
         let line_counts = count_lines(source_text);
 
+        // Compute all AST metrics and registered provider metrics in a single traversal
+        let mut tree_metrics = match tree {
+            Some(ref tree) => TreeVisitor::new(language, parsed_source)
+                .with_collectors(self.options.metric_registry.collectors(language))
+                .with_max_depth(self.options.limits.max_traversal_depth)
+                .with_error_free_only(self.options.quality.error_free_metrics)
+                .with_anonymous_functions(self.options.counting.anonymous_functions)
+                .with_complexity_profile(self.options.counting.complexity_profile)
+                .with_references(self.options.references)
+                .with_imports(self.options.imports)
+                .with_api(self.options.api)
+                .with_outline(self.options.outline)
+                .run(&tree.root_node()),
+            None => TreeMetrics::default(),
+        };
+
+        if let (Some(tree), Some(thresholds)) = (tree.as_ref(), self.options.extract_thresholds) {
+            suggest_extractions(
+                language,
+                &tree.root_node(),
+                parsed_source,
+                &mut tree_metrics.scopes,
+                &thresholds,
+            );
+        }
+
+        if tree_metrics.depth_limited {
+            warnings.push(ParseWarning::resource_limit(
+                path,
+                format!(
+                    "AST deeper than {} levels; nodes below that depth were not counted",
+                    self.options.limits.max_traversal_depth.unwrap_or_default()
+                ),
+            ));
+        }
+
         // Use AST-based comment counting for accuracy (falls back to heuristic if no tree)
         let comment_lines = if tree.is_some() {
-            count_comment_lines_ast(&tree, &language)
+            tree_metrics.comment_lines
         } else {
             line_counts.comments
         };
@@ -346,53 +1095,44 @@ impl FileParser {
         let total_non_blank = source_text.lines().filter(|l| !l.trim().is_empty()).count();
         let lines_of_code = total_non_blank.saturating_sub(comment_lines);
 
-        // Extract AST metrics
-        let functions = if let Some(ref tree) = tree {
-            count_functions(&tree.root_node(), &source_code, &language)
-        } else {
-            0
-        };
-
-        let methods = if let Some(ref tree) = tree {
-            count_methods(&tree.root_node(), &language)
-        } else {
-            0
-        };
-
-        let classes = if let Some(ref tree) = tree {
-            count_classes(&tree.root_node(), &source_code, &language)
-        } else {
-            0
-        };
-
-        // Calculate cyclomatic complexity (includes logical operators per McCabe)
-        let cyclomatic_complexity = calculate_cyclomatic_complexity(&tree, &source_code, &language);
-
-        // Calculate max nesting depth
-        let max_nesting_depth = if let Some(ref tree) = tree {
-            calculate_max_nesting_depth(&tree.root_node(), language.nesting_node_kinds())
-        } else {
-            0
-        };
-
         let mut analysis = FileAnalysis {
             path: path.to_path_buf(),
             language: language.to_string(),
             lines_of_code,
             blank_lines: line_counts.blank,
             comment_lines,
-            functions,
-            methods,
-            classes,
-            cyclomatic_complexity,
-            max_nesting_depth,
+            functions: tree_metrics.functions,
+            methods: tree_metrics.methods,
+            anonymous_functions: tree_metrics.anonymous_functions,
+            classes: tree_metrics.classes,
+            // Includes logical operators per McCabe; 1 for unparseable files
+            cyclomatic_complexity: tree_metrics.cyclomatic_complexity(),
+            max_nesting_depth: tree_metrics.max_nesting_depth,
             complexity_score: 0.0,
+            metrics: tree_metrics.metrics,
+            scopes: tree_metrics.scopes,
+            parse_error_ratio,
+            confidence,
+            root: None,
+            imports: tree_metrics.imports,
+            api: tree_metrics.api,
+            outline: tree_metrics.outline,
         };
 
         // Calculate complexity score (uses cyclomatic_complexity)
         analysis.calculate_complexity();
+        if confidence == Confidence::Low
+            && self.options.quality.low_confidence == LowConfidenceAction::Downweight
+        {
+            analysis.complexity_score *= 1.0 - parse_error_ratio.min(1.0);
+        }
+        timing.metrics = metrics_started.elapsed();
 
-        Ok(FileAnalysisResult { analysis, warnings })
+        Ok(FileAnalysisResult {
+            analysis,
+            warnings,
+            timing,
+        })
     }
 
     /// Check if a file can be parsed (size and language support)
@@ -468,10 +1208,42 @@ fn is_comment_line(line: &str) -> bool {
         || line.starts_with("-->") // HTML comment end
 }
 
+/// Share of `source_len` bytes covered by ERROR and MISSING nodes under `root`
+fn parse_error_ratio(root: &Node, source_len: usize) -> f64 {
+    if source_len == 0 || !root.has_error() {
+        return 0.0;
+    }
+    (error_byte_count(root) as f64 / source_len as f64).min(1.0)
+}
+
+/// Sum the byte length of error nodes, descending only into subtrees with errors
+///
+/// Iterative, so deeply nested broken input cannot overflow the stack.
+fn error_byte_count(root: &Node) -> usize {
+    let mut total = 0;
+    let mut stack = vec![*root];
+    while let Some(node) = stack.pop() {
+        if node.is_error() {
+            total += node.byte_range().len();
+        } else if node.is_missing() {
+            // MISSING nodes are zero-width; count them as one byte so they register
+            total += node.byte_range().len().max(1);
+        } else {
+            let mut cursor = node.walk();
+            stack.extend(node.children(&mut cursor).filter(|child| child.has_error()));
+        }
+    }
+    total
+}
+
 /// Parse result including tree and any warnings
 pub struct ParseResult {
     pub tree: Option<Tree>,
     pub warnings: Vec<ParseWarning>,
+    /// Rewritten source the tree was parsed from, if sanitization was needed
+    pub source: Option<String>,
+    /// Time spent sanitizing and parsing
+    pub timing: FileTiming,
 }
 
 /// Safely parse a file with error recovery and warning collection
@@ -481,11 +1253,32 @@ fn parse_file_safely(
     source_text: &str,
     language: SupportedLanguage,
     file_path: &Path,
+    timeout: Option<Duration>,
+    rewrite_rules: &RewriteRules,
 ) -> Result<ParseResult> {
-    let sanitized = sanitize_for_tree_sitter(source_text, language);
-    let original_tree = match parser.parse(source, None) {
-        Some(tree) => tree,
-        None => {
+    let deadline = timeout.map(|t| Instant::now() + t);
+    let mut timing = FileTiming::default();
+    let timed_out = |timeout: Duration, timing: FileTiming| ParseResult {
+        tree: None,
+        warnings: vec![ParseWarning::resource_limit(
+            file_path,
+            format!(
+                "Parsing exceeded the {} ms timeout; AST metrics skipped",
+                timeout.as_millis()
+            ),
+        )],
+        source: None,
+        timing,
+    };
+
+    let parse_started = Instant::now();
+    let attempt = parse_with_deadline(parser, source, deadline);
+    timing.parse = parse_started.elapsed();
+
+    let original_tree = match attempt {
+        ParseAttempt::Parsed(tree) => tree,
+        ParseAttempt::TimedOut => return Ok(timed_out(timeout.unwrap_or_default(), timing)),
+        ParseAttempt::Failed => {
             return Err(AnalyzerError::tree_sitter_error(
                 "Failed to parse file - tree-sitter returned None",
             ));
@@ -497,16 +1290,29 @@ fn parse_file_safely(
         return Ok(ParseResult {
             tree: Some(original_tree),
             warnings: Vec::new(),
+            source: None,
+            timing,
         });
     }
 
-    // If there are parse errors, try a best-effort sanitization for known grammar gaps.
+    // If there are parse errors, try a best-effort sanitization for known grammar gaps,
+    // followed by the user-defined rewrite rules. Both keep line numbers intact.
+    let sanitize_started = Instant::now();
+    let sanitized = rewrite_rules.apply(sanitize_for_tree_sitter(source_text, language), language);
+    timing.sanitize = sanitize_started.elapsed();
+
     if let Cow::Owned(sanitized_text) = sanitized {
-        if let Some(sanitized_tree) = parser.parse(sanitized_text.as_bytes(), None) {
+        let reparse_started = Instant::now();
+        let attempt = parse_with_deadline(parser, sanitized_text.as_bytes(), deadline);
+        timing.parse += reparse_started.elapsed();
+
+        if let ParseAttempt::Parsed(sanitized_tree) = attempt {
             if !sanitized_tree.root_node().has_error() {
                 return Ok(ParseResult {
                     tree: Some(sanitized_tree),
                     warnings: Vec::new(),
+                    source: Some(sanitized_text),
+                    timing,
                 });
             }
         }
@@ -535,12 +1341,56 @@ fn parse_file_safely(
             Ok(ParseResult {
                 tree: Some(tree),
                 warnings,
+                source: None,
+                timing,
             })
         }
         None => unreachable!("tree was already constructed"),
     }
 }
 
+/// Outcome of a single tree-sitter parse
+enum ParseAttempt {
+    Parsed(Tree),
+    TimedOut,
+    Failed,
+}
+
+/// Parse `source`, cancelling once `deadline` has passed
+fn parse_with_deadline(
+    parser: &mut tree_sitter::Parser,
+    source: &[u8],
+    deadline: Option<Instant>,
+) -> ParseAttempt {
+    let Some(deadline) = deadline else {
+        return match parser.parse(source, None) {
+            Some(tree) => ParseAttempt::Parsed(tree),
+            None => ParseAttempt::Failed,
+        };
+    };
+
+    let mut cancelled = false;
+    let mut progress = |_: &ParseState| {
+        cancelled = Instant::now() >= deadline;
+        cancelled
+    };
+    let tree = parser.parse_with_options(
+        &mut |byte, _| source.get(byte..).unwrap_or_default(),
+        None,
+        Some(ParseOptions::new().progress_callback(&mut progress)),
+    );
+
+    match tree {
+        Some(tree) => ParseAttempt::Parsed(tree),
+        None if cancelled => {
+            // A cancelled parse would otherwise be resumed by the next call
+            parser.reset();
+            ParseAttempt::TimedOut
+        }
+        None => ParseAttempt::Failed,
+    }
+}
+
 fn collect_parse_error_locations(
     tree: &Tree,
     source_text: &str,
@@ -593,235 +1443,25 @@ fn collect_parse_error_locations(
     locations
 }
 
-/// Generic iterative AST traversal using TreeCursor (stack-safe)
-fn count_nodes_iterative<F>(root: &Node, is_target: F) -> usize
+/// Create a project summary from analysis results
+///
+/// Takes any collection of borrowed results, so subsets of a report can be
+/// summarized without copying them; only the listed top files are cloned.
+pub fn create_project_summary<'a, I>(files: I) -> ProjectSummary
 where
-    F: Fn(&str) -> bool,
+    I: IntoIterator<Item = &'a FileAnalysis>,
 {
-    let mut count = 0;
-    let mut cursor = root.walk();
-
-    loop {
-        // Check current node
-        if is_target(cursor.node().kind()) {
-            count += 1;
-        }
-
-        // Try to descend to first child
-        if cursor.goto_first_child() {
-            continue;
-        }
-
-        // No children, try siblings or go up
-        loop {
-            if cursor.goto_next_sibling() {
-                break;
-            }
-
-            if !cursor.goto_parent() {
-                return count; // Reached root, traversal complete
-            }
-        }
-    }
-}
-
-/// Count function declarations in an AST tree (iterative, stack-safe)
-fn count_functions(node: &Node, _source: &[u8], language: &SupportedLanguage) -> usize {
-    count_nodes_iterative(node, |kind| language.is_function_node(kind))
-}
-
-/// Count class/struct declarations in an AST tree (iterative, stack-safe)
-fn count_classes(node: &Node, _source: &[u8], language: &SupportedLanguage) -> usize {
-    count_nodes_iterative(node, |kind| language.is_class_node(kind))
-}
-
-/// Count control flow nodes for cyclomatic complexity (iterative, stack-safe)
-fn count_control_flow(node: &Node, language: &SupportedLanguage) -> usize {
-    count_nodes_iterative(node, |kind| language.is_control_flow_node(kind))
-}
-
-/// Count logical operators (&& / ||) in binary expressions for cyclomatic complexity
-/// Per McCabe's original paper, compound predicates should count each condition
-/// e.g., "if (a && b)" has CC=3 (1 base + 1 if + 1 &&)
-fn count_logical_operators(node: &Node, source: &[u8], language: &SupportedLanguage) -> usize {
-    // Python handles this via 'boolean_operator' node in control_flow_node_kinds
-    let binary_kind = match language.binary_expression_node_kind() {
-        Some(kind) => kind,
-        None => return 0,
-    };
-
-    let logical_ops = language.logical_operators();
-    if logical_ops.is_empty() {
-        return 0;
-    }
-
-    let mut count = 0;
-    let mut cursor = node.walk();
-
-    loop {
-        let current = cursor.node();
-
-        // Check if this is a binary expression
-        if current.kind() == binary_kind {
-            // Look for the operator child node
-            // In tree-sitter, the operator is typically an anonymous node
-            // We need to find a child that matches our logical operators
-            for i in 0..current.child_count() {
-                if let Some(child) = current.child(i as u32) {
-                    // Anonymous nodes (operators) have is_named() == false
-                    if !child.is_named() {
-                        if let Ok(op_text) = child.utf8_text(source) {
-                            if logical_ops.contains(&op_text) {
-                                count += 1;
-                                break; // Only count once per binary_expression
-                            }
-                        }
-                    }
-                }
-            }
-        }
-
-        // Navigate tree iteratively
-        if cursor.goto_first_child() {
-            continue;
-        }
-
-        loop {
-            if cursor.goto_next_sibling() {
-                break;
-            }
-            if !cursor.goto_parent() {
-                return count;
-            }
-        }
-    }
-}
-
-/// Count method declarations in an AST tree (iterative, stack-safe)
-fn count_methods(node: &Node, language: &SupportedLanguage) -> usize {
-    count_nodes_iterative(node, |kind| language.is_method_node(kind))
-}
-
-fn calculate_max_nesting_depth(root: &Node, nesting_kinds: &[&str]) -> usize {
-    let mut max_depth = 0;
-    let mut cursor = root.walk();
-    let mut depth_stack: Vec<bool> = Vec::new();
-
-    loop {
-        let node = cursor.node();
-        let is_nesting = nesting_kinds.contains(&node.kind());
-
-        if is_nesting {
-            depth_stack.push(true);
-            let current_depth = depth_stack.iter().filter(|&&x| x).count();
-            max_depth = max_depth.max(current_depth);
-        }
-
-        if cursor.goto_first_child() {
-            if !is_nesting {
-                depth_stack.push(false);
-            }
-            continue;
-        }
-
-        loop {
-            if cursor.goto_next_sibling() {
-                break;
-            }
-            if !cursor.goto_parent() {
-                return max_depth;
-            }
-            depth_stack.pop();
-        }
-    }
-}
-
-/// Calculate cyclomatic complexity: 1 + number of decision points + logical operators
-///
-/// This follows McCabe's original formula where compound predicates count each condition.
-/// For example: `if (a && b)` has CC=3 (1 base + 1 if + 1 &&)
-///
-/// Formula: M = 1 + decision_points + logical_operators
-/// - decision_points: if, for, while, switch, catch, ternary, etc.
-/// - logical_operators: && and || (compound predicates per McCabe)
-///
-/// Reference: NIST SP 500-235, McCabe's original 1976 paper
-fn calculate_cyclomatic_complexity(
-    tree: &Option<Tree>,
-    source: &[u8],
-    language: &SupportedLanguage,
-) -> usize {
-    match tree {
-        Some(tree) => {
-            // Base complexity is 1 (for the main path)
-            let base = 1;
-
-            // Count decision points (if, for, while, switch, etc.)
-            let decision_points = count_control_flow(&tree.root_node(), language);
-
-            // Count logical operators (&& / ||) per McCabe's compound predicate rule
-            // Note: Python's 'and'/'or' are handled via boolean_operator in decision_points
-            let logical_ops = count_logical_operators(&tree.root_node(), source, language);
-
-            base + decision_points + logical_ops
-        }
-        None => 1, // Minimum complexity for unparseable files
-    }
-}
-
-/// Count comment lines using AST - more accurate than heuristic
-/// Returns the number of unique lines that contain comments
-fn count_comment_lines_ast(tree: &Option<Tree>, language: &SupportedLanguage) -> usize {
-    use std::collections::HashSet;
-
-    match tree {
-        Some(tree) => {
-            let mut comment_lines = HashSet::new();
-            let mut cursor = tree.root_node().walk();
-
-            loop {
-                let node = cursor.node();
-
-                // Check if this is a comment node
-                if language.is_comment_node(node.kind()) {
-                    // Add all lines this comment spans
-                    let start_line = node.start_position().row;
-                    let end_line = node.end_position().row;
-                    for line in start_line..=end_line {
-                        comment_lines.insert(line);
-                    }
-                }
-
-                // Navigate tree iteratively
-                if cursor.goto_first_child() {
-                    continue;
-                }
-
-                loop {
-                    if cursor.goto_next_sibling() {
-                        break;
-                    }
-                    if !cursor.goto_parent() {
-                        return comment_lines.len();
-                    }
-                }
-            }
-        }
-        None => 0,
-    }
-}
-
-/// Create a project summary from analysis results
-pub fn create_project_summary(files: &[FileAnalysis]) -> ProjectSummary {
+    let files: Vec<&FileAnalysis> = files.into_iter().collect();
     let total_files = files.len();
     let total_lines = files.iter().map(|f| f.total_lines()).sum();
     let total_functions = files.iter().map(|f| f.functions).sum();
     let total_methods = files.iter().map(|f| f.methods).sum();
+    let total_anonymous_functions = files.iter().map(|f| f.anonymous_functions).sum();
     let total_classes = files.iter().map(|f| f.classes).sum();
 
     // Calculate language breakdown
-    let mut language_breakdown = HashMap::new();
-    for file in files {
+    let mut language_breakdown = BTreeMap::new();
+    for file in &files {
         let entry = language_breakdown
             .entry(file.language.clone())
             .or_insert_with(|| LanguageStats {
@@ -862,25 +1502,36 @@ pub fn create_project_summary(files: &[FileAnalysis]) -> ProjectSummary {
         };
     }
 
-    // Get largest files (top 10 by total lines)
-    let mut largest_files = files.to_vec();
-    largest_files.sort_by_key(|f| std::cmp::Reverse(f.total_lines()));
-    largest_files.truncate(10);
+    // Get largest files (top 10 by total lines, ties by path)
+    let mut largest_files = files.clone();
+    largest_files.sort_by(|a, b| {
+        b.total_lines()
+            .cmp(&a.total_lines())
+            .then_with(|| a.path.cmp(&b.path))
+    });
+    let largest_files = largest_files.into_iter().take(10).cloned().collect();
 
-    // Get most complex files (top 10 by complexity score)
-    let mut most_complex_files = files.to_vec();
-    most_complex_files.sort_by(|a, b| b.complexity_score.partial_cmp(&a.complexity_score).unwrap());
-    most_complex_files.truncate(10);
+    // Get most complex files (top 10 by complexity score, ties by path)
+    let mut most_complex_files = files;
+    most_complex_files.sort_by(|a, b| {
+        b.complexity_score
+            .partial_cmp(&a.complexity_score)
+            .unwrap_or(std::cmp::Ordering::Equal)
+            .then_with(|| a.path.cmp(&b.path))
+    });
+    let most_complex_files = most_complex_files.into_iter().take(10).cloned().collect();
 
     ProjectSummary {
         total_files,
         total_lines,
         total_functions,
         total_methods,
+        total_anonymous_functions,
         total_classes,
         language_breakdown,
         largest_files,
         most_complex_files,
+        packages: Vec::new(),
     }
 }
 
@@ -926,10 +1577,19 @@ fn main() {
             comment_lines: 20,
             functions: 5,
             methods: 3,
+            anonymous_functions: 0,
             classes: 2,
             cyclomatic_complexity: 10,
             max_nesting_depth: 0,
             complexity_score: 0.0,
+            metrics: BTreeMap::new(),
+            scopes: Vec::new(),
+            parse_error_ratio: 0.0,
+            confidence: Confidence::High,
+            root: None,
+            imports: Vec::new(),
+            api: Vec::new(),
+            outline: Vec::new(),
         };
 
         analysis.calculate_complexity();
@@ -947,10 +1607,19 @@ fn main() {
                 comment_lines: 5,
                 functions: 3,
                 methods: 2,
+                anonymous_functions: 0,
                 classes: 1,
                 cyclomatic_complexity: 5,
                 max_nesting_depth: 0,
                 complexity_score: 2.5,
+                metrics: BTreeMap::new(),
+                scopes: Vec::new(),
+                parse_error_ratio: 0.0,
+                confidence: Confidence::High,
+                root: None,
+                imports: Vec::new(),
+                api: Vec::new(),
+                outline: Vec::new(),
             },
             FileAnalysis {
                 path: PathBuf::from("test2.rs"),
@@ -960,10 +1629,19 @@ fn main() {
                 comment_lines: 10,
                 functions: 5,
                 methods: 4,
+                anonymous_functions: 0,
                 classes: 2,
                 cyclomatic_complexity: 8,
                 max_nesting_depth: 0,
                 complexity_score: 4.0,
+                metrics: BTreeMap::new(),
+                scopes: Vec::new(),
+                parse_error_ratio: 0.0,
+                confidence: Confidence::High,
+                root: None,
+                imports: Vec::new(),
+                api: Vec::new(),
+                outline: Vec::new(),
             },
         ];
 
@@ -999,6 +1677,194 @@ fn main() {
         let _ = temp_file.close();
     }
 
+    #[test]
+    fn test_max_ast_nodes_skips_ast_metrics() {
+        use crate::analyzer::language::LanguageManager;
+        use crate::error::WarningType;
+
+        let dir = tempfile::TempDir::new().unwrap();
+        let path = dir.path().join("big.rs");
+        std::fs::write(&path, "fn a() { if x { } }\nfn b() {}\n").unwrap();
+
+        let limits = ParseLimits {
+            max_ast_nodes: Some(5),
+            ..ParseLimits::unlimited()
+        };
+        let mut parser = FileParser::new(LanguageManager::new(), 10).with_limits(limits);
+        let result = parser.parse_file_with_warnings(&path).unwrap();
+
+        assert!(matches!(
+            result.warnings[0].warning_type,
+            WarningType::ResourceLimit
+        ));
+        // Line counts survive, AST metrics fall back to their minimums
+        assert_eq!(result.analysis.lines_of_code, 2);
+        assert_eq!(result.analysis.functions, 0);
+        assert_eq!(result.analysis.cyclomatic_complexity, 1);
+
+        let mut parser = FileParser::new(LanguageManager::new(), 10);
+        let result = parser.parse_file_with_warnings(&path).unwrap();
+        assert!(result.warnings.is_empty());
+        assert_eq!(result.analysis.functions, 2);
+    }
+
+    #[test]
+    fn test_parse_error_ratio_and_confidence() {
+        use crate::analyzer::language::LanguageManager;
+
+        let dir = tempfile::TempDir::new().unwrap();
+        let clean = dir.path().join("clean.rs");
+        let broken = dir.path().join("broken.rs");
+        std::fs::write(&clean, "fn a() { if x { } }\n").unwrap();
+        std::fs::write(&broken, "fn a() { if x { } }\nfn b() { let = = = ; }\n").unwrap();
+
+        let mut parser = FileParser::new(LanguageManager::new(), 10);
+        let clean = parser.parse_file_metrics(&clean).unwrap();
+        assert_eq!(clean.parse_error_ratio, 0.0);
+        assert_eq!(clean.confidence, Confidence::High);
+
+        let mut parser =
+            FileParser::new(LanguageManager::new(), 10).with_quality_policy(QualityPolicy {
+                error_threshold: 0.0,
+                low_confidence: LowConfidenceAction::Downweight,
+                error_free_metrics: true,
+            });
+        let result = parser.parse_file_metrics(&broken).unwrap();
+        assert!(result.parse_error_ratio > 0.0 && result.parse_error_ratio < 1.0);
+        assert_eq!(result.confidence, Confidence::Low);
+        // Only the well-formed function is counted
+        assert_eq!(result.functions, 1);
+
+        let mut undamped = result.clone();
+        undamped.calculate_complexity();
+        assert!(result.complexity_score < undamped.complexity_score);
+
+        // AST metrics of low-confidence files only gate when downweighted
+        let thresholds = RefactoringThresholds {
+            max_functions: 1,
+            ..Default::default()
+        };
+        assert!(identify_refactoring_candidates(&[result.clone()], &thresholds).is_empty());
+        assert_eq!(
+            identify_refactoring_candidates(&[clean], &thresholds).len(),
+            1
+        );
+        let downweighted = RefactoringThresholds {
+            low_confidence: LowConfidenceAction::Downweight,
+            ..thresholds
+        };
+        assert_eq!(
+            identify_refactoring_candidates(&[result.clone()], &downweighted)[0].reasons,
+            vec![RefactoringReason::TooManyFunctions(1)]
+        );
+
+        // Line counts gate regardless of confidence
+        let thresholds = RefactoringThresholds {
+            max_lines_of_code: 1,
+            ..Default::default()
+        };
+        assert_eq!(
+            identify_refactoring_candidates(&[result], &thresholds)[0].reasons,
+            vec![RefactoringReason::LargeFile(2)]
+        );
+    }
+
+    #[test]
+    fn test_reproducible_path() {
+        let base = Path::new("/work/repo");
+        assert_eq!(
+            reproducible_path(Path::new("/work/repo/src/main.rs"), base),
+            PathBuf::from("src/main.rs")
+        );
+        assert_eq!(
+            reproducible_path(Path::new("./src/lib.rs"), Path::new(".")),
+            PathBuf::from("src/lib.rs")
+        );
+        // Paths outside the root are left alone
+        assert_eq!(
+            reproducible_path(Path::new("/elsewhere/a.rs"), base),
+            PathBuf::from("/elsewhere/a.rs")
+        );
+    }
+
+    #[test]
+    fn test_reproducible_timestamp() {
+        assert_eq!(reproducible_timestamp(None).unwrap().timestamp(), 0);
+        assert_eq!(
+            reproducible_timestamp(Some("1700000000"))
+                .unwrap()
+                .timestamp(),
+            1_700_000_000
+        );
+        assert!(reproducible_timestamp(Some("yesterday")).is_err());
+    }
+
+    #[test]
+    fn test_make_reproducible() {
+        let root = tempfile::TempDir::new().unwrap();
+        let file = |name: &str| FileAnalysis {
+            path: root.path().join(name),
+            ..fixtures::file(name)
+        };
+        let files = vec![file("src/b.rs"), file("a.rs")];
+
+        let mut report = AnalysisReport {
+            summary: create_project_summary(&files),
+            files,
+            config: AnalysisConfig {
+                target_path: root.path().to_path_buf(),
+                languages: Vec::new(),
+                min_lines: 1,
+                max_lines: None,
+                include_hidden: false,
+                max_file_size_mb: 10,
+            },
+            generated_at: Utc::now(),
+            warnings: vec![
+                ParseWarning::syntax_error(root.path().join("src/b.rs"), "b"),
+                ParseWarning::syntax_error(root.path().join("a.rs"), "a"),
+            ],
+            limit_reached: None,
+            failed_files: Vec::new(),
+            skipped_files: Vec::new(),
+            walk_stats: WalkStats::default(),
+            profile: None,
+            components: Vec::new(),
+            roots: Vec::new(),
+            layer_violations: Vec::new(),
+            split_suggestions: Vec::new(),
+        };
+        report.make_reproducible(root.path(), DateTime::UNIX_EPOCH);
+
+        let paths: Vec<_> = report.files.iter().map(|f| f.path.clone()).collect();
+        assert_eq!(
+            paths,
+            vec![PathBuf::from("a.rs"), PathBuf::from("src/b.rs")]
+        );
+        assert_eq!(report.summary.largest_files[0].path, PathBuf::from("a.rs"));
+        assert_eq!(report.warnings[0].file_path, PathBuf::from("a.rs"));
+        assert_eq!(report.config.target_path, PathBuf::from("."));
+        assert_eq!(report.generated_at.timestamp(), 0);
+    }
+
+    #[test]
+    fn test_parse_limits_from_cli() {
+        let args = crate::cli::CliArgs {
+            parse_timeout_ms: 0,
+            max_ast_nodes: 100,
+            ..Default::default()
+        };
+        let limits = ParseLimits::from_cli(&args);
+
+        assert_eq!(limits.parse_timeout, None);
+        assert_eq!(limits.max_ast_nodes, Some(100));
+        assert_eq!(limits.max_traversal_depth, Some(2_000));
+        assert_eq!(
+            ParseLimits::from_cli(&crate::cli::CliArgs::default()),
+            ParseLimits::default()
+        );
+    }
+
     #[test]
     fn test_file_analysis_result_structure() {
         let file = FileAnalysis {
@@ -1009,15 +1875,25 @@ fn main() {
             comment_lines: 10,
             functions: 3,
             methods: 2,
+            anonymous_functions: 0,
             classes: 1,
             cyclomatic_complexity: 5,
             max_nesting_depth: 0,
             complexity_score: 2.5,
+            metrics: BTreeMap::new(),
+            scopes: Vec::new(),
+            parse_error_ratio: 0.0,
+            confidence: Confidence::High,
+            root: None,
+            imports: Vec::new(),
+            api: Vec::new(),
+            outline: Vec::new(),
         };
 
         let result = FileAnalysisResult {
             analysis: file,
             warnings: vec![],
+            timing: FileTiming::default(),
         };
 
         assert_eq!(result.analysis.lines_of_code, 50);
@@ -1030,79 +1906,52 @@ fn main() {
         let parse_result = ParseResult {
             tree: None,
             warnings: vec![],
+            source: None,
+            timing: FileTiming::default(),
         };
 
         assert!(parse_result.tree.is_none());
         assert!(parse_result.warnings.is_empty());
     }
 
-    #[test]
-    fn test_count_nodes_iterative_empty_tree() {
-        use crate::analyzer::language::SupportedLanguage;
-
-        let source = "fn main() {}";
-        let _language = SupportedLanguage::Rust;
-
-        // Create a minimal tree for testing
+    fn visit(source: &[u8]) -> TreeMetrics {
         let mut parser = tree_sitter::Parser::new();
         parser
             .set_language(&tree_sitter_rust::LANGUAGE.into())
             .unwrap();
         let tree = parser.parse(source, None).unwrap();
-        let root = tree.root_node();
-
-        // Test with a non-matching kind (should return 0)
-        let count = count_nodes_iterative(&root, |kind| kind == "nonexistent_node");
-        assert_eq!(count, 0);
+        TreeVisitor::new(SupportedLanguage::Rust, source).run(&tree.root_node())
     }
 
     #[test]
-    fn test_calculate_cyclomatic_complexity_none_tree() {
-        use crate::analyzer::language::SupportedLanguage;
-
-        let language = SupportedLanguage::Rust;
-        let source = b"";
-        let complexity = calculate_cyclomatic_complexity(&None, source, &language);
+    fn test_tree_visitor_no_matching_nodes() {
+        let metrics = visit(b"fn main() {}");
 
-        // Should return minimum complexity of 1
-        assert_eq!(complexity, 1);
+        // No classes, comments or decision points in a bare function
+        assert_eq!(metrics.classes, 0);
+        assert_eq!(metrics.comment_lines, 0);
+        assert_eq!(metrics.decision_points, 0);
     }
 
     #[test]
-    fn test_calculate_cyclomatic_complexity_with_logical_operators() {
-        use crate::analyzer::language::SupportedLanguage;
-
-        // Test Rust code with logical operators
-        let source = b"fn test() { if a && b { } if c || d { } }";
-        let mut parser = tree_sitter::Parser::new();
-        parser
-            .set_language(&tree_sitter_rust::LANGUAGE.into())
-            .unwrap();
-        let tree = parser.parse(&source[..], None);
+    fn test_cyclomatic_complexity_without_tree() {
+        // Unparseable files get the minimum complexity of 1
+        assert_eq!(TreeMetrics::default().cyclomatic_complexity(), 1);
+    }
 
-        let language = SupportedLanguage::Rust;
-        let complexity = calculate_cyclomatic_complexity(&tree, source, &language);
+    #[test]
+    fn test_cyclomatic_complexity_with_logical_operators() {
+        let metrics = visit(b"fn test() { if a && b { } if c || d { } }");
 
         // Expected: 1 base + 2 if statements + 2 logical operators (&&, ||) = 5
-        assert_eq!(complexity, 5);
+        assert_eq!(metrics.cyclomatic_complexity(), 5);
     }
 
     #[test]
-    fn test_calculate_cyclomatic_complexity_compound_predicates() {
-        use crate::analyzer::language::SupportedLanguage;
-
-        // Test with multiple chained logical operators
-        let source = b"fn test() { if a && b && c || d { } }";
-        let mut parser = tree_sitter::Parser::new();
-        parser
-            .set_language(&tree_sitter_rust::LANGUAGE.into())
-            .unwrap();
-        let tree = parser.parse(&source[..], None);
-
-        let language = SupportedLanguage::Rust;
-        let complexity = calculate_cyclomatic_complexity(&tree, source, &language);
+    fn test_cyclomatic_complexity_compound_predicates() {
+        let metrics = visit(b"fn test() { if a && b && c || d { } }");
 
         // Expected: 1 base + 1 if + 3 logical operators (&&, &&, ||) = 5
-        assert_eq!(complexity, 5);
+        assert_eq!(metrics.cyclomatic_complexity(), 5);
     }
 }
@@ -0,0 +1,307 @@
+//! Self-profiling of an analysis run (`--profile`).
+//!
+//! Per-file phases (read, sanitize, parse, metrics) are measured by the parser
+//! and summed across worker threads, so they can add up to more than the wall
+//! time. Walk, aggregation and output are wall-clock times of their stage.
+
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
+use std::path::{Path, PathBuf};
+use std::time::{Duration, Instant};
+
+/// Stage of an analysis run
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum Phase {
+    Walk,
+    Read,
+    Sanitize,
+    Parse,
+    Metrics,
+    Aggregation,
+    Output,
+}
+
+impl Phase {
+    /// Get the lowercase name of the phase
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Phase::Walk => "walk",
+            Phase::Read => "read",
+            Phase::Sanitize => "sanitize",
+            Phase::Parse => "parse",
+            Phase::Metrics => "metrics",
+            Phase::Aggregation => "aggregation",
+            Phase::Output => "output",
+        }
+    }
+}
+
+/// Time spent on a single file, by phase
+#[derive(Debug, Clone, Copy, Default, PartialEq)]
+pub struct FileTiming {
+    /// Size of the file in bytes
+    pub bytes: u64,
+    pub read: Duration,
+    pub sanitize: Duration,
+    pub parse: Duration,
+    pub metrics: Duration,
+}
+
+impl FileTiming {
+    /// Total time spent on the file
+    pub fn total(&self) -> Duration {
+        self.read + self.sanitize + self.parse + self.metrics
+    }
+}
+
+/// Parse throughput for one language
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct LanguageProfile {
+    pub language: String,
+    pub files: usize,
+    pub bytes: u64,
+    pub parse_seconds: f64,
+    /// Bytes parsed per second of parse time
+    pub bytes_per_second: f64,
+}
+
+/// Timing of one of the slowest files
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct FileProfile {
+    pub path: PathBuf,
+    pub language: String,
+    pub bytes: u64,
+    pub seconds: f64,
+    pub parse_seconds: f64,
+}
+
+/// Profile of an analysis run, embedded in the JSON report
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct ProfileReport {
+    /// Wall time from the start of the run until the profile was taken
+    pub wall_seconds: f64,
+    /// Seconds per phase (the JSON report is written before `output` is known)
+    pub phases: BTreeMap<Phase, f64>,
+    pub languages: Vec<LanguageProfile>,
+    pub slowest_files: Vec<FileProfile>,
+    /// Peak resident set size, where the platform reports it
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub peak_memory_bytes: Option<u64>,
+}
+
+impl ProfileReport {
+    /// Add time spent in `phase` after the report was taken
+    pub fn record_phase(&mut self, phase: Phase, elapsed: Duration) {
+        *self.phases.entry(phase).or_default() += elapsed.as_secs_f64();
+        self.peak_memory_bytes = peak_memory_bytes().or(self.peak_memory_bytes);
+    }
+
+    /// Print the profile to stderr
+    pub fn print(&self) {
+        eprintln!();
+        eprintln!("Profile ({:.3}s wall time)", self.wall_seconds);
+        eprintln!("  Phases:");
+        for (phase, seconds) in &self.phases {
+            eprintln!("    {:<12} {:>9.3}s", phase.as_str(), seconds);
+        }
+
+        if !self.languages.is_empty() {
+            eprintln!("  Parse throughput:");
+            for lang in &self.languages {
+                eprintln!(
+                    "    {:<12} {:>6} files {:>12} bytes {:>10.1} KB/s",
+                    lang.language,
+                    lang.files,
+                    lang.bytes,
+                    lang.bytes_per_second / 1024.0
+                );
+            }
+        }
+
+        if !self.slowest_files.is_empty() {
+            eprintln!("  Slowest files:");
+            for file in &self.slowest_files {
+                eprintln!(
+                    "    {:>9.3}s (parse {:.3}s, {} bytes) {}",
+                    file.seconds,
+                    file.parse_seconds,
+                    file.bytes,
+                    file.path.display()
+                );
+            }
+        }
+
+        if let Some(bytes) = self.peak_memory_bytes {
+            eprintln!("  Peak memory: {:.1} MB", bytes as f64 / (1024.0 * 1024.0));
+        }
+    }
+}
+
+/// Per-language accumulator
+#[derive(Debug, Default)]
+struct LanguageTotals {
+    files: usize,
+    bytes: u64,
+    parse: Duration,
+}
+
+/// Collects phase and file timings during a run
+#[derive(Debug)]
+pub struct Profiler {
+    started: Instant,
+    slowest: usize,
+    phases: BTreeMap<Phase, Duration>,
+    languages: BTreeMap<String, LanguageTotals>,
+    files: Vec<FileProfile>,
+}
+
+impl Profiler {
+    /// Start profiling now, keeping the `slowest` slowest files
+    pub fn new(slowest: usize) -> Self {
+        Self {
+            started: Instant::now(),
+            slowest,
+            phases: BTreeMap::new(),
+            languages: BTreeMap::new(),
+            files: Vec::new(),
+        }
+    }
+
+    /// Add wall time spent in `phase`
+    pub fn record_phase(&mut self, phase: Phase, elapsed: Duration) {
+        *self.phases.entry(phase).or_default() += elapsed;
+    }
+
+    /// Add the timing of one analyzed file
+    pub fn record_file(&mut self, path: &Path, language: &str, timing: &FileTiming) {
+        self.record_phase(Phase::Read, timing.read);
+        self.record_phase(Phase::Sanitize, timing.sanitize);
+        self.record_phase(Phase::Parse, timing.parse);
+        self.record_phase(Phase::Metrics, timing.metrics);
+
+        let totals = self.languages.entry(language.to_string()).or_default();
+        totals.files += 1;
+        totals.bytes += timing.bytes;
+        totals.parse += timing.parse;
+
+        self.files.push(FileProfile {
+            path: path.to_path_buf(),
+            language: language.to_string(),
+            bytes: timing.bytes,
+            seconds: timing.total().as_secs_f64(),
+            parse_seconds: timing.parse.as_secs_f64(),
+        });
+    }
+
+    /// Produce the profile report
+    pub fn finish(mut self) -> ProfileReport {
+        self.files.sort_by(|a, b| {
+            b.seconds
+                .partial_cmp(&a.seconds)
+                .unwrap_or(std::cmp::Ordering::Equal)
+                .then_with(|| a.path.cmp(&b.path))
+        });
+        self.files.truncate(self.slowest);
+
+        let languages = self
+            .languages
+            .into_iter()
+            .map(|(language, totals)| {
+                let parse_seconds = totals.parse.as_secs_f64();
+                LanguageProfile {
+                    language,
+                    files: totals.files,
+                    bytes: totals.bytes,
+                    parse_seconds,
+                    bytes_per_second: if parse_seconds > 0.0 {
+                        totals.bytes as f64 / parse_seconds
+                    } else {
+                        0.0
+                    },
+                }
+            })
+            .collect();
+
+        ProfileReport {
+            wall_seconds: self.started.elapsed().as_secs_f64(),
+            phases: self
+                .phases
+                .into_iter()
+                .map(|(phase, elapsed)| (phase, elapsed.as_secs_f64()))
+                .collect(),
+            languages,
+            slowest_files: self.files,
+            peak_memory_bytes: peak_memory_bytes(),
+        }
+    }
+}
+
+/// Peak resident set size of this process (Linux only)
+#[cfg(target_os = "linux")]
+pub fn peak_memory_bytes() -> Option<u64> {
+    let status = std::fs::read_to_string("/proc/self/status").ok()?;
+    let line = status.lines().find(|l| l.starts_with("VmHWM:"))?;
+    let kb: u64 = line.split_whitespace().nth(1)?.parse().ok()?;
+    Some(kb * 1024)
+}
+
+/// Peak resident set size of this process (Linux only)
+#[cfg(not(target_os = "linux"))]
+pub fn peak_memory_bytes() -> Option<u64> {
+    None
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn timing(bytes: u64, parse_ms: u64) -> FileTiming {
+        FileTiming {
+            bytes,
+            read: Duration::from_millis(1),
+            parse: Duration::from_millis(parse_ms),
+            ..Default::default()
+        }
+    }
+
+    #[test]
+    fn test_profiler_aggregates_files() {
+        let mut profiler = Profiler::new(2);
+        profiler.record_phase(Phase::Walk, Duration::from_millis(5));
+        profiler.record_file(Path::new("a.rs"), "rust", &timing(1000, 10));
+        profiler.record_file(Path::new("b.rs"), "rust", &timing(3000, 30));
+        profiler.record_file(Path::new("c.py"), "python", &timing(500, 20));
+
+        let report = profiler.finish();
+
+        assert_eq!(report.phases[&Phase::Walk], 0.005);
+        assert!((report.phases[&Phase::Parse] - 0.06).abs() < 1e-9);
+        assert!((report.phases[&Phase::Read] - 0.003).abs() < 1e-9);
+
+        let rust = &report.languages[1];
+        assert_eq!(
+            (rust.language.as_str(), rust.files, rust.bytes),
+            ("rust", 2, 4000)
+        );
+        assert!((rust.bytes_per_second - 100_000.0).abs() < 1e-6);
+
+        let slowest: Vec<_> = report
+            .slowest_files
+            .iter()
+            .map(|f| f.path.clone())
+            .collect();
+        assert_eq!(slowest, vec![PathBuf::from("b.rs"), PathBuf::from("c.py")]);
+    }
+
+    #[test]
+    fn test_profile_report_json_round_trip() {
+        let mut report = Profiler::new(10).finish();
+        report.record_phase(Phase::Output, Duration::from_millis(2));
+
+        let json = serde_json::to_string(&report).unwrap();
+        assert!(json.contains("\"output\":0.002"));
+        let parsed: ProfileReport = serde_json::from_str(&json).unwrap();
+        assert_eq!(parsed.phases, report.phases);
+    }
+}
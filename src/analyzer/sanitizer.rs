@@ -1,11 +1,252 @@
 //! Source code sanitization for tree-sitter parsing.
 //!
 //! Some language constructs are not supported by tree-sitter grammars and require
-//! preprocessing before parsing. This module handles those edge cases.
+//! preprocessing before parsing. This module handles those edge cases, both the
+//! built-in ones and user-defined `RewriteRules` loaded from a JSON file.
 
 use std::borrow::Cow;
+use std::path::Path;
+use std::sync::atomic::{AtomicUsize, Ordering};
+use std::sync::Arc;
+
+use regex::Regex;
+use serde::Deserialize;
 
 use super::language::SupportedLanguage;
+use crate::error::{AnalyzerError, Result};
+
+/// What a rewrite rule matches
+#[derive(Debug, Clone)]
+enum RewritePattern {
+    Literal(String),
+    Regex(Regex),
+}
+
+/// A user-defined source rewrite, applied line by line so line numbers are preserved
+#[derive(Debug, Clone)]
+pub struct RewriteRule {
+    name: String,
+    /// Languages the rule applies to (empty = all languages)
+    languages: Vec<SupportedLanguage>,
+    pattern: RewritePattern,
+    replacement: String,
+}
+
+impl RewriteRule {
+    /// Create a rule replacing every occurrence of `literal`
+    pub fn literal(name: &str, literal: &str, replacement: &str) -> Result<Self> {
+        if literal.is_empty() {
+            return Err(AnalyzerError::config_error(format!(
+                "rewrite rule '{name}': literal must not be empty"
+            )));
+        }
+        Self::new(
+            name,
+            RewritePattern::Literal(literal.to_string()),
+            replacement,
+        )
+    }
+
+    /// Create a rule replacing every match of `pattern` (`$1`-style captures allowed)
+    pub fn regex(name: &str, pattern: &str, replacement: &str) -> Result<Self> {
+        let regex = Regex::new(pattern).map_err(|e| {
+            AnalyzerError::config_error(format!("rewrite rule '{name}': invalid regex: {e}"))
+        })?;
+        if regex.is_match("") {
+            return Err(AnalyzerError::config_error(format!(
+                "rewrite rule '{name}': regex must not match the empty string"
+            )));
+        }
+        Self::new(name, RewritePattern::Regex(regex), replacement)
+    }
+
+    fn new(name: &str, pattern: RewritePattern, replacement: &str) -> Result<Self> {
+        let spans_lines = match &pattern {
+            RewritePattern::Literal(literal) => literal.contains('\n'),
+            RewritePattern::Regex(_) => false,
+        };
+        if spans_lines || replacement.contains('\n') {
+            return Err(AnalyzerError::config_error(format!(
+                "rewrite rule '{name}': rules must not add or remove line breaks"
+            )));
+        }
+
+        Ok(Self {
+            name: name.to_string(),
+            languages: Vec::new(),
+            pattern,
+            replacement: replacement.to_string(),
+        })
+    }
+
+    /// Restrict the rule to the given languages
+    pub fn with_languages(mut self, languages: Vec<SupportedLanguage>) -> Self {
+        self.languages = languages;
+        self
+    }
+
+    /// Get the rule name
+    pub fn name(&self) -> &str {
+        &self.name
+    }
+
+    /// Check if the rule applies to `language`
+    pub fn applies_to(&self, language: SupportedLanguage) -> bool {
+        self.languages.is_empty() || self.languages.contains(&language)
+    }
+
+    /// Quick check whether the rule can match anywhere in `source`
+    fn may_match(&self, source: &str) -> bool {
+        match &self.pattern {
+            RewritePattern::Literal(literal) => source.contains(literal.as_str()),
+            RewritePattern::Regex(regex) => regex.is_match(source),
+        }
+    }
+
+    /// Rewrite a single line (without its terminator), returning the number of matches
+    fn rewrite_line(&self, line: &str, out: &mut String) -> usize {
+        match &self.pattern {
+            RewritePattern::Literal(literal) => {
+                let count = line.matches(literal.as_str()).count();
+                out.push_str(&line.replace(literal.as_str(), &self.replacement));
+                count
+            }
+            RewritePattern::Regex(regex) => {
+                let count = regex.find_iter(line).count();
+                out.push_str(&regex.replace_all(line, self.replacement.as_str()));
+                count
+            }
+        }
+    }
+}
+
+/// On-disk form of a rewrite rule
+#[derive(Debug, Deserialize)]
+#[serde(deny_unknown_fields)]
+struct RewriteRuleSpec {
+    name: String,
+    #[serde(default)]
+    languages: Vec<String>,
+    literal: Option<String>,
+    regex: Option<String>,
+    replace: String,
+}
+
+/// On-disk form of a rewrite rules file
+#[derive(Debug, Deserialize)]
+#[serde(deny_unknown_fields)]
+struct RewriteRulesFile {
+    rules: Vec<RewriteRuleSpec>,
+}
+
+/// User-defined rewrite rules with firing counts shared across clones
+#[derive(Debug, Clone, Default)]
+pub struct RewriteRules {
+    rules: Arc<[RewriteRule]>,
+    fired: Arc<[AtomicUsize]>,
+}
+
+impl RewriteRules {
+    /// Create a rule set
+    pub fn new(rules: Vec<RewriteRule>) -> Self {
+        let fired = rules.iter().map(|_| AtomicUsize::new(0)).collect();
+        Self {
+            rules: rules.into(),
+            fired,
+        }
+    }
+
+    /// Load rules from a JSON file
+    ///
+    /// ```json
+    /// {"rules": [{"name": "satisfies", "languages": ["typescript"],
+    ///             "regex": "\\s+satisfies\\s+\\w+", "replace": ""}]}
+    /// ```
+    pub fn load<P: AsRef<Path>>(path: P) -> Result<Self> {
+        let path = path.as_ref();
+        let content = std::fs::read_to_string(path).map_err(|e| {
+            AnalyzerError::config_error(format!(
+                "Cannot read rewrite rules {}: {e}",
+                path.display()
+            ))
+        })?;
+        Self::from_json(&content)
+    }
+
+    /// Parse rules from their JSON representation
+    pub fn from_json(json: &str) -> Result<Self> {
+        let file: RewriteRulesFile = serde_json::from_str(json)
+            .map_err(|e| AnalyzerError::config_error(format!("Invalid rewrite rules: {e}")))?;
+
+        let rules = file
+            .rules
+            .into_iter()
+            .map(|spec| {
+                let rule = match (&spec.literal, &spec.regex) {
+                    (Some(literal), None) => {
+                        RewriteRule::literal(&spec.name, literal, &spec.replace)
+                    }
+                    (None, Some(regex)) => RewriteRule::regex(&spec.name, regex, &spec.replace),
+                    _ => Err(AnalyzerError::config_error(format!(
+                        "rewrite rule '{}': set exactly one of 'literal' or 'regex'",
+                        spec.name
+                    ))),
+                }?;
+                let languages = spec
+                    .languages
+                    .iter()
+                    .map(|l| l.parse::<SupportedLanguage>())
+                    .collect::<Result<Vec<_>>>()
+                    .map_err(|e| {
+                        AnalyzerError::config_error(format!("rewrite rule '{}': {e}", spec.name))
+                    })?;
+                Ok(rule.with_languages(languages))
+            })
+            .collect::<Result<Vec<_>>>()?;
+
+        Ok(Self::new(rules))
+    }
+
+    /// Check if there are no rules
+    pub fn is_empty(&self) -> bool {
+        self.rules.is_empty()
+    }
+
+    /// Apply every rule for `language` to `source`
+    pub fn apply<'a>(&self, source: Cow<'a, str>, language: SupportedLanguage) -> Cow<'a, str> {
+        let mut result = source;
+
+        for (rule, fired) in self.rules.iter().zip(self.fired.iter()) {
+            if !rule.applies_to(language) || !rule.may_match(&result) {
+                continue;
+            }
+
+            let mut out = String::with_capacity(result.len());
+            let mut count = 0;
+            for line in result.split_inclusive('\n') {
+                let content = line.trim_end_matches(['\r', '\n']);
+                count += rule.rewrite_line(content, &mut out);
+                out.push_str(&line[content.len()..]);
+            }
+
+            if count > 0 {
+                fired.fetch_add(count, Ordering::Relaxed);
+                result = Cow::Owned(out);
+            }
+        }
+
+        result
+    }
+
+    /// Number of replacements made by each rule so far, in rule order
+    pub fn fire_counts(&self) -> Vec<(&str, usize)> {
+        self.rules
+            .iter()
+            .zip(self.fired.iter())
+            .map(|(rule, fired)| (rule.name(), fired.load(Ordering::Relaxed)))
+            .collect()
+    }
+}
 
 /// Sanitize source code for tree-sitter parsing.
 ///
@@ -285,6 +526,61 @@ fn escape_ampersands_in_jsx_text(source: &str) -> String {
 mod tests {
     use super::*;
 
+    #[test]
+    fn test_rewrite_rules_from_json() {
+        let rules = RewriteRules::from_json(
+            r#"{"rules": [
+                {"name": "satisfies", "languages": ["typescript"],
+                 "regex": "\\s+satisfies\\s+\\w+", "replace": ""},
+                {"name": "at-decorator", "literal": "@@", "replace": "@"}
+            ]}"#,
+        )
+        .unwrap();
+
+        let input = "const a = {} satisfies Foo;\r\n@@dec\nclass X {}\n";
+        let output = rules.apply(Cow::Borrowed(input), SupportedLanguage::TypeScript);
+        assert_eq!(output, "const a = {};\r\n@dec\nclass X {}\n");
+        assert_eq!(output.lines().count(), input.lines().count());
+
+        // The regex rule is TypeScript-only
+        let output = rules.apply(Cow::Borrowed(input), SupportedLanguage::Python);
+        assert!(output.contains("satisfies Foo"));
+
+        assert_eq!(
+            rules.fire_counts(),
+            vec![("satisfies", 1), ("at-decorator", 2)]
+        );
+    }
+
+    #[test]
+    fn test_rewrite_rules_counts_are_shared_between_clones() {
+        let rules = RewriteRules::new(vec![RewriteRule::literal("x", "a", "b").unwrap()]);
+        let clone = rules.clone();
+
+        let output = clone.apply(Cow::Borrowed("a\naa\n"), SupportedLanguage::Rust);
+        assert_eq!(output, "b\nbb\n");
+        assert!(matches!(
+            rules.apply(Cow::Borrowed("c\n"), SupportedLanguage::Rust),
+            Cow::Borrowed(_)
+        ));
+        assert_eq!(rules.fire_counts(), vec![("x", 3)]);
+    }
+
+    #[test]
+    fn test_rewrite_rules_reject_invalid_rules() {
+        let cases = [
+            r#"{"rules": [{"name": "a", "literal": "x\ny", "replace": ""}]}"#,
+            r#"{"rules": [{"name": "a", "literal": "x", "replace": "\n"}]}"#,
+            r#"{"rules": [{"name": "a", "regex": "(", "replace": ""}]}"#,
+            r#"{"rules": [{"name": "a", "regex": "x*", "replace": ""}]}"#,
+            r#"{"rules": [{"name": "a", "literal": "x", "regex": "x", "replace": ""}]}"#,
+            r#"{"rules": [{"name": "a", "languages": ["cobol"], "literal": "x", "replace": ""}]}"#,
+        ];
+        for json in cases {
+            assert!(RewriteRules::from_json(json).is_err(), "{json}");
+        }
+    }
+
     #[test]
     fn test_sanitize_typescript_export_type_star() {
         let input = r#"export type * from "../documents/types";"#;
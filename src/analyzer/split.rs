@@ -0,0 +1,416 @@
+//! Split-file suggestions for files flagged as too large.
+//!
+//! The top-level symbols of a file (functions, and types together with their
+//! methods and impl blocks) are the units of a split. Two units are related
+//! by every function of one that calls or names the other or one of its
+//! members. Units are merged greedily, strongest average link first, as long
+//! as the merged group stays under the large-file and function-count
+//! thresholds; small groups left over join the group they are most related
+//! to. The cut size is the number of references crossing groups: the
+//! imports the split would add.
+
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::path::PathBuf;
+
+use super::parser::{
+    identify_refactoring_candidates, FileAnalysis, RefactoringReason, RefactoringThresholds,
+};
+use super::visitor::OutlineSymbol;
+
+/// Words that say little about what a group of symbols is about
+const STOP_WORDS: &[&str] = &[
+    "get", "set", "is", "has", "new", "to", "from", "as", "into", "with", "for", "of", "and",
+    "the", "a", "an", "do", "make", "create", "init", "impl", "test", "tests", "helper", "util",
+    "utils", "main", "self",
+];
+
+/// A group of symbols proposed as a module of its own
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SplitGroup {
+    /// Suggested module name, from the words most common in the symbol names
+    pub name: String,
+    /// Top-level symbols of the group in document order
+    pub symbols: Vec<String>,
+    pub lines: usize,
+    pub functions: usize,
+}
+
+/// A proposed partition of a file into cohesive groups
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SplitSuggestion {
+    pub path: PathBuf,
+    pub groups: Vec<SplitGroup>,
+    /// References between symbols of different groups
+    pub cut_size: usize,
+    /// References between symbols of the same group
+    pub internal_references: usize,
+}
+
+/// Split suggestions for the files flagged as large or as having too many functions
+pub fn suggest_splits(
+    files: &[FileAnalysis],
+    thresholds: &RefactoringThresholds,
+) -> Vec<SplitSuggestion> {
+    identify_refactoring_candidates(files, thresholds)
+        .iter()
+        .filter(|candidate| {
+            candidate.reasons.iter().any(|reason| {
+                matches!(
+                    reason,
+                    RefactoringReason::LargeFile(_) | RefactoringReason::TooManyFunctions(_)
+                )
+            })
+        })
+        .filter_map(|candidate| suggest_split(&candidate.file, thresholds))
+        .collect()
+}
+
+/// A top-level symbol, or a type merged with its impl blocks
+struct Unit {
+    name: String,
+    /// Line ranges of the symbols making up the unit
+    ranges: Vec<(usize, usize)>,
+    lines: usize,
+    functions: usize,
+    /// Names the unit answers to: its own and its members'
+    members: Vec<String>,
+    /// Names referenced by the unit's functions, with the number of functions
+    references: BTreeMap<String, usize>,
+}
+
+/// Partition of one file, if its symbols fall into more than one group
+pub fn suggest_split(
+    file: &FileAnalysis,
+    thresholds: &RefactoringThresholds,
+) -> Option<SplitSuggestion> {
+    let mut units = units(&file.outline);
+    if units.len() < 2 {
+        return None;
+    }
+
+    for scope in &file.scopes {
+        let Some(unit) = units.iter_mut().find(|unit| {
+            unit.ranges
+                .iter()
+                .any(|&(start, end)| start <= scope.start_line && scope.end_line <= end)
+        }) else {
+            continue;
+        };
+        if scope.name.is_some() {
+            unit.functions += 1;
+        }
+        let names: HashSet<&String> = scope.references.iter().chain(&scope.calls).collect();
+        for name in names {
+            *unit.references.entry(name.clone()).or_default() += 1;
+        }
+    }
+
+    // Names shared by several units do not tell which one is meant
+    let mut owners: HashMap<&str, Option<usize>> = HashMap::new();
+    for (index, unit) in units.iter().enumerate() {
+        for member in &unit.members {
+            owners
+                .entry(member.as_str())
+                .and_modify(|owner| {
+                    if *owner != Some(index) {
+                        *owner = None;
+                    }
+                })
+                .or_insert(Some(index));
+        }
+    }
+    let n = units.len();
+    let mut weights = vec![vec![0usize; n]; n];
+    for (from, unit) in units.iter().enumerate() {
+        for (name, count) in &unit.references {
+            if let Some(&Some(to)) = owners.get(name.as_str()) {
+                if to != from {
+                    weights[from][to] += count;
+                    weights[to][from] += count;
+                }
+            }
+        }
+    }
+
+    let groups = cluster(&units, &weights, thresholds);
+    if groups.len() < 2 {
+        return None;
+    }
+
+    let mut group_of = vec![0; n];
+    for (group, members) in groups.iter().enumerate() {
+        for &unit in members {
+            group_of[unit] = group;
+        }
+    }
+    let (mut cut_size, mut internal_references) = (0, 0);
+    for a in 0..n {
+        for b in a + 1..n {
+            if group_of[a] == group_of[b] {
+                internal_references += weights[a][b];
+            } else {
+                cut_size += weights[a][b];
+            }
+        }
+    }
+
+    let mut taken = HashSet::new();
+    let groups = groups
+        .iter()
+        .map(|members| {
+            let mut name = module_name(members.iter().map(|&u| &units[u]));
+            let base = name.clone();
+            let mut suffix = 2;
+            while !taken.insert(name.clone()) {
+                name = format!("{base}_{suffix}");
+                suffix += 1;
+            }
+            SplitGroup {
+                name,
+                symbols: members.iter().map(|&u| units[u].name.clone()).collect(),
+                lines: members.iter().map(|&u| units[u].lines).sum(),
+                functions: members.iter().map(|&u| units[u].functions).sum(),
+            }
+        })
+        .collect();
+
+    Some(SplitSuggestion {
+        path: file.path.clone(),
+        groups,
+        cut_size,
+        internal_references,
+    })
+}
+
+/// Units of an outline; modules and namespaces are split into their symbols
+fn units(outline: &[OutlineSymbol]) -> Vec<Unit> {
+    let mut symbols = Vec::new();
+    let mut pending: Vec<&OutlineSymbol> = outline.iter().rev().collect();
+    while let Some(symbol) = pending.pop() {
+        if matches!(symbol.kind.as_str(), "module" | "namespace") && !symbol.children.is_empty() {
+            pending.extend(symbol.children.iter().rev());
+        } else {
+            symbols.push(symbol);
+        }
+    }
+
+    let mut units: Vec<Unit> = Vec::new();
+    let mut by_name: HashMap<String, usize> = HashMap::new();
+    for symbol in symbols {
+        // `impl<T> Trait for Type<T>` belongs with `Type`
+        let name = match symbol.kind.as_str() {
+            "impl" => {
+                let implemented = symbol.name.rsplit(" for ").next().unwrap_or(&symbol.name);
+                implemented
+                    .split('<')
+                    .next()
+                    .unwrap_or(implemented)
+                    .to_string()
+            }
+            _ => symbol.name.clone(),
+        };
+        let index = *by_name.entry(name.clone()).or_insert_with(|| {
+            units.push(Unit {
+                name: name.clone(),
+                ranges: Vec::new(),
+                lines: 0,
+                functions: 0,
+                members: vec![name],
+                references: BTreeMap::new(),
+            });
+            units.len() - 1
+        });
+        let unit = &mut units[index];
+        unit.ranges.push((symbol.start_line, symbol.end_line));
+        unit.lines += symbol.lines;
+        unit.members
+            .extend(symbol.children.iter().map(|child| child.name.clone()));
+    }
+    units
+}
+
+/// Groups of unit indices, each sorted, ordered by their first unit
+fn cluster(
+    units: &[Unit],
+    weights: &[Vec<usize>],
+    thresholds: &RefactoringThresholds,
+) -> Vec<Vec<usize>> {
+    let mut groups: Vec<Vec<usize>> = (0..units.len()).map(|unit| vec![unit]).collect();
+    let size = |group: &[usize]| -> (usize, usize) {
+        group.iter().fold((0, 0), |(lines, functions), &u| {
+            (lines + units[u].lines, functions + units[u].functions)
+        })
+    };
+    let fits = |a: &[usize], b: &[usize]| {
+        let ((lines_a, functions_a), (lines_b, functions_b)) = (size(a), size(b));
+        lines_a + lines_b < thresholds.max_lines_of_code
+            && functions_a + functions_b < thresholds.max_functions
+    };
+    let link = |a: &[usize], b: &[usize]| -> usize {
+        a.iter()
+            .flat_map(|&x| b.iter().map(move |&y| weights[x][y]))
+            .sum()
+    };
+
+    // Strongest average link first
+    loop {
+        let mut best: Option<(f64, usize, usize)> = None;
+        for i in 0..groups.len() {
+            for j in i + 1..groups.len() {
+                let weight = link(&groups[i], &groups[j]);
+                if weight == 0 || !fits(&groups[i], &groups[j]) {
+                    continue;
+                }
+                let average = weight as f64 / (groups[i].len() * groups[j].len()) as f64;
+                if best.is_none_or(|(score, _, _)| average > score) {
+                    best = Some((average, i, j));
+                }
+            }
+        }
+        let Some((_, i, j)) = best else {
+            break;
+        };
+        let merged = groups.remove(j);
+        groups[i].extend(merged);
+    }
+
+    // Groups far below the size limit join the group they are most related to
+    let small = thresholds.max_lines_of_code / 4;
+    loop {
+        let Some(i) = (0..groups.len())
+            .filter(|&i| size(&groups[i]).0 < small)
+            .min_by_key(|&i| size(&groups[i]).0)
+        else {
+            break;
+        };
+        let target = (0..groups.len())
+            .filter(|&j| j != i && fits(&groups[i], &groups[j]))
+            .max_by_key(|&j| {
+                (
+                    link(&groups[i], &groups[j]),
+                    std::cmp::Reverse(size(&groups[j]).0),
+                )
+            });
+        let Some(j) = target else {
+            break;
+        };
+        let merged = groups.remove(i);
+        let j = if j > i { j - 1 } else { j };
+        groups[j].extend(merged);
+    }
+
+    for group in &mut groups {
+        group.sort_unstable();
+    }
+    groups.sort();
+    groups
+}
+
+/// Most common meaningful word in the names of a group's symbols and members
+fn module_name<'a>(units: impl Iterator<Item = &'a Unit>) -> String {
+    let mut counts: BTreeMap<String, usize> = BTreeMap::new();
+    let mut fallback = None;
+    for unit in units {
+        fallback.get_or_insert_with(|| words(&unit.name).join("_"));
+        for name in &unit.members {
+            for word in words(name) {
+                if word.len() > 2 && !STOP_WORDS.contains(&word.as_str()) {
+                    *counts.entry(word).or_default() += 1;
+                }
+            }
+        }
+    }
+    counts
+        .into_iter()
+        .max_by(|(a, x), (b, y)| x.cmp(y).then_with(|| b.cmp(a)))
+        .map(|(word, _)| word)
+        .or(fallback)
+        .unwrap_or_default()
+}
+
+/// Lowercase words of a snake_case, camelCase or PascalCase name
+fn words(name: &str) -> Vec<String> {
+    let mut words = Vec::new();
+    let mut current = String::new();
+    let mut previous_lower = false;
+    for c in name.chars() {
+        if !c.is_alphanumeric() {
+            if !current.is_empty() {
+                words.push(std::mem::take(&mut current));
+            }
+            previous_lower = false;
+            continue;
+        }
+        if c.is_uppercase() && previous_lower && !current.is_empty() {
+            words.push(std::mem::take(&mut current));
+        }
+        previous_lower = c.is_lowercase() || c.is_numeric();
+        current.extend(c.to_lowercase());
+    }
+    if !current.is_empty() {
+        words.push(current);
+    }
+    words
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::analyzer::parser::fixtures::{file, scope, symbol};
+
+    #[test]
+    fn test_words() {
+        assert_eq!(words("parse_header"), vec!["parse", "header"]);
+        assert_eq!(words("renderHtmlPage"), vec!["render", "html", "page"]);
+        assert_eq!(words("HTTPClient"), vec!["httpclient"]);
+    }
+
+    #[test]
+    fn test_split_by_calls() {
+        let functions = [
+            ("parse_header", (1, 40), vec!["parse_field"]),
+            ("parse_field", (41, 80), vec![]),
+            ("parse_body", (81, 120), vec!["parse_field"]),
+            ("render_page", (121, 160), vec!["render_row"]),
+            ("render_row", (161, 200), vec!["escape_html"]),
+            ("escape_html", (201, 240), vec![]),
+        ];
+        let file = FileAnalysis {
+            lines_of_code: 240,
+            ..file("src/big.rs")
+        }
+        .with_scopes(
+            functions
+                .iter()
+                .map(|(name, lines, calls)| scope(name, *lines, calls))
+                .collect(),
+        )
+        .with_outline(
+            functions
+                .iter()
+                .map(|(name, lines, _)| symbol("function", name, *lines))
+                .collect(),
+        );
+        let thresholds = RefactoringThresholds {
+            max_lines_of_code: 200,
+            ..Default::default()
+        };
+
+        let suggestion = suggest_split(&file, &thresholds).unwrap();
+        assert_eq!(suggestion.groups.len(), 2);
+        assert_eq!(suggestion.cut_size, 0);
+        assert_eq!(suggestion.internal_references, 4);
+
+        let parse = &suggestion.groups[0];
+        assert_eq!(parse.name, "parse");
+        assert_eq!(
+            parse.symbols,
+            vec!["parse_header", "parse_field", "parse_body"]
+        );
+        assert_eq!((parse.lines, parse.functions), (120, 3));
+        assert_eq!(suggestion.groups[1].name, "render");
+
+        assert_eq!(suggest_splits(&[file], &thresholds).len(), 1);
+    }
+}
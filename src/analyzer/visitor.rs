@@ -0,0 +1,891 @@
+//! Single-pass AST traversal.
+//!
+//! `TreeVisitor` walks a syntax tree once with a `TreeCursor`, computing every
+//! built-in AST metric, dispatching each node to the active `MetricCollector`s
+//! and recording a `FunctionScope` for every function and method on the way
+//! (and for closures and lambdas, when they are counted separately),
+//! along with the names it calls (for the call graph), the public items
+//! the file declares (for the API manifest) and the hierarchy of types and
+//! functions (for the symbol outline).
+
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeMap, HashSet};
+use tree_sitter::Node;
+
+use super::api::{self, ApiItem, Visibility};
+use super::counting::{self, Ruleset};
+use super::extract::ExtractSuggestion;
+use super::language::{NodeKindMapper, SupportedLanguage};
+use super::metrics::{MetricCollector, MetricValue};
+use crate::cli::{AnonymousFunctionMode, ComplexityProfile};
+
+/// Metrics of a single function or method
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct FunctionScope {
+    /// Function name (None for anonymous functions)
+    pub name: Option<String>,
+    /// AST node kind of the function
+    pub kind: String,
+    /// First line of the function (1-based)
+    pub start_line: usize,
+    /// Last line of the function (1-based)
+    pub end_line: usize,
+    /// Cyclomatic complexity of the function body, excluding nested functions
+    pub cyclomatic_complexity: usize,
+    /// Maximum nesting depth relative to the function
+    pub max_nesting_depth: usize,
+    /// Names of the functions and methods called directly from the body, sorted
+    /// (only exported by `--call-graph`)
+    #[serde(skip)]
+    pub calls: Vec<String>,
+    /// Called names that only ever have a receiver or path qualifier
+    /// (`x.f()`, `T::f()`), sorted
+    #[serde(skip)]
+    pub qualified_calls: Vec<String>,
+    /// Identifiers named in the body, sorted (only recorded for split suggestions)
+    #[serde(skip)]
+    pub references: Vec<String>,
+    /// Blocks worth extracting (only for long or complex functions, on request)
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub extractions: Vec<ExtractSuggestion>,
+}
+
+impl FunctionScope {
+    /// Number of lines spanned by the function
+    pub fn line_count(&self) -> usize {
+        self.end_line + 1 - self.start_line
+    }
+}
+
+/// An import statement of a file
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Import {
+    /// Line of the statement (1-based)
+    pub line: usize,
+    /// The statement, whitespace collapsed
+    pub statement: String,
+}
+
+/// A type, container or function in the symbol outline of a file
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct OutlineSymbol {
+    /// Normalized kind (class, struct, impl, interface, function, method, ...)
+    pub kind: String,
+    pub name: String,
+    /// First line of the symbol (1-based)
+    pub start_line: usize,
+    /// Last line of the symbol (1-based)
+    pub end_line: usize,
+    /// Declared visibility (None for impl blocks and namespaces)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub visibility: Option<Visibility>,
+    /// Interface, trait or abstract class
+    #[serde(
+        rename = "abstract",
+        default,
+        skip_serializing_if = "std::ops::Not::not"
+    )]
+    pub is_abstract: bool,
+    pub lines: usize,
+    /// Cyclomatic complexity of a function; the sum over the functions of a container
+    pub cyclomatic_complexity: usize,
+    /// Maximum nesting depth within the symbol, relative to its functions
+    pub max_nesting_depth: usize,
+    /// Nested symbols in document order
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub children: Vec<OutlineSymbol>,
+}
+
+impl OutlineSymbol {
+    /// Check if the symbol declares a type (class, struct, interface, trait, ...)
+    pub fn is_type(&self) -> bool {
+        !matches!(
+            self.kind.as_str(),
+            "function" | "method" | "constructor" | "impl" | "module" | "namespace"
+        )
+    }
+}
+
+/// Results of a single traversal
+#[derive(Debug, Clone, Default)]
+pub struct TreeMetrics {
+    pub functions: usize,
+    pub methods: usize,
+    /// Closures, lambdas and other anonymous functions
+    pub anonymous_functions: usize,
+    pub classes: usize,
+    /// Decision points (if, for, while, case, catch, ...)
+    pub decision_points: usize,
+    /// Logical operators in binary expressions (&&, ||)
+    pub logical_operators: usize,
+    pub max_nesting_depth: usize,
+    /// Number of distinct lines containing comments
+    pub comment_lines: usize,
+    /// Function-level scopes in document order
+    pub scopes: Vec<FunctionScope>,
+    /// Import statements in document order
+    pub imports: Vec<Import>,
+    /// Public API items in document order
+    pub api: Vec<ApiItem>,
+    /// Top-level symbols of the outline in document order
+    pub outline: Vec<OutlineSymbol>,
+    /// Values produced by metric collectors
+    pub metrics: BTreeMap<String, MetricValue>,
+    /// True if nodes below the maximum traversal depth were not visited
+    pub depth_limited: bool,
+}
+
+impl TreeMetrics {
+    /// File-level cyclomatic complexity (McCabe: 1 + decision points + logical operators)
+    pub fn cyclomatic_complexity(&self) -> usize {
+        1 + self.decision_points + self.logical_operators
+    }
+}
+
+/// Scope of a function currently being traversed
+struct OpenScope {
+    scope: FunctionScope,
+    /// Called names without a receiver or path qualifier
+    plain_calls: Vec<String>,
+    /// Nesting depth at the function node
+    base_depth: usize,
+}
+
+/// What a node contributed on enter, so it can be undone on leave
+#[derive(Clone, Copy)]
+struct Frame {
+    nesting: bool,
+    function: bool,
+    symbol: bool,
+}
+
+/// Single-pass visitor computing built-in metrics and feeding collectors
+pub struct TreeVisitor<'a> {
+    language: SupportedLanguage,
+    source: &'a [u8],
+    collectors: Vec<(&'a str, Box<dyn MetricCollector>)>,
+    max_depth: Option<usize>,
+    error_free_only: bool,
+    anonymous_functions: AnonymousFunctionMode,
+    references: bool,
+    imports: bool,
+    api: bool,
+    outline: bool,
+    /// Decision point rules of a reference tool (None = the language's control flow nodes)
+    ruleset: Option<&'static Ruleset>,
+    metrics: TreeMetrics,
+    comment_rows: HashSet<usize>,
+    depth: usize,
+    frames: Vec<Frame>,
+    open_scopes: Vec<OpenScope>,
+    open_symbols: Vec<OutlineSymbol>,
+}
+
+impl<'a> TreeVisitor<'a> {
+    /// Create a visitor for a file in `language`
+    pub fn new(language: SupportedLanguage, source: &'a [u8]) -> Self {
+        Self {
+            language,
+            source,
+            collectors: Vec::new(),
+            max_depth: None,
+            error_free_only: false,
+            anonymous_functions: AnonymousFunctionMode::Inline,
+            references: false,
+            imports: false,
+            api: false,
+            outline: false,
+            ruleset: None,
+            metrics: TreeMetrics::default(),
+            comment_rows: HashSet::new(),
+            depth: 0,
+            frames: Vec::new(),
+            open_scopes: Vec::new(),
+            open_symbols: Vec::new(),
+        }
+    }
+
+    /// Dispatch every visited node to these named collectors
+    pub fn with_collectors(mut self, collectors: Vec<(&'a str, Box<dyn MetricCollector>)>) -> Self {
+        self.collectors = collectors;
+        self
+    }
+
+    /// Do not descend more than `max_depth` levels below the root (None = unlimited)
+    pub fn with_max_depth(mut self, max_depth: Option<usize>) -> Self {
+        self.max_depth = max_depth;
+        self
+    }
+
+    /// Skip ERROR/MISSING nodes and functions containing parse errors
+    pub fn with_error_free_only(mut self, error_free_only: bool) -> Self {
+        self.error_free_only = error_free_only;
+        self
+    }
+
+    /// Attribute anonymous functions to their enclosing function or give them scopes of their own
+    pub fn with_anonymous_functions(mut self, mode: AnonymousFunctionMode) -> Self {
+        self.anonymous_functions = mode;
+        self
+    }
+
+    /// Record the identifiers named in each function scope
+    pub fn with_references(mut self, references: bool) -> Self {
+        self.references = references;
+        self
+    }
+
+    /// Record the file's import statements
+    pub fn with_imports(mut self, imports: bool) -> Self {
+        self.imports = imports;
+        self
+    }
+
+    /// Record the file's public items and their signatures
+    pub fn with_api(mut self, api: bool) -> Self {
+        self.api = api;
+        self
+    }
+
+    /// Build the file's symbol outline
+    pub fn with_outline(mut self, outline: bool) -> Self {
+        self.outline = outline;
+        self
+    }
+
+    /// Count decision points the way the reference tool of `profile` does
+    pub fn with_complexity_profile(mut self, profile: ComplexityProfile) -> Self {
+        self.ruleset = counting::ruleset(profile, self.language);
+        self
+    }
+
+    /// Traverse the tree rooted at `root` and return the collected metrics
+    pub fn run(mut self, root: &Node) -> TreeMetrics {
+        let mut cursor = root.walk();
+        let mut level = 0;
+
+        'walk: loop {
+            let node = cursor.node();
+
+            // Broken subtrees are skipped entirely: neither entered nor left
+            if !(self.error_free_only && self.is_broken(&node)) {
+                self.enter(&node);
+
+                let may_descend = self.max_depth.is_none_or(|max| level < max);
+                if may_descend && cursor.goto_first_child() {
+                    level += 1;
+                    continue;
+                }
+                if !may_descend && node.child_count() > 0 {
+                    self.metrics.depth_limited = true;
+                }
+                self.leave();
+            }
+
+            loop {
+                if cursor.goto_next_sibling() {
+                    break;
+                }
+                if !cursor.goto_parent() {
+                    break 'walk;
+                }
+                level -= 1;
+                self.leave();
+            }
+        }
+
+        self.finish()
+    }
+
+    fn enter(&mut self, node: &Node) {
+        let kind = node.kind();
+        let language = self.language;
+
+        for (_, collector) in self.collectors.iter_mut() {
+            collector.visit(node, self.source);
+        }
+
+        if language.is_comment_node(kind) {
+            self.comment_rows
+                .extend(node.start_position().row..=node.end_position().row);
+        }
+        if language.is_class_node(kind) {
+            self.metrics.classes += 1;
+        }
+        if self.imports && language.is_import_node(kind) {
+            self.record_imports(node);
+        }
+        if self.api && language.is_api_node(kind) {
+            self.metrics
+                .api
+                .extend(api::public_items(language, node, self.source));
+        }
+        if language.is_call_node(kind) && !self.open_scopes.is_empty() {
+            if let Some((callee, qualified)) = self.callee(node) {
+                if let Some(open) = self.open_scopes.last_mut() {
+                    if qualified {
+                        open.scope.qualified_calls.push(callee.clone());
+                    } else {
+                        open.plain_calls.push(callee.clone());
+                    }
+                    open.scope.calls.push(callee);
+                }
+            }
+        }
+        if self.references && node.child_count() == 0 && kind.ends_with("identifier") {
+            if let (Some(open), Ok(name)) =
+                (self.open_scopes.last_mut(), node.utf8_text(self.source))
+            {
+                open.scope.references.push(name.to_string());
+            }
+        }
+
+        // Function expressions bound to a name are named functions
+        let binding = if language.is_anonymous_function_node(kind) {
+            self.binding_name(node)
+        } else {
+            None
+        };
+        let is_function = language.is_function_node(kind) || binding.is_some();
+        let is_method = language.is_method_node(kind);
+        if is_function {
+            self.metrics.functions += 1;
+        }
+        if is_method {
+            self.metrics.methods += 1;
+        }
+        let is_anonymous = language.is_anonymous_function_node(kind) && binding.is_none();
+        if is_anonymous {
+            self.metrics.anonymous_functions += 1;
+        }
+
+        let (decision, logical) = match self.ruleset {
+            Some(ruleset) => ruleset.count(node),
+            None => (
+                usize::from(language.is_control_flow_node(kind)),
+                usize::from(self.is_logical_operator(node)),
+            ),
+        };
+        self.metrics.decision_points += decision;
+        self.metrics.logical_operators += logical;
+        let decisions = decision + logical;
+        if decisions > 0 {
+            if let Some(open) = self.open_scopes.last_mut() {
+                open.scope.cyclomatic_complexity += decisions;
+            }
+        }
+
+        let nesting = language.nesting_node_kinds().contains(&kind);
+        if nesting {
+            self.depth += 1;
+            self.metrics.max_nesting_depth = self.metrics.max_nesting_depth.max(self.depth);
+            if let Some(open) = self.open_scopes.last_mut() {
+                let relative = self.depth - open.base_depth;
+                open.scope.max_nesting_depth = open.scope.max_nesting_depth.max(relative);
+            }
+        }
+
+        let function = is_function || is_method;
+        let scoped = function
+            || (is_anonymous && self.anonymous_functions == AnonymousFunctionMode::Separate);
+        if scoped {
+            self.open_scopes.push(OpenScope {
+                scope: FunctionScope {
+                    name: binding.clone().or_else(|| {
+                        node.child_by_field_name("name")
+                            .and_then(|n| n.utf8_text(self.source).ok())
+                            .map(str::to_string)
+                    }),
+                    kind: kind.to_string(),
+                    start_line: node.start_position().row + 1,
+                    end_line: node.end_position().row + 1,
+                    cyclomatic_complexity: 1,
+                    max_nesting_depth: 0,
+                    calls: Vec::new(),
+                    qualified_calls: Vec::new(),
+                    references: Vec::new(),
+                    extractions: Vec::new(),
+                },
+                plain_calls: Vec::new(),
+                base_depth: self.depth,
+            });
+        }
+
+        let symbol = if self.outline {
+            self.outline_symbol(node, function, binding)
+        } else {
+            None
+        };
+        let is_symbol = symbol.is_some();
+        self.open_symbols.extend(symbol);
+
+        self.frames.push(Frame {
+            nesting,
+            function: scoped,
+            symbol: is_symbol,
+        });
+    }
+
+    fn leave(&mut self) {
+        let Some(frame) = self.frames.pop() else {
+            return;
+        };
+
+        let mut closed = None;
+        if frame.function {
+            if let Some(mut open) = self.open_scopes.pop() {
+                open.scope.calls.sort();
+                open.scope.calls.dedup();
+                let plain_calls = open.plain_calls;
+                open.scope
+                    .qualified_calls
+                    .retain(|name| !plain_calls.contains(name));
+                open.scope.qualified_calls.sort();
+                open.scope.qualified_calls.dedup();
+                open.scope.references.sort();
+                open.scope.references.dedup();
+                closed = Some((
+                    open.scope.cyclomatic_complexity,
+                    open.scope.max_nesting_depth,
+                ));
+                self.metrics.scopes.push(open.scope);
+            }
+        }
+        if frame.symbol {
+            if let Some(mut symbol) = self.open_symbols.pop() {
+                // Functions report their own scope, containers aggregate their members
+                let (complexity, nesting) = closed.unwrap_or_else(|| {
+                    symbol.children.iter().fold((0, 0), |(cc, depth), child| {
+                        (
+                            cc + child.cyclomatic_complexity,
+                            depth.max(child.max_nesting_depth),
+                        )
+                    })
+                });
+                symbol.cyclomatic_complexity = complexity;
+                symbol.max_nesting_depth = nesting;
+                match self.open_symbols.last_mut() {
+                    Some(parent) => parent.children.push(symbol),
+                    None => self.metrics.outline.push(symbol),
+                }
+            }
+        }
+        if frame.nesting {
+            self.depth -= 1;
+        }
+    }
+
+    /// Record an import node; grouped Go imports (`import ( ... )`) count as
+    /// one statement per imported package
+    fn record_imports(&mut self, node: &Node) {
+        let mut cursor = node.walk();
+        let group = node
+            .named_children(&mut cursor)
+            .find(|child| child.kind() == "import_spec_list");
+
+        let statement = |node: &Node, prefix: &str| {
+            let text = node.utf8_text(self.source).ok()?;
+            let words: Vec<&str> = prefix
+                .split_whitespace()
+                .chain(text.split_whitespace())
+                .collect();
+            Some(Import {
+                line: node.start_position().row + 1,
+                statement: words.join(" "),
+            })
+        };
+        let imports: Vec<Import> = match group {
+            Some(group) => {
+                let mut cursor = group.walk();
+                let specs = group
+                    .named_children(&mut cursor)
+                    .filter(|spec| spec.kind() == "import_spec")
+                    .filter_map(|spec| statement(&spec, "import"))
+                    .collect();
+                specs
+            }
+            None => statement(node, "").into_iter().collect(),
+        };
+        self.metrics.imports.extend(imports);
+    }
+
+    /// Outline entry opened by `node`, if it is a named function or a type
+    /// definition (declarations without a body, like `struct point p;`, are not)
+    fn outline_symbol(
+        &self,
+        node: &Node,
+        function: bool,
+        binding: Option<String>,
+    ) -> Option<OutlineSymbol> {
+        let kind = node.kind();
+        if !function && !self.language.is_outline_node(kind) {
+            return None;
+        }
+        if !function && kind != "type_spec" && node.child_by_field_name("body").is_none() {
+            return None;
+        }
+
+        let name = match kind {
+            "impl_item" => {
+                let implemented = node.child_by_field_name("type")?;
+                let implemented = implemented.utf8_text(self.source).ok()?;
+                match node.child_by_field_name("trait") {
+                    Some(t) => format!("{} for {implemented}", t.utf8_text(self.source).ok()?),
+                    None => implemented.to_string(),
+                }
+            }
+            _ => match binding {
+                Some(name) => name,
+                None => api::declared_name(node, self.source)?,
+            },
+        };
+
+        let start_line = node.start_position().row + 1;
+        let end_line = node.end_position().row + 1;
+        Some(OutlineSymbol {
+            kind: api::symbol_kind(node).to_string(),
+            name,
+            start_line,
+            end_line,
+            visibility: api::visibility(self.language, node, self.source),
+            is_abstract: !function && api::is_abstract_type(self.language, node, self.source),
+            lines: end_line + 1 - start_line,
+            cyclomatic_complexity: 0,
+            max_nesting_depth: 0,
+            children: Vec::new(),
+        })
+    }
+
+    /// Check if `node` is a parse error or a function containing one
+    fn is_broken(&self, node: &Node) -> bool {
+        if node.is_error() || node.is_missing() {
+            return true;
+        }
+        let kind = node.kind();
+        node.has_error()
+            && (self.language.is_function_node(kind)
+                || self.language.is_method_node(kind)
+                || self.language.is_anonymous_function_node(kind))
+    }
+
+    /// Name a function expression is bound to: `const f = () => {}`,
+    /// `{ f: function () {} }`, `this.f = () => {}` or a class field `f = () => {}`
+    fn binding_name(&self, node: &Node) -> Option<String> {
+        let parent = node.parent()?;
+        let field = self.language.function_binding_field(parent.kind())?;
+        // The function must be the bound value, not part of the target
+        if parent.child_by_field_name(field)?.id() == node.id() {
+            return None;
+        }
+        let mut target = parent.child_by_field_name(field)?;
+        if let Some(property) = target.child_by_field_name("property") {
+            target = property;
+        }
+        if !target.kind().contains("identifier") {
+            return None;
+        }
+        target.utf8_text(self.source).ok().map(str::to_string)
+    }
+
+    /// Name of the function called by a call node (the last segment of paths,
+    /// member accesses and method calls: `a::b::f()`, `x.f()` and `f()` all call `f`),
+    /// and whether the call has a receiver or path qualifier
+    fn callee(&self, call: &Node) -> Option<(String, bool)> {
+        const QUALIFIERS: [&str; 6] = ["path", "scope", "object", "value", "argument", "operand"];
+        let has_qualifier = |node: &Node| {
+            QUALIFIERS
+                .iter()
+                .any(|f| node.child_by_field_name(f).is_some())
+        };
+
+        let mut target = call.child_by_field_name(self.language.call_target_field())?;
+        // Java method invocations carry their receiver on the call itself
+        let mut qualified = has_qualifier(call);
+        while let Some(next) = ["name", "field", "property", "attribute", "function"]
+            .iter()
+            .find_map(|field| target.child_by_field_name(field))
+        {
+            qualified |= has_qualifier(&target);
+            target = next;
+        }
+
+        if target.named_child_count() > 0 || !target.kind().contains("identifier") {
+            return None;
+        }
+        let name = target.utf8_text(self.source).ok()?.to_string();
+        Some((name, qualified))
+    }
+
+    /// Check if `node` is a binary expression using a logical operator
+    fn is_logical_operator(&self, node: &Node) -> bool {
+        // Python handles this via 'boolean_operator' in its control flow kinds
+        let Some(binary_kind) = self.language.binary_expression_node_kind() else {
+            return false;
+        };
+        if node.kind() != binary_kind {
+            return false;
+        }
+
+        let logical_ops = self.language.logical_operators();
+        // The operator is an anonymous child node
+        (0..node.child_count())
+            .filter_map(|i| node.child(i as u32))
+            .filter(|child| !child.is_named())
+            .filter_map(|child| child.utf8_text(self.source).ok())
+            .any(|op| logical_ops.contains(&op))
+    }
+
+    fn finish(mut self) -> TreeMetrics {
+        self.metrics.comment_lines = self.comment_rows.len();
+
+        // Scopes close innermost-first; report them in document order
+        self.metrics
+            .scopes
+            .sort_by_key(|s| (s.start_line, std::cmp::Reverse(s.end_line)));
+
+        self.metrics.metrics = self
+            .collectors
+            .into_iter()
+            .filter_map(|(name, collector)| collector.finish().map(|v| (name.to_string(), v)))
+            .collect();
+
+        self.metrics
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn visit_rust(source: &[u8]) -> TreeMetrics {
+        let mut parser = tree_sitter::Parser::new();
+        parser
+            .set_language(&tree_sitter_rust::LANGUAGE.into())
+            .unwrap();
+        let tree = parser.parse(source, None).unwrap();
+        TreeVisitor::new(SupportedLanguage::Rust, source)
+            .with_imports(true)
+            .with_outline(true)
+            .run(&tree.root_node())
+    }
+
+    #[test]
+    fn test_single_pass_counts() {
+        let source = b"// header\nstruct Foo;\nfn a() { if x && y { while z {} } }\nfn b() {}\n";
+        let metrics = visit_rust(source);
+
+        assert_eq!(metrics.functions, 2);
+        assert_eq!(metrics.classes, 1);
+        assert_eq!(metrics.comment_lines, 1);
+        assert_eq!(metrics.decision_points, 2);
+        assert_eq!(metrics.logical_operators, 1);
+        assert_eq!(metrics.cyclomatic_complexity(), 4);
+        assert!(metrics.max_nesting_depth >= 2);
+    }
+
+    #[test]
+    fn test_function_scopes() {
+        let source = b"fn a() {\n    if x { }\n}\n\nfn b() {}\n";
+        let metrics = visit_rust(source);
+
+        assert_eq!(metrics.scopes.len(), 2);
+        let a = &metrics.scopes[0];
+        assert_eq!(a.name.as_deref(), Some("a"));
+        assert_eq!((a.start_line, a.end_line), (1, 3));
+        assert_eq!(a.line_count(), 3);
+        assert_eq!(a.cyclomatic_complexity, 2);
+
+        let b = &metrics.scopes[1];
+        assert_eq!(b.name.as_deref(), Some("b"));
+        assert_eq!(b.cyclomatic_complexity, 1);
+    }
+
+    #[test]
+    fn test_calls_and_imports() {
+        let source = b"use crate::util::helper;\n\nfn a() {\n    b();\n    helper::run(1);\n    x.method();\n    b();\n}\n\nfn b() {}\n";
+        let metrics = visit_rust(source);
+
+        assert_eq!(
+            metrics.imports,
+            vec![Import {
+                line: 1,
+                statement: "use crate::util::helper;".to_string()
+            }]
+        );
+        assert_eq!(metrics.scopes[0].calls, vec!["b", "method", "run"]);
+        assert_eq!(metrics.scopes[0].qualified_calls, vec!["method", "run"]);
+        assert!(metrics.scopes[1].calls.is_empty());
+    }
+
+    #[test]
+    fn test_references() {
+        let source = b"fn a(s: Stack) -> u8 {\n    let n = Stack::new();\n    s.top + n.len\n}\n";
+        let mut parser = tree_sitter::Parser::new();
+        parser
+            .set_language(&tree_sitter_rust::LANGUAGE.into())
+            .unwrap();
+        let tree = parser.parse(&source[..], None).unwrap();
+
+        assert!(visit_rust(source).scopes[0].references.is_empty());
+        let metrics = TreeVisitor::new(SupportedLanguage::Rust, source)
+            .with_references(true)
+            .run(&tree.root_node());
+        assert_eq!(
+            metrics.scopes[0].references,
+            vec!["Stack", "a", "len", "n", "new", "s", "top"]
+        );
+    }
+
+    #[test]
+    fn test_nested_function_complexity_not_double_counted() {
+        let source = b"fn outer() {\n    fn inner() { if a { } }\n    if b { }\n}\n";
+        let metrics = visit_rust(source);
+
+        assert_eq!(metrics.scopes.len(), 2);
+        assert_eq!(metrics.scopes[0].name.as_deref(), Some("outer"));
+        assert_eq!(metrics.scopes[0].cyclomatic_complexity, 2);
+        assert_eq!(metrics.scopes[1].name.as_deref(), Some("inner"));
+        assert_eq!(metrics.scopes[1].cyclomatic_complexity, 2);
+        // File-level complexity still counts every decision point once
+        assert_eq!(metrics.cyclomatic_complexity(), 3);
+    }
+
+    #[test]
+    fn test_anonymous_functions() {
+        let source =
+            b"fn outer(v: Vec<u8>) {\n    let f = |x| if x { 1 } else { 2 };\n    v.iter().map(|y| y + 1);\n}\n";
+        let mut parser = tree_sitter::Parser::new();
+        parser
+            .set_language(&tree_sitter_rust::LANGUAGE.into())
+            .unwrap();
+        let tree = parser.parse(&source[..], None).unwrap();
+
+        let inline = TreeVisitor::new(SupportedLanguage::Rust, source).run(&tree.root_node());
+        assert_eq!(inline.functions, 1);
+        assert_eq!(inline.anonymous_functions, 2);
+        assert_eq!(inline.scopes.len(), 1);
+        assert_eq!(inline.scopes[0].cyclomatic_complexity, 2);
+
+        let separate = TreeVisitor::new(SupportedLanguage::Rust, source)
+            .with_anonymous_functions(AnonymousFunctionMode::Separate)
+            .with_outline(true)
+            .run(&tree.root_node());
+        assert_eq!(separate.functions, 1);
+        assert_eq!(separate.anonymous_functions, 2);
+        assert_eq!(separate.scopes.len(), 3);
+        assert_eq!(separate.scopes[0].cyclomatic_complexity, 1);
+        assert_eq!(separate.scopes[1].name, None);
+        assert_eq!(separate.scopes[1].kind, "closure_expression");
+        assert_eq!(separate.scopes[1].cyclomatic_complexity, 2);
+        // File-level complexity does not depend on the attribution
+        assert_eq!(
+            separate.cyclomatic_complexity(),
+            inline.cyclomatic_complexity()
+        );
+        // Closures are not outline symbols
+        assert_eq!(separate.outline.len(), 1);
+        assert!(separate.outline[0].children.is_empty());
+    }
+
+    #[test]
+    fn test_bound_function_expressions_are_named() {
+        let source = b"const f = () => {\n    items.map((x) => x + 1);\n};\nconst api = { load: function () {} };\n";
+        let mut parser = tree_sitter::Parser::new();
+        parser
+            .set_language(&tree_sitter_javascript::LANGUAGE.into())
+            .unwrap();
+        let tree = parser.parse(&source[..], None).unwrap();
+
+        let metrics = TreeVisitor::new(SupportedLanguage::JavaScript, source)
+            .with_outline(true)
+            .run(&tree.root_node());
+        assert_eq!(metrics.functions, 2);
+        assert_eq!(metrics.anonymous_functions, 1);
+        let names: Vec<_> = metrics
+            .scopes
+            .iter()
+            .map(|scope| scope.name.as_deref())
+            .collect();
+        assert_eq!(names, vec![Some("f"), Some("load")]);
+        assert_eq!(metrics.outline[0].name, "f");
+        assert_eq!(metrics.outline[0].kind, "function");
+    }
+
+    #[test]
+    fn test_outline_hierarchy() {
+        let source = b"pub struct Stack {\n    items: Vec<u8>,\n}\n\nimpl Stack {\n    pub fn push(&mut self) {\n        if a { while b { } }\n    }\n    fn pop(&mut self) {}\n}\n\nfn helper() {}\n";
+        let metrics = visit_rust(source);
+
+        let names: Vec<_> = metrics.outline.iter().map(|s| s.name.as_str()).collect();
+        assert_eq!(names, vec!["Stack", "Stack", "helper"]);
+
+        let stack = &metrics.outline[0];
+        assert_eq!(stack.kind, "struct");
+        assert_eq!(stack.visibility, Some(Visibility::Public));
+        assert!(stack.children.is_empty());
+
+        let implementation = &metrics.outline[1];
+        assert_eq!(implementation.kind, "impl");
+        assert_eq!(implementation.visibility, None);
+        assert_eq!(
+            (implementation.start_line, implementation.end_line),
+            (5, 10)
+        );
+        assert_eq!(implementation.children.len(), 2);
+        assert_eq!(implementation.cyclomatic_complexity, 4);
+        assert_eq!(implementation.max_nesting_depth, 2);
+
+        let push = &implementation.children[0];
+        assert_eq!((push.kind.as_str(), push.name.as_str()), ("method", "push"));
+        assert_eq!(push.visibility, Some(Visibility::Public));
+        assert_eq!(push.lines, 3);
+        assert_eq!(push.cyclomatic_complexity, 3);
+        assert_eq!(
+            implementation.children[1].visibility,
+            Some(Visibility::Private)
+        );
+
+        assert_eq!(metrics.outline[2].kind, "function");
+        assert_eq!(metrics.outline[2].visibility, Some(Visibility::Private));
+    }
+
+    #[test]
+    fn test_error_free_only_skips_broken_functions() {
+        let source = b"fn good() { if a { } }\nfn bad() { if b { let = ; } }\n";
+        let mut parser = tree_sitter::Parser::new();
+        parser
+            .set_language(&tree_sitter_rust::LANGUAGE.into())
+            .unwrap();
+        let tree = parser.parse(&source[..], None).unwrap();
+        assert!(tree.root_node().has_error());
+
+        let all = TreeVisitor::new(SupportedLanguage::Rust, source).run(&tree.root_node());
+        let clean = TreeVisitor::new(SupportedLanguage::Rust, source)
+            .with_error_free_only(true)
+            .run(&tree.root_node());
+
+        assert_eq!(clean.functions, 1);
+        assert_eq!(clean.scopes[0].name.as_deref(), Some("good"));
+        assert!(clean.decision_points < all.decision_points);
+    }
+
+    #[test]
+    fn test_max_depth_truncates_traversal() {
+        let source = b"fn a() { if x { if y { if z { } } } }";
+        let mut parser = tree_sitter::Parser::new();
+        parser
+            .set_language(&tree_sitter_rust::LANGUAGE.into())
+            .unwrap();
+        let tree = parser.parse(&source[..], None).unwrap();
+
+        let full = TreeVisitor::new(SupportedLanguage::Rust, source).run(&tree.root_node());
+        assert!(!full.depth_limited);
+
+        let limited = TreeVisitor::new(SupportedLanguage::Rust, source)
+            .with_max_depth(Some(3))
+            .run(&tree.root_node());
+        assert!(limited.depth_limited);
+        assert_eq!(limited.functions, 1);
+        assert!(limited.decision_points < full.decision_points);
+    }
+}
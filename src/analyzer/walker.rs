@@ -1,10 +1,44 @@
-use ignore::{WalkBuilder, WalkState};
+use ignore::gitignore::{Gitignore, GitignoreBuilder, Glob};
+use ignore::{Match, WalkBuilder, WalkState};
 use indicatif::{ProgressBar, ProgressStyle};
-use std::path::{Path, PathBuf};
-use std::sync::{Arc, Mutex};
-
+use serde::{Deserialize, Serialize};
+use std::collections::{HashMap, HashSet};
+use std::fmt;
+use std::path::{Component, Path, PathBuf};
+use std::sync::Mutex;
+
+use super::archive::{self, EntryAction, EntryKind};
+use super::budget::RunBudget;
+use super::git::nested_repo_kind;
 use super::language::LanguageManager;
+use crate::cli::NestedRepoMode;
 use crate::error::{AnalyzerError, Result};
+use crate::log_warn;
+
+/// Name of the analyzer's own ignore file (gitignore syntax, honored at any level)
+pub const IGNORE_FILE_NAME: &str = ".codeanalyzerignore";
+
+/// File name endings of code generators' output (protobuf, minifiers, bundlers)
+const GENERATED_SUFFIXES: &[&str] = &[
+    ".pb.go",
+    ".pb.cc",
+    ".pb.h",
+    "_pb2.py",
+    "_pb2_grpc.py",
+    ".min.js",
+    ".bundle.js",
+];
+
+/// Markers of generated code in a file header, lowercase (`// Code generated ... DO NOT EDIT.`)
+const GENERATED_MARKERS: &[&str] = &[
+    "@generated",
+    "do not edit",
+    "auto-generated",
+    "autogenerated",
+];
+
+/// Bytes at the start of a file searched for generated-code markers
+const GENERATED_HEADER_BYTES: usize = 1024;
 
 /// Configuration for file filtering during traversal
 #[derive(Debug, Clone)]
@@ -18,6 +52,15 @@ pub struct FilterConfig {
     /// Additional glob patterns to exclude
     pub exclude_patterns: Vec<String>,
 
+    /// Glob patterns a file must match to be analyzed (if empty, include all)
+    pub include_patterns: Vec<String>,
+
+    /// Honor .gitignore, .git/info/exclude and the global gitignore
+    pub respect_gitignore: bool,
+
+    /// How to treat git submodules and nested repositories
+    pub nested_repos: NestedRepoMode,
+
     /// Languages to include (if empty, include all supported)
     pub target_languages: Vec<String>,
 
@@ -26,6 +69,12 @@ pub struct FilterConfig {
 
     /// Maximum directory depth to traverse
     pub max_depth: Option<usize>,
+
+    /// Record the path and reason of every skipped file in `WalkStats::skipped_files`
+    pub record_skipped: bool,
+
+    /// Leave out generated files (by name or a marker in their header)
+    pub skip_generated: bool,
 }
 
 impl Default for FilterConfig {
@@ -34,15 +83,50 @@ impl Default for FilterConfig {
             max_file_size_bytes: 10 * 1024 * 1024, // 10MB
             include_hidden: false,
             exclude_patterns: Vec::new(),
+            include_patterns: Vec::new(),
+            respect_gitignore: true,
+            nested_repos: NestedRepoMode::Include,
             target_languages: Vec::new(),
             follow_symlinks: false,
             max_depth: None,
+            record_skipped: false,
+            skip_generated: false,
         }
     }
 }
 
+/// Why a file was left out during discovery
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum SkipReason {
+    /// Larger than the maximum file size
+    Size,
+    /// Unsupported or filtered-out language
+    Language,
+    /// Hidden file or directory
+    Hidden,
+    /// Matched by a `.gitignore`, `.ignore` or `.codeanalyzerignore` rule
+    Ignored,
+    /// Generated code (`--skip-generated`)
+    Generated,
+    /// Matched by an `--exclude` pattern
+    Excluded,
+    /// Not matched by any `--include` pattern
+    NotIncluded,
+    /// Submodule or nested repository (`--nested-repos skip`)
+    NestedRepo,
+}
+
+/// A file left out during discovery; skipped directories are listed once,
+/// without their contents
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SkippedFile {
+    pub path: PathBuf,
+    pub reason: SkipReason,
+}
+
 /// Statistics about the file discovery process
-#[derive(Debug, Default)]
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
 pub struct WalkStats {
     pub total_entries_scanned: usize,
     pub files_found: usize,
@@ -50,10 +134,45 @@ pub struct WalkStats {
     pub files_skipped_size: usize,
     pub files_skipped_language: usize,
     pub files_skipped_hidden: usize,
+    /// Files and directories left out by ignore files (only counted, like hidden
+    /// entries on disk, when skipped files are recorded)
+    #[serde(default)]
+    pub files_skipped_ignored: usize,
+    /// Generated files left out (`--skip-generated`)
+    #[serde(default)]
+    pub files_skipped_generated: usize,
+    /// Files left out by `--include` or `--exclude` patterns
+    #[serde(default)]
+    pub files_skipped_pattern: usize,
+    /// Symlinked directories skipped because they loop or were already visited
+    pub symlinks_skipped: usize,
+    /// Submodules and nested repositories not descended into (`--nested-repos skip`)
+    #[serde(default)]
+    pub nested_repos_skipped: usize,
     pub errors_encountered: usize,
+    /// Skipped files, if `FilterConfig::record_skipped` is set (reported separately)
+    #[serde(skip)]
+    pub skipped_files: Vec<SkippedFile>,
 }
 
 impl WalkStats {
+    /// Add the counters of another walk (e.g. from a different walker thread)
+    pub fn merge(&mut self, other: &WalkStats) {
+        self.total_entries_scanned += other.total_entries_scanned;
+        self.files_found += other.files_found;
+        self.directories_scanned += other.directories_scanned;
+        self.files_skipped_size += other.files_skipped_size;
+        self.files_skipped_language += other.files_skipped_language;
+        self.files_skipped_hidden += other.files_skipped_hidden;
+        self.files_skipped_ignored += other.files_skipped_ignored;
+        self.files_skipped_generated += other.files_skipped_generated;
+        self.files_skipped_pattern += other.files_skipped_pattern;
+        self.symlinks_skipped += other.symlinks_skipped;
+        self.nested_repos_skipped += other.nested_repos_skipped;
+        self.errors_encountered += other.errors_encountered;
+        self.skipped_files.extend_from_slice(&other.skipped_files);
+    }
+
     /// Get a summary of the walk statistics
     pub fn summary(&self) -> String {
         format!(
@@ -96,30 +215,12 @@ impl FileWalker {
     }
 
     /// Discover files in a directory with parallel processing
+    ///
+    /// Files are returned sorted by path so results do not depend on thread scheduling.
     pub fn discover_files<P: AsRef<Path>>(
         &self,
         root_path: P,
     ) -> Result<(Vec<PathBuf>, WalkStats)> {
-        let root_path = root_path.as_ref();
-
-        if !root_path.exists() {
-            return Err(AnalyzerError::invalid_path(root_path));
-        }
-
-        // Handle single file analysis
-        if root_path.is_file() {
-            return self.discover_single_file(root_path);
-        }
-
-        // Set up the ignore walker
-        let mut builder = WalkBuilder::new(root_path);
-        self.configure_walker(&mut builder)?;
-
-        // Collect results with thread-safe containers
-        let files = Arc::new(Mutex::new(Vec::new()));
-        let stats = Arc::new(Mutex::new(WalkStats::default()));
-        let errors = Arc::new(Mutex::new(Vec::new()));
-
         // Set up progress bar if requested
         let progress_bar = if self.show_progress {
             let pb = ProgressBar::new_spinner();
@@ -135,63 +236,234 @@ impl FileWalker {
             None
         };
 
+        let files = Mutex::new(Vec::new());
+        let stats = self.walk_files(root_path, |path| {
+            if let Some(ref pb) = progress_bar {
+                pb.set_message(format!(
+                    "Scanning: {}",
+                    path.file_name().unwrap_or_default().to_string_lossy()
+                ));
+                pb.inc(1);
+            }
+            files
+                .lock()
+                .expect("discovered files mutex poisoned")
+                .push(path);
+        })?;
+
+        if let Some(pb) = progress_bar {
+            pb.finish_with_message("File discovery completed");
+        }
+
+        let mut files = files.into_inner().expect("files mutex poisoned");
+        files.sort();
+
+        Ok((files, stats))
+    }
+
+    /// Walk a directory in parallel, calling `on_file` for every included file
+    ///
+    /// `on_file` is called from the walker threads as soon as a file passes the
+    /// filters, in no particular order, so callers can start processing files
+    /// (e.g. by sending them into a channel) while the walk continues. Each
+    /// thread counts into its own `WalkStats`; the counts are merged when the
+    /// walk completes.
+    pub fn walk_files<P, F>(&self, root_path: P, on_file: F) -> Result<WalkStats>
+    where
+        P: AsRef<Path>,
+        F: Fn(PathBuf) + Sync,
+    {
+        self.walk_files_with_budget(root_path, &RunBudget::unlimited(), on_file)
+    }
+
+    /// Like `walk_files`, but stops as soon as `budget` refuses a file or runs out of time
+    ///
+    /// Files refused by the budget are not passed to `on_file`; `budget.reached()`
+    /// tells which limit ended the walk.
+    pub fn walk_files_with_budget<P, F>(
+        &self,
+        root_path: P,
+        budget: &RunBudget,
+        on_file: F,
+    ) -> Result<WalkStats>
+    where
+        P: AsRef<Path>,
+        F: Fn(PathBuf) + Sync,
+    {
+        self.walk_budgeted(root_path.as_ref(), budget, None, on_file)
+    }
+
+    /// Like `walk_files_with_budget`, but leaves out files already in `seen`
+    ///
+    /// Files are keyed by their canonical path and added to `seen` before the
+    /// budget counts them, so walks over overlapping trees sharing one `seen`
+    /// set pass on (and charge the budget for) every file only once.
+    pub fn walk_unseen_files_with_budget<P, F>(
+        &self,
+        root_path: P,
+        budget: &RunBudget,
+        seen: &Mutex<HashSet<PathBuf>>,
+        on_file: F,
+    ) -> Result<WalkStats>
+    where
+        P: AsRef<Path>,
+        F: Fn(PathBuf) + Sync,
+    {
+        self.walk_budgeted(root_path.as_ref(), budget, Some(seen), on_file)
+    }
+
+    fn walk_budgeted<F>(
+        &self,
+        root_path: &Path,
+        budget: &RunBudget,
+        seen: Option<&Mutex<HashSet<PathBuf>>>,
+        on_file: F,
+    ) -> Result<WalkStats>
+    where
+        F: Fn(PathBuf) + Sync,
+    {
+        if !root_path.exists() {
+            return Err(AnalyzerError::invalid_path(root_path));
+        }
+
+        // Handle single file analysis
+        if root_path.is_file() {
+            let (mut files, mut stats) = self.discover_single_file(root_path)?;
+            files.retain(|file| first_seen(seen, file));
+            stats.files_found = files.len();
+            for file in files {
+                let size = std::fs::metadata(&file).map(|m| m.len()).unwrap_or(0);
+                if budget.admit_file(size) {
+                    on_file(file);
+                }
+            }
+            return Ok(stats);
+        }
+
+        // Set up the ignore walker
+        let mut builder = WalkBuilder::new(root_path);
+        self.configure_walker(&mut builder)?;
+        let patterns = PathPatterns::new(root_path, &self.filter_config)?;
+
+        let merged_stats = Mutex::new(WalkStats::default());
+        let errors = Mutex::new(Vec::new());
+        // Canonical targets of symlinked directories already entered
+        let visited_links = Mutex::new(HashSet::new());
+
+        // Entries the walker handed out (only kept to record what its filters dropped)
+        let merged_visited = Mutex::new(HashMap::new());
+
         // Parallel file discovery
         builder.build_parallel().run(|| {
-            let files = Arc::clone(&files);
-            let stats = Arc::clone(&stats);
-            let errors = Arc::clone(&errors);
-            let progress_bar = progress_bar.as_ref().cloned();
-            let filter_config = self.filter_config.clone();
+            let mut thread_stats = ThreadStats {
+                local: WalkStats::default(),
+                merged: &merged_stats,
+            };
+            let errors = &errors;
+            let visited_links = &visited_links;
+            let on_file = &on_file;
+            let filter_config = &self.filter_config;
+            let record_skipped = filter_config.record_skipped;
             let language_manager = &self.language_manager;
+            let patterns = &patterns;
+            let mut visited = ThreadVisited {
+                local: HashMap::new(),
+                merged: &merged_visited,
+                enabled: record_skipped,
+            };
 
             Box::new(move |result| {
-                let mut stats_guard = stats.lock().expect("walk stats mutex poisoned");
-                stats_guard.total_entries_scanned += 1;
+                if !budget.within_time() {
+                    return WalkState::Quit;
+                }
+
+                let stats = &mut thread_stats.local;
+                stats.total_entries_scanned += 1;
 
                 match result {
                     Ok(entry) => {
                         let path = entry.path();
 
-                        // Update progress
-                        if let Some(ref pb) = progress_bar {
-                            pb.set_message(format!(
-                                "Scanning: {}",
-                                path.file_name().unwrap_or_default().to_string_lossy()
-                            ));
-                            pb.inc(1);
-                        }
-
-                        if path.is_dir() {
-                            stats_guard.directories_scanned += 1;
+                        if entry.file_type().is_some_and(|t| t.is_dir()) {
+                            // Guard against symlinks leading into an already visited tree
+                            if entry.path_is_symlink() {
+                                let first_visit = std::fs::canonicalize(path)
+                                    .map(|target| {
+                                        visited_links
+                                            .lock()
+                                            .expect("visited links mutex poisoned")
+                                            .insert(target)
+                                    })
+                                    .unwrap_or(false);
+                                if !first_visit {
+                                    stats.symlinks_skipped += 1;
+                                    visited.mark(path, false);
+                                    return WalkState::Skip;
+                                }
+                            }
+                            if entry.depth() > 0 {
+                                // Don't descend into directories matched by `--exclude`
+                                if patterns.excluded_by(path, true).is_some() {
+                                    thread_stats.skip(path, SkipReason::Excluded, record_skipped);
+                                    visited.mark(path, false);
+                                    return WalkState::Skip;
+                                }
+                                if filter_config.nested_repos == NestedRepoMode::Skip
+                                    && nested_repo_kind(path).is_some()
+                                {
+                                    thread_stats.skip(path, SkipReason::NestedRepo, record_skipped);
+                                    visited.mark(path, false);
+                                    return WalkState::Skip;
+                                }
+                            }
+                            stats.directories_scanned += 1;
+                            visited.mark(path, true);
                             return WalkState::Continue;
                         }
+                        visited.mark(path, false);
 
                         // Apply file filters
-                        match should_include_file(path, &filter_config, language_manager) {
-                            Ok(IncludeResult::Include) => {
-                                files
-                                    .lock()
-                                    .expect("discovered files mutex poisoned")
-                                    .push(path.to_path_buf());
-                                stats_guard.files_found += 1;
+                        match should_include_file(path, filter_config, patterns, language_manager) {
+                            Ok(IncludeResult::Include(size)) => {
+                                if !first_seen(seen, path) {
+                                    return WalkState::Continue;
+                                }
+                                if !budget.admit_file(size) {
+                                    return WalkState::Quit;
+                                }
+                                stats.files_found += 1;
+                                on_file(path.to_path_buf());
                             }
                             Ok(IncludeResult::SkipSize) => {
-                                stats_guard.files_skipped_size += 1;
+                                thread_stats.skip(path, SkipReason::Size, record_skipped);
                             }
                             Ok(IncludeResult::SkipLanguage) => {
-                                stats_guard.files_skipped_language += 1;
+                                thread_stats.skip(path, SkipReason::Language, record_skipped);
                             }
                             Ok(IncludeResult::SkipHidden) => {
-                                stats_guard.files_skipped_hidden += 1;
+                                thread_stats.skip(path, SkipReason::Hidden, record_skipped);
+                            }
+                            Ok(IncludeResult::SkipGenerated) => {
+                                thread_stats.skip(path, SkipReason::Generated, record_skipped);
+                            }
+                            Ok(IncludeResult::SkipExcluded) => {
+                                thread_stats.skip(path, SkipReason::Excluded, record_skipped);
+                            }
+                            Ok(IncludeResult::SkipNotIncluded) => {
+                                thread_stats.skip(path, SkipReason::NotIncluded, record_skipped);
                             }
                             Err(e) => {
                                 errors.lock().expect("walk errors mutex poisoned").push(e);
-                                stats_guard.errors_encountered += 1;
+                                stats.errors_encountered += 1;
                             }
                         }
                     }
+                    // Symlink cycles detected by the walker are skipped, not errors
+                    Err(err) if is_symlink_loop(&err) => {
+                        stats.symlinks_skipped += 1;
+                    }
                     Err(err) => {
-                        stats_guard.errors_encountered += 1;
+                        stats.errors_encountered += 1;
                         errors
                             .lock()
                             .expect("walk errors mutex poisoned")
@@ -203,55 +475,460 @@ impl FileWalker {
             })
         });
 
-        if let Some(pb) = progress_bar {
-            pb.finish_with_message("File discovery completed");
+        for error in errors.into_inner().expect("errors mutex poisoned") {
+            log_warn!("Walk error", error = error);
         }
 
-        // Extract results
-        let files = Arc::try_unwrap(files)
-            .expect("files Arc still has multiple owners after walk completed")
+        // An interrupted walk didn't visit everything its filters let through
+        let visited = merged_visited
             .into_inner()
-            .expect("files mutex poisoned");
+            .expect("visited entries mutex poisoned");
+        if self.filter_config.record_skipped && budget.reached().is_none() && budget.within_time() {
+            self.record_filtered_entries(root_path, &visited, &merged_stats);
+        }
 
-        let stats = Arc::try_unwrap(stats)
-            .expect("stats Arc still has multiple owners after walk completed")
-            .into_inner()
-            .expect("stats mutex poisoned");
+        Ok(merged_stats.into_inner().expect("stats mutex poisoned"))
+    }
 
-        let errors = Arc::try_unwrap(errors)
-            .expect("errors Arc still has multiple owners after walk completed")
-            .into_inner()
-            .expect("errors mutex poisoned");
+    /// Record the hidden and ignored entries the walker's standard filters dropped
+    ///
+    /// The walker leaves them out unseen, so they are found by walking `root_path`
+    /// again without those filters and comparing with the entries the filtered walk
+    /// visited (`true` for directories it descended into). Skipped directories are
+    /// recorded once, without their contents.
+    fn record_filtered_entries(
+        &self,
+        root_path: &Path,
+        visited: &HashMap<PathBuf, bool>,
+        merged_stats: &Mutex<WalkStats>,
+    ) {
+        let mut builder = WalkBuilder::new(root_path);
+        builder
+            .standard_filters(false)
+            .follow_links(self.filter_config.follow_symlinks)
+            .max_depth(self.filter_config.max_depth);
+        let mut thread_stats = ThreadStats {
+            local: WalkStats::default(),
+            merged: merged_stats,
+        };
 
-        // Log errors if any
-        for error in &errors {
-            eprintln!("Walk error: {error}");
+        let mut walk = builder.build();
+        while let Some(result) = walk.next() {
+            let Ok(entry) = result else {
+                continue;
+            };
+            if entry.depth() == 0 {
+                continue;
+            }
+            let path = entry.path();
+            let is_dir = entry.file_type().is_some_and(|t| t.is_dir());
+            match visited.get(path) {
+                Some(true) => continue,
+                Some(false) => {}
+                None => {
+                    let reason = if !self.filter_config.include_hidden && is_hidden(path) {
+                        SkipReason::Hidden
+                    } else {
+                        SkipReason::Ignored
+                    };
+                    thread_stats.skip(path, reason, true);
+                }
+            }
+            if is_dir {
+                walk.skip_current_dir();
+            }
         }
+    }
 
-        Ok((files, stats))
+    /// Walk the entries of an archive, calling `on_file` with the path (relative
+    /// to the archive root) and contents of every included file
+    ///
+    /// Entries pass the same depth, hidden, pattern, language and size filters
+    /// as files on disk; ignore files inside the archive are not consulted.
+    /// Excluded entries are never read into memory.
+    pub fn walk_archive_with_budget<F>(
+        &self,
+        archive_path: &Path,
+        budget: &RunBudget,
+        on_file: F,
+    ) -> Result<WalkStats>
+    where
+        F: FnMut(PathBuf, Vec<u8>),
+    {
+        let config = &self.filter_config;
+        let patterns = PathPatterns::new(Path::new(""), config)?;
+        let merged_stats = Mutex::new(WalkStats::default());
+        let mut thread_stats = ThreadStats {
+            local: WalkStats::default(),
+            merged: &merged_stats,
+        };
+
+        archive::for_each_entry(
+            archive_path,
+            |path, kind, size| {
+                if !budget.within_time() {
+                    return EntryAction::Stop;
+                }
+                thread_stats.local.total_entries_scanned += 1;
+
+                if config
+                    .max_depth
+                    .is_some_and(|max_depth| path.components().count() > max_depth)
+                {
+                    return EntryAction::Skip;
+                }
+                match kind {
+                    EntryKind::File => {}
+                    EntryKind::Directory => {
+                        thread_stats.local.directories_scanned += 1;
+                        return EntryAction::Skip;
+                    }
+                    EntryKind::Other => return EntryAction::Skip,
+                }
+                // On disk, the walker never enters hidden directories
+                let in_hidden_dir = path.parent().is_some_and(|dir| {
+                    dir.components()
+                        .any(|c| c.as_os_str().to_string_lossy().starts_with('.'))
+                });
+                if !config.include_hidden && in_hidden_dir {
+                    thread_stats.skip(path, SkipReason::Hidden, config.record_skipped);
+                    return EntryAction::Skip;
+                }
+
+                let reason = match should_include_entry(
+                    path,
+                    size,
+                    config,
+                    &patterns,
+                    &self.language_manager,
+                ) {
+                    IncludeResult::Include(size) => {
+                        if !budget.admit_file(size) {
+                            return EntryAction::Stop;
+                        }
+                        thread_stats.local.files_found += 1;
+                        return EntryAction::Read;
+                    }
+                    IncludeResult::SkipSize => SkipReason::Size,
+                    IncludeResult::SkipLanguage => SkipReason::Language,
+                    IncludeResult::SkipHidden => SkipReason::Hidden,
+                    IncludeResult::SkipGenerated => SkipReason::Generated,
+                    IncludeResult::SkipExcluded => SkipReason::Excluded,
+                    IncludeResult::SkipNotIncluded => SkipReason::NotIncluded,
+                };
+                thread_stats.skip(path, reason, config.record_skipped);
+                EntryAction::Skip
+            },
+            on_file,
+        )?;
+
+        drop(thread_stats);
+        Ok(merged_stats.into_inner().expect("stats mutex poisoned"))
+    }
+
+    /// Filter an explicit list of files (e.g. `--files-from`) without walking
+    ///
+    /// Listed files go through the same file filters as walked ones; ignore
+    /// files, hidden directories and the depth limit don't apply. Relative
+    /// paths and `--include`/`--exclude` globs are relative to the current
+    /// directory. Missing files are counted as errors.
+    pub fn walk_list_with_budget<I, F>(
+        &self,
+        files: I,
+        budget: &RunBudget,
+        mut on_file: F,
+    ) -> Result<WalkStats>
+    where
+        I: IntoIterator<Item = PathBuf>,
+        F: FnMut(PathBuf),
+    {
+        let config = &self.filter_config;
+        let cwd = std::env::current_dir()?;
+        let patterns = PathPatterns::new(&cwd, config)?;
+        let merged_stats = Mutex::new(WalkStats::default());
+        let mut thread_stats = ThreadStats {
+            local: WalkStats::default(),
+            merged: &merged_stats,
+        };
+
+        for path in files {
+            if !budget.within_time() {
+                break;
+            }
+            thread_stats.local.total_entries_scanned += 1;
+
+            let size = match std::fs::metadata(&path) {
+                Ok(metadata) if metadata.is_file() => metadata.len(),
+                _ => {
+                    log_warn!("Listed file not found", path = path.display());
+                    thread_stats.local.errors_encountered += 1;
+                    continue;
+                }
+            };
+
+            // Globs only match below their root, so paths outside it are
+            // matched from the filesystem root instead
+            let relative = path.strip_prefix(&cwd).unwrap_or(&path);
+            let relative: PathBuf = relative
+                .components()
+                .filter(|c| matches!(c, Component::Normal(_)))
+                .collect();
+            let reason =
+                match check_path_filters(&relative, config, &patterns, &self.language_manager)
+                    .unwrap_or_else(|| {
+                        check_generated_header(&path, config, check_size(size, config))
+                    }) {
+                    IncludeResult::Include(size) => {
+                        if !budget.admit_file(size) {
+                            break;
+                        }
+                        thread_stats.local.files_found += 1;
+                        on_file(path);
+                        continue;
+                    }
+                    IncludeResult::SkipSize => SkipReason::Size,
+                    IncludeResult::SkipLanguage => SkipReason::Language,
+                    IncludeResult::SkipHidden => SkipReason::Hidden,
+                    IncludeResult::SkipGenerated => SkipReason::Generated,
+                    IncludeResult::SkipExcluded => SkipReason::Excluded,
+                    IncludeResult::SkipNotIncluded => SkipReason::NotIncluded,
+                };
+            thread_stats.skip(&path, reason, config.record_skipped);
+        }
+
+        drop(thread_stats);
+        Ok(merged_stats.into_inner().expect("stats mutex poisoned"))
     }
 
     /// Configure the WalkBuilder with filter settings
     fn configure_walker(&self, builder: &mut WalkBuilder) -> Result<()> {
+        let respect_gitignore = self.filter_config.respect_gitignore;
+
         // Configure basic walker settings
         builder
             .hidden(!self.filter_config.include_hidden)
             .follow_links(self.filter_config.follow_symlinks)
-            .git_ignore(true)
-            .git_global(true)
-            .git_exclude(true);
+            .git_ignore(respect_gitignore)
+            .git_global(respect_gitignore)
+            .git_exclude(respect_gitignore)
+            .add_custom_ignore_filename(IGNORE_FILE_NAME);
 
         // Set maximum depth if specified
         if let Some(max_depth) = self.filter_config.max_depth {
             builder.max_depth(Some(max_depth));
         }
 
-        // Add custom exclude patterns
-        for pattern in &self.filter_config.exclude_patterns {
-            builder.add_custom_ignore_filename(pattern);
+        Ok(())
+    }
+
+    /// Explain why `path` is or isn't analyzed when walking `root_path`
+    ///
+    /// Replays the walker's checks in order (depth, symlinks, hidden entries,
+    /// ignore files, `--exclude`/`--include`, language, size) and stops at the
+    /// first one that leaves the path out.
+    pub fn explain_path(&self, root_path: &Path, path: &Path) -> Result<PathExplanation> {
+        let config = &self.filter_config;
+        let mut explanation = PathExplanation {
+            path: path.to_path_buf(),
+            analyzed: false,
+            checks: Vec::new(),
+        };
+
+        if !path.exists() {
+            return Err(AnalyzerError::invalid_path(path));
+        }
+        if path.is_dir() {
+            return Err(AnalyzerError::validation_error(format!(
+                "--explain-path expects a file, but {} is a directory",
+                path.display()
+            )));
         }
 
-        Ok(())
+        let cwd = std::env::current_dir()?;
+        let root = cwd.join(root_path);
+        let target = cwd.join(path);
+
+        if archive::is_archive(&root) {
+            return Err(AnalyzerError::validation_error(
+                "--explain-path does not support archive targets",
+            ));
+        }
+
+        // A file given as the target path bypasses directory filtering
+        if root.is_file() {
+            if root != target {
+                explanation.fail(format!("not the target file {}", root_path.display()));
+                return Ok(explanation);
+            }
+            explanation.pass("given directly as the target path");
+            return Ok(self.explain_file_filters(explanation, &target, None));
+        }
+
+        let relative = match target.strip_prefix(&root) {
+            Ok(relative) => relative.to_path_buf(),
+            Err(_) => {
+                explanation.fail(format!("outside the target path {}", root_path.display()));
+                return Ok(explanation);
+            }
+        };
+        explanation.pass(format!("inside the target path {}", root_path.display()));
+
+        let depth = relative.components().count();
+        if let Some(max_depth) = config.max_depth {
+            if depth > max_depth {
+                explanation.fail(format!("depth {depth} exceeds --max-depth {max_depth}"));
+                return Ok(explanation);
+            }
+            explanation.pass(format!("depth {depth} within --max-depth {max_depth}"));
+        }
+
+        let ignore_files = IgnoreFiles::load(&root, &target, config.respect_gitignore);
+        let patterns = PathPatterns::new(&root, config)?;
+
+        // Every directory on the way down, then the file itself
+        let components: Vec<Component> = relative.components().collect();
+        let mut current = root.clone();
+        for (index, component) in components.iter().enumerate() {
+            current.push(component);
+            let is_dir = index + 1 < components.len();
+            let kind = if is_dir { "directory" } else { "file" };
+            let shown = current.strip_prefix(&root).unwrap_or(&current).display();
+
+            if is_dir && !config.follow_symlinks && current.is_symlink() {
+                explanation.fail(format!(
+                    "directory {shown} is a symbolic link (use --follow-symlinks)"
+                ));
+                return Ok(explanation);
+            }
+
+            if !config.include_hidden && component.as_os_str().to_string_lossy().starts_with('.') {
+                explanation.fail(format!("{kind} {shown} is hidden (use --include-hidden)"));
+                return Ok(explanation);
+            }
+
+            match ignore_files.matched(&current, is_dir) {
+                Match::Ignore(glob) => {
+                    explanation.fail(format!(
+                        "{kind} {shown} is ignored by {}",
+                        describe_glob(glob)
+                    ));
+                    return Ok(explanation);
+                }
+                Match::Whitelist(glob) => {
+                    explanation.pass(format!(
+                        "{kind} {shown} is re-included by {}",
+                        describe_glob(glob)
+                    ));
+                }
+                Match::None => {}
+            }
+
+            if is_dir {
+                if let Some(glob) = patterns.excluded_by(&current, true) {
+                    explanation.fail(format!(
+                        "directory {shown} is excluded by --exclude '{}'",
+                        glob.original()
+                    ));
+                    return Ok(explanation);
+                }
+                if config.nested_repos == NestedRepoMode::Skip {
+                    if let Some(kind) = nested_repo_kind(&current) {
+                        explanation.fail(format!(
+                            "directory {shown} is a git {} (--nested-repos skip)",
+                            kind.as_str()
+                        ));
+                        return Ok(explanation);
+                    }
+                }
+            }
+        }
+        explanation.pass(if config.respect_gitignore {
+            format!("not ignored by .gitignore, .ignore or {IGNORE_FILE_NAME} files")
+        } else {
+            format!("not ignored by .ignore or {IGNORE_FILE_NAME} files (--no-gitignore)")
+        });
+
+        Ok(self.explain_file_filters(explanation, &target, Some(&patterns)))
+    }
+
+    /// Add the per-file checks (patterns, language, size) to an explanation
+    fn explain_file_filters(
+        &self,
+        mut explanation: PathExplanation,
+        path: &Path,
+        patterns: Option<&PathPatterns>,
+    ) -> PathExplanation {
+        let config = &self.filter_config;
+
+        if let Some(patterns) = patterns {
+            if let Some(glob) = patterns.excluded_by(path, false) {
+                explanation.fail(format!("excluded by --exclude '{}'", glob.original()));
+                return explanation;
+            }
+            if patterns.has_includes() {
+                match patterns.included_by(path) {
+                    Some(glob) => {
+                        explanation.pass(format!("matches --include '{}'", glob.original()))
+                    }
+                    None => {
+                        explanation.fail("matches none of the --include patterns");
+                        return explanation;
+                    }
+                }
+            }
+        }
+
+        let language = match self.language_manager.detect_language(path) {
+            Some(language) => language.to_string(),
+            None => {
+                explanation.fail("not a file of a supported language");
+                return explanation;
+            }
+        };
+        if !config.target_languages.is_empty() && !config.target_languages.contains(&language) {
+            explanation.fail(format!(
+                "language {language} is not selected by --languages"
+            ));
+            return explanation;
+        }
+        explanation.pass(format!("language {language}"));
+
+        let size = match std::fs::metadata(path) {
+            Ok(metadata) => metadata.len(),
+            Err(e) => {
+                explanation.fail(format!("cannot read file metadata: {e}"));
+                return explanation;
+            }
+        };
+        if size > config.max_file_size_bytes {
+            explanation.fail(format!(
+                "size {size} bytes exceeds the limit of {} bytes",
+                config.max_file_size_bytes
+            ));
+            return explanation;
+        }
+        explanation.pass(format!(
+            "size {size} bytes within the limit of {} bytes",
+            config.max_file_size_bytes
+        ));
+
+        if config.skip_generated {
+            if is_generated_name(path) {
+                explanation.fail("generated file name (--skip-generated)");
+                return explanation;
+            }
+            if matches!(
+                check_generated_header(path, config, IncludeResult::Include(size)),
+                IncludeResult::SkipGenerated
+            ) {
+                explanation.fail("header marks the file as generated (--skip-generated)");
+                return explanation;
+            }
+            explanation.pass("not generated");
+        }
+
+        explanation.analyzed = true;
+        explanation
     }
 
     /// Get a reference to the filter configuration
@@ -293,57 +970,446 @@ impl FileWalker {
     }
 }
 
+/// Per-thread walk statistics, merged into the shared total when the thread's
+/// visitor is dropped at the end of the walk
+struct ThreadStats<'a> {
+    local: WalkStats,
+    merged: &'a Mutex<WalkStats>,
+}
+
+impl ThreadStats<'_> {
+    /// Count a skipped file, recording its path if configured
+    fn skip(&mut self, path: &Path, reason: SkipReason, record: bool) {
+        match reason {
+            SkipReason::Size => self.local.files_skipped_size += 1,
+            SkipReason::Language => self.local.files_skipped_language += 1,
+            SkipReason::Hidden => self.local.files_skipped_hidden += 1,
+            SkipReason::Ignored => self.local.files_skipped_ignored += 1,
+            SkipReason::Generated => self.local.files_skipped_generated += 1,
+            SkipReason::Excluded | SkipReason::NotIncluded => self.local.files_skipped_pattern += 1,
+            SkipReason::NestedRepo => self.local.nested_repos_skipped += 1,
+        }
+        if record {
+            self.local.skipped_files.push(SkippedFile {
+                path: path.to_path_buf(),
+                reason,
+            });
+        }
+    }
+}
+
+impl Drop for ThreadStats<'_> {
+    fn drop(&mut self) {
+        if let Ok(mut merged) = self.merged.lock() {
+            merged.merge(&self.local);
+        }
+    }
+}
+
+/// Entries a walker thread visited, merged when the thread finishes
+///
+/// Only kept when skipped files are recorded, to find the entries the
+/// walker's own hidden and ignore-file filters dropped.
+struct ThreadVisited<'a> {
+    /// Visited paths, `true` for directories the walk descended into
+    local: HashMap<PathBuf, bool>,
+    merged: &'a Mutex<HashMap<PathBuf, bool>>,
+    enabled: bool,
+}
+
+impl ThreadVisited<'_> {
+    fn mark(&mut self, path: &Path, descended: bool) {
+        if self.enabled {
+            self.local.insert(path.to_path_buf(), descended);
+        }
+    }
+}
+
+impl Drop for ThreadVisited<'_> {
+    fn drop(&mut self) {
+        if let Ok(mut merged) = self.merged.lock() {
+            merged.extend(self.local.drain());
+        }
+    }
+}
+
 /// Result of file inclusion check
 #[derive(Debug)]
 enum IncludeResult {
-    Include,
+    /// Include the file (with its size in bytes)
+    Include(u64),
     SkipSize,
     SkipLanguage,
     SkipHidden,
+    SkipGenerated,
+    SkipExcluded,
+    SkipNotIncluded,
 }
 
 /// Check if a file should be included based on filters
 fn should_include_file(
     path: &Path,
     config: &FilterConfig,
+    patterns: &PathPatterns,
     language_manager: &LanguageManager,
 ) -> Result<IncludeResult> {
+    if let Some(skip) = check_path_filters(path, config, patterns, language_manager) {
+        return Ok(skip);
+    }
+
+    // Check file size
+    let size = match std::fs::metadata(path) {
+        Ok(metadata) => metadata.len(),
+        // If we can't get metadata, skip the file
+        Err(_) => return Ok(IncludeResult::SkipSize),
+    };
+    Ok(check_generated_header(
+        path,
+        config,
+        check_size(size, config),
+    ))
+}
+
+/// Skip an included file whose header marks it as generated (`--skip-generated`)
+fn check_generated_header(
+    path: &Path,
+    config: &FilterConfig,
+    result: IncludeResult,
+) -> IncludeResult {
+    if !config.skip_generated || !matches!(result, IncludeResult::Include(_)) {
+        return result;
+    }
+    let mut header = Vec::with_capacity(GENERATED_HEADER_BYTES);
+    let read = std::fs::File::open(path).and_then(|file| {
+        use std::io::Read;
+        file.take(GENERATED_HEADER_BYTES as u64)
+            .read_to_end(&mut header)
+    });
+    if read.is_ok() && is_generated_header(&header) {
+        IncludeResult::SkipGenerated
+    } else {
+        result
+    }
+}
+
+/// Check if a file name is that of a code generator's output
+fn is_generated_name(path: &Path) -> bool {
+    let name = path.file_name().unwrap_or_default().to_string_lossy();
+    GENERATED_SUFFIXES
+        .iter()
+        .any(|suffix| name.ends_with(suffix))
+}
+
+/// Check if a file header carries a generated-code marker
+fn is_generated_header(header: &[u8]) -> bool {
+    let header = String::from_utf8_lossy(header).to_lowercase();
+    GENERATED_MARKERS
+        .iter()
+        .any(|marker| header.contains(marker))
+}
+
+/// Check if an archive entry of `size` bytes should be included based on filters
+fn should_include_entry(
+    path: &Path,
+    size: u64,
+    config: &FilterConfig,
+    patterns: &PathPatterns,
+    language_manager: &LanguageManager,
+) -> IncludeResult {
+    check_path_filters(path, config, patterns, language_manager)
+        .unwrap_or_else(|| check_size(size, config))
+}
+
+/// Apply the filters that only look at the path (hidden, patterns, language)
+fn check_path_filters(
+    path: &Path,
+    config: &FilterConfig,
+    patterns: &PathPatterns,
+    language_manager: &LanguageManager,
+) -> Option<IncludeResult> {
     // Check if hidden and we're not including hidden files
     if !config.include_hidden {
         if let Some(file_name) = path.file_name() {
             if file_name.to_string_lossy().starts_with('.') {
-                return Ok(IncludeResult::SkipHidden);
+                return Some(IncludeResult::SkipHidden);
             }
         }
     }
 
+    // Check generated file names
+    if config.skip_generated && is_generated_name(path) {
+        return Some(IncludeResult::SkipGenerated);
+    }
+
+    // Check --exclude and --include patterns
+    if patterns.excluded_by(path, false).is_some() {
+        return Some(IncludeResult::SkipExcluded);
+    }
+    if patterns.has_includes() && patterns.included_by(path).is_none() {
+        return Some(IncludeResult::SkipNotIncluded);
+    }
+
     // Check language support
     if !language_manager.is_supported_file(path) {
-        return Ok(IncludeResult::SkipLanguage);
+        return Some(IncludeResult::SkipLanguage);
     }
 
     // Check specific language filtering
     if !config.target_languages.is_empty() {
         if let Some(detected_lang) = language_manager.detect_language(path) {
             if !config.target_languages.contains(&detected_lang.to_string()) {
-                return Ok(IncludeResult::SkipLanguage);
+                return Some(IncludeResult::SkipLanguage);
             }
         } else {
-            return Ok(IncludeResult::SkipLanguage);
+            return Some(IncludeResult::SkipLanguage);
         }
     }
 
-    // Check file size
-    if let Ok(metadata) = std::fs::metadata(path) {
-        if metadata.len() > config.max_file_size_bytes {
-            return Ok(IncludeResult::SkipSize);
-        }
+    None
+}
+
+/// Check a file size against the limit
+fn check_size(size: u64, config: &FilterConfig) -> IncludeResult {
+    if size > config.max_file_size_bytes {
+        IncludeResult::SkipSize
     } else {
-        // If we can't get metadata, skip the file
-        return Ok(IncludeResult::SkipSize);
+        IncludeResult::Include(size)
+    }
+}
+
+/// `--include` and `--exclude` globs (gitignore syntax) compiled against a walk root
+#[derive(Default)]
+struct PathPatterns {
+    include: Option<Gitignore>,
+    exclude: Option<Gitignore>,
+}
+
+impl PathPatterns {
+    fn new(root: &Path, config: &FilterConfig) -> Result<Self> {
+        Ok(Self {
+            include: compile_patterns(root, &config.include_patterns, "--include")?,
+            exclude: compile_patterns(root, &config.exclude_patterns, "--exclude")?,
+        })
+    }
+
+    fn has_includes(&self) -> bool {
+        self.include.is_some()
+    }
+
+    /// The `--exclude` glob matching the path or one of its parent directories
+    fn excluded_by(&self, path: &Path, is_dir: bool) -> Option<&Glob> {
+        match self
+            .exclude
+            .as_ref()?
+            .matched_path_or_any_parents(path, is_dir)
+        {
+            Match::Ignore(glob) => Some(glob),
+            _ => None,
+        }
+    }
+
+    /// The `--include` glob matching the file or one of its parent directories
+    fn included_by(&self, path: &Path) -> Option<&Glob> {
+        match self
+            .include
+            .as_ref()?
+            .matched_path_or_any_parents(path, false)
+        {
+            Match::Ignore(glob) => Some(glob),
+            _ => None,
+        }
+    }
+}
+
+/// Compile glob patterns into a matcher, or `None` if there are no patterns
+fn compile_patterns(root: &Path, patterns: &[String], option: &str) -> Result<Option<Gitignore>> {
+    if patterns.is_empty() {
+        return Ok(None);
+    }
+
+    let mut builder = GitignoreBuilder::new(root);
+    for pattern in patterns {
+        builder.add_line(None, pattern).map_err(|e| {
+            AnalyzerError::config_error(format!("Invalid {option} pattern '{pattern}': {e}"))
+        })?;
+    }
+    let matcher = builder
+        .build()
+        .map_err(|e| AnalyzerError::config_error(format!("Invalid {option} patterns: {e}")))?;
+    Ok(Some(matcher))
+}
+
+/// Ignore files that apply to a path, in the walker's order of precedence
+///
+/// As in the walker, the analyzer's ignore file wins over `.ignore`, which wins
+/// over `.gitignore`, `.git/info/exclude` and the global gitignore; within each
+/// kind, the file in the deepest directory wins. Git ignore rules only apply
+/// inside a git repository.
+struct IgnoreFiles {
+    /// Matchers of each kind, paired with their directory, deepest first
+    kinds: Vec<Vec<(PathBuf, Gitignore)>>,
+}
+
+impl IgnoreFiles {
+    /// Load the ignore files of every directory above `target`
+    fn load(root: &Path, target: &Path, respect_gitignore: bool) -> Self {
+        let dirs: Vec<&Path> = target.ancestors().skip(1).collect();
+        let mut kinds = vec![
+            load_ignore_files(&dirs, IGNORE_FILE_NAME),
+            load_ignore_files(&dirs, ".ignore"),
+        ];
+
+        let git_root = if respect_gitignore {
+            root.ancestors().find(|dir| dir.join(".git").exists())
+        } else {
+            None
+        };
+        if let Some(git_root) = git_root {
+            let repo_dirs: Vec<&Path> = dirs
+                .iter()
+                .copied()
+                .filter(|dir| dir.starts_with(git_root))
+                .collect();
+            kinds.push(load_ignore_files(&repo_dirs, ".gitignore"));
+
+            let exclude = git_root.join(".git").join("info").join("exclude");
+            kinds.push(load_ignore_file(git_root, &exclude).into_iter().collect());
+
+            let (global, error) = Gitignore::global();
+            if let Some(error) = error {
+                log_warn!("Cannot read the global gitignore", error = error);
+            }
+            kinds.push(vec![(PathBuf::new(), global)]);
+        }
+
+        Self { kinds }
+    }
+
+    /// Match a path against the ignore files of the directories above it
+    fn matched(&self, path: &Path, is_dir: bool) -> Match<&Glob> {
+        for matchers in &self.kinds {
+            for (dir, matcher) in matchers {
+                // A directory's own ignore file applies to its contents only
+                if path == dir || !path.starts_with(dir) {
+                    continue;
+                }
+                let matched = matcher.matched(path, is_dir);
+                if !matched.is_none() {
+                    return matched;
+                }
+            }
+        }
+        Match::None
+    }
+}
+
+/// Load the ignore file named `name` from each directory that has one
+fn load_ignore_files(dirs: &[&Path], name: &str) -> Vec<(PathBuf, Gitignore)> {
+    dirs.iter()
+        .filter_map(|dir| load_ignore_file(dir, &dir.join(name)))
+        .collect()
+}
+
+/// Load one ignore file, if it exists
+fn load_ignore_file(dir: &Path, file: &Path) -> Option<(PathBuf, Gitignore)> {
+    if !file.is_file() {
+        return None;
     }
+    let (matcher, error) = Gitignore::new(file);
+    if let Some(error) = error {
+        log_warn!("Invalid ignore file", path = file.display(), error = error);
+    }
+    Some((dir.to_path_buf(), matcher))
+}
+
+/// Describe an ignore rule as "<file>: '<pattern>'"
+fn describe_glob(glob: &Glob) -> String {
+    match glob.from() {
+        Some(file) => format!("{}: '{}'", file.display(), glob.original()),
+        None => format!("'{}'", glob.original()),
+    }
+}
+
+/// Why a path is or isn't analyzed (see `FileWalker::explain_path`)
+#[derive(Debug, Clone, PartialEq)]
+pub struct PathExplanation {
+    pub path: PathBuf,
+    pub analyzed: bool,
+    /// Checks in the order the walker applies them, ending at the deciding one
+    pub checks: Vec<PathCheck>,
+}
+
+/// One filter check applied to a path
+#[derive(Debug, Clone, PartialEq)]
+pub struct PathCheck {
+    pub passed: bool,
+    pub detail: String,
+}
+
+impl PathExplanation {
+    fn pass(&mut self, detail: impl Into<String>) {
+        self.checks.push(PathCheck {
+            passed: true,
+            detail: detail.into(),
+        });
+    }
+
+    fn fail(&mut self, detail: impl Into<String>) {
+        self.checks.push(PathCheck {
+            passed: false,
+            detail: detail.into(),
+        });
+    }
+
+    /// The check that left the path out, if any
+    pub fn reason(&self) -> Option<&str> {
+        self.checks
+            .iter()
+            .find(|check| !check.passed)
+            .map(|check| check.detail.as_str())
+    }
+}
+
+impl fmt::Display for PathExplanation {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        let verdict = if self.analyzed {
+            "analyzed"
+        } else {
+            "not analyzed"
+        };
+        writeln!(f, "{}: {verdict}", self.path.display())?;
+        for check in &self.checks {
+            let mark = if check.passed { "ok" } else { "skip" };
+            writeln!(f, "  [{mark:>4}] {}", check.detail)?;
+        }
+        Ok(())
+    }
+}
+
+/// Check if the final component of a path starts with a dot
+fn is_hidden(path: &Path) -> bool {
+    path.file_name()
+        .is_some_and(|name| name.to_string_lossy().starts_with('.'))
+}
 
-    Ok(IncludeResult::Include)
+/// Add `path` (canonicalized) to `seen`, telling whether it was new (always true without a set)
+fn first_seen(seen: Option<&Mutex<HashSet<PathBuf>>>, path: &Path) -> bool {
+    let Some(seen) = seen else {
+        return true;
+    };
+    let key = std::fs::canonicalize(path).unwrap_or_else(|_| path.to_path_buf());
+    seen.lock().expect("seen files mutex poisoned").insert(key)
+}
+
+/// Check if a walk error reports a symlink pointing back at one of its ancestors
+fn is_symlink_loop(err: &ignore::Error) -> bool {
+    match err {
+        ignore::Error::Loop { .. } => true,
+        ignore::Error::WithPath { err, .. }
+        | ignore::Error::WithDepth { err, .. }
+        | ignore::Error::WithLineNumber { err, .. } => is_symlink_loop(err),
+        _ => false,
+    }
 }
 
 /// Helper function to create a walker with CLI arguments
@@ -355,9 +1421,14 @@ pub fn create_walker_from_cli(
         max_file_size_bytes: cli_args.max_file_size_bytes(),
         include_hidden: cli_args.include_hidden,
         exclude_patterns: cli_args.exclude.clone(),
+        include_patterns: cli_args.include.clone(),
+        respect_gitignore: !cli_args.no_gitignore,
+        nested_repos: cli_args.nested_repos,
         target_languages: cli_args.languages.clone(),
-        follow_symlinks: false,
-        max_depth: None,
+        follow_symlinks: cli_args.follow_symlinks,
+        max_depth: cli_args.max_depth,
+        record_skipped: cli_args.report_skipped,
+        skip_generated: cli_args.skip_generated,
     };
 
     FileWalker::with_config(language_manager, filter_config).show_progress(cli_args.verbose)
@@ -540,9 +1611,13 @@ mod tests {
             max_file_size_bytes: 1000,
             include_hidden: true,
             exclude_patterns: vec!["*.tmp".to_string()],
+            include_patterns: vec![],
+            respect_gitignore: true,
+            nested_repos: NestedRepoMode::Include,
             target_languages: vec!["rust".to_string()],
             follow_symlinks: false,
             max_depth: Some(2),
+            record_skipped: false,
         };
 
         let walker = FileWalker::with_config(language_manager, config);
@@ -560,9 +1635,13 @@ mod tests {
             max_file_size_bytes: 5000,
             include_hidden: false,
             exclude_patterns: vec![],
+            include_patterns: vec![],
+            respect_gitignore: true,
+            nested_repos: NestedRepoMode::Include,
             target_languages: vec![],
             follow_symlinks: true,
             max_depth: None,
+            record_skipped: false,
         };
 
         let mut walker = walker;
@@ -580,9 +1659,13 @@ mod tests {
             max_file_size_bytes: u64::MAX,
             include_hidden: false,
             exclude_patterns: vec![],
+            include_patterns: vec![],
+            respect_gitignore: true,
+            nested_repos: NestedRepoMode::Include,
             target_languages: vec![],
             follow_symlinks: false,
             max_depth: Some(3),
+            record_skipped: false,
         };
 
         let walker = FileWalker::with_config(language_manager, config);
@@ -598,9 +1681,13 @@ mod tests {
             max_file_size_bytes: u64::MAX,
             include_hidden: false,
             exclude_patterns: vec!["*.tmp".to_string(), "*.log".to_string()],
+            include_patterns: vec![],
+            respect_gitignore: true,
+            nested_repos: NestedRepoMode::Include,
             target_languages: vec![],
             follow_symlinks: false,
             max_depth: None,
+            record_skipped: false,
         };
 
         let walker = FileWalker::with_config(language_manager, config);
@@ -618,16 +1705,25 @@ mod tests {
             max_file_size_bytes: u64::MAX,
             include_hidden: false,
             exclude_patterns: vec![],
+            include_patterns: vec![],
+            respect_gitignore: true,
+            nested_repos: NestedRepoMode::Include,
             target_languages: vec![],
             follow_symlinks: false,
             max_depth: None,
+            record_skipped: false,
         };
 
         // Create a temporary hidden file
         let temp_file = std::env::temp_dir().join(".hidden_file.rs");
         std::fs::write(&temp_file, "fn main() {}").unwrap();
 
-        let result = should_include_file(&temp_file, &config, &language_manager);
+        let result = should_include_file(
+            &temp_file,
+            &config,
+            &PathPatterns::default(),
+            &language_manager,
+        );
         assert!(result.is_ok());
         assert!(matches!(result.unwrap(), IncludeResult::SkipHidden));
 
@@ -643,16 +1739,25 @@ mod tests {
             max_file_size_bytes: u64::MAX,
             include_hidden: true,
             exclude_patterns: vec![],
+            include_patterns: vec![],
+            respect_gitignore: true,
+            nested_repos: NestedRepoMode::Include,
             target_languages: vec![],
             follow_symlinks: false,
             max_depth: None,
+            record_skipped: false,
         };
 
         // Create a file with no extension
         let temp_file = std::env::temp_dir().join("test_file_no_ext");
         std::fs::write(&temp_file, "some content").unwrap();
 
-        let result = should_include_file(&temp_file, &config, &language_manager);
+        let result = should_include_file(
+            &temp_file,
+            &config,
+            &PathPatterns::default(),
+            &language_manager,
+        );
         assert!(result.is_ok());
         assert!(matches!(result.unwrap(), IncludeResult::SkipLanguage));
 
@@ -668,23 +1773,337 @@ mod tests {
             max_file_size_bytes: u64::MAX,
             include_hidden: true,
             exclude_patterns: vec![],
+            include_patterns: vec![],
+            respect_gitignore: true,
+            nested_repos: NestedRepoMode::Include,
             target_languages: vec![],
             follow_symlinks: false,
             max_depth: None,
+            record_skipped: false,
         };
 
         // Test with a nonexistent file (will cause metadata() to fail)
         let nonexistent_file = PathBuf::from("/nonexistent/file/that/does/not/exist.rs");
 
-        let result = should_include_file(&nonexistent_file, &config, &language_manager);
+        let result = should_include_file(
+            &nonexistent_file,
+            &config,
+            &PathPatterns::default(),
+            &language_manager,
+        );
         assert!(result.is_ok());
         assert!(matches!(result.unwrap(), IncludeResult::SkipSize));
     }
 
+    #[test]
+    fn test_include_and_exclude_patterns() {
+        let test_dir = create_test_project();
+        let config = FilterConfig {
+            include_patterns: vec!["src/".to_string(), "*.py".to_string()],
+            exclude_patterns: vec!["lib.py".to_string()],
+            record_skipped: true,
+            ..Default::default()
+        };
+        let walker = FileWalker::with_config(LanguageManager::new(), config);
+
+        let (files, stats) = walker.discover_files(test_dir.path()).unwrap();
+
+        assert_eq!(files, vec![test_dir.path().join("src").join("module.rs")]);
+        assert_eq!(stats.files_skipped_pattern, 4);
+        assert!(stats
+            .skipped_files
+            .iter()
+            .any(|f| f.path.ends_with("lib.py") && f.reason == SkipReason::Excluded));
+    }
+
+    #[test]
+    fn test_invalid_include_pattern() {
+        let test_dir = create_test_project();
+        let config = FilterConfig {
+            include_patterns: vec!["src/[".to_string()],
+            ..Default::default()
+        };
+        let walker = FileWalker::with_config(LanguageManager::new(), config);
+
+        let err = walker.discover_files(test_dir.path()).unwrap_err();
+        assert!(err.to_string().contains("--include"));
+    }
+
+    #[test]
+    fn test_codeanalyzerignore_at_any_level() {
+        let test_dir = create_test_project();
+        fs::write(test_dir.path().join(IGNORE_FILE_NAME), "script.js\n").unwrap();
+        fs::write(test_dir.path().join("src").join(IGNORE_FILE_NAME), "*.rs\n").unwrap();
+        let walker = FileWalker::new(LanguageManager::new());
+
+        let (files, _) = walker.discover_files(test_dir.path()).unwrap();
+
+        let names: Vec<_> = files.iter().filter_map(|f| f.file_name()).collect();
+        assert_eq!(names, vec!["lib.py", "main.rs"]);
+    }
+
+    #[test]
+    fn test_hidden_ignored_and_generated_are_recorded() {
+        let test_dir = create_test_project();
+        let root = test_dir.path();
+        fs::create_dir(root.join(".cache")).unwrap();
+        fs::write(root.join(".cache").join("tmp.rs"), "fn tmp() {}").unwrap();
+        fs::write(root.join(IGNORE_FILE_NAME), "script.js\n").unwrap();
+        fs::write(root.join("api.pb.go"), "package api").unwrap();
+        fs::write(
+            root.join("src").join("table.rs"),
+            "// @generated by tablegen\npub fn table() {}",
+        )
+        .unwrap();
+        let config = FilterConfig {
+            record_skipped: true,
+            skip_generated: true,
+            ..Default::default()
+        };
+        let walker = FileWalker::with_config(LanguageManager::new(), config);
+
+        let (files, stats) = walker.discover_files(root).unwrap();
+        let names: Vec<_> = files.iter().filter_map(|f| f.file_name()).collect();
+        assert_eq!(names, vec!["lib.py", "main.rs", "module.rs"]);
+
+        let mut skipped: Vec<_> = stats
+            .skipped_files
+            .iter()
+            .map(|f| (f.path.strip_prefix(root).unwrap().to_path_buf(), f.reason))
+            .filter(|(_, reason)| *reason != SkipReason::Language)
+            .collect();
+        skipped.sort_by(|a, b| a.0.cmp(&b.0));
+        assert_eq!(
+            skipped,
+            vec![
+                (PathBuf::from(".cache"), SkipReason::Hidden),
+                (PathBuf::from(IGNORE_FILE_NAME), SkipReason::Hidden),
+                (PathBuf::from(".hidden"), SkipReason::Hidden),
+                (PathBuf::from("api.pb.go"), SkipReason::Generated),
+                (PathBuf::from("script.js"), SkipReason::Ignored),
+                (PathBuf::from("src/table.rs"), SkipReason::Generated),
+            ]
+        );
+        assert_eq!(stats.files_skipped_hidden, 3);
+        assert_eq!(stats.files_skipped_ignored, 1);
+        assert_eq!(stats.files_skipped_generated, 2);
+    }
+
+    #[test]
+    fn test_nested_repos_skip() {
+        let test_dir = create_test_project();
+        let vendor = test_dir.path().join("vendor");
+        fs::create_dir_all(&vendor).unwrap();
+        fs::write(vendor.join(".git"), "gitdir: ../.git/modules/vendor").unwrap();
+        fs::write(vendor.join("lib.rs"), "pub fn vendored() {}").unwrap();
+        let config = FilterConfig {
+            nested_repos: NestedRepoMode::Skip,
+            ..Default::default()
+        };
+        let walker = FileWalker::with_config(LanguageManager::new(), config);
+
+        let (files, stats) = walker.discover_files(test_dir.path()).unwrap();
+        assert!(!files.iter().any(|f| f.starts_with(&vendor)));
+        assert_eq!(stats.nested_repos_skipped, 1);
+        assert!(stats.skipped_files.is_empty());
+
+        let recording = FileWalker::with_config(
+            LanguageManager::new(),
+            FilterConfig {
+                nested_repos: NestedRepoMode::Skip,
+                record_skipped: true,
+                ..Default::default()
+            },
+        );
+        let (_, stats) = recording.discover_files(test_dir.path()).unwrap();
+        assert_eq!(stats.nested_repos_skipped, 1);
+        let nested: Vec<_> = stats
+            .skipped_files
+            .iter()
+            .filter(|f| f.reason == SkipReason::NestedRepo)
+            .map(|f| f.path.clone())
+            .collect();
+        assert_eq!(nested, vec![vendor.clone()]);
+
+        let explanation = walker
+            .explain_path(test_dir.path(), &vendor.join("lib.rs"))
+            .unwrap();
+        assert_eq!(
+            explanation.reason(),
+            Some("directory vendor is a git submodule (--nested-repos skip)")
+        );
+
+        let walker = FileWalker::new(LanguageManager::new());
+        let (files, _) = walker.discover_files(test_dir.path()).unwrap();
+        assert!(files.contains(&vendor.join("lib.rs")));
+    }
+
+    #[test]
+    fn test_walk_list() {
+        let dir = TempDir::new().unwrap();
+        let root = dir.path();
+        fs::create_dir_all(root.join("vendor")).unwrap();
+        fs::write(root.join("main.rs"), "fn main() {}").unwrap();
+        fs::write(root.join("notes.md"), "# Notes").unwrap();
+        fs::write(root.join("vendor/dep.rs"), "fn dep() {}").unwrap();
+
+        let config = FilterConfig {
+            exclude_patterns: vec!["vendor/".to_string()],
+            ..Default::default()
+        };
+        let walker = FileWalker::with_config(LanguageManager::new(), config);
+        let list = ["main.rs", "notes.md", "vendor/dep.rs", "missing.rs"]
+            .iter()
+            .map(|name| root.join(name));
+        let mut files = Vec::new();
+        let stats = walker
+            .walk_list_with_budget(list, &RunBudget::unlimited(), |path| files.push(path))
+            .unwrap();
+
+        assert_eq!(files, vec![root.join("main.rs")]);
+        assert_eq!(stats.total_entries_scanned, 4);
+        assert_eq!(stats.files_skipped_language, 1);
+        assert_eq!(stats.files_skipped_pattern, 1);
+        assert_eq!(stats.errors_encountered, 1);
+    }
+
+    #[test]
+    fn test_walk_archive() {
+        let dir = TempDir::new().unwrap();
+        let archive_path = dir.path().join("drop.tar.gz");
+        let encoder = flate2::write::GzEncoder::new(
+            fs::File::create(&archive_path).unwrap(),
+            flate2::Compression::default(),
+        );
+        let mut builder = tar::Builder::new(encoder);
+        for (name, contents) in [
+            ("pkg/src/main.rs", "fn main() {}"),
+            ("pkg/README.md", "# Drop"),
+            ("pkg/.github/ci.py", "print()"),
+            ("pkg/.env.py", "SECRET = 1"),
+            ("pkg/huge.py", "x = 1\n"),
+        ] {
+            let mut header = tar::Header::new_gnu();
+            header.set_size(contents.len() as u64);
+            header.set_mode(0o644);
+            header.set_cksum();
+            builder
+                .append_data(&mut header, name, contents.as_bytes())
+                .unwrap();
+        }
+        builder.into_inner().unwrap().finish().unwrap();
+
+        let config = FilterConfig {
+            max_file_size_bytes: 5,
+            record_skipped: true,
+            ..Default::default()
+        };
+        let walker = FileWalker::with_config(LanguageManager::new(), config);
+        let mut files = Vec::new();
+        let stats = walker
+            .walk_archive_with_budget(&archive_path, &RunBudget::unlimited(), |path, contents| {
+                files.push((path, contents))
+            })
+            .unwrap();
+
+        assert!(files.is_empty());
+        assert_eq!(stats.files_skipped_size, 2);
+        assert_eq!(stats.files_skipped_language, 1);
+        assert_eq!(stats.files_skipped_hidden, 1);
+        assert!(stats
+            .skipped_files
+            .iter()
+            .any(|f| f.path == Path::new("pkg/src/main.rs") && f.reason == SkipReason::Size));
+
+        let walker = FileWalker::new(LanguageManager::new());
+        let mut files = Vec::new();
+        walker
+            .walk_archive_with_budget(&archive_path, &RunBudget::unlimited(), |path, contents| {
+                files.push((path, contents))
+            })
+            .unwrap();
+        let paths: Vec<_> = files.iter().map(|(path, _)| path.as_path()).collect();
+        assert_eq!(
+            paths,
+            vec![Path::new("pkg/src/main.rs"), Path::new("pkg/huge.py")]
+        );
+        assert_eq!(files[0].1, b"fn main() {}");
+    }
+
+    #[test]
+    fn test_explain_path_analyzed() {
+        let test_dir = create_test_project();
+        let walker = FileWalker::new(LanguageManager::new());
+        let path = test_dir.path().join("src").join("module.rs");
+
+        let explanation = walker.explain_path(test_dir.path(), &path).unwrap();
+
+        assert!(explanation.analyzed, "{explanation}");
+        assert!(explanation.reason().is_none());
+        assert!(explanation
+            .checks
+            .iter()
+            .any(|c| c.detail == "language rust"));
+    }
+
+    #[test]
+    fn test_explain_path_reports_deciding_rule() {
+        let test_dir = create_test_project();
+        let root = test_dir.path();
+        fs::write(root.join("src").join(IGNORE_FILE_NAME), "module.rs\n").unwrap();
+        let config = FilterConfig {
+            max_depth: Some(1),
+            ..Default::default()
+        };
+        let walker = FileWalker::with_config(LanguageManager::new(), config);
+
+        let deep = walker
+            .explain_path(root, &root.join("src").join("module.rs"))
+            .unwrap();
+        assert!(!deep.analyzed);
+        assert_eq!(deep.reason(), Some("depth 2 exceeds --max-depth 1"));
+
+        let walker = FileWalker::new(LanguageManager::new());
+        let ignored = walker
+            .explain_path(root, &root.join("src").join("module.rs"))
+            .unwrap();
+        let reason = ignored.reason().unwrap();
+        assert!(reason.contains(IGNORE_FILE_NAME), "{reason}");
+        assert!(reason.contains("'module.rs'"), "{reason}");
+
+        let readme = walker.explain_path(root, &root.join("README.md")).unwrap();
+        assert_eq!(readme.reason(), Some("not a file of a supported language"));
+
+        let hidden = walker.explain_path(root, &root.join(".hidden")).unwrap();
+        assert_eq!(
+            hidden.reason(),
+            Some("file .hidden is hidden (use --include-hidden)")
+        );
+    }
+
+    #[test]
+    fn test_explain_path_outside_root() {
+        let test_dir = create_test_project();
+        let other = create_test_project();
+        let walker = FileWalker::new(LanguageManager::new());
+
+        let explanation = walker
+            .explain_path(&test_dir.path().join("src"), &other.path().join("main.rs"))
+            .unwrap();
+        assert!(!explanation.analyzed);
+        assert!(explanation.to_string().contains("not analyzed"));
+        assert!(walker
+            .explain_path(test_dir.path(), test_dir.path())
+            .is_err());
+    }
+
     #[test]
     fn test_include_result_variants() {
         // Test that all IncludeResult variants can be created
-        assert!(matches!(IncludeResult::Include, IncludeResult::Include));
+        assert!(matches!(
+            IncludeResult::Include(0),
+            IncludeResult::Include(_)
+        ));
         assert!(matches!(IncludeResult::SkipSize, IncludeResult::SkipSize));
         assert!(matches!(
             IncludeResult::SkipLanguage,
@@ -702,6 +2121,8 @@ mod tests {
         assert_eq!(config.max_file_size_bytes, 10 * 1024 * 1024); // 10 MB
         assert!(!config.include_hidden);
         assert!(config.exclude_patterns.is_empty());
+        assert!(config.include_patterns.is_empty());
+        assert!(config.respect_gitignore);
         assert!(config.target_languages.is_empty());
         assert!(!config.follow_symlinks);
         assert!(config.max_depth.is_none());
@@ -716,7 +2137,11 @@ mod tests {
             files_skipped_size: 5,
             files_skipped_language: 20,
             files_skipped_hidden: 15,
+            files_skipped_pattern: 0,
+            symlinks_skipped: 0,
+            nested_repos_skipped: 0,
             errors_encountered: 2,
+            skipped_files: Vec::new(),
         };
 
         let summary = stats.summary();
@@ -736,4 +2161,92 @@ mod tests {
         let walker_with_progress = walker.show_progress(true);
         assert!(walker_with_progress.show_progress);
     }
+
+    #[test]
+    fn test_walk_stats_merge() {
+        let mut total = WalkStats {
+            total_entries_scanned: 3,
+            files_found: 2,
+            ..Default::default()
+        };
+        total.merge(&WalkStats {
+            total_entries_scanned: 4,
+            files_found: 1,
+            errors_encountered: 1,
+            ..Default::default()
+        });
+
+        assert_eq!(total.total_entries_scanned, 7);
+        assert_eq!(total.files_found, 3);
+        assert_eq!(total.errors_encountered, 1);
+    }
+
+    #[test]
+    fn test_walk_files_streams_every_found_file() {
+        let test_dir = create_test_project();
+        let walker = FileWalker::new(LanguageManager::new());
+
+        let streamed = Mutex::new(Vec::new());
+        let stats = walker
+            .walk_files(test_dir.path(), |path| streamed.lock().unwrap().push(path))
+            .unwrap();
+
+        let streamed = streamed.into_inner().unwrap();
+        assert_eq!(streamed.len(), stats.files_found);
+        assert!(stats.directories_scanned >= 2);
+    }
+
+    #[test]
+    fn test_discover_files_is_sorted() {
+        let test_dir = create_test_project();
+        let walker = FileWalker::new(LanguageManager::new());
+
+        let (files, _) = walker.discover_files(test_dir.path()).unwrap();
+        let mut sorted = files.clone();
+        sorted.sort();
+        assert_eq!(files, sorted);
+    }
+
+    #[test]
+    fn test_walk_stops_at_max_files() {
+        use crate::analyzer::budget::{RunLimit, RunLimits};
+
+        let test_dir = create_test_project();
+        let walker = FileWalker::new(LanguageManager::new());
+        let budget = RunBudget::new(RunLimits {
+            max_files: Some(2),
+            ..Default::default()
+        });
+
+        let streamed = Mutex::new(Vec::new());
+        let stats = walker
+            .walk_files_with_budget(test_dir.path(), &budget, |path| {
+                streamed.lock().unwrap().push(path)
+            })
+            .unwrap();
+
+        assert_eq!(streamed.into_inner().unwrap().len(), 2);
+        assert_eq!(stats.files_found, 2);
+        assert_eq!(budget.reached().unwrap().limit, RunLimit::MaxFiles);
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_symlink_loop_is_skipped() {
+        let test_dir = create_test_project();
+        let root = test_dir.path();
+        std::os::unix::fs::symlink(root, root.join("src").join("loop")).unwrap();
+
+        let config = FilterConfig {
+            follow_symlinks: true,
+            ..Default::default()
+        };
+        let walker = FileWalker::with_config(LanguageManager::new(), config);
+        let (files, stats) = walker.discover_files(root).unwrap();
+
+        assert_eq!(stats.errors_encountered, 0);
+        assert_eq!(stats.symlinks_skipped, 1);
+        // Every file is found exactly once
+        assert_eq!(files.len(), 4);
+    }
 }
@@ -1,5 +1,5 @@
-use clap::{Parser, ValueEnum};
-use std::path::PathBuf;
+use clap::{Args, Parser, Subcommand, ValueEnum};
+use std::path::{Path, PathBuf};
 
 /// Color output mode
 #[derive(Debug, Clone, Copy, PartialEq, ValueEnum, Default)]
@@ -13,6 +13,136 @@ pub enum ColorMode {
     Never,
 }
 
+/// Minimum severity of log records to write
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, ValueEnum)]
+pub enum LogLevel {
+    /// No logging at all
+    Off,
+    Error,
+    Warn,
+    Info,
+    Debug,
+    Trace,
+}
+
+impl LogLevel {
+    /// Get the lowercase name of the level
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            LogLevel::Off => "off",
+            LogLevel::Error => "error",
+            LogLevel::Warn => "warn",
+            LogLevel::Info => "info",
+            LogLevel::Debug => "debug",
+            LogLevel::Trace => "trace",
+        }
+    }
+}
+
+/// Log record format
+#[derive(Debug, Clone, Copy, PartialEq, ValueEnum, Default)]
+pub enum LogFormat {
+    /// Human-readable lines
+    #[default]
+    Text,
+    /// One JSON object per line
+    Json,
+}
+
+/// File format of the exported call graph
+#[derive(Debug, Clone, Copy, PartialEq, ValueEnum)]
+pub enum CallGraphFormat {
+    /// Functions, edges, cycles and uncalled functions as JSON
+    Json,
+    /// Graphviz DOT
+    Dot,
+}
+
+impl CallGraphFormat {
+    /// Format implied by a file extension (`.dot`/`.gv` for DOT, JSON otherwise)
+    pub fn from_path(path: &std::path::Path) -> Self {
+        match path.extension().and_then(|ext| ext.to_str()) {
+            Some("dot" | "gv") => CallGraphFormat::Dot,
+            _ => CallGraphFormat::Json,
+        }
+    }
+}
+
+/// Handling of files whose parse error ratio exceeds the threshold
+#[derive(Debug, Clone, Copy, PartialEq, ValueEnum, Default)]
+pub enum LowConfidenceAction {
+    /// Report the file as-is, flagged as low confidence
+    #[default]
+    Keep,
+    /// Leave the file out of the report
+    Exclude,
+    /// Scale the complexity score down by the share of well-formed bytes
+    Downweight,
+}
+
+/// Attribution of closures, lambdas and other anonymous functions
+#[derive(Debug, Clone, Copy, PartialEq, Eq, ValueEnum, Default)]
+pub enum AnonymousFunctionMode {
+    /// Count their complexity in the enclosing function
+    #[default]
+    Inline,
+    /// Report them as scopes of their own, excluded from the enclosing function
+    Separate,
+}
+
+/// Reference tool whose cyclomatic complexity rules are followed
+#[derive(Debug, Clone, Copy, PartialEq, Eq, ValueEnum, Default)]
+pub enum ComplexityProfile {
+    /// Control flow nodes of each language plus `&&`/`||`
+    #[default]
+    Default,
+    /// radon (Python)
+    Radon,
+    /// gocyclo (Go)
+    Gocyclo,
+    /// lizard (all languages)
+    Lizard,
+    /// PMD's CyclomaticComplexity (Java)
+    Pmd,
+    /// ESLint's complexity rule (JavaScript/TypeScript)
+    Eslint,
+}
+
+/// Handling of git submodules and nested repositories found while walking
+#[derive(Debug, Clone, Copy, PartialEq, ValueEnum, Default)]
+pub enum NestedRepoMode {
+    /// Don't descend into them
+    Skip,
+    /// Analyze their files as part of the project
+    #[default]
+    Include,
+    /// Analyze their files, but summarize each repository as its own component
+    Separate,
+}
+
+/// Subcommands run instead of the default analysis
+#[derive(Debug, Clone, Subcommand)]
+pub enum Command {
+    /// Report public items removed, added or changed between two git revisions
+    ApiDiff(ApiDiffArgs),
+}
+
+/// Arguments of `code-analyzer api-diff`
+#[derive(Debug, Clone, Args)]
+pub struct ApiDiffArgs {
+    /// Revision with the API consumers rely on (e.g., v1.2.0)
+    #[arg(value_name = "REV_A")]
+    pub rev_a: String,
+
+    /// Revision to check against it (e.g., HEAD)
+    #[arg(value_name = "REV_B")]
+    pub rev_b: String,
+
+    /// Also write the diff as JSON
+    #[arg(long, value_name = "FILE", help = "Write the API diff as JSON to FILE")]
+    pub json: Option<PathBuf>,
+}
+
 /// CLI arguments for the code analyzer application
 #[derive(Parser)]
 #[command(name = "code-analyzer")]
@@ -22,9 +152,50 @@ pub enum ColorMode {
     long_about = "A powerful CLI tool that recursively analyzes directory trees, parsing source files with tree-sitter AST parsers, counting lines/functions/classes with language-specific accuracy, filtering files using .gitignore rules, and outputting both formatted terminal tables and structured JSON reports."
 )]
 pub struct CliArgs {
-    /// Directory to analyze (default: current directory)
-    #[arg(value_name = "PATH", help = "Path to the directory to analyze")]
-    pub path: Option<PathBuf>,
+    #[command(subcommand)]
+    pub command: Option<Command>,
+
+    /// Directories, files or source archive to analyze (default: current directory)
+    #[arg(
+        value_name = "PATH",
+        help = "Paths to the directories or files, or the source archive (.tar, .tar.gz, .tgz, .zip), to analyze"
+    )]
+    pub paths: Vec<PathBuf>,
+
+    /// Read the files to analyze from a list instead of walking PATH
+    #[arg(
+        long,
+        value_name = "FILE",
+        conflicts_with_all = ["stdin", "only_changed_since"],
+        help = "Analyze the files listed in FILE ('-' for stdin), one per line or NUL-separated (git ls-files -z, fd -0)"
+    )]
+    pub files_from: Option<PathBuf>,
+
+    /// Analyze a single source buffer read from stdin
+    #[arg(
+        long,
+        conflicts_with = "only_changed_since",
+        help = "Analyze source code read from stdin (needs --stdin-language or --stdin-filename)"
+    )]
+    pub stdin: bool,
+
+    /// Language of the source read with --stdin
+    #[arg(
+        long,
+        value_name = "LANG",
+        requires = "stdin",
+        help = "Language of the source read with --stdin (default: from --stdin-filename)"
+    )]
+    pub stdin_language: Option<String>,
+
+    /// Path reported for the source read with --stdin
+    #[arg(
+        long,
+        value_name = "PATH",
+        requires = "stdin",
+        help = "Report the source read with --stdin as PATH (its extension selects the language)"
+    )]
+    pub stdin_filename: Option<PathBuf>,
 
     /// Minimum lines of code to include in results
     #[arg(
@@ -46,10 +217,36 @@ pub struct CliArgs {
     #[arg(long, help = "Filter files with fewer than N classes")]
     pub min_classes: Option<usize>,
 
+    /// Keep only files where a named metric is at least the given value
+    #[arg(
+        long,
+        value_name = "NAME=VALUE",
+        value_delimiter = ',',
+        help = "Filter files whose metric NAME is below VALUE (any built-in or custom metric)"
+    )]
+    pub min_metric: Vec<String>,
+
+    /// Keep only files where a named metric is at most the given value
+    #[arg(
+        long,
+        value_name = "NAME=VALUE",
+        value_delimiter = ',',
+        help = "Filter files whose metric NAME is above VALUE (any built-in or custom metric)"
+    )]
+    pub max_metric: Vec<String>,
+
     /// Sort results by the specified criteria
     #[arg(long, value_enum, default_value_t = SortBy::Complexity, help = "Sort output by specified metric")]
     pub sort: SortBy,
 
+    /// Sort results by a named metric instead of --sort
+    #[arg(
+        long,
+        value_name = "NAME",
+        help = "Sort output by any built-in or custom metric name (descending)"
+    )]
+    pub sort_metric: Option<String>,
+
     /// Output format selection
     #[arg(long, value_enum, default_value_t = OutputFormat::Table, help = "Choose output format")]
     pub output: OutputFormat,
@@ -62,6 +259,31 @@ pub struct CliArgs {
     #[arg(short, long, help = "Show detailed progress and debug information")]
     pub verbose: bool,
 
+    /// Minimum log level
+    #[arg(
+        long,
+        value_enum,
+        help = "Log records at this level and above (default: warn; info with --ci, debug with --verbose)"
+    )]
+    pub log_level: Option<LogLevel>,
+
+    /// Log record format
+    #[arg(
+        long,
+        value_enum,
+        default_value_t = LogFormat::Text,
+        help = "Log format (text, json)"
+    )]
+    pub log_format: LogFormat,
+
+    /// Write log records to a file instead of stderr
+    #[arg(
+        long,
+        value_name = "FILE",
+        help = "Write log records to FILE instead of stderr"
+    )]
+    pub log_file: Option<PathBuf>,
+
     /// Languages to analyze (default: all supported languages)
     #[arg(
         long,
@@ -74,14 +296,56 @@ pub struct CliArgs {
     #[arg(
         long,
         value_delimiter = ',',
-        help = "Additional patterns to exclude from analysis"
+        help = "Additional patterns to exclude from analysis (gitignore syntax)"
     )]
     pub exclude: Vec<String>,
 
+    /// Only analyze files matching these patterns
+    #[arg(
+        long,
+        value_delimiter = ',',
+        value_name = "GLOB",
+        help = "Only analyze files matching one of these patterns (gitignore syntax, e.g. 'src/**/*.rs')"
+    )]
+    pub include: Vec<String>,
+
     /// Include hidden files in analysis
     #[arg(long, help = "Include hidden files and directories")]
     pub include_hidden: bool,
 
+    /// Follow symbolic links during traversal
+    #[arg(long, help = "Follow symbolic links to files and directories")]
+    pub follow_symlinks: bool,
+
+    /// Ignore .gitignore files
+    #[arg(
+        long,
+        help = "Don't honor .gitignore, .git/info/exclude or the global gitignore (.codeanalyzerignore still applies)"
+    )]
+    pub no_gitignore: bool,
+
+    /// Explain why a path is or isn't analyzed
+    #[arg(
+        long,
+        value_name = "FILE",
+        help = "Print why FILE is or isn't analyzed with the given filters, then exit"
+    )]
+    pub explain_path: Option<PathBuf>,
+
+    /// List skipped files in the report
+    #[arg(
+        long,
+        help = "Record every skipped file and the reason (size, language, hidden, ignored, generated, excluded, not_included, nested_repo) in the report"
+    )]
+    pub report_skipped: bool,
+
+    /// Leave out generated files
+    #[arg(
+        long,
+        help = "Skip generated files (protobuf and minified output, or a header marker such as @generated or DO NOT EDIT)"
+    )]
+    pub skip_generated: bool,
+
     /// Maximum file size to analyze (in MB)
     #[arg(
         long,
@@ -90,6 +354,142 @@ pub struct CliArgs {
     )]
     pub max_file_size_mb: usize,
 
+    /// Per-file tree-sitter parse timeout (in milliseconds)
+    #[arg(
+        long,
+        value_name = "MS",
+        default_value_t = 30_000,
+        help = "Stop parsing a file after MS milliseconds (0 = no limit)"
+    )]
+    pub parse_timeout_ms: u64,
+
+    /// Maximum number of AST nodes per file
+    #[arg(
+        long,
+        value_name = "COUNT",
+        default_value_t = 5_000_000,
+        help = "Skip AST metrics for files with more than COUNT syntax nodes (0 = no limit)"
+    )]
+    pub max_ast_nodes: usize,
+
+    /// Maximum AST depth visited when computing metrics
+    #[arg(
+        long,
+        value_name = "DEPTH",
+        default_value_t = 2_000,
+        help = "Do not count AST nodes nested deeper than DEPTH (0 = no limit)"
+    )]
+    pub max_ast_depth: usize,
+
+    /// Parse error ratio above which a file's metrics are low confidence
+    #[arg(
+        long,
+        value_name = "RATIO",
+        default_value_t = 0.1,
+        help = "Mark files with more than RATIO of their bytes in parse errors as low confidence"
+    )]
+    pub parse_error_threshold: f64,
+
+    /// Handling of low-confidence files
+    #[arg(
+        long,
+        value_enum,
+        default_value_t = LowConfidenceAction::Keep,
+        help = "What to do with low-confidence files (keep, exclude, downweight)"
+    )]
+    pub low_confidence: LowConfidenceAction,
+
+    /// Compute metrics only from error-free subtrees
+    #[arg(
+        long,
+        help = "Ignore functions and subtrees containing parse errors when computing metrics"
+    )]
+    pub error_free_metrics: bool,
+
+    /// Attribution of anonymous function bodies
+    #[arg(
+        long,
+        value_enum,
+        value_name = "MODE",
+        default_value_t = AnonymousFunctionMode::Inline,
+        help = "Count closures and lambdas in the enclosing function (inline) or as their own scopes (separate)"
+    )]
+    pub anonymous_functions: AnonymousFunctionMode,
+
+    /// Cyclomatic complexity counting rules
+    #[arg(
+        long,
+        value_enum,
+        value_name = "PROFILE",
+        default_value_t = ComplexityProfile::Default,
+        help = "Count decision points like a reference tool: radon (Python), gocyclo (Go), lizard, pmd (Java) or eslint (JS/TS)"
+    )]
+    pub complexity_profile: ComplexityProfile,
+
+    /// JSON file with source rewrite rules for grammar gaps
+    #[arg(
+        long,
+        value_name = "FILE",
+        help = "Load line-preserving source rewrite rules (literal or regex, per language) from a JSON file"
+    )]
+    pub rewrite_rules: Option<PathBuf>,
+
+    /// Maximum number of files to analyze
+    #[arg(
+        long,
+        value_name = "COUNT",
+        help = "Stop after analyzing COUNT files and report partial results"
+    )]
+    pub max_files: Option<usize>,
+
+    /// Maximum total size of analyzed files (in MB)
+    #[arg(
+        long,
+        value_name = "MB",
+        help = "Stop once analyzed files add up to MB megabytes and report partial results"
+    )]
+    pub max_total_mb: Option<u64>,
+
+    /// Maximum directory depth to traverse
+    #[arg(
+        long,
+        value_name = "DEPTH",
+        help = "Do not descend more than DEPTH directories below the target path"
+    )]
+    pub max_depth: Option<usize>,
+
+    /// Wall-clock budget for the whole run (in seconds)
+    #[arg(
+        long,
+        value_name = "SECS",
+        help = "Stop after SECS seconds and report partial results"
+    )]
+    pub time_budget_secs: Option<u64>,
+
+    /// Profile the analyzer itself
+    #[arg(
+        long,
+        help = "Report time per phase, parse throughput per language, slowest files and peak memory (stderr and JSON)"
+    )]
+    pub profile: bool,
+
+    /// Number of slowest files listed in the profile
+    #[arg(
+        long,
+        value_name = "N",
+        default_value_t = 10,
+        help = "Number of slowest files to list with --profile"
+    )]
+    pub profile_slowest: usize,
+
+    /// Deterministic report output
+    #[arg(
+        long,
+        conflicts_with = "profile",
+        help = "Byte-for-byte reproducible reports: relative paths, fixed timestamp (SOURCE_DATE_EPOCH or 0), stable ordering"
+    )]
+    pub reproducible: bool,
+
     /// Compact output mode (minimal output for CI/CD pipelines)
     #[arg(
         long,
@@ -101,6 +501,48 @@ pub struct CliArgs {
     #[arg(long, value_name = "FILE", help = "Custom path for JSON output file")]
     pub output_file: Option<PathBuf>,
 
+    /// Export the function call graph
+    #[arg(
+        long,
+        value_name = "FILE",
+        help = "Write the function call graph (fan-in/fan-out, recursion, uncalled functions) to FILE"
+    )]
+    pub call_graph: Option<PathBuf>,
+
+    /// Call graph file format
+    #[arg(
+        long,
+        value_enum,
+        value_name = "FORMAT",
+        requires = "call_graph",
+        help = "Call graph format: json or dot (default: from the --call-graph extension)"
+    )]
+    pub call_graph_format: Option<CallGraphFormat>,
+
+    /// Export the public API manifest
+    #[arg(
+        long,
+        value_name = "FILE",
+        help = "Write the public items and their signatures (API manifest) to FILE as JSON"
+    )]
+    pub api_manifest: Option<PathBuf>,
+
+    /// Export the package coupling metrics
+    #[arg(
+        long,
+        value_name = "FILE",
+        help = "Write coupling, instability, abstractness and distance per package (directory) to FILE as JSON"
+    )]
+    pub package_metrics: Option<PathBuf>,
+
+    /// JSON file declaring architecture layers and their allowed dependencies
+    #[arg(
+        long,
+        value_name = "FILE",
+        help = "Check imports against architecture layers (path globs) and dependency rules from a JSON file"
+    )]
+    pub layer_rules: Option<PathBuf>,
+
     /// Show only top N results in terminal output
     #[arg(
         long,
@@ -151,6 +593,45 @@ pub struct CliArgs {
     )]
     pub max_functions_per_file: Option<usize>,
 
+    /// Suggest blocks to extract from long or complex functions
+    #[arg(
+        long,
+        help = "Suggest statement blocks to extract from functions over --max-function-lines or --max-function-cc"
+    )]
+    pub suggest_extractions: bool,
+
+    /// Function length threshold for extract-method suggestions
+    #[arg(
+        long,
+        value_name = "LINES",
+        help = "Function length threshold for extract-method suggestions (default: 50)"
+    )]
+    pub max_function_lines: Option<usize>,
+
+    /// Function complexity threshold for extract-method suggestions
+    #[arg(
+        long,
+        value_name = "COMPLEXITY",
+        help = "Function cyclomatic complexity threshold for extract-method suggestions (default: 10)"
+    )]
+    pub max_function_cc: Option<usize>,
+
+    /// Suggest how to split files over --max-loc or --max-functions-per-file
+    #[arg(
+        long,
+        help = "Suggest how to split large files by clustering their functions and types"
+    )]
+    pub suggest_splits: bool,
+
+    /// Thresholds for named metrics
+    #[arg(
+        long,
+        value_name = "NAME=VALUE",
+        value_delimiter = ',',
+        help = "Flag files whose metric NAME reaches VALUE as refactoring candidates"
+    )]
+    pub metric_threshold: Vec<String>,
+
     // === Phase 2: Git Integration ===
     /// Only analyze files changed since the specified git commit
     #[arg(
@@ -160,11 +641,21 @@ pub struct CliArgs {
     )]
     pub only_changed_since: Option<String>,
 
+    /// How to treat git submodules and nested repositories
+    #[arg(
+        long,
+        value_enum,
+        value_name = "MODE",
+        default_value = "include",
+        help = "Git submodules and nested repositories: skip, include, or separate (own summary and git metadata)"
+    )]
+    pub nested_repos: NestedRepoMode,
+
     // === Phase 4: CI Mode ===
     /// CI mode: exit with code 2 if refactoring candidates found
     #[arg(
         long,
-        help = "CI mode: exit code 2 if refactoring candidates exceed threshold"
+        help = "CI mode: exit code 2 if refactoring candidates exceed threshold or imports break --layer-rules"
     )]
     pub ci: bool,
 
@@ -228,6 +719,8 @@ pub enum OutputFormat {
     /// CSV output format
     #[value(name = "csv")]
     Csv,
+    /// JSON outline of the types and functions of each file
+    Outline,
 }
 
 impl std::fmt::Display for OutputFormat {
@@ -239,6 +732,7 @@ impl std::fmt::Display for OutputFormat {
             OutputFormat::JsonFilesOnly => write!(f, "json-files-only"),
             OutputFormat::JsonSummaryOnly => write!(f, "json-summary-only"),
             OutputFormat::Csv => write!(f, "csv"),
+            OutputFormat::Outline => write!(f, "outline"),
         }
     }
 }
@@ -246,14 +740,62 @@ impl std::fmt::Display for OutputFormat {
 impl CliArgs {
     /// Validate CLI arguments and return meaningful errors
     pub fn validate(&self) -> Result<(), crate::error::AnalyzerError> {
-        // Validate path if provided
-        if let Some(ref path) = self.path {
+        // Validate paths if provided
+        for path in &self.paths {
             if !path.exists() {
                 return Err(crate::error::AnalyzerError::invalid_path(path));
             }
             // Allow both files and directories - walker.rs handles both
         }
 
+        // Only the walker can take several roots
+        if self.paths.len() > 1 {
+            let single_root_option = if self.files_from.is_some() {
+                Some("--files-from")
+            } else if self.stdin {
+                Some("--stdin")
+            } else if self.only_changed_since.is_some() {
+                Some("--only-changed-since")
+            } else if self.explain_path.is_some() {
+                Some("--explain-path")
+            } else if self.command.is_some() {
+                Some("api-diff")
+            } else {
+                None
+            };
+            if let Some(option) = single_root_option {
+                return Err(crate::error::AnalyzerError::validation_error(format!(
+                    "{option} takes a single PATH"
+                )));
+            }
+        }
+
+        // api-diff reads the sources of both revisions from git
+        if self.command.is_some() {
+            let input_option = if self.files_from.is_some() {
+                Some("--files-from")
+            } else if self.stdin {
+                Some("--stdin")
+            } else if self.only_changed_since.is_some() {
+                Some("--only-changed-since")
+            } else {
+                None
+            };
+            if let Some(option) = input_option {
+                return Err(crate::error::AnalyzerError::validation_error(format!(
+                    "{option} cannot be used with api-diff"
+                )));
+            }
+        }
+
+        // Validate explicit inputs
+        if let Some(ref list) = self.files_from {
+            if list != Path::new(crate::analyzer::input::STDIN_ARG) && !list.is_file() {
+                return Err(crate::error::AnalyzerError::invalid_path(list));
+            }
+        }
+        crate::analyzer::input::StdinSource::from_cli(self)?;
+
         // Validate min/max lines constraints
         if let Some(max_lines) = self.max_lines {
             if self.min_lines >= max_lines {
@@ -270,6 +812,25 @@ impl CliArgs {
             ));
         }
 
+        // Validate run limits
+        if self.max_files == Some(0) {
+            return Err(crate::error::AnalyzerError::validation_error(
+                "max-files must be greater than 0",
+            ));
+        }
+        if self.max_total_mb == Some(0) {
+            return Err(crate::error::AnalyzerError::validation_error(
+                "max-total-mb must be greater than 0",
+            ));
+        }
+
+        // Validate parse error threshold
+        if !(0.0..=1.0).contains(&self.parse_error_threshold) {
+            return Err(crate::error::AnalyzerError::validation_error(
+                "parse-error-threshold must be between 0.0 and 1.0",
+            ));
+        }
+
         // Validate limit
         if self.limit == 0 {
             return Err(crate::error::AnalyzerError::validation_error(
@@ -277,10 +838,28 @@ impl CliArgs {
             ));
         }
 
-        // Validate output file path if provided
-        if let Some(ref output_path) = self.output_file {
+        // Validate metric bounds
+        for specs in [&self.min_metric, &self.max_metric, &self.metric_threshold] {
+            crate::analyzer::metrics::parse_metric_bounds(specs)?;
+        }
+
+        // Validate output file paths if provided
+        let api_diff_json = match self.command {
+            Some(Command::ApiDiff(ref diff_args)) => diff_args.json.clone(),
+            None => None,
+        };
+        for output_path in [
+            &self.output_file,
+            &self.call_graph,
+            &self.api_manifest,
+            &self.package_metrics,
+            &api_diff_json,
+        ]
+        .into_iter()
+        .flatten()
+        {
             if let Some(parent) = output_path.parent() {
-                if !parent.exists() {
+                if !parent.as_os_str().is_empty() && !parent.exists() {
                     return Err(crate::error::AnalyzerError::validation_error(format!(
                         "Output directory does not exist: {}",
                         parent.display()
@@ -294,10 +873,16 @@ impl CliArgs {
 
     /// Get the target path for analysis (current directory if not specified)
     pub fn target_path(&self) -> PathBuf {
-        self.path
-            .as_ref()
-            .cloned()
-            .unwrap_or_else(|| std::env::current_dir().unwrap_or_else(|_| PathBuf::from(".")))
+        crate::analyzer::common_root(&self.target_paths())
+    }
+
+    /// Get the root paths to analyze (current directory if none are specified)
+    pub fn target_paths(&self) -> Vec<PathBuf> {
+        if self.paths.is_empty() {
+            vec![std::env::current_dir().unwrap_or_else(|_| PathBuf::from("."))]
+        } else {
+            self.paths.clone()
+        }
     }
 
     /// Get the output file path (default to refactor-candidates.json)
@@ -367,22 +952,76 @@ mod tests {
         assert_eq!(OutputFormat::Table.to_string(), "table");
         assert_eq!(OutputFormat::Json.to_string(), "json");
         assert_eq!(OutputFormat::Both.to_string(), "both");
+        assert_eq!(OutputFormat::Outline.to_string(), "outline");
     }
 
     #[test]
     fn test_target_path() {
         let args = CliArgs {
-            path: Some(PathBuf::from("/test/path")),
+            paths: vec![PathBuf::from("/test/path")],
             ..Default::default()
         };
         assert_eq!(args.target_path(), PathBuf::from("/test/path"));
 
         let args = CliArgs {
-            path: None,
+            paths: Vec::new(),
             ..Default::default()
         };
         // Should return current directory or "." fallback
         assert!(!args.target_path().as_os_str().is_empty());
+
+        let args = CliArgs {
+            paths: vec![PathBuf::from("services/a"), PathBuf::from("services/b")],
+            ..Default::default()
+        };
+        assert_eq!(args.target_path(), PathBuf::from("services"));
+        assert_eq!(args.target_paths().len(), 2);
+    }
+
+    #[test]
+    fn test_validate_multiple_roots() {
+        let args = CliArgs {
+            paths: vec![PathBuf::from("."), PathBuf::from("src")],
+            ..Default::default()
+        };
+        assert!(args.validate().is_ok());
+
+        let args = CliArgs {
+            only_changed_since: Some("HEAD".to_string()),
+            ..args
+        };
+        let err = args.validate().unwrap_err();
+        assert!(err
+            .to_string()
+            .contains("--only-changed-since takes a single PATH"));
+    }
+
+    #[test]
+    fn test_api_diff_subcommand() {
+        let args = CliArgs::parse_from([
+            "code-analyzer",
+            "--languages",
+            "rust",
+            "api-diff",
+            "v1.0",
+            "HEAD",
+        ]);
+        let Some(Command::ApiDiff(ref diff_args)) = args.command else {
+            panic!("api-diff should parse as a subcommand");
+        };
+        assert_eq!(diff_args.rev_a, "v1.0");
+        assert_eq!(diff_args.rev_b, "HEAD");
+        assert_eq!(args.languages, vec!["rust"]);
+        assert!(args.paths.is_empty());
+
+        let args = CliArgs {
+            stdin: true,
+            ..args
+        };
+        let err = args.validate().unwrap_err();
+        assert!(err
+            .to_string()
+            .contains("--stdin cannot be used with api-diff"));
     }
 
     #[test]
@@ -441,7 +1080,7 @@ mod tests {
     #[test]
     fn test_validate_valid_args() {
         let args = CliArgs {
-            path: Some(PathBuf::from(".")), // Current directory exists
+            paths: vec![PathBuf::from(".")], // Current directory exists
             min_lines: 1,
             max_lines: Some(1000),
             max_file_size_mb: 10,
@@ -453,7 +1092,7 @@ mod tests {
     #[test]
     fn test_validate_nonexistent_path() {
         let args = CliArgs {
-            path: Some(PathBuf::from("/nonexistent/path")),
+            paths: vec![PathBuf::from("/nonexistent/path")],
             ..Default::default()
         };
         let result = args.validate();
@@ -468,7 +1107,7 @@ mod tests {
         std::fs::write(&temp_file, "test").unwrap();
 
         let args = CliArgs {
-            path: Some(temp_file.clone()),
+            paths: vec![temp_file.clone()],
             ..Default::default()
         };
         let result = args.validate();
@@ -523,11 +1162,32 @@ mod tests {
             .contains("max-file-size-mb must be greater than 0"));
     }
 
+    #[test]
+    fn test_validate_metric_bounds() {
+        let args = CliArgs::parse_from([
+            "code-analyzer",
+            "--min-metric",
+            "fan_out=2",
+            "--metric-threshold",
+            "fan_out=10,max_nesting_depth=6",
+        ]);
+        assert_eq!(args.metric_threshold.len(), 2);
+        assert!(args.validate().is_ok());
+
+        let args = CliArgs {
+            max_metric: vec!["fan_out".to_string()],
+            ..Default::default()
+        };
+        let result = args.validate();
+        assert!(result.is_err());
+        assert!(result.unwrap_err().to_string().contains("NAME=VALUE"));
+    }
+
     #[test]
     fn test_validate_with_none_path() {
-        // None path should be valid (defaults to current directory)
+        // No path should be valid (defaults to current directory)
         let args = CliArgs {
-            path: None,
+            paths: Vec::new(),
             ..Default::default()
         };
         assert!(args.validate().is_ok());
@@ -549,21 +1209,59 @@ mod tests {
 impl Default for CliArgs {
     fn default() -> Self {
         Self {
-            path: None,
+            command: None,
+            paths: Vec::new(),
+            files_from: None,
+            stdin: false,
+            stdin_language: None,
+            stdin_filename: None,
             min_lines: 1,
             max_lines: None,
             min_functions: None,
             min_classes: None,
+            min_metric: Vec::new(),
+            max_metric: Vec::new(),
             sort: SortBy::Complexity,
+            sort_metric: None,
             output: OutputFormat::Table,
             json_only: false,
             verbose: false,
             languages: Vec::new(),
             exclude: Vec::new(),
+            include: Vec::new(),
             include_hidden: false,
+            follow_symlinks: false,
+            no_gitignore: false,
+            explain_path: None,
+            report_skipped: false,
+            skip_generated: false,
             max_file_size_mb: 10,
+            parse_timeout_ms: 30_000,
+            max_ast_nodes: 5_000_000,
+            max_ast_depth: 2_000,
+            parse_error_threshold: 0.1,
+            low_confidence: LowConfidenceAction::Keep,
+            error_free_metrics: false,
+            anonymous_functions: AnonymousFunctionMode::Inline,
+            complexity_profile: ComplexityProfile::Default,
+            rewrite_rules: None,
+            profile: false,
+            profile_slowest: 10,
+            reproducible: false,
+            log_level: None,
+            log_format: LogFormat::Text,
+            log_file: None,
+            max_files: None,
+            max_total_mb: None,
+            max_depth: None,
+            time_budget_secs: None,
             compact: false,
             output_file: None,
+            call_graph: None,
+            call_graph_format: None,
+            api_manifest: None,
+            package_metrics: None,
+            layer_rules: None,
             limit: 10,
             color: ColorMode::Auto,
             // Phase 1: Custom thresholds (None = use defaults)
@@ -571,8 +1269,14 @@ impl Default for CliArgs {
             max_cc: None,
             max_loc: None,
             max_functions_per_file: None,
+            suggest_extractions: false,
+            max_function_lines: None,
+            max_function_cc: None,
+            suggest_splits: false,
+            metric_threshold: Vec::new(),
             // Phase 2: Git integration
             only_changed_since: None,
+            nested_repos: NestedRepoMode::Include,
             // Phase 4: CI mode
             ci: false,
             ci_max_candidates: 0,
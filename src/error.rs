@@ -35,6 +35,8 @@ pub enum WarningType {
     PartialParse,
     /// File encoding issues
     EncodingError,
+    /// A per-file resource limit was hit; metrics are partial or skipped
+    ResourceLimit,
 }
 
 impl ParseWarning {
@@ -67,6 +69,16 @@ impl ParseWarning {
             locations: Vec::new(),
         }
     }
+
+    /// Create a resource limit warning
+    pub fn resource_limit<P: Into<PathBuf>, S: Into<String>>(path: P, message: S) -> Self {
+        Self {
+            file_path: path.into(),
+            warning_type: WarningType::ResourceLimit,
+            message: message.into(),
+            locations: Vec::new(),
+        }
+    }
 }
 
 impl fmt::Display for ParseWarning {
@@ -80,6 +92,7 @@ impl fmt::Display for ParseWarning {
                 WarningType::SyntaxError => "syntax error",
                 WarningType::PartialParse => "partial parse",
                 WarningType::EncodingError => "encoding error",
+                WarningType::ResourceLimit => "resource limit",
             }
         )
     }
@@ -184,6 +197,23 @@ pub type Result<T> = std::result::Result<T, AnalyzerError>;
 
 /// Helper functions for creating common errors
 impl AnalyzerError {
+    /// Short machine-readable name of the error variant
+    pub fn kind(&self) -> &'static str {
+        match self {
+            AnalyzerError::Io(_) => "io",
+            AnalyzerError::Parse(_) => "parse",
+            AnalyzerError::InvalidPath(_) => "invalid_path",
+            AnalyzerError::UnsupportedLanguage(_) => "unsupported_language",
+            AnalyzerError::ConfigError(_) => "config",
+            AnalyzerError::TreeSitter(_) => "tree_sitter",
+            AnalyzerError::Json(_) => "json",
+            AnalyzerError::Walk(_) => "walk",
+            AnalyzerError::Csv(_) => "csv",
+            AnalyzerError::Progress(_) => "progress",
+            AnalyzerError::Validation(_) => "validation",
+        }
+    }
+
     /// Create a parse error with context
     pub fn parse_error<S: Into<String>>(msg: S) -> Self {
         AnalyzerError::Parse(msg.into())
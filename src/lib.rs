@@ -9,7 +9,7 @@
 //! use code_analyzer::{run_analysis, CliArgs};
 //!
 //! let args = CliArgs {
-//!     path: Some("./my-project".into()),
+//!     paths: vec!["./my-project".into()],
 //!     verbose: true,
 //!     ..Default::default()
 //! };
@@ -32,15 +32,19 @@ use std::path::Path;
 pub mod analyzer;
 pub mod cli;
 pub mod error;
+pub mod logging;
 pub mod output;
 
 // Re-export main types for convenience
 pub use analyzer::{
     analyze_project_simple, identify_refactoring_candidates, AnalysisReport, AnalyzerEngine,
-    FileAnalysis, LanguageManager, ProjectSummary, RefactoringCandidate, RefactoringReason,
-    RefactoringThresholds, SupportedLanguage,
+    ApiDiff, ApiManifest, FileAnalysis, LanguageManager, LayerRules, LayerViolation,
+    PackageMetrics, PathExplanation, ProjectSummary, RefactoringCandidate, RefactoringReason,
+    RefactoringThresholds, SplitSuggestion, SupportedLanguage,
+};
+pub use cli::{
+    ApiDiffArgs, CliArgs, ColorMode, Command, LogFormat, LogLevel, OutputFormat, SortBy,
 };
-pub use cli::{CliArgs, ColorMode, OutputFormat, SortBy};
 pub use error::{AnalyzerError, Result};
 pub use output::{
     display_analysis_results, export_analysis_json, generate_dual_output, JsonExporter,
@@ -49,30 +53,27 @@ pub use output::{
 
 /// Internal helper that performs core analysis logic shared by public functions
 fn execute_analysis_core(args: &CliArgs) -> Result<AnalysisReport> {
+    logging::init_from_cli(args)?;
     args.validate()?;
 
-    if args.verbose {
-        println!("Starting code analysis...");
-        println!("Target: {}", args.target_path().display());
-
-        if !args.languages.is_empty() {
-            println!("Languages: {}", args.languages.join(", "));
-        }
-
-        println!("Min lines: {}", args.min_lines);
-        if let Some(max_lines) = args.max_lines {
-            println!("Max lines: {max_lines}");
-        }
-
-        println!("Output format: {}", args.output);
-        println!();
-    }
+    log_info!(
+        "Starting code analysis",
+        target = args.target_path().display(),
+        languages = if args.languages.is_empty() {
+            "all".to_string()
+        } else {
+            args.languages.join(",")
+        },
+        min_lines = args.min_lines,
+        max_lines = args.max_lines.map_or("none".to_string(), |n| n.to_string()),
+        output = args.output,
+    );
 
     // Create and configure analyzer engine
     let mut analyzer = AnalyzerEngine::from_cli_args(args)?;
 
     // Run the analysis
-    analyzer.analyze_project(args.target_path(), args)
+    analyzer.analyze_roots(&args.target_paths(), args)
 }
 
 /// Main entry point for running code analysis
@@ -98,7 +99,7 @@ fn execute_analysis_core(args: &CliArgs) -> Result<AnalysisReport> {
 /// use code_analyzer::{run_analysis, CliArgs, OutputFormat};
 ///
 /// let args = CliArgs {
-///     path: Some("./src".into()),
+///     paths: vec!["./src".into()],
 ///     output: OutputFormat::Both,
 ///     min_lines: 10,
 ///     verbose: true,
@@ -116,27 +117,103 @@ pub fn run_analysis(args: CliArgs) -> Result<()> {
 /// This function performs the full analysis workflow and returns the report,
 /// allowing callers to inspect results for CI/CD integration.
 pub fn run_analysis_returning_report(args: CliArgs) -> Result<AnalysisReport> {
-    let report = execute_analysis_core(&args)?;
+    let mut report = execute_analysis_core(&args)?;
+    let output_started = std::time::Instant::now();
+    let output_span = log_span!(LogLevel::Debug, "output", format = args.output);
 
     if args.compact {
-        output::display_compact_table(&report.files, args.sort, args.limit);
+        output::display_compact_table(
+            &report.files,
+            args.sort,
+            args.sort_metric.as_deref(),
+            args.limit,
+        );
     } else {
         let output_manager = OutputManager::from_cli_args(&args);
         output_manager.generate_output(&report, &args)?;
     }
 
-    if args.verbose {
-        println!();
-        println!("Analysis completed successfully!");
-        println!("Files analyzed: {}", report.files.len());
-        println!("Total lines: {}", report.summary.total_lines);
-        println!("Total functions: {}", report.summary.total_functions);
-        println!("Total classes: {}", report.summary.total_classes);
+    drop(output_span);
+
+    if let Some(ref mut profile) = report.profile {
+        profile.record_phase(analyzer::Phase::Output, output_started.elapsed());
+        profile.print();
     }
 
+    log_info!(
+        "Analysis completed",
+        files = report.files.len(),
+        total_lines = report.summary.total_lines,
+        total_functions = report.summary.total_functions,
+        total_classes = report.summary.total_classes,
+    );
+
     Ok(report)
 }
 
+/// Explain why a file is or isn't analyzed with the given arguments (`--explain-path`)
+pub fn explain_path(args: &CliArgs, path: &Path) -> Result<PathExplanation> {
+    logging::init_from_cli(args)?;
+    args.validate()?;
+
+    let engine = AnalyzerEngine::from_cli_args(args)?;
+    engine.explain_path(&args.target_path(), path)
+}
+
+/// Compare the public API of two git revisions (`code-analyzer api-diff`)
+///
+/// Both revisions are exported from the repository containing the target
+/// path and analyzed with the same filters as a regular run. The diff is
+/// displayed unless `--json-only` is set, and written to `--json` if given.
+pub fn run_api_diff(args: &CliArgs, diff_args: &ApiDiffArgs) -> Result<ApiDiff> {
+    logging::init_from_cli(args)?;
+    args.validate()?;
+
+    let target = args.target_path();
+    let before = api_manifest_at(args, &target, &diff_args.rev_a)?;
+    let after = api_manifest_at(args, &target, &diff_args.rev_b)?;
+    let diff = before.diff(&after);
+
+    log_info!(
+        "API diff computed",
+        removed = diff.removed.len(),
+        changed = diff.changed.len(),
+        added = diff.added.len(),
+    );
+
+    if let Some(ref path) = diff_args.json {
+        std::fs::write(path, serde_json::to_string_pretty(&diff)?)?;
+        log_info!("API diff saved", path = path.display());
+    }
+    if !args.json_only {
+        TerminalReporter::new()
+            .color_enabled(args.should_use_colors())
+            .display_api_diff(&diff, &diff_args.rev_a, &diff_args.rev_b);
+    }
+
+    Ok(diff)
+}
+
+/// Public API manifest of `revision`, analyzed from a temporary `git archive`
+fn api_manifest_at(args: &CliArgs, target: &Path, revision: &str) -> Result<ApiManifest> {
+    let _span = log_span!(LogLevel::Debug, "api_manifest", revision = revision);
+    // A private directory, so nothing placed in the shared temp dir beforehand is written through
+    let dir = tempfile::Builder::new()
+        .prefix("code-analyzer-api-")
+        .tempdir()?;
+    let archive = dir.path().join(format!(
+        "{}.tar",
+        revision.replace(|c: char| !c.is_ascii_alphanumeric(), "_")
+    ));
+
+    analyzer::git::export_revision(target, revision, &archive)?;
+    let mut analyzer = AnalyzerEngine::from_cli_args(args)?;
+    analyzer.record_public_api();
+    let report = analyzer.analyze_project(&archive, args)?;
+
+    Ok(ApiManifest::from_files(&report.files))
+}
+
 /// Run analysis with custom configuration
 ///
 /// This function provides a more flexible interface for programmatic use,
@@ -157,7 +234,7 @@ pub fn run_analysis_with_config<P: AsRef<Path>>(
 ) -> Result<AnalysisReport> {
     // Convert config to CLI args for compatibility
     let cli_args = CliArgs {
-        path: Some(target_path.as_ref().to_path_buf()),
+        paths: vec![target_path.as_ref().to_path_buf()],
         languages: config.languages.clone(),
         min_lines: config.min_lines,
         max_lines: config.max_lines,
@@ -454,7 +531,7 @@ mod tests {
     fn test_run_analysis_with_verbose_and_max_lines() {
         let temp_dir = create_test_project();
         let args = CliArgs {
-            path: Some(temp_dir.path().to_path_buf()),
+            paths: vec![temp_dir.path().to_path_buf()],
             min_lines: 1,
             max_lines: Some(1000), // Set max_lines to trigger the uncovered line
             verbose: true,         // Set verbose to trigger the uncovered line
@@ -0,0 +1,325 @@
+//! Leveled, structured logging (`--log-level`, `--log-format`, `--log-file`).
+//!
+//! Records go to stderr or a file, never to stdout, so report output stays
+//! machine-readable. The logger is process-wide and the first `init` wins;
+//! before that, warnings and errors are written to stderr as text.
+//!
+//! Spans (`log_span!`) mark a phase or a file: they log when they start and
+//! finish, and every record logged inside them carries the span path.
+
+use std::cell::RefCell;
+use std::fmt::{Display, Write as _};
+use std::fs::File;
+use std::io::{self, Write};
+use std::marker::PhantomData;
+use std::path::Path;
+use std::sync::{Mutex, OnceLock};
+use std::time::Instant;
+
+use chrono::{SecondsFormat, Utc};
+
+use crate::cli::{CliArgs, LogFormat, LogLevel};
+use crate::error::{AnalyzerError, Result};
+
+/// Log a record at `level` with an optional list of `key = value` fields
+#[macro_export]
+macro_rules! log_event {
+    ($level:expr, $message:expr $(, $key:ident = $value:expr)* $(,)?) => {
+        if $crate::logging::enabled($level) {
+            $crate::logging::log(
+                $level,
+                &$message,
+                &[$((stringify!($key), &$value as &dyn ::std::fmt::Display)),*],
+            );
+        }
+    };
+}
+
+/// Log an error record
+#[macro_export]
+macro_rules! log_error {
+    ($($args:tt)*) => { $crate::log_event!($crate::cli::LogLevel::Error, $($args)*) };
+}
+
+/// Log a warning record
+#[macro_export]
+macro_rules! log_warn {
+    ($($args:tt)*) => { $crate::log_event!($crate::cli::LogLevel::Warn, $($args)*) };
+}
+
+/// Log an informational record
+#[macro_export]
+macro_rules! log_info {
+    ($($args:tt)*) => { $crate::log_event!($crate::cli::LogLevel::Info, $($args)*) };
+}
+
+/// Log a debug record
+#[macro_export]
+macro_rules! log_debug {
+    ($($args:tt)*) => { $crate::log_event!($crate::cli::LogLevel::Debug, $($args)*) };
+}
+
+/// Log a trace record
+#[macro_export]
+macro_rules! log_trace {
+    ($($args:tt)*) => { $crate::log_event!($crate::cli::LogLevel::Trace, $($args)*) };
+}
+
+/// Open a span at `level`; it is closed when the returned guard is dropped
+#[macro_export]
+macro_rules! log_span {
+    ($level:expr, $name:expr $(, $key:ident = $value:expr)* $(,)?) => {
+        $crate::logging::Span::enter(
+            $level,
+            $name,
+            &[$((stringify!($key), &$value as &dyn ::std::fmt::Display)),*],
+        )
+    };
+}
+
+/// Process-wide log configuration and sink
+struct Logger {
+    level: LogLevel,
+    format: LogFormat,
+    sink: Mutex<Box<dyn Write + Send>>,
+}
+
+static LOGGER: OnceLock<Logger> = OnceLock::new();
+
+thread_local! {
+    /// Names of the spans open on this thread, outermost first
+    static SPANS: RefCell<Vec<&'static str>> = const { RefCell::new(Vec::new()) };
+}
+
+fn logger() -> &'static Logger {
+    LOGGER.get_or_init(|| Logger {
+        level: LogLevel::Warn,
+        format: LogFormat::Text,
+        sink: Mutex::new(Box::new(io::stderr())),
+    })
+}
+
+/// Configure the process-wide logger (later calls have no effect)
+pub fn init(level: LogLevel, format: LogFormat, file: Option<&Path>) -> Result<()> {
+    if LOGGER.get().is_some() {
+        return Ok(());
+    }
+
+    let sink: Box<dyn Write + Send> = match file {
+        Some(path) => Box::new(File::create(path).map_err(|e| {
+            AnalyzerError::config_error(format!("Cannot open log file {}: {e}", path.display()))
+        })?),
+        None => Box::new(io::stderr()),
+    };
+
+    let _ = LOGGER.set(Logger {
+        level,
+        format,
+        sink: Mutex::new(sink),
+    });
+    Ok(())
+}
+
+/// Configure the logger from CLI arguments
+///
+/// Without `--log-level`, `--verbose` logs at debug level and CI mode at info
+/// level (so the CI verdict is visible); otherwise only warnings are logged.
+pub fn init_from_cli(args: &CliArgs) -> Result<()> {
+    let level = args.log_level.unwrap_or(if args.verbose {
+        LogLevel::Debug
+    } else if args.ci {
+        LogLevel::Info
+    } else {
+        LogLevel::Warn
+    });
+    init(level, args.log_format, args.log_file.as_deref())
+}
+
+/// Check if records at `level` are written
+pub fn enabled(level: LogLevel) -> bool {
+    level != LogLevel::Off && level <= logger().level
+}
+
+/// Write a record (use the `log_*!` macros instead of calling this directly)
+pub fn log(level: LogLevel, message: &str, fields: &[(&str, &dyn Display)]) {
+    let logger = logger();
+    if level == LogLevel::Off || level > logger.level {
+        return;
+    }
+
+    let timestamp = Utc::now().to_rfc3339_opts(SecondsFormat::Millis, true);
+    let span = SPANS.with(|spans| spans.borrow().join("."));
+    let record = format_record(logger.format, &timestamp, level, &span, message, fields);
+
+    // A poisoned sink only means another thread panicked mid-write
+    let mut sink = logger.sink.lock().unwrap_or_else(|e| e.into_inner());
+    let _ = writeln!(sink, "{record}");
+}
+
+/// Render one log record in the given format
+fn format_record(
+    format: LogFormat,
+    timestamp: &str,
+    level: LogLevel,
+    span: &str,
+    message: &str,
+    fields: &[(&str, &dyn Display)],
+) -> String {
+    match format {
+        LogFormat::Text => {
+            let mut line = format!("{timestamp} {:>5} ", level.as_str().to_uppercase());
+            if !span.is_empty() {
+                let _ = write!(line, "{span}: ");
+            }
+            line.push_str(message);
+            for (key, value) in fields {
+                let _ = write!(line, " {key}={value}");
+            }
+            line
+        }
+        LogFormat::Json => {
+            let mut record = serde_json::Map::new();
+            record.insert("timestamp".into(), timestamp.into());
+            record.insert("level".into(), level.as_str().into());
+            if !span.is_empty() {
+                record.insert("span".into(), span.into());
+            }
+            record.insert("message".into(), message.into());
+            for (key, value) in fields {
+                record.insert((*key).into(), value.to_string().into());
+            }
+            serde_json::Value::Object(record).to_string()
+        }
+    }
+}
+
+/// Guard for an open span (see `log_span!`)
+#[must_use = "a span is closed as soon as its guard is dropped"]
+pub struct Span {
+    level: LogLevel,
+    name: &'static str,
+    fields: Vec<(&'static str, String)>,
+    started: Instant,
+    /// Spans live on the thread-local stack of the thread that opened them
+    _not_send: PhantomData<*const ()>,
+}
+
+impl Span {
+    /// Open a span (use `log_span!` instead of calling this directly)
+    pub fn enter(
+        level: LogLevel,
+        name: &'static str,
+        fields: &[(&'static str, &dyn Display)],
+    ) -> Self {
+        SPANS.with(|spans| spans.borrow_mut().push(name));
+        let span = Self {
+            level,
+            name,
+            // Fields are only rendered if the span is logged at all
+            fields: if enabled(level) {
+                fields
+                    .iter()
+                    .map(|(key, value)| (*key, value.to_string()))
+                    .collect()
+            } else {
+                Vec::new()
+            },
+            started: Instant::now(),
+            _not_send: PhantomData,
+        };
+        span.log("started", None);
+        span
+    }
+
+    fn log(&self, message: &str, elapsed_ms: Option<f64>) {
+        if !enabled(self.level) {
+            return;
+        }
+
+        let elapsed = elapsed_ms.map(|ms| format!("{ms:.3}"));
+        let mut fields: Vec<(&str, &dyn Display)> = self
+            .fields
+            .iter()
+            .map(|(key, value)| (*key, value as &dyn Display))
+            .collect();
+        if let Some(ref elapsed) = elapsed {
+            fields.push(("elapsed_ms", elapsed));
+        }
+        log(self.level, message, &fields);
+    }
+}
+
+impl Drop for Span {
+    fn drop(&mut self) {
+        self.log(
+            "finished",
+            Some(self.started.elapsed().as_secs_f64() * 1000.0),
+        );
+        SPANS.with(|spans| {
+            let mut spans = spans.borrow_mut();
+            debug_assert_eq!(spans.last(), Some(&self.name), "spans closed out of order");
+            spans.pop();
+        });
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_level_order() {
+        assert!(LogLevel::Error < LogLevel::Warn);
+        assert!(LogLevel::Debug < LogLevel::Trace);
+        assert!(LogLevel::Off < LogLevel::Error);
+    }
+
+    #[test]
+    fn test_format_text_record() {
+        let files = 3;
+        let record = format_record(
+            LogFormat::Text,
+            "2024-01-01T00:00:00.000Z",
+            LogLevel::Info,
+            "analyze.walk",
+            "Discovered files",
+            &[("files", &files), ("root", &"src")],
+        );
+        assert_eq!(
+            record,
+            "2024-01-01T00:00:00.000Z  INFO analyze.walk: Discovered files files=3 root=src"
+        );
+    }
+
+    #[test]
+    fn test_format_json_record() {
+        let record = format_record(
+            LogFormat::Json,
+            "2024-01-01T00:00:00.000Z",
+            LogLevel::Warn,
+            "",
+            "Walk error",
+            &[("error", &"permission denied")],
+        );
+        let value: serde_json::Value = serde_json::from_str(&record).unwrap();
+
+        assert_eq!(value["level"], "warn");
+        assert_eq!(value["message"], "Walk error");
+        assert_eq!(value["error"], "permission denied");
+        assert!(value.get("span").is_none());
+    }
+
+    #[test]
+    fn test_spans_nest_per_thread() {
+        let current = || SPANS.with(|spans| spans.borrow().join("."));
+        {
+            let _outer = log_span!(LogLevel::Trace, "outer");
+            {
+                let _inner = log_span!(LogLevel::Trace, "inner", file = "a.rs");
+                assert_eq!(current(), "outer.inner");
+            }
+            assert_eq!(current(), "outer");
+        }
+        assert_eq!(current(), "");
+    }
+}
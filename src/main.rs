@@ -1,7 +1,7 @@
 use clap::Parser;
 use code_analyzer::{
-    identify_refactoring_candidates, run_analysis, run_analysis_returning_report, CliArgs,
-    RefactoringThresholds,
+    explain_path, identify_refactoring_candidates, log_error, log_info, run_analysis,
+    run_analysis_returning_report, run_api_diff, CliArgs, Command, RefactoringThresholds,
 };
 use std::process;
 
@@ -9,24 +9,51 @@ use std::process;
 const EXIT_SUCCESS: i32 = 0;
 const EXIT_ERROR: i32 = 1;
 const EXIT_CANDIDATES_EXCEEDED: i32 = 2;
+const EXIT_BREAKING_API_CHANGES: i32 = 2;
+const EXIT_LAYER_VIOLATIONS: i32 = 2;
 
 fn main() {
     // Parse command line arguments
     let args = CliArgs::parse();
 
+    // Compare the public API of two revisions instead of analyzing
+    if let Some(Command::ApiDiff(ref diff_args)) = args.command {
+        match run_api_diff(&args, diff_args) {
+            Ok(diff) if diff.is_breaking() => process::exit(EXIT_BREAKING_API_CHANGES),
+            Ok(_) => {}
+            Err(error) => {
+                log_error!("API diff failed", error = error);
+                process::exit(EXIT_ERROR);
+            }
+        }
+        return;
+    }
+
+    // Explain a single path instead of analyzing
+    if let Some(ref path) = args.explain_path {
+        match explain_path(&args, path) {
+            Ok(explanation) => print!("{explanation}"),
+            Err(error) => {
+                log_error!("Cannot explain path", error = error);
+                process::exit(EXIT_ERROR);
+            }
+        }
+        return;
+    }
+
     // Check if CI mode is enabled
     if args.ci {
         run_ci_mode(args);
     } else {
         // Normal mode
         if let Err(error) = run_analysis(args) {
-            eprintln!("Error: {error}");
+            log_error!("Analysis failed", error = error);
             process::exit(EXIT_ERROR);
         }
     }
 }
 
-/// Run in CI mode with exit codes based on refactoring candidates
+/// Run in CI mode with exit codes based on refactoring candidates and layering rules
 fn run_ci_mode(args: CliArgs) {
     // Store CI settings before running analysis
     let ci_max = args.ci_max_candidates;
@@ -34,45 +61,65 @@ fn run_ci_mode(args: CliArgs) {
 
     match run_analysis_returning_report(args) {
         Ok(report) => {
+            if !report.layer_violations.is_empty() {
+                log_error!(
+                    "CI check failed: imports break the architecture layering rules",
+                    violations = report.layer_violations.len(),
+                );
+                for violation in report.layer_violations.iter().take(10) {
+                    log_error!(
+                        "Layer violation",
+                        path = violation.path.display(),
+                        line = violation.line,
+                        import = violation.import,
+                        rule = violation.rule,
+                    );
+                }
+                if report.layer_violations.len() > 10 {
+                    log_error!(
+                        "Further layer violations not listed",
+                        count = report.layer_violations.len() - 10
+                    );
+                }
+                process::exit(EXIT_LAYER_VIOLATIONS);
+            }
+
             // Identify refactoring candidates using configured thresholds
             let candidates = identify_refactoring_candidates(&report.files, &thresholds);
 
             if candidates.len() > ci_max {
-                eprintln!();
-                eprintln!(
-                    "CI check failed: {} refactoring candidates found (max allowed: {})",
-                    candidates.len(),
-                    ci_max
+                log_error!(
+                    "CI check failed: too many refactoring candidates",
+                    candidates = candidates.len(),
+                    max_allowed = ci_max,
                 );
-                eprintln!("Candidates:");
                 for candidate in candidates.iter().take(10) {
-                    eprintln!(
-                        "  - {} (Score: {:.2}, CC: {}) - {}",
-                        candidate.file.path.display(),
-                        candidate.file.complexity_score,
-                        candidate.file.cyclomatic_complexity,
-                        candidate.reasons_string()
+                    log_error!(
+                        "Refactoring candidate",
+                        path = candidate.file.path.display(),
+                        score = format!("{:.2}", candidate.file.complexity_score),
+                        cc = candidate.file.cyclomatic_complexity,
+                        reasons = candidate.reasons_string(),
                     );
                 }
                 if candidates.len() > 10 {
-                    eprintln!("  ... and {} more", candidates.len() - 10);
+                    log_error!(
+                        "Further refactoring candidates not listed",
+                        count = candidates.len() - 10
+                    );
                 }
                 process::exit(EXIT_CANDIDATES_EXCEEDED);
             } else {
-                if candidates.is_empty() {
-                    eprintln!("CI check passed: No refactoring candidates found");
-                } else {
-                    eprintln!(
-                        "CI check passed: {} refactoring candidates found (within limit of {})",
-                        candidates.len(),
-                        ci_max
-                    );
-                }
+                log_info!(
+                    "CI check passed",
+                    candidates = candidates.len(),
+                    max_allowed = ci_max,
+                );
                 process::exit(EXIT_SUCCESS);
             }
         }
         Err(e) => {
-            eprintln!("Error: {}", e);
+            log_error!("Analysis failed", error = e);
             process::exit(EXIT_ERROR);
         }
     }
@@ -1,4 +1,5 @@
 use csv::Writer;
+use std::collections::BTreeSet;
 use std::fs::File;
 use std::io::{self, Write};
 use std::path::Path;
@@ -25,8 +26,20 @@ impl CsvExporter {
 
     fn write_csv<W: Write>(&self, files: &[FileAnalysis], writer: W) -> Result<()> {
         let mut wtr = Writer::from_writer(writer);
+        Self::write_records(&mut wtr, files)?;
+        wtr.flush()?;
+        Ok(())
+    }
 
-        wtr.write_record([
+    /// Write the header and one row per file, adding a column for every
+    /// extension metric present in any file
+    fn write_records<W: Write>(wtr: &mut Writer<W>, files: &[FileAnalysis]) -> Result<()> {
+        let metric_names: BTreeSet<&str> = files
+            .iter()
+            .flat_map(|f| f.metrics.keys().map(String::as_str))
+            .collect();
+
+        let mut header = vec![
             "path",
             "language",
             "lines_of_code",
@@ -34,14 +47,17 @@ impl CsvExporter {
             "comment_lines",
             "functions",
             "methods",
+            "anonymous_functions",
             "classes",
             "cyclomatic_complexity",
             "max_nesting_depth",
             "complexity_score",
-        ])?;
+        ];
+        header.extend(metric_names.iter().copied());
+        wtr.write_record(&header)?;
 
         for f in files {
-            wtr.write_record([
+            let mut record = vec![
                 f.path.display().to_string(),
                 f.language.clone(),
                 f.lines_of_code.to_string(),
@@ -49,49 +65,27 @@ impl CsvExporter {
                 f.comment_lines.to_string(),
                 f.functions.to_string(),
                 f.methods.to_string(),
+                f.anonymous_functions.to_string(),
                 f.classes.to_string(),
                 f.cyclomatic_complexity.to_string(),
                 f.max_nesting_depth.to_string(),
                 format!("{:.2}", f.complexity_score),
-            ])?;
+            ];
+            record.extend(metric_names.iter().map(|name| {
+                f.metrics
+                    .get(*name)
+                    .map(|v| v.to_string())
+                    .unwrap_or_default()
+            }));
+            wtr.write_record(&record)?;
         }
 
-        wtr.flush()?;
         Ok(())
     }
 
     pub fn format_csv(&self, files: &[FileAnalysis]) -> Result<String> {
         let mut wtr = Writer::from_writer(Vec::new());
-
-        wtr.write_record([
-            "path",
-            "language",
-            "lines_of_code",
-            "blank_lines",
-            "comment_lines",
-            "functions",
-            "methods",
-            "classes",
-            "cyclomatic_complexity",
-            "max_nesting_depth",
-            "complexity_score",
-        ])?;
-
-        for f in files {
-            wtr.write_record([
-                f.path.display().to_string(),
-                f.language.clone(),
-                f.lines_of_code.to_string(),
-                f.blank_lines.to_string(),
-                f.comment_lines.to_string(),
-                f.functions.to_string(),
-                f.methods.to_string(),
-                f.classes.to_string(),
-                f.cyclomatic_complexity.to_string(),
-                f.max_nesting_depth.to_string(),
-                format!("{:.2}", f.complexity_score),
-            ])?;
-        }
+        Self::write_records(&mut wtr, files)?;
 
         let data = wtr.into_inner().map_err(|e| {
             crate::error::AnalyzerError::validation_error(format!("CSV flush error: {}", e))
@@ -112,6 +106,8 @@ impl Default for CsvExporter {
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::analyzer::metrics::MetricValue;
+    use crate::analyzer::Confidence;
     use std::path::PathBuf;
     use tempfile::NamedTempFile;
 
@@ -125,10 +121,19 @@ mod tests {
                 comment_lines: 20,
                 functions: 5,
                 methods: 3,
+                anonymous_functions: 0,
                 classes: 2,
                 cyclomatic_complexity: 8,
                 max_nesting_depth: 3,
                 complexity_score: 3.2,
+                metrics: Default::default(),
+                scopes: Vec::new(),
+                parse_error_ratio: 0.0,
+                confidence: Confidence::High,
+                root: None,
+                imports: Vec::new(),
+                api: Vec::new(),
+                outline: Vec::new(),
             },
             FileAnalysis {
                 path: PathBuf::from("lib/utils.js"),
@@ -138,10 +143,19 @@ mod tests {
                 comment_lines: 15,
                 functions: 3,
                 methods: 2,
+                anonymous_functions: 0,
                 classes: 1,
                 cyclomatic_complexity: 5,
                 max_nesting_depth: 2,
                 complexity_score: 2.1,
+                metrics: Default::default(),
+                scopes: Vec::new(),
+                parse_error_ratio: 0.0,
+                confidence: Confidence::High,
+                root: None,
+                imports: Vec::new(),
+                api: Vec::new(),
+                outline: Vec::new(),
             },
         ]
     }
@@ -206,9 +220,26 @@ mod tests {
         assert!(header.contains("comment_lines"));
         assert!(header.contains("functions"));
         assert!(header.contains("methods"));
+        assert!(header.contains("anonymous_functions"));
         assert!(header.contains("classes"));
         assert!(header.contains("cyclomatic_complexity"));
         assert!(header.contains("max_nesting_depth"));
         assert!(header.contains("complexity_score"));
     }
+
+    #[test]
+    fn test_csv_extension_metric_columns() {
+        let mut files = create_test_files();
+        files[0]
+            .metrics
+            .insert("fan_out".to_string(), MetricValue::Count(4));
+
+        let csv_string = CsvExporter::new().format_csv(&files).unwrap();
+        let lines: Vec<&str> = csv_string.lines().collect();
+
+        assert!(lines[0].ends_with("complexity_score,fan_out"));
+        assert!(lines[1].ends_with(",4"));
+        // Files without the metric get an empty cell
+        assert!(lines[2].ends_with("2.10,"));
+    }
 }
@@ -4,6 +4,7 @@ use std::io::Write;
 use std::path::Path;
 
 use crate::analyzer::parser::{AnalysisReport, FileAnalysis, ProjectSummary};
+use crate::analyzer::walker::WalkStats;
 use crate::cli::SortBy;
 use crate::error::{AnalyzerError, Result};
 
@@ -12,6 +13,7 @@ use crate::error::{AnalyzerError, Result};
 pub struct JsonExporter {
     pretty_print: bool,
     include_metadata: bool,
+    sort_metric: Option<String>,
 }
 
 impl JsonExporter {
@@ -20,6 +22,7 @@ impl JsonExporter {
         Self {
             pretty_print: true,
             include_metadata: true,
+            sort_metric: None,
         }
     }
 
@@ -35,6 +38,12 @@ impl JsonExporter {
         self
     }
 
+    /// Sort filtered reports by a named metric instead of the `SortBy` criteria
+    pub fn with_sort_metric(mut self, metric: Option<String>) -> Self {
+        self.sort_metric = metric;
+        self
+    }
+
     /// Export analysis report to a JSON file
     pub fn export_to_file<P: AsRef<Path>>(
         &self,
@@ -122,7 +131,11 @@ impl JsonExporter {
         }
 
         // Apply sorting
-        crate::output::terminal::apply_sorting(&mut filtered_files, sort_by);
+        crate::output::terminal::sort_files(
+            &mut filtered_files,
+            sort_by,
+            self.sort_metric.as_deref(),
+        );
 
         // Apply limit if specified
         if let Some(limit) = limit {
@@ -136,6 +149,15 @@ impl JsonExporter {
             config: report.config.clone(),
             generated_at: report.generated_at,
             warnings: report.warnings.clone(),
+            limit_reached: report.limit_reached,
+            failed_files: report.failed_files.clone(),
+            skipped_files: report.skipped_files.clone(),
+            walk_stats: report.walk_stats.clone(),
+            profile: report.profile.clone(),
+            components: report.components.clone(),
+            roots: report.roots.clone(),
+            layer_violations: report.layer_violations.clone(),
+            split_suggestions: report.split_suggestions.clone(),
         };
 
         self.export_to_file(&filtered_report, file_path)
@@ -179,6 +201,15 @@ pub fn export_analysis_results<P: AsRef<Path>>(
         config: config.clone(),
         generated_at: chrono::Utc::now(),
         warnings: Vec::new(),
+        limit_reached: None,
+        failed_files: Vec::new(),
+        skipped_files: Vec::new(),
+        walk_stats: Default::default(),
+        profile: None,
+        components: Vec::new(),
+        roots: Vec::new(),
+        layer_violations: Vec::new(),
+        split_suggestions: Vec::new(),
     };
 
     let exporter = JsonExporter::new().pretty_print(pretty_print);
@@ -207,6 +238,15 @@ pub fn format_analysis_json(
         config: config.clone(),
         generated_at: chrono::Utc::now(),
         warnings: Vec::new(),
+        limit_reached: None,
+        failed_files: Vec::new(),
+        skipped_files: Vec::new(),
+        walk_stats: Default::default(),
+        profile: None,
+        components: Vec::new(),
+        roots: Vec::new(),
+        layer_violations: Vec::new(),
+        split_suggestions: Vec::new(),
     };
 
     let exporter = JsonExporter::new().pretty_print(pretty);
@@ -249,12 +289,44 @@ pub fn merge_analysis_reports(reports: &[AnalysisReport]) -> Result<AnalysisRepo
         config: base_report.config.clone(),
         generated_at: chrono::Utc::now(),
         warnings: merged_warnings,
+        limit_reached: reports.iter().find_map(|r| r.limit_reached),
+        failed_files: reports
+            .iter()
+            .flat_map(|r| r.failed_files.iter().cloned())
+            .collect(),
+        skipped_files: reports
+            .iter()
+            .flat_map(|r| r.skipped_files.iter().cloned())
+            .collect(),
+        walk_stats: reports.iter().fold(WalkStats::default(), |mut total, r| {
+            total.merge(&r.walk_stats);
+            total
+        }),
+        // Profiles of separate runs do not add up meaningfully
+        profile: None,
+        components: reports
+            .iter()
+            .flat_map(|r| r.components.iter().cloned())
+            .collect(),
+        roots: reports
+            .iter()
+            .flat_map(|r| r.roots.iter().cloned())
+            .collect(),
+        layer_violations: reports
+            .iter()
+            .flat_map(|r| r.layer_violations.iter().cloned())
+            .collect(),
+        split_suggestions: reports
+            .iter()
+            .flat_map(|r| r.split_suggestions.iter().cloned())
+            .collect(),
     })
 }
 
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::analyzer::Confidence;
     use chrono::Utc;
 
     use std::path::PathBuf;
@@ -270,10 +342,19 @@ mod tests {
                 comment_lines: 20,
                 functions: 5,
                 methods: 3,
+                anonymous_functions: 0,
                 classes: 2,
                 cyclomatic_complexity: 8,
                 max_nesting_depth: 0,
                 complexity_score: 3.2,
+                metrics: Default::default(),
+                scopes: Vec::new(),
+                parse_error_ratio: 0.0,
+                confidence: Confidence::High,
+                root: None,
+                imports: Vec::new(),
+                api: Vec::new(),
+                outline: Vec::new(),
             },
             FileAnalysis {
                 path: PathBuf::from("lib/utils.js"),
@@ -283,10 +364,19 @@ mod tests {
                 comment_lines: 15,
                 functions: 3,
                 methods: 2,
+                anonymous_functions: 0,
                 classes: 1,
                 cyclomatic_complexity: 5,
                 max_nesting_depth: 0,
                 complexity_score: 2.1,
+                metrics: Default::default(),
+                scopes: Vec::new(),
+                parse_error_ratio: 0.0,
+                confidence: Confidence::High,
+                root: None,
+                imports: Vec::new(),
+                api: Vec::new(),
+                outline: Vec::new(),
             },
         ];
 
@@ -307,6 +397,15 @@ mod tests {
             config,
             generated_at: Utc::now(),
             warnings: Vec::new(),
+            limit_reached: None,
+            failed_files: Vec::new(),
+            skipped_files: Vec::new(),
+            walk_stats: Default::default(),
+            profile: None,
+            components: Vec::new(),
+            roots: Vec::new(),
+            layer_violations: Vec::new(),
+            split_suggestions: Vec::new(),
         }
     }
 
@@ -1,16 +1,23 @@
 use std::path::Path;
 
-use crate::analyzer::{AnalysisReport, FileAnalysis, ProjectSummary, RefactoringThresholds};
-use crate::cli::{CliArgs, OutputFormat, SortBy};
+use crate::analyzer::{
+    AnalysisReport, ApiManifest, CallGraph, FileAnalysis, ProjectSummary, RefactoringThresholds,
+};
+use crate::cli::{CallGraphFormat, CliArgs, OutputFormat, SortBy};
 use crate::error::{AnalyzerError, Result};
+use crate::log_info;
 
 pub mod csv;
 pub mod json;
+pub mod outline;
 pub mod terminal;
 
 pub use csv::CsvExporter;
 pub use json::{export_analysis_results, export_compact_json, JsonExporter};
-pub use terminal::{apply_sorting, create_simple_table, display_compact_table, TerminalReporter};
+pub use outline::OutlineExporter;
+pub use terminal::{
+    apply_sorting, create_simple_table, display_compact_table, sort_files, TerminalReporter,
+};
 
 /// Output manager that coordinates terminal and JSON output
 pub struct OutputManager {
@@ -37,12 +44,15 @@ impl OutputManager {
         let mut terminal_reporter = TerminalReporter::new()
             .show_summary(!args.json_only)
             .color_enabled(args.should_use_colors())
-            .with_thresholds(thresholds);
+            .with_thresholds(thresholds)
+            .with_sort_metric(args.sort_metric.clone());
 
         // Set base path for relative path display
         terminal_reporter = terminal_reporter.with_base_path(args.target_path());
 
-        let json_exporter = JsonExporter::new().pretty_print(true); // Always use pretty print for files
+        let json_exporter = JsonExporter::new()
+            .pretty_print(true) // Always use pretty print for files
+            .with_sort_metric(args.sort_metric.clone());
 
         Self {
             terminal_reporter,
@@ -59,49 +69,48 @@ impl OutputManager {
             }
             OutputFormat::Json => {
                 self.generate_json_output(report, args)?;
-                if args.verbose {
-                    println!(
-                        "JSON report saved to: {}",
-                        args.json_output_path().display()
-                    );
-                }
+                log_info!(
+                    "JSON report saved",
+                    path = args.json_output_path().display()
+                );
             }
             OutputFormat::Both => {
                 self.generate_terminal_output(report, args)?;
                 self.generate_json_output(report, args)?;
-                if args.verbose {
-                    println!(
-                        "JSON report saved to: {}",
-                        args.json_output_path().display()
-                    );
-                }
+                log_info!(
+                    "JSON report saved",
+                    path = args.json_output_path().display()
+                );
             }
             OutputFormat::JsonFilesOnly => {
                 let path = args.json_output_path();
                 self.json_exporter.export_files_only(&report.files, &path)?;
-                if args.verbose {
-                    println!("Files-only JSON written to: {}", path.display());
-                }
+                log_info!("Files-only JSON written", path = path.display());
             }
             OutputFormat::JsonSummaryOnly => {
                 let path = args.json_output_path();
                 self.json_exporter
                     .export_summary_only(&report.summary, &path)?;
-                if args.verbose {
-                    println!("Summary-only JSON written to: {}", path.display());
-                }
+                log_info!("Summary-only JSON written", path = path.display());
             }
             OutputFormat::Csv => {
                 let csv_exporter = CsvExporter::new();
                 if let Some(ref path) = args.output_file {
                     csv_exporter.export_to_file(&report.files, path)?;
-                    if args.verbose {
-                        println!("CSV report saved to: {}", path.display());
-                    }
+                    log_info!("CSV report saved", path = path.display());
                 } else {
                     csv_exporter.export_to_stdout(&report.files)?;
                 }
             }
+            OutputFormat::Outline => {
+                let outline_exporter = OutlineExporter::new();
+                if let Some(ref path) = args.output_file {
+                    outline_exporter.export_to_file(&report.files, path)?;
+                    log_info!("Outline saved", path = path.display());
+                } else {
+                    outline_exporter.export_to_stdout(&report.files)?;
+                }
+            }
         }
 
         // Handle json_only flag (legacy support)
@@ -114,6 +123,46 @@ impl OutputManager {
             self.generate_json_output(report, args)?;
         }
 
+        if let Some(ref path) = args.call_graph {
+            let graph = CallGraph::build(&report.files, &report.config.target_path);
+            let format = args
+                .call_graph_format
+                .unwrap_or_else(|| CallGraphFormat::from_path(path));
+            export_call_graph(&graph, path, format)?;
+            log_info!(
+                "Call graph saved",
+                path = path.display(),
+                functions = graph.functions.len(),
+                edges = graph.edges.len()
+            );
+            if args.should_output_terminal() {
+                self.terminal_reporter
+                    .display_call_graph_hotspots(&graph, args.limit);
+            }
+        }
+
+        if let Some(ref path) = args.api_manifest {
+            let manifest = ApiManifest::from_files(&report.files);
+            std::fs::write(path, serde_json::to_string_pretty(&manifest)?)?;
+            log_info!(
+                "API manifest saved",
+                path = path.display(),
+                items = manifest.item_count()
+            );
+        }
+
+        if let Some(ref path) = args.package_metrics {
+            std::fs::write(
+                path,
+                serde_json::to_string_pretty(&report.summary.packages)?,
+            )?;
+            log_info!(
+                "Package metrics saved",
+                path = path.display(),
+                packages = report.summary.packages.len()
+            );
+        }
+
         Ok(())
     }
 
@@ -198,6 +247,16 @@ impl OutputManager {
     }
 }
 
+/// Write a call graph to `path` as JSON or Graphviz DOT
+pub fn export_call_graph(graph: &CallGraph, path: &Path, format: CallGraphFormat) -> Result<()> {
+    let contents = match format {
+        CallGraphFormat::Json => serde_json::to_string_pretty(graph)?,
+        CallGraphFormat::Dot => graph.to_dot(),
+    };
+    std::fs::write(path, contents)?;
+    Ok(())
+}
+
 impl Default for OutputManager {
     fn default() -> Self {
         Self::new()
@@ -216,7 +275,8 @@ impl TerminalReporterExt for TerminalReporter {
         let mut reporter = TerminalReporter::new()
             .show_summary(show_summary)
             .color_enabled(color_enabled)
-            .with_thresholds(self.get_thresholds());
+            .with_thresholds(self.get_thresholds())
+            .with_sort_metric(self.get_sort_metric().map(str::to_string));
         if let Some(base) = self.base_path() {
             reporter = reporter.with_base_path(base);
         }
@@ -356,12 +416,21 @@ pub fn route_output_by_format(
                 csv_exporter.export_to_stdout(&report.files)
             }
         }
+        OutputFormat::Outline => {
+            let outline_exporter = OutlineExporter::new();
+            if let Some(path) = json_path {
+                outline_exporter.export_to_file(&report.files, path)
+            } else {
+                outline_exporter.export_to_stdout(&report.files)
+            }
+        }
     }
 }
 
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::analyzer::Confidence;
     use chrono::Utc;
 
     use std::path::PathBuf;
@@ -377,10 +446,19 @@ mod tests {
                 comment_lines: 20,
                 functions: 5,
                 methods: 3,
+                anonymous_functions: 0,
                 classes: 2,
                 cyclomatic_complexity: 8,
                 max_nesting_depth: 0,
                 complexity_score: 3.2,
+                metrics: Default::default(),
+                scopes: Vec::new(),
+                parse_error_ratio: 0.0,
+                confidence: Confidence::High,
+                root: None,
+                imports: Vec::new(),
+                api: Vec::new(),
+                outline: Vec::new(),
             },
             crate::analyzer::FileAnalysis {
                 path: PathBuf::from("lib/utils.js"),
@@ -390,10 +468,19 @@ mod tests {
                 comment_lines: 15,
                 functions: 3,
                 methods: 2,
+                anonymous_functions: 0,
                 classes: 1,
                 cyclomatic_complexity: 5,
                 max_nesting_depth: 0,
                 complexity_score: 2.1,
+                metrics: Default::default(),
+                scopes: Vec::new(),
+                parse_error_ratio: 0.0,
+                confidence: Confidence::High,
+                root: None,
+                imports: Vec::new(),
+                api: Vec::new(),
+                outline: Vec::new(),
             },
         ];
 
@@ -414,6 +501,15 @@ mod tests {
             config,
             generated_at: Utc::now(),
             warnings: Vec::new(),
+            limit_reached: None,
+            failed_files: Vec::new(),
+            skipped_files: Vec::new(),
+            walk_stats: Default::default(),
+            profile: None,
+            components: Vec::new(),
+            roots: Vec::new(),
+            layer_violations: Vec::new(),
+            split_suggestions: Vec::new(),
         }
     }
 
@@ -0,0 +1,128 @@
+use serde::Serialize;
+use std::fs::File;
+use std::io::{self, Write};
+use std::path::{Path, PathBuf};
+
+use crate::analyzer::{FileAnalysis, OutlineSymbol};
+use crate::error::Result;
+
+/// Outline of a single file
+#[derive(Serialize)]
+struct FileOutline<'a> {
+    path: &'a PathBuf,
+    language: &'a str,
+    symbols: &'a [OutlineSymbol],
+}
+
+/// Exports the hierarchical symbol outline of every file as JSON
+#[derive(Clone)]
+pub struct OutlineExporter;
+
+impl OutlineExporter {
+    pub fn new() -> Self {
+        Self
+    }
+
+    pub fn export_to_file<P: AsRef<Path>>(&self, files: &[FileAnalysis], path: P) -> Result<()> {
+        let file = File::create(path.as_ref())?;
+        self.write_outline(files, file)
+    }
+
+    pub fn export_to_stdout(&self, files: &[FileAnalysis]) -> Result<()> {
+        self.write_outline(files, io::stdout())
+    }
+
+    fn write_outline<W: Write>(&self, files: &[FileAnalysis], mut writer: W) -> Result<()> {
+        writeln!(writer, "{}", self.format_outline(files)?)?;
+        Ok(())
+    }
+
+    pub fn format_outline(&self, files: &[FileAnalysis]) -> Result<String> {
+        let outlines: Vec<FileOutline> = files
+            .iter()
+            .map(|f| FileOutline {
+                path: &f.path,
+                language: &f.language,
+                symbols: &f.outline,
+            })
+            .collect();
+        Ok(serde_json::to_string_pretty(&outlines)?)
+    }
+}
+
+impl Default for OutlineExporter {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::analyzer::Visibility;
+
+    fn create_test_file() -> FileAnalysis {
+        let method = OutlineSymbol {
+            kind: "method".to_string(),
+            name: "push".to_string(),
+            start_line: 6,
+            end_line: 8,
+            visibility: Some(Visibility::Public),
+            is_abstract: false,
+            lines: 3,
+            cyclomatic_complexity: 3,
+            max_nesting_depth: 2,
+            children: Vec::new(),
+        };
+        FileAnalysis {
+            path: PathBuf::from("src/stack.rs"),
+            language: "rust".to_string(),
+            lines_of_code: 10,
+            blank_lines: 1,
+            functions: 1,
+            methods: 1,
+            classes: 1,
+            cyclomatic_complexity: 3,
+            max_nesting_depth: 2,
+            complexity_score: 1.5,
+            outline: vec![OutlineSymbol {
+                kind: "impl".to_string(),
+                name: "Stack".to_string(),
+                start_line: 5,
+                end_line: 10,
+                visibility: None,
+                is_abstract: false,
+                lines: 6,
+                cyclomatic_complexity: 3,
+                max_nesting_depth: 2,
+                children: vec![method],
+            }],
+            ..Default::default()
+        }
+    }
+
+    #[test]
+    fn test_outline_format() {
+        let exporter = OutlineExporter::new();
+        let json = exporter.format_outline(&[create_test_file()]).unwrap();
+        let value: serde_json::Value = serde_json::from_str(&json).unwrap();
+
+        assert_eq!(value[0]["path"], "src/stack.rs");
+        assert_eq!(value[0]["language"], "rust");
+        let implementation = &value[0]["symbols"][0];
+        assert_eq!(implementation["kind"], "impl");
+        assert!(implementation.get("visibility").is_none());
+        let method = &implementation["children"][0];
+        assert_eq!(method["name"], "push");
+        assert_eq!(method["visibility"], "public");
+        assert_eq!(method["start_line"], 6);
+        assert_eq!(method["cyclomatic_complexity"], 3);
+        assert!(method.get("children").is_none());
+    }
+
+    #[test]
+    fn test_outline_empty_files() {
+        let exporter = OutlineExporter::new();
+        assert_eq!(exporter.format_outline(&[]).unwrap(), "[]");
+    }
+}
@@ -1,11 +1,13 @@
 use crate::analyzer::parser::{
-    identify_refactoring_candidates, AnalysisReport, FileAnalysis, ProjectSummary,
-    RefactoringCandidate, RefactoringThresholds,
+    identify_refactoring_candidates, AnalysisReport, ComponentReport, Confidence, FailedFile,
+    FileAnalysis, ProjectSummary, RefactoringCandidate, RefactoringThresholds, RootReport,
 };
+use crate::analyzer::{ApiDiff, CallGraph, LayerViolation, PackageMetrics, SplitSuggestion};
 use crate::cli::SortBy;
 use crate::error::{ParseWarning, Result};
 use prettytable::{format, row, Cell, Row, Table};
 use std::cmp::Ordering;
+use std::collections::BTreeSet;
 use std::path::PathBuf;
 
 /// Terminal reporter for displaying analysis results as formatted tables
@@ -14,6 +16,7 @@ pub struct TerminalReporter {
     color_enabled: bool,
     base_path: Option<PathBuf>,
     thresholds: RefactoringThresholds,
+    sort_metric: Option<String>,
 }
 
 impl TerminalReporter {
@@ -24,6 +27,7 @@ impl TerminalReporter {
             color_enabled: true,
             base_path: None,
             thresholds: RefactoringThresholds::default(),
+            sort_metric: None,
         }
     }
 
@@ -51,6 +55,17 @@ impl TerminalReporter {
         self
     }
 
+    /// Sort tables by a named metric instead of the `SortBy` criteria
+    pub fn with_sort_metric(mut self, metric: Option<String>) -> Self {
+        self.sort_metric = metric;
+        self
+    }
+
+    /// Get the metric used for sorting (if set)
+    pub fn get_sort_metric(&self) -> Option<&str> {
+        self.sort_metric.as_deref()
+    }
+
     /// Get the base path (if set)
     pub fn get_base_path(&self) -> Option<&PathBuf> {
         self.base_path.as_ref()
@@ -90,9 +105,36 @@ impl TerminalReporter {
         println!("====================");
         println!();
 
+        if let Some(limit) = report.limit_reached {
+            println!("⚠ Partial report: analysis stopped at the {limit}");
+            println!();
+        }
+
+        let low_confidence = report
+            .files
+            .iter()
+            .filter(|f| f.confidence == Confidence::Low)
+            .count();
+        if low_confidence > 0 {
+            println!(
+                "⚠ {low_confidence} file(s) have low parse confidence and are not flagged as refactoring candidates"
+            );
+            println!();
+        }
+
         if self.show_summary {
             self.display_project_summary(&report.summary)?;
             println!();
+
+            if !report.roots.is_empty() {
+                self.display_roots(&report.roots);
+                println!();
+            }
+
+            if !report.components.is_empty() {
+                self.display_components(&report.components);
+                println!();
+            }
         }
 
         // Identify and display refactoring candidates using configured thresholds
@@ -100,13 +142,19 @@ impl TerminalReporter {
         if !candidates.is_empty() {
             self.display_refactoring_candidates(&candidates, 10)?;
         }
+        self.display_extract_suggestions(&report.files, 10);
+        if !report.split_suggestions.is_empty() {
+            self.display_split_suggestions(&report.split_suggestions);
+        }
 
         // Show main file analysis table
         println!(
             "All Files (showing {} of {}, sorted by {}):",
             std::cmp::min(limit, report.files.len()),
             report.files.len(),
-            sort_by
+            self.sort_metric
+                .clone()
+                .unwrap_or_else(|| sort_by.to_string())
         );
         self.display_file_analysis_table(&report.files, sort_by, limit)?;
 
@@ -119,9 +167,120 @@ impl TerminalReporter {
             self.display_warnings(&report.warnings)?;
         }
 
+        // Make dropped files visible so a clean report can be trusted
+        if !report.failed_files.is_empty() {
+            println!();
+            self.display_failed_files(&report.failed_files);
+        }
+        if !report.layer_violations.is_empty() {
+            println!();
+            self.display_layer_violations(&report.layer_violations);
+        }
+        self.display_walk_stats(&report.walk_stats);
+
         Ok(())
     }
 
+    /// Display files that could not be analyzed
+    pub fn display_failed_files(&self, failed: &[FailedFile]) {
+        println!("Failed Files ({}):", failed.len());
+        println!("─────────────");
+
+        for file in failed {
+            println!(
+                "✗ {} [{}]: {}",
+                self.format_file_path(&file.path),
+                file.kind,
+                file.message
+            );
+        }
+    }
+
+    /// Display the blocks suggested for extraction from the most complex functions
+    pub fn display_extract_suggestions(&self, files: &[FileAnalysis], limit: usize) {
+        let mut functions: Vec<_> = files
+            .iter()
+            .flat_map(|file| file.scopes.iter().map(move |scope| (file, scope)))
+            .filter(|(_, scope)| !scope.extractions.is_empty())
+            .collect();
+        if functions.is_empty() {
+            return;
+        }
+        functions.sort_by_key(|(_, scope)| std::cmp::Reverse(scope.cyclomatic_complexity));
+
+        println!(
+            "Extract Method Suggestions ({} function{}):",
+            functions.len(),
+            if functions.len() == 1 { "" } else { "s" }
+        );
+        for (file, scope) in functions.into_iter().take(limit) {
+            println!(
+                "{}:{} {} ({} lines, CC {})",
+                self.format_file_path(&file.path),
+                scope.start_line,
+                scope.name.as_deref().unwrap_or("<anonymous>"),
+                scope.line_count(),
+                scope.cyclomatic_complexity
+            );
+            for (i, suggestion) in scope.extractions.iter().enumerate() {
+                let prefix = if i == scope.extractions.len() - 1 {
+                    "└─"
+                } else {
+                    "├─"
+                };
+                println!("  {prefix} {suggestion}");
+            }
+        }
+        println!();
+    }
+
+    /// Display the proposed partitions of large files
+    pub fn display_split_suggestions(&self, suggestions: &[SplitSuggestion]) {
+        println!("Split Suggestions ({}):", suggestions.len());
+        for suggestion in suggestions {
+            println!(
+                "{} → {} modules, cut size {} ({} references kept inside modules)",
+                self.format_file_path(&suggestion.path),
+                suggestion.groups.len(),
+                suggestion.cut_size,
+                suggestion.internal_references
+            );
+            for (i, group) in suggestion.groups.iter().enumerate() {
+                let prefix = if i == suggestion.groups.len() - 1 {
+                    "└─"
+                } else {
+                    "├─"
+                };
+                println!(
+                    "  {prefix} {} ({} lines, {} functions): {}",
+                    group.name,
+                    group.lines,
+                    group.functions,
+                    group.symbols.join(", ")
+                );
+            }
+        }
+        println!();
+    }
+
+    /// Display imports that break the architecture layering rules
+    pub fn display_layer_violations(&self, violations: &[LayerViolation]) {
+        println!("Layer Violations ({}):", violations.len());
+        println!("─────────────");
+
+        for violation in violations {
+            println!(
+                "✗ {}:{}: {} ({} → {}: {})",
+                self.format_file_path(&violation.path),
+                violation.line,
+                violation.import,
+                violation.from_layer,
+                violation.to_layer,
+                violation.rule
+            );
+        }
+    }
+
     /// Display parse warnings
     pub fn display_warnings(&self, warnings: &[ParseWarning]) -> Result<()> {
         if warnings.is_empty() {
@@ -136,6 +295,7 @@ impl TerminalReporter {
                 crate::error::WarningType::SyntaxError => "⚠ Syntax",
                 crate::error::WarningType::PartialParse => "⚠ Partial",
                 crate::error::WarningType::EncodingError => "⚠ Encoding",
+                crate::error::WarningType::ResourceLimit => "⚠ Limit",
             };
 
             println!(
@@ -183,8 +343,14 @@ impl TerminalReporter {
             table.set_format(*format::consts::FORMAT_NO_COLSEP);
         }
 
+        // Extension metrics present in any file get their own columns
+        let metric_names: BTreeSet<&str> = files
+            .iter()
+            .flat_map(|f| f.metrics.keys().map(String::as_str))
+            .collect();
+
         // Add headers with severity indicator column
-        table.add_row(row![
+        let header = table.add_row(row![
             bFg->"",
             bFg->"File",
             bFg->"Lang",
@@ -197,10 +363,13 @@ impl TerminalReporter {
             bFg->"CC",
             bFg->"Score"
         ]);
+        for name in &metric_names {
+            header.add_cell(Cell::new(name).style_spec("bFg"));
+        }
 
         // Sort files according to the specified criteria
         let mut sorted_files = files.to_vec();
-        apply_sorting(&mut sorted_files, sort_by);
+        sort_files(&mut sorted_files, sort_by, self.sort_metric.as_deref());
 
         // Add rows for each file (limited by the specified limit)
         for file in sorted_files.iter().take(limit) {
@@ -221,7 +390,7 @@ impl TerminalReporter {
                 Cell::new(&format!("{:.2}", file.complexity_score))
             };
 
-            table.add_row(Row::new(vec![
+            let mut cells = vec![
                 Cell::new(severity),
                 Cell::new(&path_display),
                 Cell::new(&file.language),
@@ -233,7 +402,17 @@ impl TerminalReporter {
                 Cell::new(&file.classes.to_string()).style_spec("r"),
                 cc_cell.style_spec("r"),
                 score_cell.style_spec("r"),
-            ]));
+            ];
+            cells.extend(metric_names.iter().map(|name| {
+                let value = file
+                    .metrics
+                    .get(*name)
+                    .map(|v| v.to_string())
+                    .unwrap_or_else(|| "-".to_string());
+                Cell::new(&value).style_spec("r")
+            }));
+
+            table.add_row(Row::new(cells));
         }
 
         Ok(table)
@@ -246,6 +425,12 @@ impl TerminalReporter {
         println!("├─ Total lines: {}", summary.total_lines);
         println!("├─ Functions: {}", summary.total_functions);
         println!("├─ Methods: {}", summary.total_methods);
+        if summary.total_anonymous_functions > 0 {
+            println!(
+                "├─ Anonymous functions: {}",
+                summary.total_anonymous_functions
+            );
+        }
         println!("└─ Classes: {}", summary.total_classes);
 
         if !summary.language_breakdown.is_empty() {
@@ -253,13 +438,103 @@ impl TerminalReporter {
             self.display_language_breakdown(&summary.language_breakdown)?;
         }
 
+        if summary.packages.len() > 1 {
+            println!();
+            self.display_packages(&summary.packages, 10);
+        }
+
         Ok(())
     }
 
+    /// Display the packages furthest from the main sequence
+    pub fn display_packages(&self, packages: &[PackageMetrics], limit: usize) {
+        let mut packages: Vec<&PackageMetrics> = packages.iter().collect();
+        packages.sort_by(|a, b| {
+            b.distance
+                .partial_cmp(&a.distance)
+                .unwrap_or(Ordering::Equal)
+                .then_with(|| a.package.cmp(&b.package))
+        });
+        packages.truncate(limit);
+
+        println!("Packages (by distance from the main sequence):");
+        for (i, package) in packages.iter().enumerate() {
+            let prefix = if i == packages.len() - 1 {
+                "└─"
+            } else {
+                "├─"
+            };
+            println!(
+                "{} {}: D={:.2}  I={:.2} (Ca {}, Ce {})  A={:.2} ({}/{} abstract)",
+                prefix,
+                package.package.display(),
+                package.distance,
+                package.instability,
+                package.afferent_coupling,
+                package.efferent_coupling,
+                package.abstractness,
+                package.abstract_types,
+                package.types
+            );
+        }
+    }
+
+    /// Display the summary of each root path
+    pub fn display_roots(&self, roots: &[RootReport]) {
+        println!("Roots:");
+        for (i, root) in roots.iter().enumerate() {
+            let prefix = if i == roots.len() - 1 {
+                "└─"
+            } else {
+                "├─"
+            };
+            println!(
+                "{} {}: {} files, {} lines, {} functions, {} classes",
+                prefix,
+                root.path.display(),
+                root.summary.total_files,
+                Self::format_number(root.summary.total_lines),
+                root.summary.total_functions,
+                root.summary.total_classes
+            );
+        }
+    }
+
+    /// Display the summary of each nested repository analyzed as a component
+    pub fn display_components(&self, components: &[ComponentReport]) {
+        println!("Components (nested repositories):");
+        for (i, component) in components.iter().enumerate() {
+            let prefix = if i == components.len() - 1 {
+                "└─"
+            } else {
+                "├─"
+            };
+
+            let mut origin = component.kind.as_str().to_string();
+            if let Some(ref git) = component.git {
+                origin.push_str(&format!(" @ {}", &git.commit[..git.commit.len().min(8)]));
+                if let Some(ref branch) = git.branch {
+                    origin.push_str(&format!(" ({branch})"));
+                }
+            }
+
+            println!(
+                "{} {} [{}]: {} files, {} lines, {} functions, {} classes",
+                prefix,
+                self.format_file_path(&component.path),
+                origin,
+                component.summary.total_files,
+                Self::format_number(component.summary.total_lines),
+                component.summary.total_functions,
+                component.summary.total_classes
+            );
+        }
+    }
+
     /// Display language breakdown statistics with visual bar
     fn display_language_breakdown(
         &self,
-        breakdown: &std::collections::HashMap<String, crate::analyzer::parser::LanguageStats>,
+        breakdown: &std::collections::BTreeMap<String, crate::analyzer::parser::LanguageStats>,
     ) -> Result<()> {
         println!("Languages:");
 
@@ -374,6 +649,131 @@ impl TerminalReporter {
         Ok(())
     }
 
+    /// Display the riskiest functions of the call graph (complexity × fan-in)
+    pub fn display_call_graph_hotspots(&self, graph: &CallGraph, limit: usize) {
+        let hotspots = graph.hotspots(limit);
+        if hotspots.is_empty() {
+            return;
+        }
+
+        println!("Riskiest Functions (complexity × fan-in):");
+        let mut table = Table::new();
+        table.set_format(*format::consts::FORMAT_DEFAULT);
+        table.add_row(row![
+            bFg->"Function",
+            bFg->"File",
+            bFg->"CC",
+            bFg->"Fan-in",
+            bFg->"Fan-out",
+            bFg->"Risk"
+        ]);
+        for function in hotspots {
+            let name = if function.recursive {
+                format!("{} (recursive)", function.name)
+            } else {
+                function.name.clone()
+            };
+            table.add_row(Row::new(vec![
+                Cell::new(&name),
+                Cell::new(&format!(
+                    "{}:{}",
+                    self.format_file_path(&function.path),
+                    function.start_line
+                )),
+                Cell::new(&function.cyclomatic_complexity.to_string()).style_spec("r"),
+                Cell::new(&function.fan_in.to_string()).style_spec("r"),
+                Cell::new(&function.fan_out.to_string()).style_spec("r"),
+                Cell::new(&function.risk.to_string()).style_spec("r"),
+            ]));
+        }
+        table.printstd();
+        println!(
+            "{} functions, {} calls, {} cycles, {} uncalled, {} unresolved calls",
+            graph.functions.len(),
+            graph.edges.len(),
+            graph.cycles.len(),
+            graph.uncalled.len(),
+            graph.unresolved_calls
+        );
+        println!();
+    }
+
+    /// Display the public items removed, changed and added between two revisions
+    pub fn display_api_diff(&self, diff: &ApiDiff, rev_a: &str, rev_b: &str) {
+        println!("Public API changes from {rev_a} to {rev_b}:");
+        if diff.is_empty() {
+            println!("No public API changes");
+            println!();
+            return;
+        }
+
+        let mut table = Table::new();
+        table.set_format(*format::consts::FORMAT_DEFAULT);
+        table.add_row(row![bFg->"Change", bFg->"Item", bFg->"File", bFg->"Signature"]);
+        let change_cell = |change: &str, style: &str| {
+            let cell = Cell::new(change);
+            if self.color_enabled {
+                cell.style_spec(style)
+            } else {
+                cell
+            }
+        };
+
+        for entry in &diff.removed {
+            table.add_row(Row::new(vec![
+                change_cell("removed", "Fr"),
+                Cell::new(&entry.item.name),
+                Cell::new(&format!(
+                    "{}:{}",
+                    self.format_file_path(&entry.path),
+                    entry.item.line
+                )),
+                Cell::new(&entry.item.signature),
+            ]));
+        }
+        for change in &diff.changed {
+            table.add_row(Row::new(vec![
+                change_cell("changed", "Fy"),
+                Cell::new(&change.after.name),
+                Cell::new(&format!(
+                    "{}:{}",
+                    self.format_file_path(&change.path),
+                    change.after.line
+                )),
+                Cell::new(&format!(
+                    "- {}\n+ {}",
+                    change.before.signature, change.after.signature
+                )),
+            ]));
+        }
+        for entry in &diff.added {
+            table.add_row(Row::new(vec![
+                change_cell("added", "Fg"),
+                Cell::new(&entry.item.name),
+                Cell::new(&format!(
+                    "{}:{}",
+                    self.format_file_path(&entry.path),
+                    entry.item.line
+                )),
+                Cell::new(&entry.item.signature),
+            ]));
+        }
+
+        table.printstd();
+        println!(
+            "{} removed, {} changed, {} added{}",
+            diff.removed.len(),
+            diff.changed.len(),
+            diff.added.len(),
+            if diff.is_breaking() {
+                " (breaking)"
+            } else {
+                ""
+            }
+        );
+        println!();
+    }
+
     /// Display file discovery statistics
     ///
     /// Shows information about files analyzed, skipped, and directories scanned.
@@ -382,6 +782,11 @@ impl TerminalReporter {
         if stats.files_skipped_size > 0
             || stats.files_skipped_language > 0
             || stats.files_skipped_hidden > 0
+            || stats.files_skipped_ignored > 0
+            || stats.files_skipped_generated > 0
+            || stats.files_skipped_pattern > 0
+            || stats.symlinks_skipped > 0
+            || stats.nested_repos_skipped > 0
         {
             println!();
             println!("File Discovery:");
@@ -398,6 +803,27 @@ impl TerminalReporter {
             if stats.files_skipped_hidden > 0 {
                 println!("├─ Skipped (hidden): {}", stats.files_skipped_hidden);
             }
+            if stats.files_skipped_ignored > 0 {
+                println!("├─ Skipped (ignore files): {}", stats.files_skipped_ignored);
+            }
+            if stats.files_skipped_generated > 0 {
+                println!("├─ Skipped (generated): {}", stats.files_skipped_generated);
+            }
+            if stats.files_skipped_pattern > 0 {
+                println!(
+                    "├─ Skipped (--include/--exclude): {}",
+                    stats.files_skipped_pattern
+                );
+            }
+            if stats.symlinks_skipped > 0 {
+                println!("├─ Skipped (symlink loops): {}", stats.symlinks_skipped);
+            }
+            if stats.nested_repos_skipped > 0 {
+                println!(
+                    "├─ Skipped (nested repositories): {}",
+                    stats.nested_repos_skipped
+                );
+            }
             println!("└─ Directories scanned: {}", stats.directories_scanned);
         }
     }
@@ -502,6 +928,20 @@ pub fn apply_sorting(files: &mut [FileAnalysis], sort_by: SortBy) {
     }
 }
 
+/// Sort by a named metric (descending) when given, otherwise by `sort_by`
+///
+/// Files that do not report the metric sort last.
+pub fn sort_files(files: &mut [FileAnalysis], sort_by: SortBy, sort_metric: Option<&str>) {
+    match sort_metric {
+        Some(name) => files.sort_by(|a, b| {
+            let a = a.metric(name).unwrap_or(f64::NEG_INFINITY);
+            let b = b.metric(name).unwrap_or(f64::NEG_INFINITY);
+            b.partial_cmp(&a).unwrap_or(Ordering::Equal)
+        }),
+        None => apply_sorting(files, sort_by),
+    }
+}
+
 /// Helper function to create a simple table with analysis results
 pub fn create_simple_table(files: &[FileAnalysis], limit: usize) -> Table {
     let reporter = TerminalReporter::new();
@@ -531,7 +971,12 @@ pub fn display_compact_results(files: &[FileAnalysis], sort_by: SortBy) {
 }
 
 /// Display compact table with only essential metrics (for CI/CD)
-pub fn display_compact_table(files: &[FileAnalysis], sort_by: SortBy, limit: usize) {
+pub fn display_compact_table(
+    files: &[FileAnalysis],
+    sort_by: SortBy,
+    sort_metric: Option<&str>,
+    limit: usize,
+) {
     if files.is_empty() {
         println!("No files found.");
         return;
@@ -546,7 +991,7 @@ pub fn display_compact_table(files: &[FileAnalysis], sort_by: SortBy, limit: usi
 
     // Sort files
     let mut sorted_files = files.to_vec();
-    apply_sorting(&mut sorted_files, sort_by);
+    sort_files(&mut sorted_files, sort_by, sort_metric);
 
     // Add rows
     for file in sorted_files.iter().take(limit) {
@@ -578,6 +1023,7 @@ pub fn display_compact_table(files: &[FileAnalysis], sort_by: SortBy, limit: usi
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::analyzer::metrics::MetricValue;
     use std::path::PathBuf;
 
     fn create_test_file_analysis() -> Vec<FileAnalysis> {
@@ -590,10 +1036,19 @@ mod tests {
                 comment_lines: 30,
                 functions: 8,
                 methods: 4,
+                anonymous_functions: 0,
                 classes: 2,
                 cyclomatic_complexity: 12,
                 max_nesting_depth: 0,
                 complexity_score: 3.5,
+                metrics: Default::default(),
+                scopes: Vec::new(),
+                parse_error_ratio: 0.0,
+                confidence: Confidence::High,
+                root: None,
+                imports: Vec::new(),
+                api: Vec::new(),
+                outline: Vec::new(),
             },
             FileAnalysis {
                 path: PathBuf::from("lib/utils.js"),
@@ -603,10 +1058,19 @@ mod tests {
                 comment_lines: 15,
                 functions: 5,
                 methods: 3,
+                anonymous_functions: 0,
                 classes: 1,
                 cyclomatic_complexity: 6,
                 max_nesting_depth: 0,
                 complexity_score: 2.1,
+                metrics: Default::default(),
+                scopes: Vec::new(),
+                parse_error_ratio: 0.0,
+                confidence: Confidence::High,
+                root: None,
+                imports: Vec::new(),
+                api: Vec::new(),
+                outline: Vec::new(),
             },
             FileAnalysis {
                 path: PathBuf::from("tests/test_module.py"),
@@ -616,10 +1080,19 @@ mod tests {
                 comment_lines: 40,
                 functions: 12,
                 methods: 8,
+                anonymous_functions: 0,
                 classes: 3,
                 cyclomatic_complexity: 18,
                 max_nesting_depth: 0,
                 complexity_score: 4.8,
+                metrics: Default::default(),
+                scopes: Vec::new(),
+                parse_error_ratio: 0.0,
+                confidence: Confidence::High,
+                root: None,
+                imports: Vec::new(),
+                api: Vec::new(),
+                outline: Vec::new(),
             },
         ]
     }
@@ -642,6 +1115,27 @@ mod tests {
         assert!(files[0].path.file_name().unwrap() <= files[1].path.file_name().unwrap());
     }
 
+    #[test]
+    fn test_sort_files_by_metric() {
+        let mut files = create_test_file_analysis();
+        files[1]
+            .metrics
+            .insert("fan_out".to_string(), MetricValue::Count(9));
+        files[2]
+            .metrics
+            .insert("fan_out".to_string(), MetricValue::Count(3));
+
+        sort_files(&mut files, SortBy::Lines, Some("fan_out"));
+        assert_eq!(files[0].path, PathBuf::from("lib/utils.js"));
+        assert_eq!(files[1].path, PathBuf::from("tests/test_module.py"));
+        // File without the metric sorts last
+        assert_eq!(files[2].path, PathBuf::from("src/main.rs"));
+
+        // Built-in metrics are addressable by name too
+        sort_files(&mut files, SortBy::Lines, Some("cyclomatic_complexity"));
+        assert_eq!(files[0].cyclomatic_complexity, 18);
+    }
+
     #[test]
     fn test_terminal_reporter_creation() {
         let reporter = TerminalReporter::new();
@@ -782,7 +1276,7 @@ mod tests {
 
     #[test]
     fn test_display_project_summary_with_methods() {
-        use std::collections::HashMap;
+        use std::collections::BTreeMap;
 
         let reporter = TerminalReporter::new();
 
@@ -792,10 +1286,12 @@ mod tests {
             total_lines: 300,
             total_functions: 10,
             total_methods: 5,
+            total_anonymous_functions: 0,
             total_classes: 3,
-            language_breakdown: HashMap::new(),
+            language_breakdown: BTreeMap::new(),
             largest_files: vec![],
             most_complex_files: vec![],
+            packages: vec![],
         };
 
         let result = reporter.display_project_summary(&summary);
@@ -831,7 +1327,13 @@ mod tests {
             files_skipped_size: 2,
             files_skipped_language: 3,
             files_skipped_hidden: 1,
+            files_skipped_ignored: 0,
+            files_skipped_generated: 0,
+            files_skipped_pattern: 0,
+            symlinks_skipped: 0,
+            nested_repos_skipped: 0,
             errors_encountered: 0,
+            skipped_files: Vec::new(),
         };
         reporter.display_walk_stats(&stats_with_skipped);
 
@@ -843,7 +1345,13 @@ mod tests {
             files_skipped_size: 0,
             files_skipped_language: 0,
             files_skipped_hidden: 0,
+            files_skipped_ignored: 0,
+            files_skipped_generated: 0,
+            files_skipped_pattern: 0,
+            symlinks_skipped: 0,
+            nested_repos_skipped: 0,
             errors_encountered: 0,
+            skipped_files: Vec::new(),
         };
         reporter.display_walk_stats(&stats_no_skipped);
     }
@@ -48,7 +48,7 @@ fn test_default_language_detection_finds_all_languages() {
     let test_dir = create_mixed_language_project();
     
     let cli_args = CliArgs {
-        path: Some(test_dir.path().to_path_buf()),
+        paths: vec![test_dir.path().to_path_buf()],
         languages: vec![], // Empty = default behavior
         verbose: false,
         exclude: vec![], // No exclusions
@@ -78,7 +78,7 @@ fn test_explicit_language_filtering_works() {
     let test_dir = create_mixed_language_project();
     
     let cli_args = CliArgs {
-        path: Some(test_dir.path().to_path_buf()),
+        paths: vec![test_dir.path().to_path_buf()],
         languages: vec!["rust".to_string(), "go".to_string()], // Only Rust and Go
         verbose: false,
         exclude: vec![], // No exclusions
@@ -110,7 +110,7 @@ fn test_single_language_filtering() {
     let test_dir = create_mixed_language_project();
     
     let cli_args = CliArgs {
-        path: Some(test_dir.path().to_path_buf()),
+        paths: vec![test_dir.path().to_path_buf()],
         languages: vec!["python".to_string()], // Only Python
         verbose: false,
         exclude: vec![], // No exclusions
@@ -136,7 +136,7 @@ fn test_invalid_language_filtering() {
     let test_dir = create_mixed_language_project();
     
     let cli_args = CliArgs {
-        path: Some(test_dir.path().to_path_buf()),
+        paths: vec![test_dir.path().to_path_buf()],
         languages: vec!["rust".to_string(), "invalid_language".to_string()],
         verbose: false,
         exclude: vec![], // No exclusions
@@ -153,7 +153,7 @@ fn test_empty_directory_behavior() {
     // Empty directory - no files
     
     let cli_args = CliArgs {
-        path: Some(test_dir.path().to_path_buf()),
+        paths: vec![test_dir.path().to_path_buf()],
         languages: vec![], // Default behavior
         verbose: false,
         exclude: vec![], // No exclusions
@@ -175,7 +175,7 @@ fn test_no_supported_files() {
     fs::write(root.join("data.json"), "{\"test\": true}").unwrap();
     
     let cli_args = CliArgs {
-        path: Some(test_dir.path().to_path_buf()),
+        paths: vec![test_dir.path().to_path_buf()],
         languages: vec![], // Default behavior
         verbose: false,
         exclude: vec![], // No exclusions
@@ -2,8 +2,8 @@ use std::fs;
 use tempfile::TempDir;
 
 use code_analyzer::{
-    analyze_directory, analyze_directory_filtered, run_analysis_with_config, AnalysisConfig,
-    CliArgs, ColorMode, OutputFormat, SortBy, SupportedLanguage,
+    analyze_directory, analyze_directory_filtered, run_analysis_with_config, run_api_diff,
+    AnalysisConfig, ApiDiffArgs, CliArgs, ColorMode, OutputFormat, SortBy, SupportedLanguage,
 };
 
 /// Create a test project with various source files
@@ -355,7 +355,7 @@ fn test_cli_integration() {
     let test_dir = create_test_project();
 
     let cli_args = CliArgs {
-        path: Some(test_dir.path().to_path_buf()),
+        paths: vec![test_dir.path().to_path_buf()],
         languages: vec!["rust".to_string(), "javascript".to_string()],
         exclude: vec![],
         output: OutputFormat::Table,
@@ -385,7 +385,7 @@ fn test_json_output() {
     let output_file = test_dir.path().join("output.json");
 
     let cli_args = CliArgs {
-        path: Some(test_dir.path().to_path_buf()),
+        paths: vec![test_dir.path().to_path_buf()],
         languages: vec![],
         output: OutputFormat::Json,
         sort: SortBy::Lines,
@@ -430,7 +430,7 @@ fn test_both_output_formats() {
     let output_file = test_dir.path().join("both_output.json");
 
     let cli_args = CliArgs {
-        path: Some(test_dir.path().to_path_buf()),
+        paths: vec![test_dir.path().to_path_buf()],
         languages: vec!["python".to_string()],
         output: OutputFormat::Both,
         sort: SortBy::Functions,
@@ -460,6 +460,48 @@ fn test_both_output_formats() {
     );
 }
 
+#[test]
+fn test_outline_output() {
+    let test_dir = create_test_project();
+    let output_file = test_dir.path().join("outline.json");
+
+    let cli_args = CliArgs {
+        paths: vec![test_dir.path().to_path_buf()],
+        languages: vec!["rust".to_string()],
+        output: OutputFormat::Outline,
+        output_file: Some(output_file.clone()),
+        color: ColorMode::Never,
+        ..Default::default()
+    };
+
+    let result = code_analyzer::run_analysis(cli_args);
+    assert!(result.is_ok(), "Outline analysis should succeed");
+
+    let outline: serde_json::Value =
+        serde_json::from_str(&fs::read_to_string(&output_file).unwrap()).unwrap();
+    let main = outline
+        .as_array()
+        .unwrap()
+        .iter()
+        .find(|file| file["path"].as_str().unwrap().ends_with("main.rs"))
+        .expect("main.rs should be outlined");
+
+    let symbols = main["symbols"].as_array().unwrap();
+    let implementation = symbols
+        .iter()
+        .find(|symbol| symbol["kind"] == "impl")
+        .expect("impl Calculator should be outlined");
+    assert_eq!(implementation["name"], "Calculator");
+
+    let methods: Vec<_> = implementation["children"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .map(|method| method["name"].as_str().unwrap())
+        .collect();
+    assert_eq!(methods, vec!["new", "add", "multiply", "get_result"]);
+}
+
 #[test]
 fn test_language_support() {
     let test_dir = create_test_project();
@@ -679,10 +721,11 @@ fn test_cli_binary() {
 
     assert!(output.status.success(), "CLI should execute successfully");
 
-    let stdout = String::from_utf8_lossy(&output.stdout);
+    // Progress messages are logged to stderr, keeping stdout for the report
+    let stderr = String::from_utf8_lossy(&output.stderr);
     assert!(
-        stdout.contains("Analysis completed"),
-        "Should show completion message"
+        stderr.contains("Analysis completed"),
+        "Should log completion message"
     );
 }
 
@@ -891,3 +934,359 @@ fn test_explicit_language_filtering_works() {
         "Should have exactly 2 different languages"
     );
 }
+
+fn git(dir: &std::path::Path, args: &[&str]) {
+    let output = std::process::Command::new("git")
+        .args([
+            "-c",
+            "user.email=test@test.com",
+            "-c",
+            "user.name=Test User",
+        ])
+        .args(args)
+        .current_dir(dir)
+        .output()
+        .expect("Failed to run git");
+    assert!(output.status.success(), "git {:?} failed", args);
+}
+
+#[test]
+fn test_api_diff_between_revisions() {
+    let dir = TempDir::new().unwrap();
+    let root = dir.path();
+    git(root, &["init", "-q"]);
+
+    fs::write(
+        root.join("lib.rs"),
+        "pub fn open(path: &str) {}\npub fn close() {}\nfn helper() {}\n",
+    )
+    .unwrap();
+    fs::write(root.join("api.py"), "def load(name):\n    pass\n").unwrap();
+    git(root, &["add", "."]);
+    git(root, &["commit", "-q", "-m", "v1"]);
+
+    fs::write(
+        root.join("lib.rs"),
+        "pub fn open(path: &str, mode: u32) {}\nfn helper(x: u8) {}\npub fn flush() {}\n",
+    )
+    .unwrap();
+    git(root, &["commit", "-q", "-am", "v2"]);
+
+    let json = root.join("api-diff.json");
+    let args = CliArgs {
+        paths: vec![root.to_path_buf()],
+        json_only: true,
+        ..Default::default()
+    };
+    let diff_args = ApiDiffArgs {
+        rev_a: "HEAD~1".to_string(),
+        rev_b: "HEAD".to_string(),
+        json: Some(json.clone()),
+    };
+    let diff = run_api_diff(&args, &diff_args).expect("API diff should succeed");
+
+    let names = |entries: &[code_analyzer::analyzer::ApiDiffEntry]| {
+        entries
+            .iter()
+            .map(|entry| entry.item.name.clone())
+            .collect::<Vec<_>>()
+    };
+    assert_eq!(names(&diff.removed), vec!["close"]);
+    assert_eq!(names(&diff.added), vec!["flush"]);
+    assert_eq!(diff.changed.len(), 1);
+    assert_eq!(
+        diff.changed[0].after.signature,
+        "pub fn open(path: &str, mode: u32)"
+    );
+    assert!(diff.is_breaking());
+    assert!(json.exists(), "API diff JSON should be written");
+}
+
+#[test]
+fn test_reproducible_paths_in_git_mode() {
+    // A relative target, while git mode resolves changed files to absolute paths
+    let dir = tempfile::Builder::new()
+        .prefix("git-mode-")
+        .tempdir_in(".")
+        .unwrap();
+    let root = dir.path();
+    git(root, &["init", "-q"]);
+    fs::write(root.join("lib.rs"), "fn main() {}\n").unwrap();
+    git(root, &["add", "."]);
+    git(root, &["commit", "-q", "-m", "initial"]);
+    fs::write(root.join("lib.rs"), "fn main() {\n    run();\n}\n").unwrap();
+
+    let cwd = std::env::current_dir().unwrap();
+    let target = root.strip_prefix(&cwd).unwrap_or(root).to_path_buf();
+    let cli_args = CliArgs {
+        paths: vec![target.clone()],
+        only_changed_since: Some("HEAD".to_string()),
+        reproducible: true,
+        json_only: true,
+        output_file: Some(root.join("report.json")),
+        color: ColorMode::Never,
+        ..Default::default()
+    };
+    let report = code_analyzer::run_analysis_returning_report(cli_args)
+        .expect("Reproducible git-mode analysis should succeed");
+
+    assert!(target.is_relative());
+    assert_eq!(report.files.len(), 1);
+    assert_eq!(report.files[0].path, std::path::PathBuf::from("lib.rs"));
+    assert_eq!(report.config.target_path, std::path::PathBuf::from("."));
+}
+
+#[test]
+fn test_layer_rules_report_violations() {
+    let dir = TempDir::new().unwrap();
+    let root = dir.path();
+    fs::create_dir_all(root.join("src/domain")).unwrap();
+    fs::create_dir_all(root.join("src/infra")).unwrap();
+
+    fs::write(
+        root.join("src/domain/order.rs"),
+        "use std::fmt;\nuse crate::infra::db::Pool;\n\npub fn save(pool: &Pool) {}\n",
+    )
+    .unwrap();
+    fs::write(
+        root.join("src/infra/db.rs"),
+        "use crate::domain::order::save;\n\npub struct Pool;\n",
+    )
+    .unwrap();
+
+    let rules = root.join("layers.json");
+    fs::write(
+        &rules,
+        r#"{"layers": [{"name": "domain", "paths": ["src/domain/**"]},
+                       {"name": "infra", "paths": ["src/infra/**"]}],
+            "rules": [{"layer": "domain", "must_not_depend_on": ["infra"]}]}"#,
+    )
+    .unwrap();
+
+    let cli_args = CliArgs {
+        paths: vec![root.to_path_buf()],
+        layer_rules: Some(rules),
+        json_only: true,
+        output_file: Some(root.join("report.json")),
+        color: ColorMode::Never,
+        ..Default::default()
+    };
+    let report = code_analyzer::run_analysis_returning_report(cli_args)
+        .expect("Analysis with layer rules should succeed");
+
+    assert_eq!(report.layer_violations.len(), 1);
+    let violation = &report.layer_violations[0];
+    assert!(violation.path.ends_with("src/domain/order.rs"));
+    assert_eq!(violation.line, 2);
+    assert_eq!(violation.import, "use crate::infra::db::Pool;");
+    assert_eq!(violation.rule, "domain must not depend on infra");
+}
+
+#[test]
+fn test_package_metrics_export() {
+    let dir = TempDir::new().unwrap();
+    let root = dir.path();
+    fs::create_dir_all(root.join("src/domain")).unwrap();
+    fs::create_dir_all(root.join("src/infra")).unwrap();
+
+    fs::write(
+        root.join("src/domain/repository.rs"),
+        "pub trait Repository {\n    fn save(&self);\n}\n",
+    )
+    .unwrap();
+    fs::write(
+        root.join("src/infra/db.rs"),
+        "use crate::domain::repository::Repository;\n\npub struct Db;\n\nimpl Repository for Db {\n    fn save(&self) {}\n}\n",
+    )
+    .unwrap();
+
+    let output = root.join("packages.json");
+    let cli_args = CliArgs {
+        paths: vec![root.to_path_buf()],
+        package_metrics: Some(output.clone()),
+        json_only: true,
+        output_file: Some(root.join("report.json")),
+        color: ColorMode::Never,
+        ..Default::default()
+    };
+    let report = code_analyzer::run_analysis_returning_report(cli_args)
+        .expect("Analysis with package metrics should succeed");
+
+    let packages: Vec<serde_json::Value> =
+        serde_json::from_str(&fs::read_to_string(&output).unwrap()).unwrap();
+    assert_eq!(packages.len(), 2);
+    assert_eq!(packages[0]["package"], "src/domain");
+    assert_eq!(packages[0]["afferent_coupling"], 1);
+    assert_eq!(packages[0]["instability"], 0.0);
+    assert_eq!(packages[0]["abstractness"], 1.0);
+    assert_eq!(packages[0]["distance"], 0.0);
+    assert_eq!(packages[1]["package"], "src/infra");
+    assert_eq!(packages[1]["efferent_coupling"], 1);
+    assert_eq!(packages[1]["instability"], 1.0);
+    assert_eq!(packages[1]["abstractness"], 0.0);
+    assert_eq!(report.summary.packages.len(), 2);
+}
+
+#[test]
+fn test_suggest_extractions() {
+    let dir = TempDir::new().unwrap();
+    fs::write(
+        dir.path().join("report.rs"),
+        r#"fn report(items: &[u32], limit: u32) -> u32 {
+    let mut total = 0;
+    for item in items {
+        if *item > limit {
+            total += item;
+        } else if *item == 0 {
+            total += 1;
+        }
+    }
+    let label = format!("{}", total);
+    println!("{}", label);
+    println!("{}", label);
+    total
+}
+"#,
+    )
+    .unwrap();
+
+    let cli_args = CliArgs {
+        paths: vec![dir.path().to_path_buf()],
+        suggest_extractions: true,
+        max_function_lines: Some(10),
+        json_only: true,
+        output_file: Some(dir.path().join("report.json")),
+        color: ColorMode::Never,
+        ..Default::default()
+    };
+    let report = code_analyzer::run_analysis_returning_report(cli_args)
+        .expect("Analysis with extract suggestions should succeed");
+
+    let scope = &report.files[0].scopes[0];
+    assert_eq!(
+        scope.extractions[0].to_string(),
+        "extract lines 2–9 (inputs: items, limit; outputs: total), estimated CC reduction 3"
+    );
+}
+
+#[test]
+fn test_suggest_splits() {
+    let dir = TempDir::new().unwrap();
+    fs::write(
+        dir.path().join("big.rs"),
+        r#"struct Parser {
+    input: String,
+}
+
+impl Parser {
+    fn parse(&self) -> usize {
+        self.input.len()
+    }
+}
+
+fn parse_all(items: &[String]) -> usize {
+    items.iter().map(|i| Parser { input: i.clone() }.parse()).sum()
+}
+
+fn render_page(rows: &[String]) -> String {
+    rows.iter().map(|r| render_row(r)).collect()
+}
+
+fn render_row(row: &str) -> String {
+    format!("<tr>{}</tr>", escape_html(row))
+}
+
+fn escape_html(text: &str) -> String {
+    text.replace('<', "&lt;")
+}
+"#,
+    )
+    .unwrap();
+
+    let cli_args = CliArgs {
+        paths: vec![dir.path().to_path_buf()],
+        suggest_splits: true,
+        max_loc: Some(15),
+        json_only: true,
+        output_file: Some(dir.path().join("report.json")),
+        color: ColorMode::Never,
+        ..Default::default()
+    };
+    let report = code_analyzer::run_analysis_returning_report(cli_args)
+        .expect("Analysis with split suggestions should succeed");
+
+    assert_eq!(report.split_suggestions.len(), 1);
+    let suggestion = &report.split_suggestions[0];
+    assert_eq!(suggestion.cut_size, 0);
+    let groups: Vec<_> = suggestion
+        .groups
+        .iter()
+        .map(|g| (g.name.as_str(), g.symbols.clone()))
+        .collect();
+    assert_eq!(
+        groups,
+        vec![
+            ("parse", vec!["Parser".to_string(), "parse_all".to_string()]),
+            (
+                "render",
+                vec![
+                    "render_page".to_string(),
+                    "render_row".to_string(),
+                    "escape_html".to_string()
+                ]
+            ),
+        ]
+    );
+}
+
+#[test]
+fn test_max_files_analyzes_exactly_the_cap() {
+    let dir = TempDir::new().unwrap();
+    for i in 0..200 {
+        fs::write(
+            dir.path().join(format!("file_{i:03}.rs")),
+            format!("fn f{i}() {{}}\n"),
+        )
+        .unwrap();
+    }
+
+    let cli_args = CliArgs {
+        paths: vec![dir.path().to_path_buf()],
+        max_files: Some(50),
+        json_only: true,
+        output_file: Some(dir.path().join("report.json")),
+        color: ColorMode::Never,
+        ..Default::default()
+    };
+    let report = code_analyzer::run_analysis_returning_report(cli_args)
+        .expect("A capped run should produce a partial report");
+
+    assert_eq!(report.files.len(), 50);
+    assert_eq!(
+        report.limit_reached.map(|reached| reached.limit),
+        Some(code_analyzer::analyzer::RunLimit::MaxFiles)
+    );
+}
+
+#[test]
+fn test_limit_before_any_file_reports_empty() {
+    let dir = TempDir::new().unwrap();
+    fs::write(dir.path().join("main.rs"), "fn main() {}\n").unwrap();
+
+    let cli_args = CliArgs {
+        paths: vec![dir.path().to_path_buf()],
+        time_budget_secs: Some(0),
+        json_only: true,
+        output_file: Some(dir.path().join("report.json")),
+        color: ColorMode::Never,
+        ..Default::default()
+    };
+    let report = code_analyzer::run_analysis_returning_report(cli_args)
+        .expect("A run stopped before parsing should still produce a report");
+
+    assert!(report.files.is_empty());
+    assert_eq!(
+        report.limit_reached.map(|reached| reached.limit),
+        Some(code_analyzer::analyzer::RunLimit::TimeBudget)
+    );
+}